@@ -3,6 +3,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strconv"
@@ -30,9 +31,9 @@ const (
 
 func (m *AppModel) Init() tea.Cmd {
 	if m.showTop {
-		return m.startTopTicker()
+		return tea.Batch(m.startTopTicker(), m.checkStaleLinks())
 	}
-	return nil
+	return m.checkStaleLinks()
 }
 
 func (m *AppModel) FilterValue() string {
@@ -44,6 +45,24 @@ var topRunning = false
 
 func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
+	// Stale links found while something else was on screen are prompted for once it's been dealt with
+	defer m.promptRelink()
+
+	if m.spitting {
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			if msg.Type == tea.KeyCtrlC {
+				m.spit.cancel()
+			}
+			return m, nil
+		case spitTickMsg:
+			return m, spitTickCmd()
+		case spitterSuccessMsg:
+			return m.handleSpitterSuccessMsg(msg)
+		case spitterErrorMsg:
+			return m.handleSpitterErrorMsg(msg)
+		}
+	}
 
 	if m.pulling {
 		switch msg := msg.(type) {
@@ -118,6 +137,10 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.handlePushErrorMsg(msg)
 	case genericMsg:
 		return m.handleGenericMsg(msg)
+	case staleLinksMsg:
+		return m.handleStaleLinksMsg(msg)
+	case hostSwitchMsg:
+		return m.handleHostSwitchMsg(msg)
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
@@ -135,6 +158,10 @@ func (m *AppModel) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	// Log the current filter state
 	logging.DebugLogger.Printf("Current filter state: %v\n", m.list.FilterState())
 
+	if m.choosingHost {
+		return m.handleChoosingHostKey(msg)
+	}
+
 	// Handle the space key separately to ensure it works even when filtering
 	if key.Matches(msg, m.keys.Space) {
 		return m.handleSpaceKey()
@@ -218,6 +245,12 @@ func (m *AppModel) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		case "s":
 			// Save the modelfile
 			message, err := finishExternalEdit(m.client, m.externalEditorModel, m.externalEditorFile)
+			var lintErr *modelfileLintError
+			if errors.As(err, &lintErr) {
+				// Stay in the editor view so the errors can be fixed and saved again
+				m.externalEditorLint = lintErr.diagnostics
+				return m, nil
+			}
 			if err != nil {
 				m.message = fmt.Sprintf("Error saving model: %v", err)
 			} else {
@@ -245,6 +278,7 @@ func (m *AppModel) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				}
 			}
 			m.models = removeModels(m.models, m.selectedModels)
+			m.updateDiskUsage()
 			m.refreshList()
 			m.confirmDeletion = false
 			m.selectedModels = nil
@@ -257,6 +291,29 @@ func (m *AppModel) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 
+	if m.confirmRelink {
+		switch {
+		case key.Matches(msg, m.keys.ConfirmYes):
+			logging.DebugLogger.Println("ConfirmYes key matched for relinking")
+			m.message = fmt.Sprintf("Relinking %d models...", len(m.staleLinks))
+			m.confirmRelink = false
+			cmd := m.relinkStaleLinks(m.staleLinks)
+			m.staleLinks = nil
+			return m, cmd
+		case key.Matches(msg, m.keys.ConfirmNo):
+			logging.InfoLogger.Println("Relinking cancelled by user")
+			m.message = "Stale links left in place, run gollama links check --fix to relink them later"
+			m.confirmRelink = false
+			m.staleLinks = nil
+		}
+		return m, nil
+	}
+
+	if m.allHosts && !m.allHostsKeyAllowed(msg) {
+		m.message = fmt.Sprintf("Switch to a single host with %s to do that, this view only lists the models of all hosts", m.keys.SwitchHost.Help().Key)
+		return m, nil
+	}
+
 	var cmd tea.Cmd // Define the cmd variable
 	switch {
 	case key.Matches(msg, m.keys.Delete):
@@ -291,6 +348,10 @@ func (m *AppModel) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m.handleCopyModelKey()
 	case key.Matches(msg, m.keys.PushModel):
 		return m.handlePushModelKey()
+	case key.Matches(msg, m.keys.SpitModel):
+		return m.handleSpitModelKey()
+	case key.Matches(msg, m.keys.SwitchHost):
+		return m.handleSwitchHostKey()
 	case key.Matches(msg, m.keys.PullModel):
 		return m.handlePullModelKey()
 	case key.Matches(msg, m.keys.PullKeepConfig):
@@ -315,6 +376,9 @@ func (m *AppModel) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 func (m *AppModel) handleSpaceKey() (tea.Model, tea.Cmd) {
 	logging.DebugLogger.Println("Space key matched")
+	if m.allHosts {
+		return m, nil
+	}
 	if item, ok := m.list.SelectedItem().(Model); ok {
 		logging.DebugLogger.Printf("Toggling selection for model: %s (before: %v)\n", item.Name, item.Selected)
 		item.Selected = !item.Selected
@@ -431,6 +495,27 @@ func (m *AppModel) handlePushErrorMsg(msg pushErrorMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+func (m *AppModel) handleSpitterSuccessMsg(msg spitterSuccessMsg) (tea.Model, tea.Cmd) {
+	m.spitting = false
+	if msg.modelName != "" {
+		m.message = fmt.Sprintf("Copied %s to %s", msg.modelName, msg.remoteHost)
+	} else {
+		m.message = fmt.Sprintf("Copied %d models to %s", msg.modelsCount, msg.remoteHost)
+	}
+	return m, nil
+}
+
+func (m *AppModel) handleSpitterErrorMsg(msg spitterErrorMsg) (tea.Model, tea.Cmd) {
+	m.spitting = false
+	logging.ErrorLogger.Printf("Error copying %s to %s: %v\n", msg.modelName, msg.remoteHost, msg.err)
+	if errors.Is(msg.err, context.Canceled) {
+		m.message = fmt.Sprintf("Cancelled copying %s to %s, run it again to carry on from where it stopped", msg.modelName, msg.remoteHost)
+	} else {
+		m.message = fmt.Sprintf("Error copying %s to %s: %v", msg.modelName, msg.remoteHost, msg.err)
+	}
+	return m, nil
+}
+
 func (m *AppModel) handlePullSuccessMsg(msg pullSuccessMsg) (tea.Model, tea.Cmd) {
 	m.pulling = false
 	m.newModelPull = false
@@ -438,6 +523,8 @@ func (m *AppModel) handlePullSuccessMsg(msg pullSuccessMsg) (tea.Model, tea.Cmd)
 	m.message = fmt.Sprintf("Successfully pulled model: %s", msg.modelName)
 	return m, tea.Batch(
 		m.refreshModelsAfterPull(),
+		// The pull may have replaced blobs that are linked into LM Studio or another link target
+		m.checkStaleLinks(),
 		func() tea.Msg {
 			// This will force a refresh of the main view
 			return tea.WindowSizeMsg{Width: m.width, Height: m.height}
@@ -471,6 +558,63 @@ func (m *AppModel) handleGenericMsg(msg genericMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// checkStaleLinks looks for links from Ollama that no longer match their model's files in the background
+func (m *AppModel) checkStaleLinks() tea.Cmd {
+	if m.isRemoteHost() != "" {
+		return nil
+	}
+	return func() tea.Msg {
+		stale, err := findStaleLinks(m.client)
+		if err != nil {
+			logging.ErrorLogger.Printf("Error checking for stale links: %v\n", err)
+			return nil
+		}
+		return staleLinksMsg{stale: stale}
+	}
+}
+
+func (m *AppModel) handleStaleLinksMsg(msg staleLinksMsg) (tea.Model, tea.Cmd) {
+	if len(msg.stale) == 0 {
+		return m, nil
+	}
+	logging.InfoLogger.Printf("Found %d models with stale links\n", len(msg.stale))
+	m.staleLinks = msg.stale
+	return m, nil
+}
+
+// promptRelink asks whether to relink the stale links when the main view is idle, so the prompt doesn't take over
+// keys meant for a filter, an input or another confirmation
+func (m *AppModel) promptRelink() {
+	if len(m.staleLinks) == 0 || m.confirmRelink {
+		return
+	}
+	busy := m.view != MainView || m.list.FilterState() == list.Filtering || m.confirmDeletion || m.choosingHost ||
+		m.inspecting || m.editing || m.comparingModelfile || m.pulling || m.spitting || m.showProgress
+	if !busy {
+		m.confirmRelink = true
+	}
+}
+
+// relinkStaleLinks relinks the stale models in the background, as copying and hashing their files can take a while,
+// and shows what was done with the parameters that couldn't be exported again in the status line
+func (m *AppModel) relinkStaleLinks(stale []staleLink) tea.Cmd {
+	client := m.client
+	return func() tea.Msg {
+		var messages []string
+		unmapped := relinkStaleModels(stale, client, func(s staleLink, message string, err error) {
+			if err != nil {
+				logging.ErrorLogger.Printf("Error relinking %s: %v\n", s.Model, err)
+				message = fmt.Sprintf("Error relinking %s: %v", s.Model, err)
+			}
+			messages = append(messages, message)
+		})
+		if unmapped != "" {
+			messages = append(messages, strings.TrimRight(unmapped, "\n"))
+		}
+		return genericMsg{message: strings.Join(messages, "\n")}
+	}
+}
+
 func (m *AppModel) handleDeleteKey() (tea.Model, tea.Cmd) {
 	logging.DebugLogger.Println("Delete key matched")
 	logging.InfoLogger.Println("Delete key pressed")
@@ -690,8 +834,12 @@ func (m *AppModel) handleLinkModelKey() (tea.Model, tea.Cmd) {
 		m.message = msg
 		return m, nil
 	}
+	if m.linkTargetErr != nil {
+		m.message = fmt.Sprintf("Error linking model: %v", m.linkTargetErr)
+		return m, nil
+	}
 	if item, ok := m.list.SelectedItem().(Model); ok {
-		message, err := linkModel(item.Name, m.lmStudioModelsDir, m.noCleanup, false, false, m.client)
+		message, err := linkModel(item.Name, m.linkTarget, m.linkStrategy, m.noCleanup, false, nil, m.client)
 		if err != nil {
 			m.message = fmt.Sprintf("Error linking model: %v", err)
 		} else if message != "" {
@@ -710,9 +858,13 @@ func (m *AppModel) handleLinkAllModelsKey() (tea.Model, tea.Cmd) {
 		m.message = msg
 		return m, nil
 	}
+	if m.linkTargetErr != nil {
+		m.message = fmt.Sprintf("Error linking models: %v", m.linkTargetErr)
+		return m, nil
+	}
 	var messages []string
 	for _, model := range m.models {
-		message, err := linkModel(model.Name, m.lmStudioModelsDir, m.noCleanup, false, false, m.client)
+		message, err := linkModel(model.Name, m.linkTarget, m.linkStrategy, m.noCleanup, false, nil, m.client)
 		if err != nil {
 			messages = append(messages, fmt.Sprintf("Error linking model %s: %v", model.Name, err))
 		} else if message != "" {
@@ -753,6 +905,37 @@ func (m *AppModel) handlePushModelKey() (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+func (m *AppModel) handleSpitModelKey() (tea.Model, tea.Cmd) {
+	logging.DebugLogger.Println("SpitModel key matched")
+	// The blobs are read from the local models directory
+	if msg := m.isRemoteHost(); msg != "" {
+		m.message = msg
+		return m, nil
+	}
+
+	var modelNames []string
+	for _, item := range m.list.Items() {
+		if model, ok := item.(Model); ok && model.Selected {
+			modelNames = append(modelNames, model.Name)
+		}
+	}
+	if len(modelNames) == 0 {
+		if item, ok := m.list.SelectedItem().(Model); ok {
+			modelNames = []string{item.Name}
+		}
+	}
+	if len(modelNames) == 0 {
+		return m, nil
+	}
+
+	remoteHost := promptForRemoteHost()
+	if remoteHost == "" {
+		m.message = "Copy to remote host cancelled"
+		return m, nil
+	}
+	return m, m.syncModelToRemote(modelNames, resolveRemote(m.cfg, remoteHost), false)
+}
+
 func (m *AppModel) handlePullModelKey() (tea.Model, tea.Cmd) {
 	logging.DebugLogger.Println("PullModel key matched")
 	if item, ok := m.list.SelectedItem().(Model); ok {
@@ -883,6 +1066,12 @@ func (m *AppModel) View() string {
 		if m.confirmDeletion {
 			return m.confirmDeletionView()
 		}
+		if m.confirmRelink {
+			return m.confirmRelinkView()
+		}
+		if m.choosingHost {
+			return m.hostSwitcherView()
+		}
 		if m.inspecting {
 			return m.inspectModelView(m.inspectedModel)
 		}
@@ -893,6 +1082,16 @@ func (m *AppModel) View() string {
 			return m.modelfileDiffView()
 		}
 
+		if m.spitting {
+			frac, status := m.spit.view()
+			return fmt.Sprintf(
+				"Copying to remote host: %s\n%s\n%s",
+				status,
+				m.progress.ViewAs(frac),
+				"Press Ctrl+C to cancel",
+			)
+		}
+
 		if m.pulling {
 			if m.newModelPull && m.pullProgress == 0 {
 				return fmt.Sprintf(
@@ -934,6 +1133,17 @@ func (m *AppModel) confirmDeletionView() string {
 		m.keys.ConfirmNo.Help().Key)
 }
 
+func (m *AppModel) confirmRelinkView() string {
+	var stale []string
+	for _, s := range m.staleLinks {
+		stale = append(stale, fmt.Sprintf("%s (%s): %s", s.Model, s.target().Name(), s.Reason))
+	}
+	return fmt.Sprintf("\nThe links of these models are stale, relink them? (Y/N)\n\n%s\n\n%s\n%s",
+		strings.Join(stale, "\n"),
+		m.keys.ConfirmYes.Help().Key,
+		m.keys.ConfirmNo.Help().Key)
+}
+
 func (m *AppModel) inspectModelView(model Model) string {
 	logging.DebugLogger.Printf("Inspecting model view: %+v\n", model) // Log the model being inspected
 
@@ -962,6 +1172,11 @@ func (m *AppModel) inspectModelView(model Model) string {
 		{"ID", model.ID},
 		{"Size (GB)", fmt.Sprintf("%.2f", model.Size)},
 	}
+	if m.diskUsageKnown {
+		rows = append(rows,
+			table.Row{"Unique Size (GB)", fmt.Sprintf("%.2f", bytesToGB(model.UniqueSize))},
+			table.Row{"Shared Size (GB)", fmt.Sprintf("%.2f", bytesToGB(model.SharedSize))})
+	}
 
 	// Add enhanced information if available, only showing fields that have values
 	if enhancedInfo.ParameterSize != "" {
@@ -1065,6 +1280,13 @@ func (m *AppModel) refreshList() {
 	m.list.SetItems(items)
 }
 
+// updateDiskUsage recalculates the unique and shared sizes after the models change, e.g. deleting a model
+// can leave blobs it shared unique to another model
+func (m *AppModel) updateDiskUsage() {
+	m.diskUsageTotal, m.diskUsageKnown = applyDiskUsage(m.models, m.ollamaModelsDir)
+	m.list.Title = listTitle(m.activeConnectionLabel(), m.diskUsageTotal, m.diskUsageKnown)
+}
+
 func (m *AppModel) clearScreen() tea.Model {
 	m.inspecting = false
 	m.editing = false
@@ -1127,9 +1349,9 @@ func (m *AppModel) topView() string {
 // FullHelp returns keybindings for the expanded help view. It's part of the key.Map interface.
 func (k KeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
-		{k.Space, k.Delete, k.RunModel, k.LinkModel, k.LinkAllModels, k.CopyModel, k.PushModel},          // first column
-		{k.SortByName, k.SortBySize, k.SortByModified, k.SortByQuant, k.SortByFamily, k.SortByParamSize}, // second column
-		{k.Top, k.EditModel, k.InspectModel, k.Quit},                                                     // third column
+		{k.Space, k.Delete, k.RunModel, k.LinkModel, k.LinkAllModels, k.CopyModel, k.PushModel, k.SpitModel}, // first column
+		{k.SortByName, k.SortBySize, k.SortByModified, k.SortByQuant, k.SortByFamily, k.SortByParamSize},     // second column
+		{k.Top, k.EditModel, k.InspectModel, k.SwitchHost, k.Quit},                                           // third column
 	}
 }
 
@@ -1180,6 +1402,7 @@ func (m *AppModel) refreshModelsAfterPull() tea.Cmd {
 			return pullErrorMsg{err}
 		}
 		m.models = parseAPIResponse(resp)
+		m.updateDiskUsage()
 		m.refreshList()
 		return nil
 	}
@@ -1217,6 +1440,14 @@ func (m *AppModel) externalEditorView() string {
 		content = append(content, instruction)
 	}
 
+	if len(m.externalEditorLint) > 0 {
+		content = append(content,
+			"",
+			styles.ErrorStyle().Render("❌ The Modelfile was not saved, fix these problems and press 's' again:"),
+			formatLintDiagnostics(m.externalEditorLint),
+		)
+	}
+
 	content = append(content, "")
 
 	return strings.Join(content, "\n")
@@ -1227,5 +1458,6 @@ func (m *AppModel) resetExternalEditorState() {
 	m.externalEditing = false
 	m.externalEditorFile = ""
 	m.externalEditorModel = ""
+	m.externalEditorLint = nil
 	m.view = MainView
 }
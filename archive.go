@@ -0,0 +1,222 @@
+// archive.go contains the `gollama export` and `gollama import` commands, which move models between machines as tar archives.
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ollama/ollama/api"
+
+	"github.com/mipalgu/gollama/config"
+	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/ollamastore"
+	"github.com/mipalgu/gollama/spit"
+	"github.com/mipalgu/gollama/styles"
+)
+
+// maxBufferedBlob is the largest blob kept in memory when importing through the API, it only needs to fit the
+// template, system prompt, parameters, license, messages and config layers
+const maxBufferedBlob = 4 * 1024 * 1024
+
+// runExportCommand implements `gollama export <model>... -o model.tar`
+func runExportCommand(cfg *config.Config, args []string) int {
+	fs := newFlagSet("export", "export [flags] <model>...", cfg)
+	ollamaDir := addOllamaDirFlag(fs, cfg)
+	output := fs.String("o", "", "Archive to write, - for stdout (default <model>.tar, or .tar.zst with --zstd)")
+	compress := fs.Bool("zstd", false, "Compress the archive with zstd, the default when -o ends in .zst or .tzst")
+	if err := fs.Parse(args); err != nil {
+		return flagExitCode(err)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	store := ollamastore.New(*ollamaDir)
+	var models []ollamastore.Model
+	for _, name := range fs.Args() {
+		model, err := store.Model(name)
+		if err != nil {
+			return commandError("Error reading the manifest for %s: %v", name, err)
+		}
+		models = append(models, *model)
+	}
+
+	path := *output
+	if strings.HasSuffix(path, ".zst") || strings.HasSuffix(path, ".tzst") {
+		*compress = true
+	}
+	if path == "" {
+		path = archiveFileName(models[0].Name, *compress)
+	}
+
+	var total int64
+	for _, blob := range ollamastore.BlobsToVerify(models) {
+		total += blob.Size
+	}
+
+	var w io.Writer = os.Stdout
+	var file *os.File
+	if path != "-" {
+		var err error
+		file, err = os.Create(path)
+		if err != nil {
+			return commandError("Error creating %s: %v", path, err)
+		}
+		w = file
+	}
+
+	progress, stopProgress := startProgress("Exporting to "+path, total)
+	err := store.WriteArchive(w, models, *compress, progress)
+	stopProgress()
+	if file != nil {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(path)
+		}
+	}
+	if err != nil {
+		return commandError("Error exporting: %v", err)
+	}
+
+	logging.InfoLogger.Printf("Exported %d models to %s\n", len(models), path)
+	if path != "-" {
+		fmt.Println(styles.SuccessStyle().Render(fmt.Sprintf("Exported %d model(s) (%s) to %s", len(models), formatSize(total), path)))
+	}
+	return 0
+}
+
+// archiveFileName is the default archive name for a model, e.g. hf.co/user/repo:Q4_K_M becomes hf.co_user_repo_Q4_K_M.tar
+func archiveFileName(model string, compress bool) string {
+	name := strings.NewReplacer("/", "_", ":", "_").Replace(model) + ".tar"
+	if compress {
+		name += ".zst"
+	}
+	return name
+}
+
+// runImportCommand implements `gollama import model.tar`
+func runImportCommand(cfg *config.Config, args []string) int {
+	fs := newFlagSet("import", "import [flags] <archive>", cfg)
+	ollamaDir := addOllamaDirFlag(fs, cfg)
+	viaAPI := fs.Bool("api", false, "Upload the blobs and create the models through the Ollama API (-h) instead of writing to --ollama-dir")
+	if err := fs.Parse(args); err != nil {
+		return flagExitCode(err)
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	path := fs.Arg(0)
+	var r io.Reader = os.Stdin
+	var total int64
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return commandError("Error opening %s: %v", path, err)
+		}
+		defer file.Close()
+		if info, err := file.Stat(); err == nil {
+			total = info.Size()
+		}
+		r = file
+	}
+
+	var names []string
+	var err error
+	if *viaAPI {
+		names, err = importArchiveViaAPI(cfg, r)
+	} else {
+		// Progress is measured in uncompressed blob bytes, so it's only a guide for compressed archives
+		progress, stopProgress := startProgress("Importing "+path, total)
+		names, err = ollamastore.New(*ollamaDir).ImportArchive(r, progress)
+		stopProgress()
+	}
+	if err != nil {
+		return commandError("Error importing %s: %v", path, err)
+	}
+
+	for _, name := range names {
+		logging.InfoLogger.Printf("Imported model %s\n", name)
+		fmt.Println(styles.SuccessStyle().Render("Imported " + name))
+	}
+	return 0
+}
+
+// importArchiveViaAPI uploads the blobs in an archive that the server doesn't have, then creates each model
+func importArchiveViaAPI(cfg *config.Config, r io.Reader) ([]string, error) {
+	client, httpClient, err := newOllamaClient(cfg.OllamaAPIURL, cfg.Transport())
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+
+	manifests := make(map[string]ollamastore.Manifest)
+	var names []string
+	small := make(map[string][]byte)
+	err = ollamastore.ReadArchive(r, ollamastore.ArchiveHandler{
+		Manifest: func(name string, data []byte) error {
+			manifest, err := ollamastore.ParseManifest(data)
+			if err != nil {
+				return fmt.Errorf("error parsing manifest for %s: %v", name, err)
+			}
+			if _, ok := manifests[name]; !ok {
+				names = append(names, name)
+			}
+			manifests[name] = *manifest
+			return nil
+		},
+		Blob: func(digest string, size int64, blob io.Reader) error {
+			// The text and JSON layers are needed again to build the create request
+			if size <= maxBufferedBlob {
+				data, err := io.ReadAll(blob)
+				if err != nil {
+					return err
+				}
+				small[digest] = data
+				blob = bytes.NewReader(data)
+			}
+
+			exists, err := spit.BlobExists(ctx, httpClient, cfg.OllamaAPIURL, digest)
+			if err != nil {
+				return err
+			}
+			if exists {
+				logging.DebugLogger.Printf("Server already has blob %s\n", digest)
+				return nil
+			}
+			fmt.Printf("Uploading %s (%s)\n", digest, formatSize(size))
+			if err := client.CreateBlob(ctx, digest, blob); err != nil {
+				return fmt.Errorf("error uploading blob %s: %v", digest, err)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	readBlob := func(digest string) ([]byte, error) {
+		if data, ok := small[digest]; ok {
+			return data, nil
+		}
+		return nil, fmt.Errorf("blob isn't in the archive")
+	}
+	for _, name := range names {
+		req, err := ollamastore.CreateRequest(name, manifests[name], readBlob)
+		if err != nil {
+			return nil, err
+		}
+		if err := client.Create(ctx, req, func(api.ProgressResponse) error { return nil }); err != nil {
+			return nil, fmt.Errorf("error creating %s: %v", name, err)
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,169 @@
+// commands.go contains the subcommands (e.g. `gollama lint`) that run instead of the TUI.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ollama/ollama/api"
+	"golang.org/x/term"
+
+	"github.com/mipalgu/gollama/config"
+	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/transport"
+)
+
+// subcommand is run with the arguments after its name and returns the process exit code
+type subcommand struct {
+	run     func(cfg *config.Config, args []string) int
+	summary string
+}
+
+var subcommands = map[string]subcommand{
+	"export":          {run: runExportCommand, summary: "Write models and their blobs to a tar archive, optionally zstd compressed"},
+	"export-llamacpp": {run: runExportLlamaCppCommand, summary: "Write a llama-swap config or llama-server command lines that serve Ollama models from their blobs"},
+	"export-preset":   {run: runExportPresetCommand, summary: "Export models with their system prompt and parameters as Open WebUI or Jan models"},
+	"fleet":           {run: runFleetCommand, summary: "Compare the local models with the remote hosts in the config (diff) and copy the missing ones to them (sync)"},
+	"gc":              {run: runGCCommand, summary: "Remove unreferenced blobs and stale partial downloads"},
+	"import":          {run: runImportCommand, summary: "Restore models from an archive written by export"},
+	"import-preset":   {run: runImportPresetCommand, summary: "Apply an LM Studio preset's settings to a model or a new model derived from it"},
+	"links":           {run: runLinksCommand, summary: "List the links gollama has created between Ollama and LM Studio or another link target, check adds --fix to relink stale ones"},
+	"lint":            {run: runLintCommand, summary: "Check a Modelfile or a model's Modelfile for errors"},
+	"registry":        {run: runRegistryCommand, summary: "Serve the local models read-only so other Ollama hosts can ollama pull them"},
+	"sync":            {run: runSyncCommand, summary: "Link Ollama models into LM Studio and clean up after deleted ones, --watch keeps them in sync"},
+	"verify":          {run: runVerifyCommand, summary: "Re-hash model blobs to find corrupted, truncated or missing files"},
+}
+
+// runSubcommand runs the subcommand named by the first argument, reporting false if there isn't one
+func runSubcommand(cfg *config.Config, args []string) (int, bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+	command, ok := subcommands[args[0]]
+	if !ok {
+		return 0, false
+	}
+	logging.DebugLogger.Printf("Running subcommand %s with args %v\n", args[0], args[1:])
+	return command.run(cfg, args[1:]), true
+}
+
+// subcommandUsage lists the available subcommands for the help output
+func subcommandUsage() string {
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("Commands:\n")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "  %-16s %s\n", name, subcommands[name].summary)
+	}
+	return sb.String()
+}
+
+// newFlagSet creates a flag set for a subcommand with the host override flags shared with the main command
+func newFlagSet(name, usage string, cfg *config.Config) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Func("h", "Override the config file to set the Ollama API host (e.g. http://localhost:11434)", func(host string) error {
+		cfg.OllamaAPIURL = host
+		return nil
+	})
+	fs.BoolFunc("H", "Shortcut to connect to http://localhost:11434", func(string) error {
+		cfg.OllamaAPIURL = "http://localhost:11434"
+		return nil
+	})
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gollama %s\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// addOllamaDirFlag adds the --ollama-dir flag for commands that read the models directory directly
+func addOllamaDirFlag(fs *flag.FlagSet, cfg *config.Config) *string {
+	return fs.String("ollama-dir", cfg.OllamaModelsDir, "Custom Ollama models directory")
+}
+
+// newAPIClient creates an Ollama API client for the configured API URL
+func newAPIClient(cfg *config.Config) (*api.Client, error) {
+	client, _, err := newOllamaClient(cfg.OllamaAPIURL, cfg.Transport())
+	return client, err
+}
+
+// newOllamaClient creates an Ollama API client for an API URL that connects with settings, and the HTTP client
+// it uses for the requests the API client doesn't make, such as checking for blobs
+func newOllamaClient(rawURL string, settings config.Transport) (*api.Client, *http.Client, error) {
+	apiURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing API URL: %v", err)
+	}
+	httpClient, err := transport.NewHTTPClient(settings)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error setting up the connection to %s: %v", rawURL, err)
+	}
+	return api.NewClient(apiURL, httpClient), httpClient, nil
+}
+
+// startProgress shows a byte progress line on stderr until stop is called. Progress is only shown when stderr
+// is a terminal, otherwise the returned progress func is nil so the output stays clean when run from cron.
+// The progress func is safe for concurrent use.
+func startProgress(label string, total int64) (progress func(n int64), stop func()) {
+	if !term.IsTerminal(int(os.Stderr.Fd())) {
+		return nil, func() {}
+	}
+
+	var done atomic.Int64
+	quit := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-quit:
+				fmt.Fprint(os.Stderr, "\r\033[K")
+				return
+			case <-ticker.C:
+				fmt.Fprintf(os.Stderr, "\r\033[K%s: %s / %s (%.0f%%)", label, formatSize(done.Load()), formatSize(total), percentage(done.Load(), total))
+			}
+		}
+	}()
+	return func(n int64) { done.Add(n) }, func() {
+		close(quit)
+		<-finished
+	}
+}
+
+func percentage(part, total int64) float64 {
+	if total == 0 {
+		return 100
+	}
+	return float64(part) / float64(total) * 100
+}
+
+// commandError prints and logs an error for a subcommand and returns the failure exit code
+func commandError(format string, args ...any) int {
+	message := fmt.Sprintf(format, args...)
+	logging.ErrorLogger.Println(message)
+	fmt.Fprintln(os.Stderr, message)
+	return 1
+}
+
+// flagExitCode is the exit code for a flag parsing error, asking for help isn't a failure
+func flagExitCode(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	return 2
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/ollama/ollama/api"
 	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/modelfile"
 	"github.com/mipalgu/gollama/styles"
 )
 
@@ -151,22 +152,22 @@ func fetchLatestModelfile(modelName string) (string, error) {
 	}
 
 	// Construct the modelfile content
-	var modelfile strings.Builder
-	modelfile.WriteString(fmt.Sprintf("TEMPLATE \"\"\"%s\"\"\"\n", templateBody))
+	latest := &modelfile.Modelfile{}
+	latest.SetTemplate(string(templateBody))
 	for key, value := range params {
 		switch v := value.(type) {
-		case string:
-			modelfile.WriteString(fmt.Sprintf("PARAMETER %s %s\n", key, v))
 		case []interface{}:
-			for _, item := range v {
-				modelfile.WriteString(fmt.Sprintf("PARAMETER %s %s\n", key, item))
+			values := make([]string, len(v))
+			for i, item := range v {
+				values[i] = fmt.Sprint(item)
 			}
+			latest.SetParameter(key, values...)
 		default:
-			logging.DebugLogger.Printf("Unknown type for parameter %s: %v", key, value)
+			latest.SetParameter(key, fmt.Sprint(v))
 		}
 	}
 
-	return modelfile.String(), nil
+	return latest.String(), nil
 }
 
 func compareModelfiles(currentTemplate string, currentParams map[string]string, latestTemplate string, latestParams map[string]string) []ModelfileDiff {
@@ -242,23 +243,12 @@ func (m *AppModel) handleCompareModelfile() (tea.Model, tea.Cmd) {
 		}
 
 		// Parse the latest modelfile to extract TEMPLATE and PARAMETERs
-		latestLines := strings.Split(latestModelfile, "\n")
-		var latestTemplate string
-		latestParams := make(map[string]string)
-
-		for _, line := range latestLines {
-			if strings.HasPrefix(line, "TEMPLATE") {
-				latestTemplate = strings.TrimPrefix(line, "TEMPLATE ")
-				latestTemplate = strings.Trim(latestTemplate, "\"")
-			} else if strings.HasPrefix(line, "PARAMETER") {
-				parts := strings.SplitN(line, " ", 2)
-				if len(parts) == 2 {
-					key := parts[1]
-					value := strings.TrimSpace(parts[1])
-					latestParams[key] = value
-				}
-			}
+		latest, err := modelfile.Parse(latestModelfile)
+		if err != nil {
+			m.message = fmt.Sprintf("Error parsing latest modelfile: %v", err)
+			return m, nil
 		}
+		latestParams, latestTemplate := modelfileParams(latest)
 
 		// Compare modelfiles
 		m.modelfileDiffs = compareModelfiles(currentTemplate, currentParams, latestTemplate, latestParams)
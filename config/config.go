@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -13,21 +14,61 @@ import (
 )
 
 type Config struct {
-	Columns           []string `mapstructure:"columns"`
-	OllamaAPIKey      string   `mapstructure:"ollama_api_key"`
-	OllamaAPIURL      string   `mapstructure:"ollama_api_url"`
-	OllamaModelsDir   string   `mapstructure:"ollama_models_dir"`
-	LMStudioFilePaths string   `mapstructure:"lm_studio_file_paths"`
-	LogLevel          string   `mapstructure:"log_level"`
-	LogFilePath       string   `mapstructure:"log_file_path"`
-	SortOrder         string   `mapstructure:"sort_order"`   // Current sort order
-	StripString       string   `mapstructure:"strip_string"` // Optional string to strip from model names in the TUI (e.g. a private registry URL)
-	Editor            string   `mapstructure:"editor"`
-	Theme             string   `mapstructure:"theme"`            // Name of the theme to use (without .json extension)
-	DockerContainer   string   `mapstructure:"docker_container"` // Optionally specify a docker container to run the ollama commands in
-	modified          bool     // Internal flag to track if the config has been modified
+	Columns           []string          `mapstructure:"columns"`
+	OllamaAPIKey      string            `mapstructure:"ollama_api_key"`
+	OllamaAPIURL      string            `mapstructure:"ollama_api_url"`
+	OllamaModelsDir   string            `mapstructure:"ollama_models_dir"`
+	LMStudioFilePaths string            `mapstructure:"lm_studio_file_paths"`
+	LogLevel          string            `mapstructure:"log_level"`
+	LogFilePath       string            `mapstructure:"log_file_path"`
+	SortOrder         string            `mapstructure:"sort_order"`   // Current sort order
+	StripString       string            `mapstructure:"strip_string"` // Optional string to strip from model names in the TUI (e.g. a private registry URL)
+	Editor            string            `mapstructure:"editor"`
+	Theme             string            `mapstructure:"theme"`               // Name of the theme to use (without .json extension)
+	DockerContainer   string            `mapstructure:"docker_container"`    // Optionally specify a docker container to run the ollama commands in
+	SyncInclude       []string          `mapstructure:"sync_include"`        // Model name patterns `gollama sync` is limited to, all models if empty
+	SyncExclude       []string          `mapstructure:"sync_exclude"`        // Model name patterns `gollama sync` skips
+	LinkStrategy      string            `mapstructure:"link_strategy"`       // How models are linked into LM Studio: symlink (the default if empty), hardlink, reflink or copy
+	LinkTarget        string            `mapstructure:"link_target"`         // The app models are linked into: lmstudio (the default if empty), llamacpp or jan
+	LlamaCppModelsDir string            `mapstructure:"llamacpp_models_dir"` // The flat models directory llama.cpp's llama-server and koboldcpp load GGUF files from
+	JanModelsDir      string            `mapstructure:"jan_models_dir"`      // Jan's models directory, ~/jan/models if empty
+	Remotes           map[string]string `mapstructure:"remotes"`             // Named remote Ollama hosts for --remote and `gollama fleet`, name to API URL
+	Connections       []Connection      `mapstructure:"connections"`         // Named Ollama hosts the TUI can switch between, with their own settings
+	OllamaHeaders     map[string]string `mapstructure:"ollama_headers"`      // Headers sent with every request to the Ollama API, e.g. for a reverse proxy
+	TLSCACert         string            `mapstructure:"tls_ca_cert"`         // PEM file of the CAs the Ollama API's certificate is checked against, as well as the system's
+	TLSClientCert     string            `mapstructure:"tls_client_cert"`     // PEM client certificate for Ollama APIs behind mutual TLS
+	TLSClientKey      string            `mapstructure:"tls_client_key"`      // PEM key of TLSClientCert
+	HTTPProxy         string            `mapstructure:"http_proxy"`          // Proxy URL for the Ollama API, $HTTPS_PROXY, $HTTP_PROXY and $NO_PROXY are used if empty
+	RequestTimeout    string            `mapstructure:"request_timeout"`     // How long to wait for the Ollama API to respond, e.g. 30s, no limit if empty
+	modified          bool              // Internal flag to track if the config has been modified
 }
 
+// Connection is a named Ollama host with the settings that differ from host to host. The top level settings are
+// the connection named DefaultConnectionName.
+type Connection struct {
+	Name            string `mapstructure:"name" json:"name"`
+	URL             string `mapstructure:"ollama_api_url" json:"ollama_api_url"`
+	DockerContainer string `mapstructure:"docker_container" json:"docker_container,omitempty"`
+	ModelsDir       string `mapstructure:"ollama_models_dir" json:"ollama_models_dir,omitempty"`
+	StripString     string `mapstructure:"strip_string" json:"strip_string,omitempty"`
+	Transport       `mapstructure:",squash"`
+}
+
+// Transport is how an Ollama API is connected to, for one behind a reverse proxy that needs an API key, other
+// headers or client certificates. The zero value connects directly, as Ollama's own clients do.
+type Transport struct {
+	APIKey     string            `mapstructure:"ollama_api_key" json:"ollama_api_key,omitempty"` // Sent as a bearer token
+	Headers    map[string]string `mapstructure:"ollama_headers" json:"ollama_headers,omitempty"`
+	CACert     string            `mapstructure:"tls_ca_cert" json:"tls_ca_cert,omitempty"`
+	ClientCert string            `mapstructure:"tls_client_cert" json:"tls_client_cert,omitempty"`
+	ClientKey  string            `mapstructure:"tls_client_key" json:"tls_client_key,omitempty"`
+	Proxy      string            `mapstructure:"http_proxy" json:"http_proxy,omitempty"`
+	Timeout    string            `mapstructure:"request_timeout" json:"request_timeout,omitempty"`
+}
+
+// DefaultConnectionName is the name of the connection made from the top level settings
+const DefaultConnectionName = "default"
+
 var defaultConfig = Config{
 	Columns:           []string{"Name", "Size", "Quant", "Family", "Modified", "ID"},
 	OllamaAPIKey:      "",
@@ -66,6 +107,11 @@ func GetLMStudioModelDir() string {
 	return filepath.Join(homeDir, ".lmstudio", "models")
 }
 
+// GetJanModelsDir returns the models directory in Jan's default data folder
+func GetJanModelsDir() string {
+	return filepath.Join(utils.GetHomeDir(), "jan", "models")
+}
+
 // getAPIUrl determines the API URL based on environment variables.
 func getAPIUrl() string {
 	if apiUrl := os.Getenv("OLLAMA_API_URL"); apiUrl != "" {
@@ -95,6 +141,20 @@ func CreateDefaultConfig() error {
 	viper.SetDefault("editor", defaultConfig.Editor)
 	viper.SetDefault("theme", defaultConfig.Theme)
 	viper.SetDefault("docker_container", defaultConfig.DockerContainer)
+	viper.SetDefault("sync_include", defaultConfig.SyncInclude)
+	viper.SetDefault("sync_exclude", defaultConfig.SyncExclude)
+	viper.SetDefault("link_strategy", defaultConfig.LinkStrategy)
+	viper.SetDefault("link_target", defaultConfig.LinkTarget)
+	viper.SetDefault("llamacpp_models_dir", defaultConfig.LlamaCppModelsDir)
+	viper.SetDefault("jan_models_dir", defaultConfig.JanModelsDir)
+	viper.SetDefault("remotes", defaultConfig.Remotes)
+	viper.SetDefault("connections", defaultConfig.Connections)
+	viper.SetDefault("ollama_headers", defaultConfig.OllamaHeaders)
+	viper.SetDefault("tls_ca_cert", defaultConfig.TLSCACert)
+	viper.SetDefault("tls_client_cert", defaultConfig.TLSClientCert)
+	viper.SetDefault("tls_client_key", defaultConfig.TLSClientKey)
+	viper.SetDefault("http_proxy", defaultConfig.HTTPProxy)
+	viper.SetDefault("request_timeout", defaultConfig.RequestTimeout)
 
 	return SaveConfig(defaultConfig)
 }
@@ -147,6 +207,20 @@ func LoadConfig() (Config, error) {
 	viper.SetDefault("editor", defaultConfig.Editor)
 	viper.SetDefault("theme", defaultConfig.Theme)
 	viper.SetDefault("docker_container", defaultConfig.DockerContainer)
+	viper.SetDefault("sync_include", defaultConfig.SyncInclude)
+	viper.SetDefault("sync_exclude", defaultConfig.SyncExclude)
+	viper.SetDefault("link_strategy", defaultConfig.LinkStrategy)
+	viper.SetDefault("link_target", defaultConfig.LinkTarget)
+	viper.SetDefault("llamacpp_models_dir", defaultConfig.LlamaCppModelsDir)
+	viper.SetDefault("jan_models_dir", defaultConfig.JanModelsDir)
+	viper.SetDefault("remotes", defaultConfig.Remotes)
+	viper.SetDefault("connections", defaultConfig.Connections)
+	viper.SetDefault("ollama_headers", defaultConfig.OllamaHeaders)
+	viper.SetDefault("tls_ca_cert", defaultConfig.TLSCACert)
+	viper.SetDefault("tls_client_cert", defaultConfig.TLSClientCert)
+	viper.SetDefault("tls_client_key", defaultConfig.TLSClientKey)
+	viper.SetDefault("http_proxy", defaultConfig.HTTPProxy)
+	viper.SetDefault("request_timeout", defaultConfig.RequestTimeout)
 
 	config.Columns = viper.GetStringSlice("columns")
 	config.OllamaAPIKey = viper.GetString("ollama_api_key")
@@ -160,6 +234,22 @@ func LoadConfig() (Config, error) {
 	config.Editor = viper.GetString("editor")
 	config.Theme = viper.GetString("theme")
 	config.DockerContainer = viper.GetString("docker_container")
+	config.SyncInclude = viper.GetStringSlice("sync_include")
+	config.SyncExclude = viper.GetStringSlice("sync_exclude")
+	config.LinkStrategy = viper.GetString("link_strategy")
+	config.LinkTarget = viper.GetString("link_target")
+	config.LlamaCppModelsDir = viper.GetString("llamacpp_models_dir")
+	config.JanModelsDir = viper.GetString("jan_models_dir")
+	config.Remotes = viper.GetStringMapString("remotes")
+	if err := viper.UnmarshalKey("connections", &config.Connections); err != nil {
+		return Config{}, fmt.Errorf("failed to read connections: %w", err)
+	}
+	config.OllamaHeaders = viper.GetStringMapString("ollama_headers")
+	config.TLSCACert = viper.GetString("tls_ca_cert")
+	config.TLSClientCert = viper.GetString("tls_client_cert")
+	config.TLSClientKey = viper.GetString("tls_client_key")
+	config.HTTPProxy = viper.GetString("http_proxy")
+	config.RequestTimeout = viper.GetString("request_timeout")
 
 	viper.OnConfigChange(func(e fsnotify.Event) {
 		fmt.Println("Config file changed:", e.Name)
@@ -183,6 +273,20 @@ func SaveConfig(config Config) error {
 	viper.Set("editor", config.Editor)
 	viper.Set("theme", config.Theme)
 	viper.Set("docker_container", config.DockerContainer)
+	viper.Set("sync_include", config.SyncInclude)
+	viper.Set("sync_exclude", config.SyncExclude)
+	viper.Set("link_strategy", config.LinkStrategy)
+	viper.Set("link_target", config.LinkTarget)
+	viper.Set("llamacpp_models_dir", config.LlamaCppModelsDir)
+	viper.Set("jan_models_dir", config.JanModelsDir)
+	viper.Set("remotes", config.Remotes)
+	viper.Set("connections", config.Connections)
+	viper.Set("ollama_headers", config.OllamaHeaders)
+	viper.Set("tls_ca_cert", config.TLSCACert)
+	viper.Set("tls_client_cert", config.TLSClientCert)
+	viper.Set("tls_client_key", config.TLSClientKey)
+	viper.Set("http_proxy", config.HTTPProxy)
+	viper.Set("request_timeout", config.RequestTimeout)
 
 	configPath := utils.GetConfigPath()
 	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
@@ -203,6 +307,75 @@ func SaveConfig(config Config) error {
 	return nil
 }
 
+// DefaultConnection returns the connection made from the top level settings
+func (c *Config) DefaultConnection() Connection {
+	return Connection{
+		Name:            DefaultConnectionName,
+		URL:             c.OllamaAPIURL,
+		DockerContainer: c.DockerContainer,
+		ModelsDir:       c.OllamaModelsDir,
+		StripString:     c.StripString,
+		Transport:       c.Transport(),
+	}
+}
+
+// Transport returns how the top level Ollama API URL is connected to
+func (c *Config) Transport() Transport {
+	return Transport{
+		APIKey:     c.OllamaAPIKey,
+		Headers:    c.OllamaHeaders,
+		CACert:     c.TLSCACert,
+		ClientCert: c.TLSClientCert,
+		ClientKey:  c.TLSClientKey,
+		Proxy:      c.HTTPProxy,
+		Timeout:    c.RequestTimeout,
+	}
+}
+
+// TransportFor returns how an Ollama API URL is connected to, with the settings of the top level URL or the
+// connection with that URL. Other URLs are connected to directly, so the API keys only go to the hosts they're for.
+func (c *Config) TransportFor(rawURL string) Transport {
+	sameURL := func(u string) bool { return strings.TrimSuffix(u, "/") == strings.TrimSuffix(rawURL, "/") }
+	if sameURL(c.OllamaAPIURL) {
+		return c.Transport()
+	}
+	for _, conn := range c.Connections {
+		if sameURL(conn.URL) {
+			return conn.Transport
+		}
+	}
+	return Transport{}
+}
+
+// FindConnection returns the connection named name, ignoring case. DefaultConnectionName is the top level settings
+// unless a connection has that name.
+func (c *Config) FindConnection(name string) (Connection, bool) {
+	for _, conn := range c.Connections {
+		if strings.EqualFold(conn.Name, name) {
+			return conn, true
+		}
+	}
+	if strings.EqualFold(name, DefaultConnectionName) {
+		return c.DefaultConnection(), true
+	}
+	return Connection{}, false
+}
+
+// UseConnection replaces the top level settings with the connection's
+func (c *Config) UseConnection(conn Connection) {
+	c.OllamaAPIURL = conn.URL
+	c.DockerContainer = conn.DockerContainer
+	c.OllamaModelsDir = conn.ModelsDir
+	c.StripString = conn.StripString
+	c.OllamaAPIKey = conn.APIKey
+	c.OllamaHeaders = conn.Headers
+	c.TLSCACert = conn.CACert
+	c.TLSClientCert = conn.ClientCert
+	c.TLSClientKey = conn.ClientKey
+	c.HTTPProxy = conn.Proxy
+	c.RequestTimeout = conn.Timeout
+}
+
 func (c *Config) SaveIfModified() error {
 	if c.modified {
 		return SaveConfig(*c)
@@ -350,3 +350,29 @@ func saveConfigToPath(path string, config Config) error {
 func generateDefaultConfig(path string) error {
 	return saveConfigToPath(path, defaultConfig)
 }
+
+func TestTransportFor(t *testing.T) {
+	config := Config{
+		OllamaAPIURL: "https://ollama.example.com",
+		OllamaAPIKey: "top",
+		Connections: []Connection{
+			{Name: "gpu1", URL: "https://gpu1.example.com/", Transport: Transport{APIKey: "gpu1", Timeout: "1m"}},
+		},
+	}
+
+	if got := config.TransportFor("https://ollama.example.com/"); got.APIKey != "top" {
+		t.Errorf("Expected the top level settings, got %+v", got)
+	}
+	if got := config.TransportFor("https://gpu1.example.com"); got.APIKey != "gpu1" || got.Timeout != "1m" {
+		t.Errorf("Expected the settings of gpu1, got %+v", got)
+	}
+	// The API keys aren't sent to other hosts
+	if got := config.TransportFor("http://elsewhere:11434"); got.APIKey != "" {
+		t.Errorf("Expected no settings for an unknown host, got %+v", got)
+	}
+
+	config.UseConnection(config.Connections[0])
+	if config.OllamaAPIKey != "gpu1" || config.RequestTimeout != "1m" || config.Transport().APIKey != "gpu1" {
+		t.Errorf("Expected the connection's settings to be used, got %+v", config)
+	}
+}
@@ -0,0 +1,314 @@
+// connections.go contains the TUI's host switcher, which moves between the connections in the config or lists the
+// models of all of them together.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ollama/ollama/api"
+
+	"github.com/mipalgu/gollama/config"
+	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/utils"
+)
+
+// connectionListTimeout is how long a host has to list its models before it's left out
+const connectionListTimeout = 30 * time.Second
+
+// hostSwitchMsg is sent once the models of the connection being switched to, or of all of them, have been listed
+type hostSwitchMsg struct {
+	index  int // the connection switched to, -1 for all of them
+	client *api.Client
+	models []Model
+	failed []string
+	err    error
+}
+
+// connectionList returns the connections the TUI can switch between, the top level settings first. Connections
+// without a models directory use localDir if they're on this machine, and have none otherwise.
+func connectionList(cfg *config.Config, localDir string) []config.Connection {
+	var connections []config.Connection
+	if !hasConnection(cfg.Connections, config.DefaultConnectionName) {
+		conn := cfg.DefaultConnection()
+		conn.ModelsDir = localDir
+		connections = append(connections, conn)
+	}
+	for _, conn := range cfg.Connections {
+		if conn.ModelsDir == "" && isLocalhost(conn.URL) {
+			conn.ModelsDir = localDir
+		}
+		connections = append(connections, conn)
+	}
+	return connections
+}
+
+// hasConnection reports whether one of the connections is named name, ignoring case
+func hasConnection(connections []config.Connection, name string) bool {
+	for _, conn := range connections {
+		if strings.EqualFold(conn.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// findConnection returns the index of the connection named name, ignoring case
+func findConnection(connections []config.Connection, name string) (int, error) {
+	var names []string
+	for i, conn := range connections {
+		if strings.EqualFold(conn.Name, name) {
+			return i, nil
+		}
+		names = append(names, conn.Name)
+	}
+	return 0, fmt.Errorf("no connection named %s in %s, the connections are: %s", name, utils.GetConfigPath(), strings.Join(names, ", "))
+}
+
+// connectionLabel names a connection in the title and the host switcher
+func connectionLabel(conn config.Connection) string {
+	if conn.Name == config.DefaultConnectionName {
+		return conn.URL
+	}
+	return fmt.Sprintf("%s (%s)", conn.Name, conn.URL)
+}
+
+// connectionClient creates an API client for a connection
+func connectionClient(conn config.Connection) (*api.Client, error) {
+	client, _, err := newOllamaClient(conn.URL, conn.Transport)
+	return client, err
+}
+
+// listConnectionModels lists the models of a connection, giving up after connectionListTimeout
+func listConnectionModels(ctx context.Context, client *api.Client) ([]Model, error) {
+	ctx, cancel := context.WithTimeout(ctx, connectionListTimeout)
+	defer cancel()
+	resp, err := client.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return parseAPIResponse(resp), nil
+}
+
+// loadAllHosts lists the models of every connection at once, each with the name of its connection as its Host,
+// reporting the connections that couldn't be listed in failed
+func loadAllHosts(ctx context.Context, connections []config.Connection) (models []Model, failed []string) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, conn := range connections {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client, err := connectionClient(conn)
+			var hostModels []Model
+			if err == nil {
+				hostModels, err = listConnectionModels(ctx, client)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				logging.ErrorLogger.Printf("Error listing the models of %s: %v\n", connectionLabel(conn), err)
+				failed = append(failed, fmt.Sprintf("%s: %v", conn.Name, err))
+				return
+			}
+			for _, model := range hostModels {
+				model.Host = conn.Name
+				models = append(models, model)
+			}
+		}()
+	}
+	wg.Wait()
+	sort.Strings(failed)
+	return models, failed
+}
+
+// activeConnectionLabel names the host the TUI is connected to
+func (m *AppModel) activeConnectionLabel() string {
+	if m.allHosts {
+		return fmt.Sprintf("all %d hosts", len(m.connections))
+	}
+	if m.activeConnection < len(m.connections) {
+		return connectionLabel(m.connections[m.activeConnection])
+	}
+	return m.cfg.OllamaAPIURL
+}
+
+func (m *AppModel) handleSwitchHostKey() (tea.Model, tea.Cmd) {
+	logging.DebugLogger.Println("SwitchHost key matched")
+	if len(m.connections) < 2 {
+		m.message = fmt.Sprintf("Add connections to %s to switch between hosts", utils.GetConfigPath())
+		return m, nil
+	}
+	if m.pulling || m.showProgress {
+		m.message = "Wait for the pull or push to finish before switching hosts"
+		return m, nil
+	}
+	m.choosingHost = true
+	m.hostCursor = m.activeConnection
+	if m.allHosts {
+		m.hostCursor = len(m.connections)
+	}
+	return m, nil
+}
+
+// handleChoosingHostKey moves through the host switcher's options, the connections followed by all hosts
+func (m *AppModel) handleChoosingHostKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		if m.hostCursor > 0 {
+			m.hostCursor--
+		}
+	case "down", "j":
+		if m.hostCursor < len(m.connections) {
+			m.hostCursor++
+		}
+	case "esc", "q", "ctrl+c":
+		m.choosingHost = false
+	case "enter":
+		m.choosingHost = false
+		if m.hostCursor == len(m.connections) {
+			m.message = fmt.Sprintf("Listing the models of all %d hosts...", len(m.connections))
+			return m, m.switchToAllHosts()
+		}
+		m.message = fmt.Sprintf("Connecting to %s...", connectionLabel(m.connections[m.hostCursor]))
+		return m, m.switchHost(m.hostCursor)
+	}
+	return m, nil
+}
+
+// switchHost connects to a connection and lists its models in the background
+func (m *AppModel) switchHost(index int) tea.Cmd {
+	conn := m.connections[index]
+	return func() tea.Msg {
+		client, err := connectionClient(conn)
+		if err != nil {
+			return hostSwitchMsg{index: index, err: err}
+		}
+		models, err := listConnectionModels(context.Background(), client)
+		if err != nil {
+			return hostSwitchMsg{index: index, err: err}
+		}
+		return hostSwitchMsg{index: index, client: client, models: models}
+	}
+}
+
+// switchToAllHosts lists the models of every connection in the background
+func (m *AppModel) switchToAllHosts() tea.Cmd {
+	return func() tea.Msg {
+		models, failed := loadAllHosts(context.Background(), m.connections)
+		if len(failed) == len(m.connections) {
+			return hostSwitchMsg{index: -1, err: errors.New("none of the hosts could be reached")}
+		}
+		return hostSwitchMsg{index: -1, models: models, failed: failed}
+	}
+}
+
+func (m *AppModel) handleHostSwitchMsg(msg hostSwitchMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		label := "all hosts"
+		if msg.index >= 0 {
+			label = connectionLabel(m.connections[msg.index])
+		}
+		logging.ErrorLogger.Printf("Error switching to %s: %v\n", label, msg.err)
+		m.message = fmt.Sprintf("Error switching to %s, still connected to %s: %v", label, m.activeConnectionLabel(), msg.err)
+		return m, nil
+	}
+
+	m.models = msg.models
+	sortModels(m.models, m.cfg.SortOrder)
+	m.list.Select(0)
+	if msg.index < 0 {
+		m.allHosts = true
+		// The local manifests don't tell us what the remote hosts have on disk
+		m.diskUsageKnown = false
+		m.list.Title = listTitle(m.activeConnectionLabel(), 0, false)
+		m.refreshList()
+		m.message = fmt.Sprintf("Showing the models of all hosts, press %s to switch to one of them", m.keys.SwitchHost.Help().Key)
+		if len(msg.failed) > 0 {
+			m.message += "\nCouldn't list the models of " + strings.Join(msg.failed, ", ")
+		}
+		return m, nil
+	}
+
+	conn := m.connections[msg.index]
+	m.allHosts = false
+	m.activeConnection = msg.index
+	m.client = msg.client
+	m.cfg.UseConnection(conn)
+	m.ollamaModelsDir = conn.ModelsDir
+	m.updateDiskUsage()
+	m.refreshList()
+	m.message = fmt.Sprintf("Switched to %s", connectionLabel(conn))
+	logging.InfoLogger.Printf("Switched to %s\n", connectionLabel(conn))
+	return m, m.checkStaleLinks()
+}
+
+// allHostsKeyAllowed reports whether a key can be used in the all hosts view. It only lists the models, as the
+// keys that act on them act on a single host.
+func (m *AppModel) allHostsKeyAllowed(msg tea.KeyMsg) bool {
+	k := m.keys
+	for _, binding := range []key.Binding{
+		k.Delete, k.RunModel, k.EditModel, k.UnloadModels, k.LinkModel, k.LinkAllModels, k.CopyModel, k.PushModel,
+		k.SpitModel, k.PullModel, k.PullKeepConfig, k.RenameModel, k.PullNewModel, k.InspectModel, k.Top,
+		k.CompareModelfile,
+	} {
+		if key.Matches(msg, binding) {
+			return false
+		}
+	}
+	return true
+}
+
+// stripString returns the string removed from a model's name in the list, the one of the model's host in the all
+// hosts view
+func (m *AppModel) stripString(model Model) string {
+	if model.Host == "" {
+		return m.cfg.StripString
+	}
+	for _, conn := range m.connections {
+		if conn.Name == model.Host {
+			return conn.StripString
+		}
+	}
+	return ""
+}
+
+// hostColumnWidth is the width of the host column in the all hosts view, enough for the longest connection name
+func (m *AppModel) hostColumnWidth() int {
+	width := len("host")
+	for _, conn := range m.connections {
+		width = max(width, len(conn.Name))
+	}
+	return width + 2
+}
+
+// hostSwitcherView lists the connections and the all hosts view to choose from
+func (m *AppModel) hostSwitcherView() string {
+	var b strings.Builder
+	b.WriteString("\nSwitch to host:\n\n")
+	options := make([]string, 0, len(m.connections)+1)
+	for _, conn := range m.connections {
+		options = append(options, connectionLabel(conn))
+	}
+	options = append(options, "All hosts")
+	for i, option := range options {
+		cursor := "  "
+		if i == m.hostCursor {
+			cursor = "> "
+		}
+		current := ""
+		if (i == m.activeConnection && !m.allHosts) || (i == len(m.connections) && m.allHosts) {
+			current = " (current)"
+		}
+		b.WriteString(cursor + option + current + "\n")
+	}
+	b.WriteString("\nPress enter to switch, esc to cancel.")
+	return b.String()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ollama/ollama/api"
+
+	"github.com/mipalgu/gollama/config"
+)
+
+func TestConnectionList(t *testing.T) {
+	cfg := &config.Config{
+		OllamaAPIURL: "http://127.0.0.1:11434",
+		StripString:  "registry.example.com/",
+		Connections: []config.Connection{
+			{Name: "docker", URL: "http://localhost:11435", DockerContainer: "ollama"},
+			{Name: "gpu1", URL: "http://gpu1:11434"},
+			{Name: "gpu2", URL: "http://gpu2:11434", ModelsDir: "/mnt/gpu2/models"},
+		},
+	}
+
+	connections := connectionList(cfg, "/local/models")
+	if len(connections) != 4 {
+		t.Fatalf("Expected the top level connection and 3 more, got %+v", connections)
+	}
+	expected := []config.Connection{
+		{Name: config.DefaultConnectionName, URL: "http://127.0.0.1:11434", ModelsDir: "/local/models", StripString: "registry.example.com/"},
+		{Name: "docker", URL: "http://localhost:11435", DockerContainer: "ollama", ModelsDir: "/local/models"},
+		{Name: "gpu1", URL: "http://gpu1:11434"},
+		{Name: "gpu2", URL: "http://gpu2:11434", ModelsDir: "/mnt/gpu2/models"},
+	}
+	for i, conn := range connections {
+		if !reflect.DeepEqual(conn, expected[i]) {
+			t.Errorf("Expected %+v, got %+v", expected[i], conn)
+		}
+	}
+	if i, err := findConnection(connections, "GPU1"); err != nil || i != 2 {
+		t.Errorf("Expected gpu1 to be found ignoring case, got %d, %v", i, err)
+	}
+	if _, err := findConnection(connections, "gpu3"); err == nil || !strings.Contains(err.Error(), "docker, gpu1, gpu2") {
+		t.Errorf("Expected an error listing the connections, got %v", err)
+	}
+
+	// A connection named default replaces the top level settings
+	cfg.Connections = append(cfg.Connections, config.Connection{Name: "Default", URL: "http://other:11434"})
+	if connections := connectionList(cfg, "/local/models"); len(connections) != 4 || connections[3].URL != "http://other:11434" {
+		t.Errorf("Expected the default connection to replace the top level one, got %+v", connections)
+	}
+}
+
+// fakeTagsHost is an Ollama server that only lists models
+func fakeTagsHost(t *testing.T, names ...string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp api.ListResponse
+		for _, name := range names {
+			resp.Models = append(resp.Models, api.ListModelResponse{Name: name, Digest: "sha256:" + name})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestLoadAllHosts(t *testing.T) {
+	gpu1 := fakeTagsHost(t, "llama3:latest", "qwen3:8b")
+	gpu2 := fakeTagsHost(t, "llama3:latest")
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	connections := []config.Connection{{Name: "gpu1", URL: gpu1.URL}, {Name: "gpu2", URL: gpu2.URL}, {Name: "gpu3", URL: down.URL}}
+
+	models, failed := loadAllHosts(t.Context(), connections)
+	if len(failed) != 1 || !strings.HasPrefix(failed[0], "gpu3: ") {
+		t.Errorf("Expected gpu3 to fail, got %v", failed)
+	}
+	hosts := make(map[string][]string)
+	for _, model := range models {
+		hosts[model.Host] = append(hosts[model.Host], model.Name)
+	}
+	if len(hosts["gpu1"]) != 2 || len(hosts["gpu2"]) != 1 || len(hosts) != 2 {
+		t.Errorf("Expected the models of gpu1 and gpu2 with their hosts, got %v", hosts)
+	}
+}
+
+func TestSwitchHost(t *testing.T) {
+	gpu1 := fakeTagsHost(t, "llama3:latest")
+	gpu2 := fakeTagsHost(t, "qwen3:8b", "phi4:latest")
+	cfg := &config.Config{OllamaAPIURL: gpu1.URL, SortOrder: "name"}
+	cfg.Connections = []config.Connection{{Name: "gpu2", URL: gpu2.URL, DockerContainer: "ollama", StripString: "hf.co/"}}
+	m := &AppModel{cfg: cfg, keys: *NewKeyMap(), connections: connectionList(cfg, "")}
+	m.list = list.New(nil, list.NewDefaultDelegate(), 80, 24)
+
+	m.handleHostSwitchMsg(m.switchHost(1)().(hostSwitchMsg))
+	if m.activeConnection != 1 || m.client == nil || cfg.OllamaAPIURL != gpu2.URL || cfg.DockerContainer != "ollama" || cfg.StripString != "hf.co/" {
+		t.Errorf("Expected the settings of gpu2, got %+v", cfg)
+	}
+	if len(m.models) != 2 || m.models[0].Name != "phi4:latest" {
+		t.Errorf("Expected gpu2's models sorted by name, got %+v", m.models)
+	}
+	if !strings.Contains(m.list.Title, "gpu2 ("+gpu2.URL+")") {
+		t.Errorf("Expected the title to name gpu2, got %q", m.list.Title)
+	}
+
+	m.handleHostSwitchMsg(m.switchToAllHosts()().(hostSwitchMsg))
+	if !m.allHosts || len(m.models) != 3 || !strings.Contains(m.list.Title, "all 2 hosts") {
+		t.Errorf("Expected the models of both hosts, got %+v, %q", m.models, m.list.Title)
+	}
+	if m.stripString(Model{Host: "gpu2"}) != "hf.co/" || m.stripString(Model{Host: config.DefaultConnectionName}) != "" {
+		t.Error("Expected each model's name to be stripped with its host's strip string")
+	}
+	if m.allHostsKeyAllowed(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("D")}) {
+		t.Error("Expected deleting to be refused in the all hosts view")
+	}
+	if !m.allHostsKeyAllowed(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")}) {
+		t.Error("Expected sorting to work in the all hosts view")
+	}
+
+	// A host that can't be reached leaves the TUI where it was
+	m.connections[0].URL = "http://127.0.0.1:1"
+	m.handleHostSwitchMsg(m.switchHost(0)().(hostSwitchMsg))
+	if !m.allHosts || !strings.Contains(m.message, "still connected to all 2 hosts") {
+		t.Errorf("Expected an error and no switch, got %q", m.message)
+	}
+}
@@ -0,0 +1,431 @@
+// fleet.go contains the `gollama fleet` commands, which compare the local models with the models on remote hosts
+// and copy the ones a host is missing to it.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ollama/ollama/api"
+
+	"github.com/mipalgu/gollama/config"
+	"github.com/mipalgu/gollama/fleet"
+	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/ollamastore"
+	"github.com/mipalgu/gollama/spit"
+	"github.com/mipalgu/gollama/styles"
+	"github.com/mipalgu/gollama/utils"
+)
+
+// fleetListTimeout is how long a host has to list its models before it's reported as unreachable
+const fleetListTimeout = 30 * time.Second
+
+// remoteClient creates an API client for a remote host's URL, and the HTTP client it uses. Hosts that are one of
+// the config's connections are connected to with its settings.
+func remoteClient(cfg *config.Config, rawURL string) (*api.Client, *http.Client, error) {
+	remoteURL, err := url.Parse(rawURL)
+	if err != nil || remoteURL.Scheme == "" || remoteURL.Host == "" {
+		return nil, nil, fmt.Errorf("invalid remote host URL %q, it should look like http://remote-host:11434", rawURL)
+	}
+	return newOllamaClient(rawURL, cfg.TransportFor(rawURL))
+}
+
+// resolveRemote returns the URL of a remote host named in the config's remotes, or name itself if it isn't one
+func resolveRemote(cfg *config.Config, name string) string {
+	// viper lower cases the names when it reads the config
+	if remoteURL, ok := cfg.Remotes[strings.ToLower(name)]; ok {
+		return remoteURL
+	}
+	return name
+}
+
+// resolveHosts turns a comma separated list of remote names and URLs into hosts, all of the config's remotes if
+// the list is empty or "all"
+func resolveHosts(cfg *config.Config, list string) ([]fleet.Host, error) {
+	var names []string
+	if list == "" || list == "all" {
+		for name := range cfg.Remotes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no remote hosts, add them to remotes in %s or give their URLs", utils.GetConfigPath())
+		}
+	} else {
+		for _, name := range strings.Split(list, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	var hosts []fleet.Host
+	for _, name := range names {
+		remoteURL := resolveRemote(cfg, name)
+		if remoteURL == name {
+			parsed, err := url.Parse(name)
+			if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+				return nil, fmt.Errorf("unknown remote host %q, add it to remotes in the config or give its URL", name)
+			}
+			name = parsed.Host
+		}
+		name = strings.ToLower(name)
+		if !slices.ContainsFunc(hosts, func(h fleet.Host) bool { return h.Name == name }) {
+			hosts = append(hosts, fleet.Host{Name: name, URL: remoteURL})
+		}
+	}
+	return hosts, nil
+}
+
+// fleetInventory is how the models on a set of hosts compare with the local models
+type fleetInventory struct {
+	cfg     *config.Config
+	hosts   []fleet.Host
+	clients map[string]*api.Client
+	entries []fleet.Entry
+	// unreachable has the error for each host whose models couldn't be listed, they're left out of entries
+	unreachable map[string]error
+	// weightsErr is set when some models with different digests couldn't be checked for the same weights
+	weightsErr error
+}
+
+// loadFleetInventory lists the models in the local models directory and on each host, limited to modelNames if
+// there are any
+func loadFleetInventory(ctx context.Context, cfg *config.Config, store *ollamastore.Store, hosts []fleet.Host, modelNames []string) (*fleetInventory, error) {
+	models, err := store.Models()
+	if err != nil {
+		return nil, err
+	}
+	localWeights := make(map[string][]string, len(models))
+	for _, model := range models {
+		localWeights[model.Name] = fleet.ModelWeights(model.Manifest)
+	}
+
+	inv := &fleetInventory{cfg: cfg, hosts: hosts, clients: make(map[string]*api.Client), unreachable: make(map[string]error)}
+	inventories := make(map[string]fleet.Inventory)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, host := range hosts {
+		client, _, err := remoteClient(cfg, host.URL)
+		if err != nil {
+			inv.unreachable[host.Name] = err
+			continue
+		}
+		inv.clients[host.Name] = client
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			listCtx, cancel := context.WithTimeout(ctx, fleetListTimeout)
+			defer cancel()
+			inventory, err := fleet.ListModels(listCtx, client)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				inv.unreachable[host.Name] = err
+				return
+			}
+			inventories[host.Name] = inventory
+		}()
+	}
+	wg.Wait()
+
+	entries := fleet.Compare(fleet.LocalInventory(models), inventories)
+	if len(modelNames) > 0 {
+		wanted := make(map[string]bool)
+		for _, name := range modelNames {
+			wanted[ollamastore.ShortName(ollamastore.ParseName(name))] = true
+		}
+		entries = slices.DeleteFunc(entries, func(e fleet.Entry) bool { return !wanted[e.Model] })
+	}
+	inv.weightsErr = fleet.CheckWeights(ctx, entries, localWeights, inv.clients)
+	inv.entries = entries
+	return inv, nil
+}
+
+// reachable returns the hosts whose models could be listed
+func (inv *fleetInventory) reachable() []fleet.Host {
+	var hosts []fleet.Host
+	for _, host := range inv.hosts {
+		if _, ok := inv.unreachable[host.Name]; !ok {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// reportProblems prints the hosts that couldn't be listed and the models that couldn't be checked to stderr
+func (inv *fleetInventory) reportProblems() {
+	for _, host := range inv.hosts {
+		if err, ok := inv.unreachable[host.Name]; ok {
+			commandError("Error listing the models on %s (%s): %v", host.Name, host.URL, err)
+		}
+	}
+	if inv.weightsErr != nil {
+		logging.ErrorLogger.Printf("Warning: %v\n", inv.weightsErr)
+		fmt.Fprintln(os.Stderr, styles.WarningStyle().Render(fmt.Sprintf("Warning: %v", inv.weightsErr)))
+	}
+}
+
+// runFleetCommand implements `gollama fleet diff` and `gollama fleet sync`
+func runFleetCommand(cfg *config.Config, args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "diff":
+			return runFleetDiffCommand(cfg, args[1:])
+		case "sync":
+			return runFleetSyncCommand(cfg, args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Usage: gollama fleet diff [flags] [model...] | fleet sync --to <hosts> [flags] [model...]")
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+		return 0
+	}
+	return 2
+}
+
+// runFleetDiffCommand implements `gollama fleet diff`, showing how the models on each host compare with the local
+// ones. It exits with 1 if a host is missing a local model, has a different version of one or can't be reached.
+func runFleetDiffCommand(cfg *config.Config, args []string) int {
+	fs := newFlagSet("fleet diff", "fleet diff [flags] [model...]", cfg)
+	hostList := fs.String("hosts", "", "Comma separated remote host names from the config or URLs to compare, all the configured remotes by default")
+	ollamaDir := addOllamaDirFlag(fs, cfg)
+	if err := fs.Parse(args); err != nil {
+		return flagExitCode(err)
+	}
+	hosts, err := resolveHosts(cfg, *hostList)
+	if err != nil {
+		return commandError("Error: %v", err)
+	}
+
+	inv, err := loadFleetInventory(context.Background(), cfg, ollamastore.New(*ollamaDir), hosts, fs.Args())
+	if err != nil {
+		return commandError("Error: %v", err)
+	}
+	inv.reportProblems()
+	reachable := inv.reachable()
+	if len(reachable) == 0 {
+		return 1
+	}
+	writeFleetDiff(os.Stdout, inv.entries, reachable)
+
+	exitCode := 0
+	if len(inv.unreachable) > 0 {
+		exitCode = 1
+	}
+	for _, host := range reachable {
+		counts := make(map[fleet.State]int)
+		for _, e := range inv.entries {
+			if state, ok := e.States[host.Name]; ok {
+				counts[state]++
+			}
+		}
+		if counts[fleet.Missing] == 0 && counts[fleet.Different] == 0 {
+			fmt.Println(styles.SuccessStyle().Render(fmt.Sprintf("%s has every local model", host.Name)))
+			continue
+		}
+		exitCode = 1
+		fmt.Println(styles.WarningStyle().Render(fmt.Sprintf("%s is missing %d models and has a different version of %d",
+			host.Name, counts[fleet.Missing], counts[fleet.Different])))
+	}
+	return exitCode
+}
+
+// writeFleetDiff writes a table of the models with the local digest and their state on each host
+func writeFleetDiff(w io.Writer, entries []fleet.Entry, hosts []fleet.Host) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := []string{"MODEL", "LOCAL"}
+	for _, host := range hosts {
+		header = append(header, strings.ToUpper(host.Name))
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, e := range entries {
+		row := []string{e.Model, "-"}
+		if e.Digest != "" {
+			row[1] = shortDigest(e.Digest)
+		}
+		for _, host := range hosts {
+			cell := "-"
+			if state, ok := e.States[host.Name]; ok {
+				cell = state.String()
+			}
+			row = append(row, cell)
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	tw.Flush()
+}
+
+// fleetSyncResult is what `gollama fleet sync` did on a host
+type fleetSyncResult struct {
+	host   fleet.Host
+	copied []string
+	// present is how many of the models were already on the host
+	present int
+	// different are the models the host has a different version of, which are only replaced with --replace
+	different []string
+	failed    []string
+}
+
+// fleetCopies returns the models to copy to a host, the ones it has a different version of that won't be copied
+// and how many it already has
+func fleetCopies(entries []fleet.Entry, host string, replace bool) (copies, different []string, present int) {
+	for _, e := range entries {
+		switch e.States[host] {
+		case fleet.Missing:
+			copies = append(copies, e.Model)
+		case fleet.Different:
+			if replace {
+				copies = append(copies, e.Model)
+			} else {
+				different = append(different, e.Model)
+			}
+		case fleet.Same, fleet.SameWeights:
+			present++
+		}
+	}
+	return copies, different, present
+}
+
+// syncFleet copies the models each reachable host is missing to it, all the hosts at once and one model at a time
+// on each, writing a line to out for each blob and model as it's copied
+func syncFleet(ctx context.Context, inv *fleetInventory, ollamaModelsDir string, parallel int, replace, dryRun bool, out io.Writer) []fleetSyncResult {
+	var outMu sync.Mutex
+	printf := func(format string, args ...any) {
+		outMu.Lock()
+		defer outMu.Unlock()
+		fmt.Fprintf(out, format, args...)
+	}
+
+	hosts := inv.reachable()
+	results := make([]fleetSyncResult, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		copies, different, present := fleetCopies(inv.entries, host.Name, replace)
+		results[i] = fleetSyncResult{host: host, present: present, different: different}
+		if dryRun {
+			for _, name := range copies {
+				printf("[%s] Would copy %s\n", host.Name, name)
+			}
+			results[i].copied = copies
+			continue
+		}
+		if len(copies) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := &results[i]
+			copier, err := newSpitCopier(inv.cfg, host.URL, ollamaModelsDir, parallel, func(p spit.Progress) {
+				switch p.Status {
+				case spit.Skipped:
+					printf("[%s]   %s (%s) is already there\n", host.Name, p.Digest, formatSize(p.BlobTotal))
+				case spit.Uploaded:
+					printf("[%s]   Uploaded %s (%s)\n", host.Name, p.Digest, formatSize(p.BlobTotal))
+				}
+			})
+			if err != nil {
+				printf("[%s] Error: %v\n", host.Name, err)
+				result.failed = copies
+				return
+			}
+			for j, name := range copies {
+				if ctx.Err() != nil {
+					result.failed = append(result.failed, copies[j:]...)
+					return
+				}
+				printf("[%s] Copying %s (%d/%d)\n", host.Name, name, j+1, len(copies))
+				if err := copier.Copy(ctx, name); err != nil {
+					logging.ErrorLogger.Printf("Error copying %s to %s: %v\n", name, host.URL, err)
+					printf("[%s] Error copying %s: %v\n", host.Name, name, err)
+					result.failed = append(result.failed, name)
+					continue
+				}
+				printf("[%s] Copied %s\n", host.Name, name)
+				result.copied = append(result.copied, name)
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// runFleetSyncCommand implements `gollama fleet sync`, copying the local models each host is missing to it
+func runFleetSyncCommand(cfg *config.Config, args []string) int {
+	fs := newFlagSet("fleet sync", "fleet sync --to <hosts> [flags] [model...]", cfg)
+	hostList := fs.String("to", "", "Comma separated remote host names from the config or URLs to copy to, or all for all the configured remotes")
+	replace := fs.Bool("replace", false, "Also copy models a host has a different version of, replacing them")
+	parallel := fs.Int("parallel", spit.DefaultParallel, "How many blobs to upload to each host at once")
+	dryRun := fs.Bool("n", false, "Show what would be copied without copying anything (dry-run mode)")
+	fs.BoolVar(dryRun, "dry-run", false, "Show what would be copied without copying anything (dry-run mode)")
+	ollamaDir := addOllamaDirFlag(fs, cfg)
+	if err := fs.Parse(args); err != nil {
+		return flagExitCode(err)
+	}
+	if *hostList == "" {
+		return commandError("Error: --to is required, give the remote host names or URLs to copy to, or all")
+	}
+	hosts, err := resolveHosts(cfg, *hostList)
+	if err != nil {
+		return commandError("Error: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	inv, err := loadFleetInventory(ctx, cfg, ollamastore.New(*ollamaDir), hosts, fs.Args())
+	if err != nil {
+		return commandError("Error: %v", err)
+	}
+	inv.reportProblems()
+	results := syncFleet(ctx, inv, *ollamaDir, *parallel, *replace, *dryRun, os.Stdout)
+	if *dryRun {
+		return 0
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tCOPIED\tALREADY THERE\tDIFFERENT\tFAILED")
+	exitCode := 0
+	byHost := make(map[string]fleetSyncResult, len(results))
+	for _, result := range results {
+		byHost[result.host.Name] = result
+	}
+	for _, host := range inv.hosts {
+		result, ok := byHost[host.Name]
+		if !ok {
+			fmt.Fprintf(w, "%s\tunreachable\t\t\t\n", host.Name)
+			exitCode = 1
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\n", host.Name, len(result.copied), result.present, len(result.different), len(result.failed))
+	}
+	w.Flush()
+
+	for _, result := range results {
+		if len(result.failed) > 0 {
+			exitCode = 1
+			fmt.Println(styles.ErrorStyle().Render(fmt.Sprintf("Failed to copy to %s: %s", result.host.Name, strings.Join(result.failed, ", "))))
+		}
+		if len(result.different) > 0 {
+			fmt.Println(styles.WarningStyle().Render(fmt.Sprintf("%s has a different version of %s, use --replace to replace them",
+				result.host.Name, strings.Join(result.different, ", "))))
+		}
+	}
+	if ctx.Err() != nil {
+		fmt.Println(styles.InfoStyle().Render("Interrupted, run it again to carry on from where it stopped"))
+	}
+	return exitCode
+}
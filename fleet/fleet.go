@@ -0,0 +1,204 @@
+// Package fleet compares the models in a local Ollama models directory with the models on a set of remote Ollama
+// servers, so the ones a server is missing or has a different version of can be copied to it.
+package fleet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+
+	"github.com/ollama/ollama/api"
+
+	"github.com/mipalgu/gollama/modelfile"
+	"github.com/mipalgu/gollama/ollamastore"
+)
+
+// Host is a remote Ollama server
+type Host struct {
+	// Name is the host's name in the config's remotes, or its address if it was given as a URL
+	Name string
+	URL  string
+}
+
+// State is how a model on a host compares with the local model of the same name
+type State int
+
+const (
+	// Same models have the same manifest digest
+	Same State = iota
+	// Missing models are local but not on the host
+	Missing
+	// SameWeights models have a different manifest digest but the same weights, which is what copying a model
+	// with /api/create leaves as the server writes its own config and manifest
+	SameWeights
+	// Different models have different weights, e.g. because one of them has been pulled again since
+	Different
+	// Extra models are on the host but not local
+	Extra
+)
+
+func (s State) String() string {
+	switch s {
+	case Same:
+		return "ok"
+	case Missing:
+		return "missing"
+	case SameWeights:
+		return "same weights"
+	case Different:
+		return "different"
+	case Extra:
+		return "extra"
+	}
+	return "unknown"
+}
+
+// Present reports whether the host has the model, with the same weights as the local one
+func (s State) Present() bool {
+	return s == Same || s == SameWeights
+}
+
+// Inventory is the manifest digest of each model on a server, by name
+type Inventory map[string]string
+
+// Entry is a model and how each host compares with the local copy of it
+type Entry struct {
+	Model string
+	// Digest is the local model's manifest digest, "" if it's only on some of the hosts
+	Digest string
+	// States is the state of the model on each host that could be listed, hosts that don't have a model that
+	// isn't local are left out
+	States map[string]State
+}
+
+// ListModels lists the models on a server with /api/tags
+func ListModels(ctx context.Context, client *api.Client) (Inventory, error) {
+	resp, err := client.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inventory := make(Inventory, len(resp.Models))
+	for _, model := range resp.Models {
+		inventory[model.Name] = model.Digest
+	}
+	return inventory, nil
+}
+
+// LocalInventory is the inventory of models read from a models directory
+func LocalInventory(models []ollamastore.Model) Inventory {
+	inventory := make(Inventory, len(models))
+	for _, model := range models {
+		inventory[model.Name] = model.Digest
+	}
+	return inventory
+}
+
+// Compare compares the local models with the models on each host, by host name. Models that differ only by
+// digest are reported as Different, see CheckWeights. Entries are sorted by model name.
+func Compare(local Inventory, hosts map[string]Inventory) []Entry {
+	entries := make(map[string]*Entry)
+	entry := func(name string) *Entry {
+		if e, ok := entries[name]; ok {
+			return e
+		}
+		e := &Entry{Model: name, Digest: local[name], States: make(map[string]State)}
+		entries[name] = e
+		return e
+	}
+
+	for name, digest := range local {
+		e := entry(name)
+		for host, inventory := range hosts {
+			remote, ok := inventory[name]
+			switch {
+			case !ok:
+				e.States[host] = Missing
+			case remote == digest:
+				e.States[host] = Same
+			default:
+				e.States[host] = Different
+			}
+		}
+	}
+	for host, inventory := range hosts {
+		for name := range inventory {
+			if _, ok := local[name]; !ok {
+				entry(name).States[host] = Extra
+			}
+		}
+	}
+
+	sorted := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		sorted = append(sorted, *e)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Model < sorted[j].Model })
+	return sorted
+}
+
+// ModelWeights returns the digests of a model's weight, projector and adapter layers, sorted
+func ModelWeights(manifest ollamastore.Manifest) []string {
+	var digests []string
+	for _, layer := range manifest.Layers {
+		switch layer.MediaType {
+		case ollamastore.MediaTypeModel, ollamastore.MediaTypeProjector, ollamastore.MediaTypeAdapter:
+			digests = append(digests, layer.Digest)
+		}
+	}
+	sort.Strings(digests)
+	return digests
+}
+
+// blobPattern matches the digest in a blob path, .../blobs/sha256-<hex>
+var blobPattern = regexp.MustCompile(`sha256[-:]([0-9a-f]{64})$`)
+
+// HostWeights returns the digests of the weight, projector and adapter blobs of a model on a server, sorted. The
+// API doesn't list a model's layers, so they're read from the blob paths in the FROM and ADAPTER lines of the
+// Modelfile /api/show returns.
+func HostWeights(ctx context.Context, client *api.Client, name string) ([]string, error) {
+	resp, err := client.Show(ctx, &api.ShowRequest{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := modelfile.Parse(resp.Modelfile)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the Modelfile of %s: %w", name, err)
+	}
+	var digests []string
+	for _, cmd := range parsed.Commands {
+		if cmd.Instruction != modelfile.From && cmd.Instruction != modelfile.Adapter {
+			continue
+		}
+		if match := blobPattern.FindStringSubmatch(cmd.Value); match != nil {
+			digests = append(digests, "sha256:"+match[1])
+		}
+	}
+	sort.Strings(digests)
+	return digests, nil
+}
+
+// CheckWeights changes the Different states of entries to SameWeights where the host's model has the same weights
+// as the local one. local has the local model's weights by name, see ModelWeights, and clients the client for each
+// host. Hosts whose weights can't be read are left as Different and their errors returned together.
+func CheckWeights(ctx context.Context, entries []Entry, local map[string][]string, clients map[string]*api.Client) error {
+	var errs []error
+	for _, e := range entries {
+		for host, state := range e.States {
+			if state != Different {
+				continue
+			}
+			weights, err := HostWeights(ctx, clients[host], e.Model)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error reading %s on %s: %w", e.Model, host, err))
+				continue
+			}
+			if slices.Equal(weights, local[e.Model]) {
+				e.States[host] = SameWeights
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
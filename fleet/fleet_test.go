@@ -0,0 +1,89 @@
+package fleet
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ollama/ollama/api"
+
+	"github.com/mipalgu/gollama/ollamastore"
+)
+
+func TestCompare(t *testing.T) {
+	local := Inventory{"llama3:latest": "aaa", "qwen3:8b": "bbb", "phi4:latest": "ccc"}
+	hosts := map[string]Inventory{
+		"gpu1": {"llama3:latest": "aaa", "qwen3:8b": "other", "mistral:latest": "ddd"},
+		"gpu2": {},
+	}
+
+	entries := Compare(local, hosts)
+	expected := map[string]map[string]State{
+		"llama3:latest":  {"gpu1": Same, "gpu2": Missing},
+		"mistral:latest": {"gpu1": Extra},
+		"phi4:latest":    {"gpu1": Missing, "gpu2": Missing},
+		"qwen3:8b":       {"gpu1": Different, "gpu2": Missing},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %+v", len(expected), entries)
+	}
+	for i, e := range entries {
+		if i > 0 && entries[i-1].Model > e.Model {
+			t.Errorf("Entries aren't sorted: %+v", entries)
+		}
+		if e.Digest != local[e.Model] {
+			t.Errorf("Expected %s to have the local digest %q, got %q", e.Model, local[e.Model], e.Digest)
+		}
+		if len(e.States) != len(expected[e.Model]) {
+			t.Errorf("Unexpected states for %s: %v", e.Model, e.States)
+		}
+		for host, state := range expected[e.Model] {
+			if e.States[host] != state {
+				t.Errorf("Expected %s on %s to be %s, got %s", e.Model, host, state, e.States[host])
+			}
+		}
+	}
+}
+
+func TestCheckWeights(t *testing.T) {
+	weights := "sha256:" + strings.Repeat("a", 64)
+	projector := "sha256:" + strings.Repeat("b", 64)
+	modelfiles := map[string]string{
+		// Copied with /api/create, so the manifest differs but the blobs are the same
+		"copied:latest": "# Modelfile generated by \"ollama show\"\nFROM /root/.ollama/models/blobs/sha256-" + strings.Repeat("a", 64) +
+			"\nFROM /root/.ollama/models/blobs/sha256-" + strings.Repeat("b", 64) + "\nTEMPLATE {{ .Prompt }}\n",
+		"pulled:latest": "FROM /root/.ollama/models/blobs/sha256-" + strings.Repeat("c", 64) + "\n",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req api.ShowRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(api.ShowResponse{Modelfile: modelfiles[req.Name]})
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	clients := map[string]*api.Client{"gpu1": api.NewClient(serverURL, server.Client())}
+
+	manifest := ollamastore.Manifest{Layers: []ollamastore.Layer{
+		{MediaType: ollamastore.MediaTypeProjector, Digest: projector},
+		{MediaType: ollamastore.MediaTypeTemplate, Digest: "sha256:" + strings.Repeat("d", 64)},
+		{MediaType: ollamastore.MediaTypeModel, Digest: weights},
+	}}
+	local := map[string][]string{"copied:latest": ModelWeights(manifest), "pulled:latest": ModelWeights(manifest)}
+	entries := []Entry{
+		{Model: "copied:latest", States: map[string]State{"gpu1": Different}},
+		{Model: "pulled:latest", States: map[string]State{"gpu1": Different}},
+	}
+	if err := CheckWeights(context.Background(), entries, local, clients); err != nil {
+		t.Fatalf("CheckWeights failed: %v", err)
+	}
+	if entries[0].States["gpu1"] != SameWeights {
+		t.Errorf("Expected the copied model to have the same weights, got %s", entries[0].States["gpu1"])
+	}
+	if entries[1].States["gpu1"] != Different {
+		t.Errorf("Expected the pulled model to be different, got %s", entries[1].States["gpu1"])
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ollama/ollama/api"
+
+	"github.com/mipalgu/gollama/config"
+	"github.com/mipalgu/gollama/fleet"
+	"github.com/mipalgu/gollama/ollamastore"
+)
+
+func TestResolveHosts(t *testing.T) {
+	cfg := &config.Config{Remotes: map[string]string{"gpu1": "http://gpu1:11434", "gpu2": "http://10.0.0.2:11434"}}
+
+	hosts, err := resolveHosts(cfg, "")
+	if err != nil || len(hosts) != 2 || hosts[0] != (fleet.Host{Name: "gpu1", URL: "http://gpu1:11434"}) || hosts[1].Name != "gpu2" {
+		t.Errorf("Expected all the remotes, got %+v, %v", hosts, err)
+	}
+	hosts, err = resolveHosts(cfg, "GPU2, http://other:11434,gpu2")
+	if err != nil || len(hosts) != 2 || hosts[0].URL != "http://10.0.0.2:11434" || hosts[1] != (fleet.Host{Name: "other:11434", URL: "http://other:11434"}) {
+		t.Errorf("Expected a remote and a URL, got %+v, %v", hosts, err)
+	}
+	if _, err := resolveHosts(cfg, "gpu3"); err == nil {
+		t.Error("Expected an error for an unknown remote")
+	}
+	if _, err := resolveHosts(&config.Config{}, "all"); err == nil {
+		t.Error("Expected an error when there are no remotes")
+	}
+}
+
+// fakeFleetHost is an Ollama server that lists the models created on it
+type fakeFleetHost struct {
+	mu     sync.Mutex
+	blobs  map[string]bool
+	models map[string]string
+	// files are the blobs each model was created from
+	files map[string][]string
+}
+
+func (f *fakeFleetHost) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.URL.Path == "/api/tags":
+		var resp api.ListResponse
+		for name, digest := range f.models {
+			resp.Models = append(resp.Models, api.ListModelResponse{Name: name, Digest: digest})
+		}
+		json.NewEncoder(w).Encode(resp)
+	case strings.HasPrefix(r.URL.Path, "/api/blobs/"):
+		digest := strings.TrimPrefix(r.URL.Path, "/api/blobs/")
+		if r.Method == http.MethodHead {
+			if !f.blobs[digest] {
+				w.WriteHeader(http.StatusNotFound)
+			}
+			return
+		}
+		io.Copy(io.Discard, r.Body)
+		f.blobs[digest] = true
+		w.WriteHeader(http.StatusCreated)
+	case r.URL.Path == "/api/create":
+		var req api.CreateRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		// The server writes its own manifest, so the digest isn't the local one
+		f.models[req.Model] = "recreated"
+		for _, digest := range req.Files {
+			f.files[req.Model] = append(f.files[req.Model], digest)
+		}
+		json.NewEncoder(w).Encode(api.ProgressResponse{Status: "success"})
+	case r.URL.Path == "/api/show":
+		var req api.ShowRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		var modelfile strings.Builder
+		for _, digest := range f.files[req.Name] {
+			modelfile.WriteString("FROM /models/blobs/" + strings.Replace(digest, ":", "-", 1) + "\n")
+		}
+		json.NewEncoder(w).Encode(api.ShowResponse{Modelfile: modelfile.String()})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestSyncFleet(t *testing.T) {
+	store := ollamastore.New(t.TempDir())
+	for _, name := range []string{"llama3", "qwen3"} {
+		content := strings.Repeat(name, 1000)
+		sum := sha256.Sum256([]byte(content))
+		digest := "sha256:" + hex.EncodeToString(sum[:])
+		if err := os.MkdirAll(store.BlobsDir(), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(store.BlobPath(digest), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := json.Marshal(ollamastore.Manifest{SchemaVersion: 2, Layers: []ollamastore.Layer{
+			{MediaType: ollamastore.MediaTypeModel, Digest: digest, Size: int64(len(content))},
+		}})
+		path := filepath.Join(store.ManifestsDir(), "registry.ollama.ai", "library", name, "latest")
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	models, err := store.Models()
+	if err != nil || len(models) != 2 {
+		t.Fatalf("Expected 2 local models, got %v, %v", models, err)
+	}
+
+	// gpu1 already has llama3, gpu2 has nothing and gpu3 is down
+	gpu1 := &fakeFleetHost{blobs: make(map[string]bool), models: map[string]string{"llama3:latest": models[0].Digest}, files: make(map[string][]string)}
+	gpu2 := &fakeFleetHost{blobs: make(map[string]bool), models: make(map[string]string), files: make(map[string][]string)}
+	server1 := httptest.NewServer(gpu1)
+	defer server1.Close()
+	server2 := httptest.NewServer(gpu2)
+	defer server2.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	hosts := []fleet.Host{{Name: "gpu1", URL: server1.URL}, {Name: "gpu2", URL: server2.URL}, {Name: "gpu3", URL: down.URL}}
+
+	inv, err := loadFleetInventory(context.Background(), &config.Config{}, store, hosts, nil)
+	if err != nil {
+		t.Fatalf("loadFleetInventory failed: %v", err)
+	}
+	if _, ok := inv.unreachable["gpu3"]; !ok || len(inv.reachable()) != 2 {
+		t.Errorf("Expected gpu3 to be unreachable, got %v", inv.unreachable)
+	}
+
+	var out bytes.Buffer
+	results := syncFleet(context.Background(), inv, store.Dir, 2, false, false, &out)
+	if len(results) != 2 {
+		t.Fatalf("Expected results for the 2 reachable hosts, got %+v", results)
+	}
+	if r := results[0]; r.host.Name != "gpu1" || len(r.copied) != 1 || r.copied[0] != "qwen3:latest" || r.present != 1 || len(r.failed) != 0 {
+		t.Errorf("Expected gpu1 to only get qwen3, got %+v", r)
+	}
+	if r := results[1]; r.host.Name != "gpu2" || len(r.copied) != 2 || r.present != 0 || len(r.failed) != 0 {
+		t.Errorf("Expected gpu2 to get both models, got %+v\n%s", r, out.String())
+	}
+	if !strings.Contains(out.String(), "[gpu2] Copied llama3:latest") {
+		t.Errorf("Expected a line for each copy, got:\n%s", out.String())
+	}
+
+	// The copies have the server's own digests, but the same weights, so there's nothing left to copy
+	inv, err = loadFleetInventory(context.Background(), &config.Config{}, store, hosts[:2], nil)
+	if err != nil {
+		t.Fatalf("loadFleetInventory failed: %v", err)
+	}
+	if inv.weightsErr != nil {
+		t.Errorf("Expected the weights to be checked, got %v", inv.weightsErr)
+	}
+	for _, e := range inv.entries {
+		for host, state := range e.States {
+			if !state.Present() {
+				t.Errorf("Expected %s to be on %s, got %s", e.Model, host, state)
+			}
+		}
+	}
+}
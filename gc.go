@@ -0,0 +1,70 @@
+// gc.go contains the `gollama gc` command, which removes blobs no model references from the Ollama models directory.
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mipalgu/gollama/config"
+	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/ollamastore"
+	"github.com/mipalgu/gollama/styles"
+)
+
+// runGCCommand implements `gollama gc`
+func runGCCommand(cfg *config.Config, args []string) int {
+	fs := newFlagSet("gc", "gc [flags]", cfg)
+	ollamaDir := addOllamaDirFlag(fs, cfg)
+	dryRun := fs.Bool("n", false, "Show what would be removed without removing anything (dry-run mode)")
+	fs.BoolVar(dryRun, "dry-run", false, "Show what would be removed without removing anything (dry-run mode)")
+	minAge := fs.Duration("min-age", time.Hour, "Skip files modified more recently than this, they may belong to a pull in progress")
+	if err := fs.Parse(args); err != nil {
+		return flagExitCode(err)
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+
+	store := ollamastore.New(*ollamaDir)
+	report, err := store.FindGarbage(*minAge)
+	if err != nil {
+		return commandError("Error scanning %s: %v", *ollamaDir, err)
+	}
+
+	prefix := ""
+	if *dryRun {
+		prefix = "[DRY RUN] "
+	}
+
+	for _, broken := range report.Broken {
+		fmt.Println(styles.WarningStyle().Render(fmt.Sprintf("Missing blob %s referenced by %v, not touching it", broken.Digest, broken.Models)))
+	}
+
+	if len(report.Garbage) == 0 {
+		fmt.Println(styles.SuccessStyle().Render("Nothing to clean up in " + store.BlobsDir()))
+		return 0
+	}
+
+	for _, garbage := range report.Garbage {
+		verb := "Removing"
+		if *dryRun {
+			verb = "Would remove"
+		}
+		fmt.Printf("%s%s %s %s (%s, modified %s)\n", prefix, verb, garbage.Kind, garbage.Path,
+			formatSize(garbage.Size), garbage.ModTime.Format("2006-01-02 15:04"))
+	}
+
+	if *dryRun {
+		fmt.Println(styles.InfoStyle().Render(fmt.Sprintf("%sSummary: %d files, %s reclaimable", prefix, len(report.Garbage), formatSize(report.Reclaimable))))
+		return 0
+	}
+
+	freed, err := store.RemoveGarbage(report.Garbage)
+	logging.InfoLogger.Printf("gc removed garbage from %s, freed %d bytes\n", store.BlobsDir(), freed)
+	if err != nil {
+		return commandError("Freed %s, but not everything could be removed: %v", formatSize(freed), err)
+	}
+	fmt.Println(styles.SuccessStyle().Render(fmt.Sprintf("Removed %d files, freed %s", len(report.Garbage), formatSize(freed))))
+	return 0
+}
@@ -0,0 +1,60 @@
+package gguf
+
+import "fmt"
+
+// FileType is the general.file_type of a GGUF file, the quantisation most of its tensors use
+type FileType uint32
+
+// fileTypeNames follows llama.cpp's llama_ftype, gaps are types that were removed. Ollama numbers its files the
+// same way apart from MXFP4, which it writes as 4, llama.cpp's removed Q4_1_SOME_F16, where llama.cpp uses 38 for
+// MOSTLY_MXFP4_MOE. Both are here so gpt-oss files from either are named.
+var fileTypeNames = map[FileType]string{
+	0:  "F32",
+	1:  "F16",
+	2:  "Q4_0",
+	3:  "Q4_1",
+	4:  "MXFP4", // Ollama
+	7:  "Q8_0",
+	8:  "Q5_0",
+	9:  "Q5_1",
+	10: "Q2_K",
+	11: "Q3_K_S",
+	12: "Q3_K_M",
+	13: "Q3_K_L",
+	14: "Q4_K_S",
+	15: "Q4_K_M",
+	16: "Q5_K_S",
+	17: "Q5_K_M",
+	18: "Q6_K",
+	19: "IQ2_XXS",
+	20: "IQ2_XS",
+	21: "Q2_K_S",
+	22: "IQ3_XS",
+	23: "IQ3_XXS",
+	24: "IQ1_S",
+	25: "IQ4_NL",
+	26: "IQ3_S",
+	27: "IQ3_M",
+	28: "IQ2_S",
+	29: "IQ2_M",
+	30: "IQ4_XS",
+	31: "IQ1_M",
+	32: "BF16",
+	36: "TQ1_0",
+	37: "TQ2_0",
+	38: "MXFP4", // llama.cpp
+}
+
+// String returns the name used in model tags and by the vRAM estimator, e.g. Q4_K_M
+func (t FileType) String() string {
+	if name, ok := fileTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", uint32(t))
+}
+
+// Known reports whether the file type is one llama.cpp or Ollama defines
+func (t FileType) Known() bool {
+	_, ok := fileTypeNames[t]
+	return ok
+}
@@ -0,0 +1,250 @@
+package gguf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// Magic is the first four bytes of every GGUF file
+const Magic = "GGUF"
+
+// ValueType is the type of a metadata value
+type ValueType uint32
+
+const (
+	TypeUint8 ValueType = iota
+	TypeInt8
+	TypeUint16
+	TypeInt16
+	TypeUint32
+	TypeInt32
+	TypeFloat32
+	TypeBool
+	TypeString
+	TypeArray
+	TypeUint64
+	TypeInt64
+	TypeFloat64
+)
+
+// maxStringLength guards against corrupt files asking for huge allocations
+const maxStringLength = 64 * 1024 * 1024
+
+// Array is a metadata array value, Values holds Go values of the element type (uint32, string, etc.)
+type Array struct {
+	Type   ValueType
+	Values []any
+}
+
+// TensorInfo describes a tensor, its data isn't read
+type TensorInfo struct {
+	Name       string
+	Dimensions []uint64
+	Type       uint32
+	Offset     uint64
+}
+
+// Elements is the number of values in the tensor
+func (t TensorInfo) Elements() uint64 {
+	n := uint64(1)
+	for _, d := range t.Dimensions {
+		n *= d
+	}
+	return n
+}
+
+// File is the header, metadata and tensor descriptions of a GGUF file
+type File struct {
+	Version  uint32
+	Metadata map[string]any
+	Tensors  []TensorInfo
+}
+
+// Open reads the header, metadata and tensor descriptions of a GGUF file without reading the tensor data
+func Open(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	file, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading GGUF file %s: %v", path, err)
+	}
+	return file, nil
+}
+
+// IsGGUF reports whether the file at path starts with the GGUF magic
+func IsGGUF(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, len(Magic))
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false
+	}
+	return string(magic) == Magic
+}
+
+// Read parses a GGUF header, metadata and tensor descriptions from r, stopping before the tensor data
+func Read(r io.Reader) (*File, error) {
+	d := &decoder{r: bufio.NewReaderSize(r, 1<<20)}
+
+	magic := make([]byte, len(Magic))
+	if _, err := io.ReadFull(d.r, magic); err != nil {
+		return nil, fmt.Errorf("reading magic: %w", err)
+	}
+	if string(magic) != Magic {
+		return nil, fmt.Errorf("not a GGUF file (magic %q)", magic)
+	}
+
+	file := &File{Version: d.uint32()}
+	switch {
+	case file.Version != 0 && file.Version&0xffff == 0:
+		// A small version number read with the wrong byte order
+		return nil, fmt.Errorf("big-endian GGUF files aren't supported")
+	case file.Version == 0 || file.Version > 3:
+		return nil, fmt.Errorf("unsupported GGUF version %d", file.Version)
+	}
+	// Version 1 used 32-bit lengths and counts
+	d.v1 = file.Version == 1
+
+	tensorCount := d.count()
+	kvCount := d.count()
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	file.Metadata = make(map[string]any, kvCount)
+	for i := uint64(0); i < kvCount && d.err == nil; i++ {
+		key := d.string()
+		valueType := ValueType(d.uint32())
+		value := d.value(valueType)
+		if d.err != nil {
+			return nil, fmt.Errorf("reading metadata %q: %w", key, d.err)
+		}
+		file.Metadata[key] = value
+	}
+
+	file.Tensors = make([]TensorInfo, 0, min(tensorCount, 1<<16))
+	for i := uint64(0); i < tensorCount && d.err == nil; i++ {
+		tensor := TensorInfo{Name: d.string()}
+		dims := d.uint32()
+		if dims > 8 {
+			return nil, fmt.Errorf("tensor %q has %d dimensions", tensor.Name, dims)
+		}
+		for range dims {
+			tensor.Dimensions = append(tensor.Dimensions, d.uint64())
+		}
+		tensor.Type = d.uint32()
+		tensor.Offset = d.uint64()
+		file.Tensors = append(file.Tensors, tensor)
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("reading tensor descriptions: %w", d.err)
+	}
+	return file, nil
+}
+
+// decoder reads little-endian values, remembering the first error so reads can be chained
+type decoder struct {
+	r   *bufio.Reader
+	v1  bool
+	err error
+	buf [8]byte
+}
+
+func (d *decoder) read(n int) []byte {
+	if d.err != nil {
+		return d.buf[:n]
+	}
+	if _, err := io.ReadFull(d.r, d.buf[:n]); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		d.err = err
+	}
+	return d.buf[:n]
+}
+
+func (d *decoder) uint8() uint8   { return d.read(1)[0] }
+func (d *decoder) uint16() uint16 { return binary.LittleEndian.Uint16(d.read(2)) }
+func (d *decoder) uint32() uint32 { return binary.LittleEndian.Uint32(d.read(4)) }
+func (d *decoder) uint64() uint64 { return binary.LittleEndian.Uint64(d.read(8)) }
+
+// count reads a length or count, 32 bits in version 1 and 64 bits after
+func (d *decoder) count() uint64 {
+	if d.v1 {
+		return uint64(d.uint32())
+	}
+	return d.uint64()
+}
+
+func (d *decoder) string() string {
+	n := d.count()
+	if d.err != nil {
+		return ""
+	}
+	if n > maxStringLength {
+		d.err = fmt.Errorf("string length %d is too long", n)
+		return ""
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		d.err = io.ErrUnexpectedEOF
+		return ""
+	}
+	return string(b)
+}
+
+func (d *decoder) value(t ValueType) any {
+	switch t {
+	case TypeUint8:
+		return d.uint8()
+	case TypeInt8:
+		return int8(d.uint8())
+	case TypeUint16:
+		return d.uint16()
+	case TypeInt16:
+		return int16(d.uint16())
+	case TypeUint32:
+		return d.uint32()
+	case TypeInt32:
+		return int32(d.uint32())
+	case TypeFloat32:
+		return math.Float32frombits(d.uint32())
+	case TypeBool:
+		return d.uint8() != 0
+	case TypeString:
+		return d.string()
+	case TypeUint64:
+		return d.uint64()
+	case TypeInt64:
+		return int64(d.uint64())
+	case TypeFloat64:
+		return math.Float64frombits(d.uint64())
+	case TypeArray:
+		elemType := ValueType(d.uint32())
+		n := d.count()
+		if elemType == TypeArray {
+			d.err = fmt.Errorf("nested arrays aren't supported")
+			return nil
+		}
+		array := Array{Type: elemType, Values: make([]any, 0, min(n, 1<<20))}
+		for i := uint64(0); i < n && d.err == nil; i++ {
+			array.Values = append(array.Values, d.value(elemType))
+		}
+		return array
+	}
+	if d.err == nil {
+		d.err = fmt.Errorf("unknown value type %d", t)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package gguf_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mipalgu/gollama/gguf"
+	"github.com/mipalgu/gollama/internal/ggufwrite"
+)
+
+func testMetadata() map[string]any {
+	return map[string]any{
+		"general.architecture":            "llama",
+		"general.name":                    "Test Model",
+		"general.file_type":               uint32(15),
+		"llama.context_length":            uint32(8192),
+		"llama.block_count":               uint32(32),
+		"llama.embedding_length":          uint32(4096),
+		"llama.feed_forward_length":       uint32(14336),
+		"llama.attention.head_count":      uint32(32),
+		"llama.attention.head_count_kv":   uint32(8),
+		"llama.rope.freq_base":            float32(500000),
+		"tokenizer.chat_template":         "{{ bos_token }}{% for m in messages %}{{ m.content }}{% endfor %}",
+		"tokenizer.ggml.tokens":           gguf.Array{Type: gguf.TypeString, Values: []any{"<s>", "</s>", "hello"}},
+		"tokenizer.ggml.eos_token_id":     uint32(1),
+		"tokenizer.ggml.add_bos_token":    true,
+		"tokenizer.ggml.token_type_count": int64(-1),
+	}
+}
+
+func testTensors() []gguf.TensorInfo {
+	return []gguf.TensorInfo{
+		{Name: "token_embd.weight", Dimensions: []uint64{4096, 3}, Type: 12},
+		{Name: "output_norm.weight", Dimensions: []uint64{4096}, Type: 0, Offset: 12288},
+	}
+}
+
+func TestReadRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := ggufwrite.Write(&buf, testMetadata(), testTensors()); err != nil {
+		t.Fatal(err)
+	}
+	// Tensor data follows the header in real files and must not be read
+	buf.WriteString(strings.Repeat("\x00", 1024))
+
+	file, err := gguf.Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Version != 3 {
+		t.Errorf("Version = %d, want 3", file.Version)
+	}
+	if !reflect.DeepEqual(file.Metadata, testMetadata()) {
+		t.Errorf("Metadata = %#v, want %#v", file.Metadata, testMetadata())
+	}
+	if !reflect.DeepEqual(file.Tensors, testTensors()) {
+		t.Errorf("Tensors = %#v, want %#v", file.Tensors, testTensors())
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	file := &gguf.File{Metadata: testMetadata(), Tensors: testTensors()}
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"Architecture", file.Architecture(), "llama"},
+		{"Name", file.Name(), "Test Model"},
+		{"ContextLength", file.ContextLength(), uint64(8192)},
+		{"BlockCount", file.BlockCount(), uint64(32)},
+		{"EmbeddingLength", file.EmbeddingLength(), uint64(4096)},
+		{"FeedForwardLength", file.FeedForwardLength(), uint64(14336)},
+		{"HeadCount", file.HeadCount(), uint64(32)},
+		{"HeadCountKV", file.HeadCountKV(), uint64(8)},
+		{"VocabSize", file.VocabSize(), uint64(3)},
+		{"ChatTemplate", file.ChatTemplate(), "{{ bos_token }}{% for m in messages %}{{ m.content }}{% endfor %}"},
+		{"TensorCount", file.TensorCount(), 2},
+		{"ParameterCount", file.ParameterCount(), uint64(4096*3 + 4096)},
+		{"IsProjector", file.IsProjector(), false},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	fileType, ok := file.FileType()
+	if !ok || fileType.String() != "Q4_K_M" {
+		t.Errorf("FileType = %v, %v, want Q4_K_M", fileType, ok)
+	}
+	if eos, ok := file.Token("tokenizer.ggml.eos_token_id"); !ok || eos != "</s>" {
+		t.Errorf("Token(eos) = %q, %v, want </s>", eos, ok)
+	}
+	if _, ok := file.Uint("tokenizer.ggml.token_type_count"); ok {
+		t.Error("Uint of a negative value should fail")
+	}
+}
+
+func TestPerLayerHeadCounts(t *testing.T) {
+	file := &gguf.File{Metadata: map[string]any{
+		"general.architecture":         "gemma3n",
+		"gemma3n.attention.head_count": gguf.Array{Type: gguf.TypeUint32, Values: []any{uint32(8), uint32(16), uint32(8)}},
+	}}
+	if got := file.HeadCount(); got != 16 {
+		t.Errorf("HeadCount = %d, want 16", got)
+	}
+	if got := file.HeadCountKV(); got != 16 {
+		t.Errorf("HeadCountKV without head_count_kv = %d, want HeadCount", got)
+	}
+}
+
+func TestIsProjector(t *testing.T) {
+	tests := []struct {
+		metadata map[string]any
+		want     bool
+	}{
+		{map[string]any{"general.architecture": "clip"}, true},
+		{map[string]any{"general.architecture": "clip", "general.type": "mmproj"}, true},
+		{map[string]any{"general.architecture": "llama", "general.type": "model"}, false},
+	}
+	for _, tt := range tests {
+		if got := (&gguf.File{Metadata: tt.metadata}).IsProjector(); got != tt.want {
+			t.Errorf("IsProjector(%v) = %v, want %v", tt.metadata, got, tt.want)
+		}
+	}
+}
+
+func TestReadVersion1(t *testing.T) {
+	// Version 1 uses 32-bit counts and string lengths
+	var buf bytes.Buffer
+	buf.WriteString(gguf.Magic)
+	le := binary.LittleEndian
+	buf.Write(le.AppendUint32(nil, 1))
+	buf.Write(le.AppendUint32(nil, 0))
+	buf.Write(le.AppendUint32(nil, 1))
+	buf.Write(le.AppendUint32(nil, uint32(len("general.architecture"))))
+	buf.WriteString("general.architecture")
+	buf.Write(le.AppendUint32(nil, uint32(gguf.TypeString)))
+	buf.Write(le.AppendUint32(nil, 5))
+	buf.WriteString("llama")
+
+	file, err := gguf.Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Architecture() != "llama" {
+		t.Errorf("Architecture = %q, want llama", file.Architecture())
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	var valid bytes.Buffer
+	if err := ggufwrite.Write(&valid, testMetadata(), testTensors()); err != nil {
+		t.Fatal(err)
+	}
+	bigEndian := []byte(gguf.Magic)
+	bigEndian = binary.BigEndian.AppendUint32(bigEndian, 3)
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"empty", nil, "reading magic"},
+		{"not gguf", []byte("PK\x03\x04 zip file"), "not a GGUF file"},
+		{"big-endian", bigEndian, "big-endian"},
+		{"future version", append([]byte(gguf.Magic), 9, 0, 0, 0), "unsupported GGUF version 9"},
+		{"truncated metadata", valid.Bytes()[:100], "unexpected EOF"},
+		{"truncated tensors", valid.Bytes()[:valid.Len()-4], "unexpected EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := gguf.Read(bytes.NewReader(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Read error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAndIsGGUF(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "model.gguf")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ggufwrite.Write(f, testMetadata(), nil); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	other := filepath.Join(dir, "README.md")
+	if err := os.WriteFile(other, []byte("# readme"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if !gguf.IsGGUF(path) || gguf.IsGGUF(other) || gguf.IsGGUF(filepath.Join(dir, "missing.gguf")) {
+		t.Error("IsGGUF only accepts files with the GGUF magic")
+	}
+	file, err := gguf.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Name() != "Test Model" {
+		t.Errorf("Name = %q, want Test Model", file.Name())
+	}
+	if _, err := gguf.Open(other); err == nil || !strings.Contains(err.Error(), other) {
+		t.Errorf("Open error = %v, want it to name the file", err)
+	}
+}
+
+func TestFileTypeString(t *testing.T) {
+	tests := map[gguf.FileType]string{0: "F32", 1: "F16", 7: "Q8_0", 15: "Q4_K_M", 30: "IQ4_XS", 32: "BF16", 4: "MXFP4", 38: "MXFP4", 5: "unknown(5)"}
+	for fileType, want := range tests {
+		if got := fileType.String(); got != want {
+			t.Errorf("FileType(%d).String() = %q, want %q", fileType, got, want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package gguf
+
+// Uint returns a metadata value as a uint64 if it's an unsigned or non-negative signed integer
+func (f *File) Uint(key string) (uint64, bool) {
+	return toUint(f.Metadata[key])
+}
+
+// String returns a metadata value if it's a string
+func (f *File) String(key string) (string, bool) {
+	s, ok := f.Metadata[key].(string)
+	return s, ok
+}
+
+// Strings returns a metadata value if it's an array of strings
+func (f *File) Strings(key string) ([]string, bool) {
+	array, ok := f.Metadata[key].(Array)
+	if !ok || array.Type != TypeString {
+		return nil, false
+	}
+	strings := make([]string, len(array.Values))
+	for i, v := range array.Values {
+		strings[i] = v.(string)
+	}
+	return strings, true
+}
+
+// Architecture is general.architecture, e.g. llama, qwen2 or clip for projectors
+func (f *File) Architecture() string {
+	arch, _ := f.String("general.architecture")
+	return arch
+}
+
+// Name is general.name, the model's display name if the converter recorded one
+func (f *File) Name() string {
+	name, _ := f.String("general.name")
+	return name
+}
+
+// archUint returns an architecture specific value, e.g. llama.context_length for "context_length"
+func (f *File) archUint(key string) uint64 {
+	n, _ := f.Uint(f.Architecture() + "." + key)
+	return n
+}
+
+// ContextLength is the context the model was trained with, 0 if it isn't recorded
+func (f *File) ContextLength() uint64 { return f.archUint("context_length") }
+
+// BlockCount is the number of layers
+func (f *File) BlockCount() uint64 { return f.archUint("block_count") }
+
+// EmbeddingLength is the hidden size
+func (f *File) EmbeddingLength() uint64 { return f.archUint("embedding_length") }
+
+// FeedForwardLength is the feed forward size, the largest one for models that vary it by layer
+func (f *File) FeedForwardLength() uint64 { return f.archMaxUint("feed_forward_length") }
+
+// HeadCount is the number of attention heads, the largest one for models that vary it by layer
+func (f *File) HeadCount() uint64 { return f.archMaxUint("attention.head_count") }
+
+// HeadCountKV is the number of key/value heads, falling back to HeadCount for models without grouped-query attention
+func (f *File) HeadCountKV() uint64 {
+	if n := f.archMaxUint("attention.head_count_kv"); n > 0 {
+		return n
+	}
+	return f.HeadCount()
+}
+
+// archMaxUint returns an architecture specific value that may be a per-layer array, as the largest element
+func (f *File) archMaxUint(key string) uint64 {
+	value := f.Metadata[f.Architecture()+"."+key]
+	if array, ok := value.(Array); ok {
+		var largest uint64
+		for _, v := range array.Values {
+			if n, ok := toUint(v); ok && n > largest {
+				largest = n
+			}
+		}
+		return largest
+	}
+	n, _ := toUint(value)
+	return n
+}
+
+// VocabSize is the vocabulary size, from the token list if the architecture doesn't record it
+func (f *File) VocabSize() uint64 {
+	if n := f.archUint("vocab_size"); n > 0 {
+		return n
+	}
+	if array, ok := f.Metadata["tokenizer.ggml.tokens"].(Array); ok {
+		return uint64(len(array.Values))
+	}
+	return 0
+}
+
+// FileType is general.file_type, false if the converter didn't record it
+func (f *File) FileType() (FileType, bool) {
+	n, ok := f.Uint("general.file_type")
+	return FileType(n), ok
+}
+
+// ChatTemplate is tokenizer.chat_template, the Jinja template the model was trained with
+func (f *File) ChatTemplate() string {
+	template, _ := f.String("tokenizer.chat_template")
+	return template
+}
+
+// Token returns the text of the token whose ID is stored under key, e.g. tokenizer.ggml.eos_token_id
+func (f *File) Token(key string) (string, bool) {
+	id, ok := f.Uint(key)
+	if !ok {
+		return "", false
+	}
+	array, ok := f.Metadata["tokenizer.ggml.tokens"].(Array)
+	if !ok || id >= uint64(len(array.Values)) {
+		return "", false
+	}
+	token, ok := array.Values[id].(string)
+	return token, ok
+}
+
+// TensorCount is the number of tensors in the file
+func (f *File) TensorCount() int { return len(f.Tensors) }
+
+// ParameterCount is general.parameter_count, or the number of tensor values if it isn't recorded
+func (f *File) ParameterCount() uint64 {
+	if n, ok := f.Uint("general.parameter_count"); ok && n > 0 {
+		return n
+	}
+	var n uint64
+	for _, tensor := range f.Tensors {
+		n += tensor.Elements()
+	}
+	return n
+}
+
+// IsProjector reports whether the file is a multimodal projector rather than a language model
+func (f *File) IsProjector() bool {
+	if kind, _ := f.String("general.type"); kind == "mmproj" {
+		return true
+	}
+	return f.Architecture() == "clip"
+}
+
+func toUint(v any) (uint64, bool) {
+	switch n := v.(type) {
+	case uint8:
+		return uint64(n), true
+	case uint16:
+		return uint64(n), true
+	case uint32:
+		return uint64(n), true
+	case uint64:
+		return n, true
+	case int8:
+		return uint64(n), n >= 0
+	case int16:
+		return uint64(n), n >= 0
+	case int32:
+		return uint64(n), n >= 0
+	case int64:
+		return uint64(n), n >= 0
+	}
+	return 0, false
+}
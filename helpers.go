@@ -4,11 +4,13 @@ package main
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/mipalgu/gollama/config"
 	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/ollamastore"
 	"github.com/mipalgu/gollama/styles"
 
 	"github.com/ollama/ollama/api"
@@ -23,7 +25,9 @@ func parseAPIResponse(resp *api.ListResponse) []Model {
 		models[i] = Model{
 			Name:              modelResp.Name,
 			ID:                truncate(modelResp.Digest, 7),
+			Digest:            modelResp.Digest,
 			Size:              float64(modelResp.Size) / (1024 * 1024 * 1024), // Convert bytes to GB
+			SizeBytes:         modelResp.Size,
 			QuantizationLevel: modelResp.Details.QuantizationLevel,
 			Family:            modelResp.Details.Family,
 			Modified:          modelResp.ModifiedAt,
@@ -34,6 +38,99 @@ func parseAPIResponse(resp *api.ListResponse) []Model {
 	return models
 }
 
+// applyDiskUsage sets the unique and shared sizes of the models from the manifests in modelsDir and returns
+// the total bytes on disk with shared blobs counted once. It reports false if the manifests couldn't be read.
+func applyDiskUsage(models []Model, modelsDir string) (int64, bool) {
+	if modelsDir == "" {
+		return 0, false
+	}
+	usage, err := ollamastore.New(modelsDir).DiskUsage()
+	if err != nil {
+		logging.DebugLogger.Printf("Not showing disk usage, couldn't read the models directory: %v\n", err)
+		return 0, false
+	}
+	for i, model := range models {
+		u, ok := usage.Models[model.Name]
+		// A model with a different digest is a different model with the same name, e.g. on a remote host
+		if !ok || u.Digest != model.Digest {
+			models[i].UniqueSize, models[i].SharedSize, models[i].DiskUsageKnown = 0, 0, false
+			continue
+		}
+		models[i].UniqueSize, models[i].SharedSize, models[i].DiskUsageKnown = u.Unique, u.Shared, true
+	}
+	return usage.Total, true
+}
+
+// hasDiskUsage reports whether applyDiskUsage found any of the models in the local manifests
+func hasDiskUsage(models []Model) bool {
+	for _, model := range models {
+		if model.DiskUsageKnown {
+			return true
+		}
+	}
+	return false
+}
+
+// diskUsageSummary compares the true disk usage with the listed sizes, which count shared layers once per model
+func diskUsageSummary(models []Model, diskTotal int64) string {
+	var listed int64
+	for _, model := range models {
+		listed += model.SizeBytes
+	}
+	return fmt.Sprintf("Total: %.2fGB on disk for %d models (listed sizes add up to %.2fGB)", bytesToGB(diskTotal), len(models), bytesToGB(listed))
+}
+
+// bytesToGB converts bytes to the GB figures shown in the model list
+func bytesToGB(size int64) float64 {
+	return float64(size) / (1024 * 1024 * 1024)
+}
+
+// listTitle is the title of the TUI model list, naming the host it's connected to and including the true disk usage
+// when it's known
+func listTitle(host string, diskTotal int64, diskUsageKnown bool) string {
+	title := fmt.Sprintf("Ollama Models - Connected to %s", host)
+	if diskUsageKnown {
+		title += fmt.Sprintf(" - %.2fGB on disk", bytesToGB(diskTotal))
+	}
+	return title
+}
+
+// sortModels sorts the models in the order the TUI was last sorted in, leaving them as they are for orders it
+// doesn't start in
+func sortModels(models []Model, order string) {
+	switch order {
+	case "name":
+		sort.Slice(models, func(i, j int) bool {
+			return models[i].Name < models[j].Name
+		})
+	case "size":
+		sort.Slice(models, func(i, j int) bool {
+			return models[i].Size > models[j].Size
+		})
+	case "modified":
+		sort.Slice(models, func(i, j int) bool {
+			return models[i].Modified.After(models[j].Modified)
+		})
+	case "family":
+		sort.Slice(models, func(i, j int) bool {
+			return models[i].Family < models[j].Family
+		})
+	}
+}
+
+// formatSize formats a size in bytes for messages, using GB like the model list for anything large
+func formatSize(size int64) string {
+	switch {
+	case size >= 1024*1024*1024:
+		return fmt.Sprintf("%.2fGB", bytesToGB(size))
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.2fMB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.2fKB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%dB", size)
+}
+
 func normalizeSize(size float64) float64 {
 	return size // Sizes are already in GB in the API response
 }
@@ -135,7 +232,17 @@ func calculateColumnWidthsTerminal() (nameWidth, sizeWidth, quantWidth, modified
 	return calculateColumnWidths(width)
 }
 
-func listModels(models []Model) {
+func listModels(models []Model, format OutputFormat) {
+	// Machine-readable formats and piped output are printed without styling
+	if format != OutputText || !stdoutIsTerminal() {
+		if err := printModelsPlain(models, format); err != nil {
+			logging.ErrorLogger.Printf("Error writing model list: %v\n", err)
+			fmt.Fprintln(os.Stderr, "Error writing model list:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// read the config file to see if the user wants to strip a string from the model name
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -155,10 +262,18 @@ func listModels(models []Model) {
 	colSpacing := 2
 	longestNameAllowed := 60
 
+	// The unique and shared columns are only shown when the local manifests could be read
+	showUsage := hasDiskUsage(models)
+	usageHeader := ""
+	if showUsage {
+		usageHeader = fmt.Sprintf("%-*s%-*s", sizeWidth+colSpacing, "Unique", sizeWidth+colSpacing, "Shared")
+	}
+
 	// Create the header with proper padding and alignment
-	header := fmt.Sprintf("%-*s%-*s%-*s%-*s%-*s%-*s%-*s",
+	header := fmt.Sprintf("%-*s%-*s%s%-*s%-*s%-*s%-*s%-*s",
 		nameWidth, "Name",
 		sizeWidth+colSpacing, "Size",
+		usageHeader,
 		paramSizeWidth+colSpacing, "Params",
 		quantWidth+colSpacing, "Quant",
 		familyWidth+colSpacing, "Family",
@@ -173,7 +288,7 @@ func listModels(models []Model) {
 	}
 
 	// Prepare columns for padding
-	var names, sizes, quants, families, modified, ids, paramSizes []string
+	var names, sizes, uniqueSizes, sharedSizes, quants, families, modified, ids, paramSizes []string
 	var longestName int
 	for _, model := range models {
 		if len(model.Name) > longestName {
@@ -185,6 +300,8 @@ func listModels(models []Model) {
 		}
 		names = append(names, model.Name)
 		sizes = append(sizes, fmt.Sprintf("%.2fGB", model.Size))
+		uniqueSizes = append(uniqueSizes, fmt.Sprintf("%.2fGB", bytesToGB(model.UniqueSize)))
+		sharedSizes = append(sharedSizes, fmt.Sprintf("%.2fGB", bytesToGB(model.SharedSize)))
 		paramSizes = append(paramSizes, model.ParameterSize)
 		quants = append(quants, model.QuantizationLevel)
 		families = append(families, model.Family)
@@ -205,6 +322,8 @@ func listModels(models []Model) {
 	for i := range names {
 		names[i] = fmt.Sprintf("%-*s", maxNameWidth, names[i])
 		sizes[i] = fmt.Sprintf("%-*s", maxSizeWidth, sizes[i])
+		uniqueSizes[i] = fmt.Sprintf("%-*s", maxSizeWidth, uniqueSizes[i])
+		sharedSizes[i] = fmt.Sprintf("%-*s", maxSizeWidth, sharedSizes[i])
 		paramSizes[i] = fmt.Sprintf("%-*s", maxParamSizeWidth, paramSizes[i])
 		quants[i] = fmt.Sprintf("%-*s", maxQuantWidth, quants[i])
 		families[i] = fmt.Sprintf("%-*s", maxFamilyWidth, families[i])
@@ -213,9 +332,10 @@ func listModels(models []Model) {
 		if longestName > longestNameAllowed {
 			ids[i] = ""
 			// remove the ID header
-			header = fmt.Sprintf("%-*s%-*s%-*s%-*s%-*s%-*s",
+			header = fmt.Sprintf("%-*s%-*s%s%-*s%-*s%-*s%-*s",
 				nameWidth, "Name",
 				sizeWidth+colSpacing, "Size",
+				usageHeader,
 				paramSizeWidth+colSpacing, "Params",
 				quantWidth+colSpacing, "Quant",
 				familyWidth+colSpacing, "Family",
@@ -234,6 +354,10 @@ func listModels(models []Model) {
 		name := styles.ItemNameStyle(index).Render(names[index])
 		id := styles.ItemIDStyle().Render(ids[index])
 		size := styles.SizeStyle(model.Size).Render(sizes[index])
+		if showUsage {
+			size += styles.SizeStyle(bytesToGB(model.UniqueSize)).Render(uniqueSizes[index])
+			size += styles.ItemIDStyle().Render(sharedSizes[index])
+		}
 		// Apply direct color based on parameter size
 		var paramSize string
 		if paramSizes[index] != "" {
@@ -0,0 +1,150 @@
+// Package ggufwrite writes GGUF headers for tests that need model files to read, so the gguf package itself only
+// reads them.
+package ggufwrite
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/mipalgu/gollama/gguf"
+)
+
+// Write writes a version 3 GGUF header with the metadata and tensor descriptions, in sorted key order. Values
+// must be the Go types gguf.Read returns. Tensor data isn't written, so the result is only useful for reading
+// metadata back.
+func Write(w io.Writer, metadata map[string]any, tensors []gguf.TensorInfo) error {
+	bw := bufio.NewWriter(w)
+	e := &encoder{w: bw}
+	e.w.WriteString(gguf.Magic)
+	e.uint32(3)
+	e.uint64(uint64(len(tensors)))
+	e.uint64(uint64(len(metadata)))
+
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		e.string(key)
+		e.value(metadata[key], true)
+		if e.err != nil {
+			return fmt.Errorf("writing metadata %q: %w", key, e.err)
+		}
+	}
+
+	for _, tensor := range tensors {
+		e.string(tensor.Name)
+		e.uint32(uint32(len(tensor.Dimensions)))
+		for _, d := range tensor.Dimensions {
+			e.uint64(d)
+		}
+		e.uint32(tensor.Type)
+		e.uint64(tensor.Offset)
+	}
+	if e.err != nil {
+		return e.err
+	}
+	return bw.Flush()
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (e *encoder) uint32(n uint32) { e.w.Write(binary.LittleEndian.AppendUint32(nil, n)) }
+func (e *encoder) uint64(n uint64) { e.w.Write(binary.LittleEndian.AppendUint64(nil, n)) }
+
+func (e *encoder) string(s string) {
+	e.uint64(uint64(len(s)))
+	e.w.WriteString(s)
+}
+
+// value writes v, preceded by its type when withType is set, array elements share the array's type
+func (e *encoder) value(v any, withType bool) {
+	t, ok := valueType(v)
+	if !ok {
+		e.err = fmt.Errorf("unsupported value type %T", v)
+		return
+	}
+	if withType {
+		e.uint32(uint32(t))
+	}
+	switch n := v.(type) {
+	case uint8:
+		e.w.WriteByte(n)
+	case int8:
+		e.w.WriteByte(byte(n))
+	case uint16:
+		e.w.Write(binary.LittleEndian.AppendUint16(nil, n))
+	case int16:
+		e.w.Write(binary.LittleEndian.AppendUint16(nil, uint16(n)))
+	case uint32:
+		e.uint32(n)
+	case int32:
+		e.uint32(uint32(n))
+	case float32:
+		e.uint32(math.Float32bits(n))
+	case bool:
+		if n {
+			e.w.WriteByte(1)
+		} else {
+			e.w.WriteByte(0)
+		}
+	case string:
+		e.string(n)
+	case uint64:
+		e.uint64(n)
+	case int64:
+		e.uint64(uint64(n))
+	case float64:
+		e.uint64(math.Float64bits(n))
+	case gguf.Array:
+		e.uint32(uint32(n.Type))
+		e.uint64(uint64(len(n.Values)))
+		for _, elem := range n.Values {
+			if t, _ := valueType(elem); t != n.Type {
+				e.err = fmt.Errorf("array of type %d contains a %T", n.Type, elem)
+				return
+			}
+			e.value(elem, false)
+		}
+	}
+}
+
+func valueType(v any) (gguf.ValueType, bool) {
+	switch v.(type) {
+	case uint8:
+		return gguf.TypeUint8, true
+	case int8:
+		return gguf.TypeInt8, true
+	case uint16:
+		return gguf.TypeUint16, true
+	case int16:
+		return gguf.TypeInt16, true
+	case uint32:
+		return gguf.TypeUint32, true
+	case int32:
+		return gguf.TypeInt32, true
+	case float32:
+		return gguf.TypeFloat32, true
+	case bool:
+		return gguf.TypeBool, true
+	case string:
+		return gguf.TypeString, true
+	case gguf.Array:
+		return gguf.TypeArray, true
+	case uint64:
+		return gguf.TypeUint64, true
+	case int64:
+		return gguf.TypeInt64, true
+	case float64:
+		return gguf.TypeFloat64, true
+	}
+	return 0, false
+}
@@ -60,15 +60,16 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, item list.Ite
 		return
 	}
 
-	// If StripString is set in the config, strip it from the model name
-	if d.appModel.cfg.StripString != "" {
-		model.Name = strings.Replace(model.Name, d.appModel.cfg.StripString, "", 1)
+	// If StripString is set in the config, or the connection of the model's host, strip it from the model name
+	if stripString := d.appModel.stripString(model); stripString != "" {
+		model.Name = strings.Replace(model.Name, stripString, "", 1)
 	}
 
 	nameStyle := styles.ItemNameStyle(index)
 	dateStyle := styles.ItemDateStyle()
 	shaStyle := styles.ItemShaStyle()
 	sizeStyle := styles.SizeStyle(model.Size)
+	uniqueStyle := styles.SizeStyle(bytesToGB(model.UniqueSize))
 	familyStyle := styles.FamilyStyle(model.Family)
 	quantStyle := styles.QuantStyle(model.QuantizationLevel)
 	modifiedStyle := styles.ItemDateStyle() // Use date style for modified date
@@ -77,6 +78,7 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, item list.Ite
 		// Apply border and highlight styles for selected item
 		nameStyle = nameStyle.Bold(true).BorderLeft(true).BorderStyle(lipgloss.InnerHalfBlockBorder()).BorderForeground(styles.GetTheme().GetColour(styles.GetTheme().Colours.ItemBorder)).PaddingLeft(1)
 		sizeStyle = sizeStyle.Bold(true).BorderLeft(true).PaddingLeft(-2).PaddingRight(-2)
+		uniqueStyle = uniqueStyle.Bold(true).BorderLeft(true).PaddingLeft(-2).PaddingRight(-2)
 		quantStyle = quantStyle.Bold(true).BorderLeft(true).PaddingLeft(-2).PaddingRight(-2)
 		familyStyle = familyStyle.Bold(true).BorderLeft(true).PaddingLeft(-2).PaddingRight(-2)
 		modifiedStyle = modifiedStyle.Bold(true).BorderLeft(true).PaddingLeft(-2).PaddingRight(-2)
@@ -105,6 +107,7 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, item list.Ite
 		shaStyle = selectedStyle.Inherit(shaStyle)
 		dateStyle = selectedStyle.Inherit(dateStyle)
 		sizeStyle = selectedStyle.Inherit(sizeStyle)
+		uniqueStyle = selectedStyle.Inherit(uniqueStyle)
 		familyStyle = selectedStyle.Inherit(familyStyle)
 		quantStyle = selectedStyle.Inherit(quantStyle)
 	}
@@ -114,7 +117,14 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, item list.Ite
 	// Ensure the text fits within the terminal width
 	// Add consistent padding between columns
 	padding := 2
-	name := nameStyle.Width(nameWidth).Render(truncate(model.Name, nameWidth-padding))
+	// The all hosts view has a column for the host, taken out of the name's width
+	host := ""
+	if model.Host != "" {
+		hostWidth := min(d.appModel.hostColumnWidth(), nameWidth/2)
+		nameWidth -= hostWidth
+		host = dateStyle.Width(hostWidth).Render(fmt.Sprintf("%-*s", hostWidth-padding, truncate(model.Host, hostWidth-padding)))
+	}
+	name := nameStyle.Width(nameWidth).Render(truncate(model.Name, nameWidth-padding)) + host
 	size := sizeStyle.Width(sizeWidth).Render(fmt.Sprintf("%*.2fGB", sizeWidth-padding-2, model.Size))
 	paramSize := styles.ParamSizeStyle(model.ParameterSize).Width(paramSizeWidth).Render(fmt.Sprintf("%-*s", paramSizeWidth-padding, model.ParameterSize))
 	quant := quantStyle.Width(quantWidth).Render(fmt.Sprintf("%-*s", quantWidth-padding, model.QuantizationLevel))
@@ -124,6 +134,12 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, item list.Ite
 
 	// Add padding between columns
 	spacer := strings.Repeat(" ", padding)
+	// The unique size is labelled as the list has no header, it's left out when the manifests couldn't be read
+	if d.appModel.diskUsageKnown {
+		uniqueWidth := sizeWidth + len(" unique")
+		unique := uniqueStyle.Width(uniqueWidth).Render(fmt.Sprintf("%*.2fGB unique", sizeWidth-padding-2, bytesToGB(model.UniqueSize)))
+		size += spacer + unique
+	}
 	row := fmt.Sprintf("%s%s%s%s%s%s%s%s%s%s%s%s%s",
 		name, spacer, size, spacer, paramSize, spacer, quant, spacer, family, spacer, modified, spacer, id)
 
@@ -0,0 +1,251 @@
+// Package jan writes the model.json files Jan reads from its models directory, models/<id>/model.json, so the
+// GGUF files gollama links there show up as Jan models with their Ollama parameters.
+package jan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/ollama/ollama/api"
+
+	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/modelfile"
+)
+
+// DefaultContextLength is the context Ollama gives models that don't set num_ctx, Jan models get the same
+const DefaultContextLength = 4096
+
+// ModelFileName is the name of the file describing a model in its directory
+const ModelFileName = "model.json"
+
+// Model is a Jan model.json
+type Model struct {
+	Sources     []Source   `json:"sources"`
+	ID          string     `json:"id"`
+	Object      string     `json:"object"`
+	Name        string     `json:"name"`
+	Version     string     `json:"version"`
+	Description string     `json:"description"`
+	Format      string     `json:"format"`
+	Settings    Settings   `json:"settings"`
+	Parameters  Parameters `json:"parameters"`
+	Metadata    Metadata   `json:"metadata"`
+	Engine      string     `json:"engine"`
+}
+
+// Source is a file the model is made of, relative to the model's directory
+type Source struct {
+	Filename string `json:"filename"`
+	URL      string `json:"url"`
+}
+
+// Settings are how Jan loads the model
+type Settings struct {
+	CtxLen         int    `json:"ctx_len"`
+	PromptTemplate string `json:"prompt_template,omitempty"`
+	LlamaModelPath string `json:"llama_model_path"`
+	Mmproj         string `json:"mmproj,omitempty"`
+	NGL            *int   `json:"ngl,omitempty"`
+	CPUThreads     *int   `json:"cpu_threads,omitempty"`
+}
+
+// Parameters are the model's inference parameters
+type Parameters struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	TopK             *int     `json:"top_k,omitempty"`
+	MinP             *float64 `json:"min_p,omitempty"`
+	RepeatPenalty    *float64 `json:"repeat_penalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+	MaxTokens        *int     `json:"max_tokens,omitempty"`
+	Stop             []string `json:"stop"`
+	Stream           bool     `json:"stream"`
+}
+
+// Metadata describes the model in Jan's hub
+type Metadata struct {
+	Author string   `json:"author"`
+	Tags   []string `json:"tags"`
+	Size   int64    `json:"size"`
+}
+
+// NewModel builds the model.json for an Ollama model from its Modelfile parameters. id is the model's directory
+// name, modelFile and projectorFile are the GGUF files in it, projectorFile is "" if there isn't one. Jan uses the
+// chat template in the GGUF file, so the Modelfile's TEMPLATE isn't converted. It also returns the parameters that
+// have no Jan equivalent.
+func NewModel(id, modelName, author, modelFile, projectorFile string, parameters map[string][]string) (*Model, []string) {
+	model := &Model{
+		Sources:     []Source{{Filename: modelFile, URL: ""}},
+		ID:          id,
+		Object:      "model",
+		Name:        modelName,
+		Version:     "1.0",
+		Description: fmt.Sprintf("%s, linked from Ollama by gollama", modelName),
+		Format:      "gguf",
+		Settings: Settings{
+			CtxLen:         DefaultContextLength,
+			LlamaModelPath: modelFile,
+			Mmproj:         projectorFile,
+		},
+		Parameters: Parameters{Stop: []string{}, Stream: true},
+		Metadata:   Metadata{Author: author, Tags: []string{"Ollama"}},
+		Engine:     "llama-cpp",
+	}
+	if projectorFile != "" {
+		model.Sources = append(model.Sources, Source{Filename: projectorFile, URL: ""})
+	}
+
+	var unmapped []string
+	for name, values := range parameters {
+		if len(values) == 0 {
+			continue
+		}
+		if name == "stop" {
+			model.Parameters.Stop = append(model.Parameters.Stop, values...)
+			continue
+		}
+		// As in Ollama the last value of a repeated parameter wins
+		if !setParameter(model, name, values[len(values)-1]) {
+			unmapped = append(unmapped, name)
+		}
+	}
+	sort.Strings(unmapped)
+	return model, unmapped
+}
+
+// setParameter sets the Jan setting or parameter for a Modelfile parameter, reporting false if there isn't one or
+// the value isn't valid
+func setParameter(model *Model, name, value string) bool {
+	switch name {
+	case "num_ctx":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return false
+		}
+		model.Settings.CtxLen = n
+		return true
+	case "num_gpu":
+		return setInt(&model.Settings.NGL, value)
+	case "num_thread":
+		return setInt(&model.Settings.CPUThreads, value)
+	case "num_predict":
+		return setInt(&model.Parameters.MaxTokens, value)
+	case "top_k":
+		return setInt(&model.Parameters.TopK, value)
+	case "seed":
+		return setInt(&model.Parameters.Seed, value)
+	case "temperature":
+		return setFloat(&model.Parameters.Temperature, value)
+	case "top_p":
+		return setFloat(&model.Parameters.TopP, value)
+	case "min_p":
+		return setFloat(&model.Parameters.MinP, value)
+	case "repeat_penalty":
+		return setFloat(&model.Parameters.RepeatPenalty, value)
+	case "frequency_penalty":
+		return setFloat(&model.Parameters.FrequencyPenalty, value)
+	case "presence_penalty":
+		return setFloat(&model.Parameters.PresencePenalty, value)
+	}
+	return false
+}
+
+func setInt(field **int, value string) bool {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return false
+	}
+	*field = &n
+	return true
+}
+
+func setFloat(field **float64, value string) bool {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false
+	}
+	*field = &f
+	return true
+}
+
+// showModelfile returns an Ollama model's Modelfile, empty if it can't be parsed
+func showModelfile(modelName string, client *api.Client) (*modelfile.Modelfile, error) {
+	resp, err := client.Show(context.Background(), &api.ShowRequest{Name: modelName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get model info: %w", err)
+	}
+	parsed := &modelfile.Modelfile{}
+	if resp.Modelfile != "" {
+		if parsed, err = modelfile.Parse(resp.Modelfile); err != nil {
+			logging.ErrorLogger.Printf("Warning: Failed to parse Modelfile for %s: %v\n", modelName, err)
+			parsed = &modelfile.Modelfile{}
+		}
+	}
+	return parsed, nil
+}
+
+// ExportModel writes the model.json for an Ollama model linked into dir, a directory in Jan's models directory.
+// modelFile and projectorFile are the names of the linked files in dir. It returns the Modelfile parameters that
+// have no Jan equivalent.
+func ExportModel(modelName, author, dir, modelFile, projectorFile string, client *api.Client) ([]string, error) {
+	parsed, err := showModelfile(modelName, client)
+	if err != nil {
+		return nil, err
+	}
+
+	model, unmapped := NewModel(filepath.Base(dir), modelName, author, modelFile, projectorFile, parsed.ParameterMap())
+	if info, err := os.Stat(filepath.Join(dir, modelFile)); err == nil {
+		model.Metadata.Size = info.Size()
+	}
+	return unmapped, WriteModel(model, filepath.Join(dir, ModelFileName))
+}
+
+// ExportModelPreset writes the model.json for an Ollama model into dir, a directory in Jan's models directory,
+// loading the model from modelPath and projectorPath, usually its Ollama blobs, where they are rather than from
+// files linked into dir. Jan lists the model as displayName. It returns the Modelfile parameters that have no Jan
+// equivalent, and SYSTEM if the Modelfile has a system prompt as Jan keeps those in its assistants rather than
+// model.json.
+func ExportModelPreset(modelName, displayName, author, dir, modelPath, projectorPath string, client *api.Client) ([]string, error) {
+	parsed, err := showModelfile(modelName, client)
+	if err != nil {
+		return nil, err
+	}
+
+	model, unmapped := NewModel(filepath.Base(dir), modelName, author, modelPath, projectorPath, parsed.ParameterMap())
+	model.Name = displayName
+	model.Description = fmt.Sprintf("%s from Ollama, exported by gollama", modelName)
+	// Jan loads absolute paths in place, the file name is only shown
+	for i, source := range model.Sources {
+		model.Sources[i] = Source{Filename: filepath.Base(source.Filename), URL: source.Filename}
+	}
+	if info, err := os.Stat(modelPath); err == nil {
+		model.Metadata.Size = info.Size()
+	}
+	if system, ok := parsed.System(); ok && system != "" {
+		unmapped = append(unmapped, "SYSTEM")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	return unmapped, WriteModel(model, filepath.Join(dir, ModelFileName))
+}
+
+// WriteModel writes a model.json
+func WriteModel(model *Model, path string) error {
+	data, err := json.MarshalIndent(model, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal model: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package jan
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ollama/ollama/api"
+)
+
+func TestNewModel(t *testing.T) {
+	parameters := map[string][]string{
+		"num_ctx":     {"16384"},
+		"num_gpu":     {"0"},
+		"temperature": {"0.7"},
+		"top_k":       {"40"},
+		"num_predict": {"512"},
+		"stop":        {"<|im_start|>", "<|im_end|>"},
+		"mirostat":    {"2"},
+		"top_p":       {"high"},
+	}
+	model, unmapped := NewModel("qwen3-8b", "qwen3:8b", "registry.ollama.ai", "qwen3-8b.gguf", "", parameters)
+
+	if model.ID != "qwen3-8b" || model.Name != "qwen3:8b" || model.Settings.LlamaModelPath != "qwen3-8b.gguf" || len(model.Sources) != 1 {
+		t.Errorf("Unexpected model %+v", model)
+	}
+	if model.Settings.CtxLen != 16384 || model.Settings.NGL == nil || *model.Settings.NGL != 0 {
+		t.Errorf("Unexpected settings %+v", model.Settings)
+	}
+	p := model.Parameters
+	if p.Temperature == nil || *p.Temperature != 0.7 || p.TopK == nil || *p.TopK != 40 || p.MaxTokens == nil || *p.MaxTokens != 512 || p.TopP != nil {
+		t.Errorf("Unexpected parameters %+v", p)
+	}
+	if !reflect.DeepEqual(p.Stop, []string{"<|im_start|>", "<|im_end|>"}) {
+		t.Errorf("Stop = %v", p.Stop)
+	}
+	if !reflect.DeepEqual(unmapped, []string{"mirostat", "top_p"}) {
+		t.Errorf("unmapped = %v, want [mirostat top_p]", unmapped)
+	}
+
+	// The last value of a repeated parameter wins
+	model, _ = NewModel("qwen3-8b", "qwen3:8b", "registry.ollama.ai", "qwen3-8b.gguf", "", map[string][]string{"temperature": {"0.7", "0.2"}})
+	if model.Parameters.Temperature == nil || *model.Parameters.Temperature != 0.2 {
+		t.Errorf("Expected the last temperature, got %v", model.Parameters.Temperature)
+	}
+
+	// Models without parameters get Ollama's default context and an empty stop list rather than null
+	model, _ = NewModel("llava", "llava:latest", "registry.ollama.ai", "llava.gguf", "mmproj-llava.gguf", nil)
+	if model.Settings.CtxLen != DefaultContextLength || model.Settings.Mmproj != "mmproj-llava.gguf" || len(model.Sources) != 2 {
+		t.Errorf("Unexpected model %+v", model)
+	}
+	path := filepath.Join(t.TempDir(), ModelFileName)
+	if err := WriteModel(model, path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written map[string]any
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatal(err)
+	}
+	if stop := written["parameters"].(map[string]any)["stop"]; stop == nil {
+		t.Error("Expected stop to be written as an empty list")
+	}
+}
+
+func TestExportModelPreset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(api.ShowResponse{Modelfile: "FROM /blobs/sha256-model\nSYSTEM \"You are terse.\"\nPARAMETER temperature 0.2\n"})
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	client := api.NewClient(serverURL, server.Client())
+
+	dir := filepath.Join(t.TempDir(), "qwen3-8b")
+	unmapped, err := ExportModelPreset("qwen3:8b", "Terse Qwen", "registry.ollama.ai", dir, "/blobs/sha256-model", "", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Jan has nowhere to put the system prompt
+	if !reflect.DeepEqual(unmapped, []string{"SYSTEM"}) {
+		t.Errorf("unmapped = %v, want [SYSTEM]", unmapped)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ModelFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var model Model
+	if err := json.Unmarshal(data, &model); err != nil {
+		t.Fatal(err)
+	}
+	if model.ID != "qwen3-8b" || model.Name != "Terse Qwen" || model.Settings.LlamaModelPath != "/blobs/sha256-model" {
+		t.Errorf("Unexpected model %+v", model)
+	}
+	if model.Sources[0] != (Source{Filename: "sha256-model", URL: "/blobs/sha256-model"}) {
+		t.Errorf("Unexpected sources %+v", model.Sources)
+	}
+	if model.Parameters.Temperature == nil || *model.Parameters.Temperature != 0.2 {
+		t.Errorf("Unexpected parameters %+v", model.Parameters)
+	}
+}
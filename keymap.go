@@ -34,6 +34,8 @@ type KeyMap struct {
 	Help             key.Binding
 	RenameModel      key.Binding
 	PullNewModel     key.Binding
+	SpitModel        key.Binding
+	SwitchHost       key.Binding
 	SortOrder        string
 }
 
@@ -69,6 +71,8 @@ func NewKeyMap() *KeyMap {
 		SortByParamSize:  key.NewBinding(key.WithKeys("B"), key.WithHelp("B", "^params")),
 		SortByQuant:      key.NewBinding(key.WithKeys("K"), key.WithHelp("K", "^quant")),
 		SortBySize:       key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "^size")),
+		SpitModel:        key.NewBinding(key.WithKeys("S"), key.WithHelp("S", "spit to remote")),
+		SwitchHost:       key.NewBinding(key.WithKeys("H"), key.WithHelp("H", "switch host")),
 		Top:              key.NewBinding(key.WithKeys("t"), key.WithHelp("t", "top")),
 		UnloadModels:     key.NewBinding(key.WithKeys("U"), key.WithHelp("U", "unload all")),
 	}
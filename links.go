@@ -0,0 +1,450 @@
+// links.go contains the `gollama links` command and the helpers that keep the link registry in step with the links on disk.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ollama/ollama/api"
+
+	"github.com/mipalgu/gollama/config"
+	"github.com/mipalgu/gollama/links"
+	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/styles"
+)
+
+// loadLinkRegistry reads the link registry, returning nil if it can't be read so callers fall back to
+// recognising links by their paths rather than acting on a registry they can't trust
+func loadLinkRegistry() *links.Registry {
+	reg, err := links.Load()
+	if err != nil {
+		logging.ErrorLogger.Printf("Warning: %v, only links recognised by their paths will be managed\n", err)
+		return nil
+	}
+	return reg
+}
+
+// managedLink reports whether gollama created the link at path. Links in the registry are managed unless
+// something else has replaced them, symlinks made before the registry existed are recognised by isGollamaSymlink.
+func managedLink(reg *links.Registry, path, lmStudioModelsDir string) bool {
+	if reg != nil {
+		if link, ok := reg.Get(path); ok {
+			return link.Check() != links.Replaced
+		}
+	}
+	return isGollamaSymlink(path, lmStudioModelsDir)
+}
+
+// existingLink checks what's at path before placing target there with strategy, reporting true if it's already
+// there. An outdated link gollama manages, including one placed with another strategy, or a symlink pointing into
+// the same blobs directory as target, is removed so it can be replaced. Anything else at the path belongs to
+// someone else and is an error.
+func existingLink(reg *links.Registry, path, target, lmStudioModelsDir string, strategy links.Strategy, dryRun bool) (bool, error) {
+	if _, err := os.Lstat(path); err != nil {
+		return false, nil
+	}
+	recorded, isRecorded := recordedLink(reg, path)
+	if isRecorded && recorded.Check() == links.OK && filepath.Clean(recorded.Source) == filepath.Clean(target) && recorded.Strategy.Satisfies(strategy) {
+		return true, nil
+	}
+	linkTarget, err := os.Readlink(path)
+	// Symlinks made before the registry existed
+	if !isRecorded && strategy == links.Symlink && err == nil && filepath.Clean(linkTarget) == filepath.Clean(target) && isValidSymlink(path, target) {
+		return true, nil
+	}
+	blobLink := err == nil && filepath.Dir(filepath.Clean(linkTarget)) == filepath.Dir(filepath.Clean(target))
+	if !blobLink && !managedLink(reg, path, lmStudioModelsDir) {
+		return false, fmt.Errorf("%s already exists and wasn't created by gollama, not replacing it", path)
+	}
+	if dryRun {
+		logging.InfoLogger.Printf("[DRY RUN] Would replace outdated link %s\n", path)
+		return false, nil
+	}
+	if err := os.Remove(path); err != nil {
+		return false, fmt.Errorf("failed to remove outdated link %s: %v", path, err)
+	}
+	logging.InfoLogger.Printf("Removed outdated link %s\n", path)
+	return false, nil
+}
+
+// recordLinks records the model's links into a target of the given kind, projPath is empty if there's no projector
+// link. placed has the strategy of each link placed just now, links that were already in place keep their recorded
+// strategy. exportConfig is remembered for the model's links once set, so relinking them exports the config again.
+func recordLinks(kind links.Kind, modelName string, modelFiles ModelFiles, mainPath, projPath string, placed map[string]links.Strategy, exportConfig bool) {
+	recorded := []links.Link{{
+		Kind:         kind,
+		Model:        modelName,
+		Digest:       blobDigest(modelFiles.MainModel),
+		Source:       modelFiles.MainModel,
+		Path:         mainPath,
+		ExportConfig: exportConfig,
+	}}
+	if modelFiles.Projector != "" && projPath != "" {
+		recorded = append(recorded, links.Link{
+			Kind:         kind,
+			Model:        modelName,
+			Digest:       blobDigest(modelFiles.Projector),
+			Source:       modelFiles.Projector,
+			Path:         projPath,
+			Projector:    true,
+			ExportConfig: exportConfig,
+		})
+	}
+
+	err := links.Update(func(reg *links.Registry) error {
+		for _, link := range recorded {
+			previous, hasPrevious := reg.Get(link.Path)
+			if strategy, ok := placed[link.Path]; ok {
+				link.Strategy = strategy
+				if err := link.Snapshot(); err != nil {
+					logging.ErrorLogger.Printf("Warning: %v\n", err)
+					continue
+				}
+			} else if hasPrevious && filepath.Clean(previous.Source) == filepath.Clean(link.Source) {
+				link.Strategy, link.Size, link.ModTime = previous.Strategy, previous.Size, previous.ModTime
+			} else {
+				// Links made before the registry existed are symlinks
+				link.Strategy = links.Symlink
+			}
+			// Only links that are in place are recorded, e.g. a projector link may have failed
+			if link.Check() != links.OK {
+				continue
+			}
+			if hasPrevious && previous.Model == modelName {
+				link.ExportConfig = link.ExportConfig || previous.ExportConfig
+			}
+			reg.Add(link)
+		}
+		return nil
+	})
+	if err != nil {
+		logging.ErrorLogger.Printf("Warning: failed to record the links for %s: %v\n", modelName, err)
+	}
+}
+
+// recordedLink returns the link from Ollama recorded at path, reg may be nil
+func recordedLink(reg *links.Registry, path string) (links.Link, bool) {
+	if reg == nil {
+		return links.Link{}, false
+	}
+	link, ok := reg.Get(path)
+	return link, ok && link.Kind.FromOllama()
+}
+
+// reconcileLinks forgets removed links, and recorded links from Ollama in modelsDir that have since been deleted or
+// replaced by something else
+func reconcileLinks(modelsDir string, removed []string) {
+	err := links.Update(func(reg *links.Registry) error {
+		for _, path := range removed {
+			reg.Remove(path)
+		}
+		for _, link := range reg.Links() {
+			if !link.Kind.FromOllama() || !withinDir(link.Path, modelsDir) {
+				continue
+			}
+			if status := link.Check(); status == links.Missing || status == links.Replaced {
+				logging.DebugLogger.Printf("Forgetting %s link %s\n", status, link.Path)
+				reg.Remove(link.Path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logging.ErrorLogger.Printf("Warning: failed to update the link registry: %v\n", err)
+	}
+}
+
+// blobDigest returns the digest of an Ollama blob from its file name, sha256-<hex>, or "" for other files
+func blobDigest(path string) string {
+	name := filepath.Base(path)
+	if !strings.HasPrefix(name, "sha256-") {
+		return ""
+	}
+	return "sha256:" + strings.TrimPrefix(name, "sha256-")
+}
+
+func withinDir(path, dir string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// staleLink is a model whose links into a target no longer match its files in Ollama, usually because it's been
+// pulled again and its blobs have new digests
+type staleLink struct {
+	Model string
+	// Reason says what's wrong with the first outdated link
+	Reason string
+	// Deleted is set when the model is no longer in Ollama, so its links can only be removed
+	Deleted bool
+	links   []links.Link
+}
+
+// exportConfig reports whether any of the model's links were made with --export-config
+func (s staleLink) exportConfig() bool {
+	for _, link := range s.links {
+		if link.ExportConfig {
+			return true
+		}
+	}
+	return false
+}
+
+// strategy is how the model's main file was linked, so it's relinked the same way
+func (s staleLink) strategy() links.Strategy {
+	for _, link := range s.links {
+		if !link.Projector && link.Strategy != "" {
+			return link.Strategy
+		}
+	}
+	return links.Symlink
+}
+
+// target is the target the links were made in
+func (s staleLink) target() linkTarget {
+	return linkTargetOf(s.links[0])
+}
+
+// findStaleLinks compares the recorded links from Ollama with the models' current files, finding links to
+// blobs the model no longer uses and links whose blob has been deleted. Links that are missing or have been
+// replaced aren't gollama's any more and are left to reconcileLinks. A model linked into several targets is
+// stale in each of them.
+func findStaleLinks(client *api.Client) ([]staleLink, error) {
+	reg, err := links.Load()
+	if err != nil {
+		return nil, err
+	}
+	type targetModel struct {
+		dir   string
+		model string
+	}
+	var linked []targetModel
+	byModel := make(map[targetModel][]links.Link)
+	for _, link := range reg.Links() {
+		if !link.Kind.FromOllama() {
+			continue
+		}
+		if status := link.Check(); status == links.Missing || status == links.Replaced {
+			continue
+		}
+		key := targetModel{dir: linkTargetOf(link).Dir(), model: link.Model}
+		if _, ok := byModel[key]; !ok {
+			linked = append(linked, key)
+		}
+		byModel[key] = append(byModel[key], link)
+	}
+
+	var stale []staleLink
+	files := make(map[string]ModelFiles)
+	deleted := make(map[string]bool)
+	for _, key := range linked {
+		model, recorded := key.model, byModel[key]
+		modelFiles, ok := files[model]
+		if !ok && !deleted[model] {
+			modelFiles, err = getModelFiles(model, client)
+			if err != nil {
+				var statusErr api.StatusError
+				if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+					return nil, fmt.Errorf("error getting model files for %s: %v", model, err)
+				}
+				deleted[model] = true
+			}
+			files[model] = modelFiles
+		}
+		if deleted[model] {
+			stale = append(stale, staleLink{Model: model, Reason: "the model is no longer in Ollama", Deleted: true, links: recorded})
+			continue
+		}
+		if reason := staleReason(recorded, modelFiles); reason != "" {
+			stale = append(stale, staleLink{Model: model, Reason: reason, links: recorded})
+		}
+	}
+	return stale, nil
+}
+
+// staleReason describes the first of a model's links that doesn't match its files, or returns "" if they all do
+func staleReason(recorded []links.Link, modelFiles ModelFiles) string {
+	for _, link := range recorded {
+		want, file := modelFiles.MainModel, "model"
+		if link.Projector {
+			want, file = modelFiles.Projector, "projector"
+		}
+		switch {
+		case want == "":
+			return "the model no longer has a projector"
+		case filepath.Clean(link.Source) != filepath.Clean(want):
+			return fmt.Sprintf("the %s link points at %s, the model now uses %s", file, digestOrPath(link.Source), digestOrPath(want))
+		case link.Check() == links.Broken:
+			return fmt.Sprintf("the %s link points at %s, which has been deleted", file, digestOrPath(link.Source))
+		}
+	}
+	return ""
+}
+
+func digestOrPath(path string) string {
+	if digest := blobDigest(path); digest != "" {
+		return digest
+	}
+	return path
+}
+
+// relinkStale removes a stale model's links and links it again in the same target, exporting its config again if
+// it was linked with --export-config. The links of deleted models are only removed. export collects the unmapped
+// parameters of re-exported configs, it may be nil.
+func relinkStale(stale staleLink, export *configExport, client *api.Client) (string, error) {
+	target := stale.target()
+	var removed []string
+	for _, link := range stale.links {
+		if status := link.Check(); status == links.OK || status == links.Broken {
+			if err := target.Remove(link.Path); err != nil {
+				return "", fmt.Errorf("failed to remove stale link %s: %v", link.Path, err)
+			}
+			logging.InfoLogger.Printf("Removed stale link %s -> %s\n", link.Path, link.Source)
+		}
+		removed = append(removed, link.Path)
+	}
+	if err := links.Forget(removed...); err != nil {
+		logging.ErrorLogger.Printf("Warning: failed to update the link registry: %v\n", err)
+	}
+
+	if stale.Deleted {
+		return fmt.Sprintf("Removed the links to %s from %s, it's no longer in Ollama", stale.Model, target.Name()), nil
+	}
+	if !stale.exportConfig() {
+		export = nil
+	} else if export == nil {
+		export = &configExport{unmapped: make(map[string][]string)}
+	}
+	if _, err := linkModel(stale.Model, target, stale.strategy(), true, false, export, client); err != nil {
+		return "", err
+	}
+	if export != nil {
+		return fmt.Sprintf("Relinked %s in %s and exported its config", stale.Model, target.Name()), nil
+	}
+	return fmt.Sprintf("Relinked %s in %s", stale.Model, target.Name()), nil
+}
+
+// relinkStaleModels relinks each stale model with relinkStale, passing its message or error to report, and returns
+// the summary of the parameters left out of the configs it exported again, "" if there are none
+func relinkStaleModels(stale []staleLink, client *api.Client, report func(s staleLink, message string, err error)) string {
+	export := &configExport{unmapped: make(map[string][]string)}
+	var apps []string
+	for _, s := range stale {
+		message, err := relinkStale(s, export, client)
+		report(s, message, err)
+		if name := s.target().Name(); err == nil && len(export.unmapped[s.Model]) > 0 && !slices.Contains(apps, name) {
+			apps = append(apps, name)
+		}
+	}
+	var summary strings.Builder
+	printUnmappedParameters(&summary, strings.Join(apps, " or "), export.unmapped)
+	return summary.String()
+}
+
+// runLinksCommand implements `gollama links`, listing the links gollama manages
+func runLinksCommand(cfg *config.Config, args []string) int {
+	if len(args) > 0 && args[0] == "check" {
+		return runLinksCheckCommand(cfg, args[1:])
+	}
+
+	fs := newFlagSet("links", "links [flags] | links check [flags]", cfg)
+	if err := fs.Parse(args); err != nil {
+		return flagExitCode(err)
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+
+	reg, err := links.Load()
+	if err != nil {
+		return commandError("Error: %v", err)
+	}
+	recorded := reg.Links()
+	if len(recorded) == 0 {
+		fmt.Println(styles.InfoStyle().Render("No links recorded in " + reg.Path()))
+		return 0
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tMODEL\tSTATUS\tCREATED\tLINK\tSOURCE")
+	problems := 0
+	for _, link := range recorded {
+		status := link.Check()
+		if status != links.OK {
+			problems++
+		}
+		model := link.Model
+		if link.Projector {
+			model += " (projector)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", link.Kind, model, status, link.Created.Local().Format("2006-01-02 15:04"), link.Path, link.Source)
+	}
+	w.Flush()
+
+	summary := fmt.Sprintf("%d links recorded in %s", len(recorded), reg.Path())
+	if problems > 0 {
+		fmt.Println(styles.WarningStyle().Render(fmt.Sprintf("%s, %d broken, missing or replaced", summary, problems)))
+	} else {
+		fmt.Println(styles.SuccessStyle().Render(summary))
+	}
+	return 0
+}
+
+// runLinksCheckCommand implements `gollama links check`, finding links from Ollama that are stale because their
+// model has been pulled again or deleted, and relinking or removing them with --fix
+func runLinksCheckCommand(cfg *config.Config, args []string) int {
+	fs := newFlagSet("links check", "links check [flags]", cfg)
+	fix := fs.Bool("fix", false, "Relink stale links, exporting the config again for links made with --export-config, and remove the links of deleted models")
+	if err := fs.Parse(args); err != nil {
+		return flagExitCode(err)
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+
+	client, err := newAPIClient(cfg)
+	if err != nil {
+		return commandError("Error: %v", err)
+	}
+	stale, err := findStaleLinks(client)
+	if err != nil {
+		return commandError("Error: %v", err)
+	}
+	if len(stale) == 0 {
+		fmt.Println(styles.SuccessStyle().Render("No stale links"))
+		return 0
+	}
+	for _, s := range stale {
+		fmt.Println(styles.WarningStyle().Render(fmt.Sprintf("%s (%s): %s", s.Model, s.target().Name(), s.Reason)))
+	}
+	if !*fix {
+		fmt.Println(styles.InfoStyle().Render(fmt.Sprintf("%d stale models, run gollama links check --fix to relink them", len(stale))))
+		return 1
+	}
+
+	failed := 0
+	unmapped := relinkStaleModels(stale, client, func(s staleLink, message string, err error) {
+		if err != nil {
+			failed++
+			fmt.Println(styles.ErrorStyle().Render(fmt.Sprintf("Error relinking %s: %v", s.Model, err)))
+			return
+		}
+		fmt.Println(styles.SuccessStyle().Render(message))
+	})
+	fmt.Print(unmapped)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
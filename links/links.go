@@ -0,0 +1,311 @@
+// Package links keeps a registry of the links gollama creates between Ollama and the apps that share its models,
+// so they can be cleaned up and relinked exactly rather than recognised by where they point, and places links as
+// symlinks, hardlinks, reflinks or copies.
+package links
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mipalgu/gollama/utils"
+)
+
+// Kind is what a link connects
+type Kind string
+
+const (
+	// LMStudio links are Ollama model blobs linked into the LM Studio models directory (-L, l)
+	LMStudio Kind = "lmstudio"
+	// LlamaCpp links are Ollama model blobs linked into a flat models directory for llama.cpp or koboldcpp
+	LlamaCpp Kind = "llamacpp"
+	// Jan links are Ollama model blobs linked into Jan's models directory
+	Jan Kind = "jan"
+	// OllamaBlob links are LM Studio files linked into Ollama's blob store to create models (-C)
+	OllamaBlob Kind = "ollama-blob"
+	// OllamaModel links are LM Studio files linked into the Ollama models directory (--link-lmstudio)
+	OllamaModel Kind = "ollama-model"
+)
+
+// FromOllama reports whether links of this kind are Ollama model blobs linked into another app
+func (k Kind) FromOllama() bool {
+	return k == LMStudio || k == LlamaCpp || k == Jan
+}
+
+// Link is a link created by gollama
+type Link struct {
+	Kind Kind `json:"kind"`
+	// Model is the model the link was made for, the Ollama model for links from Ollama and the LM Studio model otherwise
+	Model string `json:"model"`
+	// Digest is the sha256:<hex> digest of the linked file, empty when it isn't known
+	Digest string `json:"digest,omitempty"`
+	// Source is the file the link points to
+	Source string `json:"source"`
+	// Path is the link itself
+	Path      string `json:"path"`
+	Projector bool   `json:"projector,omitempty"`
+	// Strategy is how the link was placed, empty for symlinks recorded before there were strategies
+	Strategy Strategy `json:"strategy,omitempty"`
+	// Size and ModTime are the file's when it was placed, they tell whether a link that isn't a symlink has been
+	// replaced since
+	Size    int64     `json:"size,omitempty"`
+	ModTime time.Time `json:"mod_time,omitzero"`
+	// ExportConfig is set for links from Ollama made with --export-config, so relinking exports the config again
+	ExportConfig bool      `json:"export_config,omitempty"`
+	Created      time.Time `json:"created"`
+}
+
+// Status is the state of a recorded link on disk
+type Status int
+
+const (
+	// OK links still point at their source, which exists
+	OK Status = iota
+	// Broken links still point at their source, but it's been removed. Hardlinks and copies are left as
+	// outdated copies of it.
+	Broken
+	// Missing links have been removed
+	Missing
+	// Replaced links are no longer what gollama placed, something else owns the path now
+	Replaced
+)
+
+func (s Status) String() string {
+	switch s {
+	case Broken:
+		return "broken"
+	case Missing:
+		return "missing"
+	case Replaced:
+		return "replaced"
+	}
+	return "ok"
+}
+
+// Check compares the link with the file system
+func (l Link) Check() Status {
+	if l.Strategy.orDefault() != Symlink {
+		return l.checkFile()
+	}
+	target, err := os.Readlink(l.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Missing
+		}
+		// The path exists but isn't a symlink
+		return Replaced
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(l.Path), target)
+	}
+	if filepath.Clean(target) != filepath.Clean(l.Source) {
+		return Replaced
+	}
+	if _, err := os.Stat(l.Source); os.IsNotExist(err) {
+		return Broken
+	}
+	return OK
+}
+
+// checkFile checks a hardlink, reflink or copy, which is still gollama's if it's the same size and hasn't been
+// modified since it was placed
+func (l Link) checkFile() Status {
+	info, err := os.Lstat(l.Path)
+	if os.IsNotExist(err) {
+		return Missing
+	}
+	if err != nil || !info.Mode().IsRegular() || info.Size() != l.Size || !info.ModTime().Equal(l.ModTime) {
+		return Replaced
+	}
+	source, err := os.Stat(l.Source)
+	if err != nil {
+		return Broken
+	}
+	// A hardlink that no longer shares the source's data is a copy of a blob that's been removed and downloaded again
+	if l.Strategy == Hardlink && !os.SameFile(info, source) {
+		return Broken
+	}
+	return OK
+}
+
+// Snapshot records the size and modification time of a link that isn't a symlink, for Check
+func (l *Link) Snapshot() error {
+	if l.Strategy.orDefault() == Symlink {
+		return nil
+	}
+	info, err := os.Stat(l.Path)
+	if err != nil {
+		return err
+	}
+	l.Size = info.Size()
+	l.ModTime = info.ModTime()
+	return nil
+}
+
+// Registry is the set of recorded links, keyed by the link's path
+type Registry struct {
+	path  string
+	links map[string]Link
+}
+
+// registryFile is the JSON layout of the state file
+type registryFile struct {
+	Links []Link `json:"links"`
+}
+
+// DefaultPath is the registry file in gollama's config directory
+func DefaultPath() string {
+	return filepath.Join(utils.GetConfigDir(), "links.json")
+}
+
+// Open reads the registry at path, a missing file is an empty registry
+func Open(path string) (*Registry, error) {
+	r := &Registry{path: path, links: make(map[string]Link)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading link registry %s: %v", path, err)
+	}
+	var file registryFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error parsing link registry %s: %v", path, err)
+	}
+	for _, link := range file.Links {
+		r.links[link.Path] = link
+	}
+	return r, nil
+}
+
+// Path returns the registry's file
+func (r *Registry) Path() string {
+	return r.path
+}
+
+// Links returns the recorded links sorted by kind, model and path
+func (r *Registry) Links() []Link {
+	links := make([]Link, 0, len(r.links))
+	for _, link := range r.links {
+		links = append(links, link)
+	}
+	sort.Slice(links, func(i, j int) bool {
+		a, b := links[i], links[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Model != b.Model {
+			return a.Model < b.Model
+		}
+		return a.Path < b.Path
+	})
+	return links
+}
+
+// Get returns the link recorded at path
+func (r *Registry) Get(path string) (Link, bool) {
+	link, ok := r.links[cleanPath(path)]
+	return link, ok
+}
+
+// Add records a link, replacing any link recorded at the same path. Paths are made absolute and the creation
+// time is set to now if it's zero.
+func (r *Registry) Add(link Link) {
+	link.Path = cleanPath(link.Path)
+	link.Source = cleanPath(link.Source)
+	if link.Created.IsZero() {
+		link.Created = time.Now().UTC().Truncate(time.Second)
+	}
+	r.links[link.Path] = link
+}
+
+// Remove forgets the link at path, reporting whether one was recorded
+func (r *Registry) Remove(path string) bool {
+	path = cleanPath(path)
+	_, ok := r.links[path]
+	delete(r.links, path)
+	return ok
+}
+
+// Save writes the registry, replacing the file atomically so a crash can't leave it half written
+func (r *Registry) Save() error {
+	data, err := json.MarshalIndent(registryFile{Links: r.Links()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("error creating %s: %v", filepath.Dir(r.path), err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), ".links-*.json")
+	if err != nil {
+		return fmt.Errorf("error saving link registry: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error saving link registry: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error saving link registry: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("error saving link registry: %v", err)
+	}
+	return nil
+}
+
+func cleanPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// updateMu serialises read-modify-write cycles of the default registry, the TUI links models concurrently
+var updateMu sync.Mutex
+
+// Update opens the default registry, applies fn and saves it if fn succeeds
+func Update(fn func(r *Registry) error) error {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	r, err := Open(DefaultPath())
+	if err != nil {
+		return err
+	}
+	if err := fn(r); err != nil {
+		return err
+	}
+	return r.Save()
+}
+
+// Record adds links to the default registry
+func Record(links ...Link) error {
+	return Update(func(r *Registry) error {
+		for _, link := range links {
+			r.Add(link)
+		}
+		return nil
+	})
+}
+
+// Forget removes the links at the given paths from the default registry
+func Forget(paths ...string) error {
+	return Update(func(r *Registry) error {
+		for _, path := range paths {
+			r.Remove(path)
+		}
+		return nil
+	})
+}
+
+// Load reads the default registry
+func Load() (*Registry, error) {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+	return Open(DefaultPath())
+}
@@ -0,0 +1,124 @@
+package links
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegistryRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config", "links.json")
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatalf("Opening a missing registry failed: %v", err)
+	}
+	if len(r.Links()) != 0 {
+		t.Fatalf("Expected an empty registry, got %v", r.Links())
+	}
+
+	r.Add(Link{Kind: LMStudio, Model: "qwen3:8b", Digest: "sha256:abc", Source: "/ollama/blobs/sha256-abc", Path: "/lmstudio/ollama/qwen3-8b/qwen3-8b.gguf"})
+	r.Add(Link{Kind: LMStudio, Model: "llama3:latest", Source: "/ollama/blobs/sha256-def", Path: "/lmstudio/ollama/llama3/llama3.gguf"})
+	r.Add(Link{Kind: OllamaBlob, Model: "publisher-model", Source: "/lmstudio/publisher/model/model.gguf", Path: "/ollama/blobs/sha256-123"})
+	if err := r.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := reopened.Links()
+	if len(links) != 3 {
+		t.Fatalf("Expected 3 links, got %d", len(links))
+	}
+	// Sorted by kind, then model
+	if links[0].Model != "llama3:latest" || links[1].Model != "qwen3:8b" || links[2].Kind != OllamaBlob {
+		t.Errorf("Unexpected order %+v", links)
+	}
+	link, ok := reopened.Get("/lmstudio/ollama/qwen3-8b/../qwen3-8b/qwen3-8b.gguf")
+	if !ok || link.Digest != "sha256:abc" || link.Created.IsZero() {
+		t.Errorf("Get = %+v, %v", link, ok)
+	}
+
+	if !reopened.Remove(link.Path) || reopened.Remove(link.Path) {
+		t.Error("Remove should report whether the link was recorded")
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Error("Expected an error for a corrupt registry")
+	}
+}
+
+func TestLinkCheck(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "blob")
+	if err := os.WriteFile(source, []byte("weights"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	link := Link{Source: source, Path: filepath.Join(dir, "model.gguf")}
+
+	if status := link.Check(); status != Missing {
+		t.Errorf("Check before linking = %s, want missing", status)
+	}
+	if err := os.Symlink(source, link.Path); err != nil {
+		t.Fatal(err)
+	}
+	if status := link.Check(); status != OK {
+		t.Errorf("Check = %s, want ok", status)
+	}
+
+	if err := os.Remove(source); err != nil {
+		t.Fatal(err)
+	}
+	if status := link.Check(); status != Broken {
+		t.Errorf("Check after removing the source = %s, want broken", status)
+	}
+
+	// A link to another file, or a regular file, belongs to someone else now
+	other := filepath.Join(dir, "other")
+	if err := os.WriteFile(other, []byte("other"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(link.Path)
+	if err := os.Symlink(other, link.Path); err != nil {
+		t.Fatal(err)
+	}
+	if status := link.Check(); status != Replaced {
+		t.Errorf("Check of a link to another file = %s, want replaced", status)
+	}
+	os.Remove(link.Path)
+	if err := os.WriteFile(link.Path, []byte("real file"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if status := link.Check(); status != Replaced {
+		t.Errorf("Check of a regular file = %s, want replaced", status)
+	}
+}
+
+func TestRecordAndForget(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	link := Link{Kind: OllamaModel, Model: "model", Source: "/lmstudio/model.gguf", Path: "/ollama/model.gguf"}
+	if err := Record(link); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.Get(link.Path); !ok {
+		t.Fatalf("Recorded link isn't in %s", DefaultPath())
+	}
+
+	if err := Forget(link.Path); err != nil {
+		t.Fatal(err)
+	}
+	if r, _ = Load(); len(r.Links()) != 0 {
+		t.Errorf("Expected the link to be forgotten, got %v", r.Links())
+	}
+}
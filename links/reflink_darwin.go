@@ -0,0 +1,8 @@
+package links
+
+import "golang.org/x/sys/unix"
+
+// reflink clones source to path with clonefile, which APFS supports
+func reflink(source, path string) error {
+	return unix.Clonefile(source, path, 0)
+}
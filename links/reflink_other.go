@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package links
+
+import "errors"
+
+func reflink(source, path string) error {
+	return errors.New("reflinks aren't supported on this platform")
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package links
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// sameFileSystem reports whether a and b are on the same volume, which hardlinks need
+func sameFileSystem(a, b string) (bool, error) {
+	absA, err := filepath.Abs(a)
+	if err != nil {
+		return false, err
+	}
+	absB, err := filepath.Abs(b)
+	if err != nil {
+		return false, err
+	}
+	return strings.EqualFold(filepath.VolumeName(absA), filepath.VolumeName(absB)), nil
+}
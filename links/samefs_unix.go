@@ -0,0 +1,17 @@
+//go:build unix
+
+package links
+
+import "syscall"
+
+// sameFileSystem reports whether a and b are on the same device, which hardlinks need
+func sameFileSystem(a, b string) (bool, error) {
+	var statA, statB syscall.Stat_t
+	if err := syscall.Stat(a, &statA); err != nil {
+		return false, err
+	}
+	if err := syscall.Stat(b, &statB); err != nil {
+		return false, err
+	}
+	return statA.Dev == statB.Dev, nil
+}
@@ -0,0 +1,152 @@
+package links
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mipalgu/gollama/logging"
+)
+
+// Strategy is how a linked file is placed at the link's path
+type Strategy string
+
+const (
+	// Symlink links point at their source, they break if the source is removed
+	Symlink Strategy = "symlink"
+	// Hardlink links share their source's data, so they need to be on the same file system
+	Hardlink Strategy = "hardlink"
+	// Reflink links are copy-on-write clones, which need a file system that supports them (APFS, Btrfs, XFS)
+	Reflink Strategy = "reflink"
+	// Copy links are verified copies of their source
+	Copy Strategy = "copy"
+)
+
+// Strategies are the available strategies
+var Strategies = []Strategy{Symlink, Hardlink, Reflink, Copy}
+
+// ParseStrategy returns the strategy with the given name, an empty name is a symlink
+func ParseStrategy(name string) (Strategy, error) {
+	if name == "" {
+		return Symlink, nil
+	}
+	for _, strategy := range Strategies {
+		if strings.EqualFold(name, string(strategy)) {
+			return strategy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown link strategy %q, expected one of symlink, hardlink, reflink or copy", name)
+}
+
+// orDefault treats the strategy of links recorded before there were strategies as a symlink
+func (s Strategy) orDefault() Strategy {
+	if s == "" {
+		return Symlink
+	}
+	return s
+}
+
+// Satisfies reports whether a link placed with s is what was asked for. Copies satisfy hardlinks and reflinks
+// because that's what Place falls back to, so an existing copy isn't redone every time the model is linked.
+func (s Strategy) Satisfies(requested Strategy) bool {
+	s, requested = s.orDefault(), requested.orDefault()
+	return s == requested || (s == Copy && (requested == Hardlink || requested == Reflink))
+}
+
+// Place puts source at path with the strategy and returns the strategy used. Hardlinks across file systems and
+// reflinks the file system doesn't support fall back to a copy. Copies are checked against digest, the sha256:<hex>
+// of source, and re-read to make sure they were written correctly. An empty digest skips the first check.
+func Place(strategy Strategy, source, path, digest string) (Strategy, error) {
+	switch strategy.orDefault() {
+	case Symlink:
+		return Symlink, os.Symlink(source, path)
+	case Hardlink:
+		same, err := sameFileSystem(source, filepath.Dir(path))
+		if err != nil {
+			return "", err
+		}
+		if !same {
+			logging.InfoLogger.Printf("%s and %s are on different file systems, copying instead of hardlinking\n", source, path)
+			return Copy, copyVerified(source, path, digest)
+		}
+		if err := os.Link(source, path); err != nil {
+			logging.InfoLogger.Printf("Hardlinking %s failed, copying instead: %v\n", path, err)
+			return Copy, copyVerified(source, path, digest)
+		}
+		return Hardlink, nil
+	case Reflink:
+		if err := reflink(source, path); err != nil {
+			logging.InfoLogger.Printf("Reflinking %s failed, copying instead: %v\n", path, err)
+			return Copy, copyVerified(source, path, digest)
+		}
+		return Reflink, nil
+	case Copy:
+		return Copy, copyVerified(source, path, digest)
+	}
+	return "", fmt.Errorf("unknown link strategy %q", strategy)
+}
+
+// copyVerified copies source to a temporary file next to path, hashing it on the way, then re-reads the copy and
+// only moves it into place if both hashes match each other and digest
+func copyVerified(source, path, digest string) error {
+	src, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.partial")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), src); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error copying %s: %v", source, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	sourceDigest := "sha256:" + hex.EncodeToString(hash.Sum(nil))
+	if digest != "" && sourceDigest != digest {
+		return fmt.Errorf("%s doesn't match its digest %s, got %s", source, digest, sourceDigest)
+	}
+
+	copyDigest, err := fileDigest(tmp.Name())
+	if err != nil {
+		return err
+	}
+	if copyDigest != sourceDigest {
+		return fmt.Errorf("the copy of %s is corrupt, its digest is %s rather than %s", source, copyDigest, sourceDigest)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("error reading %s: %v", path, err)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
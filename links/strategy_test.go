@@ -0,0 +1,141 @@
+package links
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseStrategy(t *testing.T) {
+	for name, want := range map[string]Strategy{"": Symlink, "symlink": Symlink, "Hardlink": Hardlink, "reflink": Reflink, "COPY": Copy} {
+		if got, err := ParseStrategy(name); err != nil || got != want {
+			t.Errorf("ParseStrategy(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseStrategy("junction"); err == nil {
+		t.Error("Expected an error for an unknown strategy")
+	}
+
+	if !Copy.Satisfies(Hardlink) || !Copy.Satisfies(Reflink) || !Strategy("").Satisfies(Symlink) {
+		t.Error("Copies should satisfy hardlinks and reflinks, and unrecorded strategies are symlinks")
+	}
+	if Copy.Satisfies(Symlink) || Hardlink.Satisfies(Copy) {
+		t.Error("A link placed with one strategy shouldn't satisfy an unrelated one")
+	}
+}
+
+func TestPlace(t *testing.T) {
+	dir := t.TempDir()
+	data := []byte("weights")
+	sum := sha256.Sum256(data)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	source := filepath.Join(dir, "sha256-"+hex.EncodeToString(sum[:]))
+	if err := os.WriteFile(source, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, strategy := range Strategies {
+		t.Run(string(strategy), func(t *testing.T) {
+			path := filepath.Join(dir, string(strategy)+".gguf")
+			used, err := Place(strategy, source, path, digest)
+			if err != nil {
+				t.Fatalf("Place failed: %v", err)
+			}
+			// Reflinks fall back to copies where the file system can't clone
+			if used != strategy && !(strategy == Reflink && used == Copy) {
+				t.Errorf("Placed a %s, want %s", used, strategy)
+			}
+			if got, err := os.ReadFile(path); err != nil || string(got) != string(data) {
+				t.Errorf("Placed file has %q, %v", got, err)
+			}
+			info, err := os.Lstat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (info.Mode()&os.ModeSymlink != 0) != (used == Symlink) {
+				t.Errorf("%s has mode %v", used, info.Mode())
+			}
+
+			link := Link{Source: source, Path: path, Strategy: used}
+			if err := link.Snapshot(); err != nil {
+				t.Fatal(err)
+			}
+			if status := link.Check(); status != OK {
+				t.Errorf("Check = %s, want ok", status)
+			}
+		})
+	}
+
+	// Nothing is left behind when the source doesn't match its digest
+	path := filepath.Join(dir, "corrupt.gguf")
+	if _, err := Place(Copy, source, path, "sha256:0000"); err == nil {
+		t.Error("Expected an error for a source that doesn't match its digest")
+	}
+	if entries, _ := filepath.Glob(filepath.Join(dir, "*corrupt*")); len(entries) != 0 {
+		t.Errorf("Expected the partial copy to be removed, found %v", entries)
+	}
+}
+
+func TestCheckCopies(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "blob")
+	if err := os.WriteFile(source, []byte("weights"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, strategy := range []Strategy{Hardlink, Copy} {
+		path := filepath.Join(dir, string(strategy)+".gguf")
+		link := Link{Source: source, Path: path, Strategy: strategy}
+		if status := link.Check(); status != Missing {
+			t.Errorf("%s before placing = %s, want missing", strategy, status)
+		}
+		if _, err := Place(strategy, source, path, ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := link.Snapshot(); err != nil {
+			t.Fatal(err)
+		}
+
+		// A file written over the copy isn't gollama's any more
+		later := time.Now().Add(time.Hour)
+		if err := os.Chtimes(path, later, later); err != nil {
+			t.Fatal(err)
+		}
+		if status := link.Check(); status != Replaced {
+			t.Errorf("%s modified since it was placed = %s, want replaced", strategy, status)
+		}
+		if err := os.Chtimes(path, link.ModTime, link.ModTime); err != nil {
+			t.Fatal(err)
+		}
+		if status := link.Check(); status != OK {
+			t.Errorf("%s = %s, want ok", strategy, status)
+		}
+	}
+
+	// Once the blob's removed the copy and the hardlink are outdated, a new blob at the same path doesn't change that
+	os.Remove(source)
+	for _, strategy := range []Strategy{Hardlink, Copy} {
+		link := Link{Source: source, Path: filepath.Join(dir, string(strategy)+".gguf"), Strategy: strategy}
+		info, err := os.Stat(link.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		link.Size, link.ModTime = info.Size(), info.ModTime()
+		if status := link.Check(); status != Broken {
+			t.Errorf("%s after removing the source = %s, want broken", strategy, status)
+		}
+	}
+	if err := os.WriteFile(source, []byte("weights"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	hardlink := Link{Source: source, Path: filepath.Join(dir, "hardlink.gguf"), Strategy: Hardlink}
+	if err := hardlink.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if status := hardlink.Check(); status != Broken {
+		t.Errorf("Hardlink to a removed blob = %s, want broken", status)
+	}
+}
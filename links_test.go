@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ollama/ollama/api"
+
+	"github.com/mipalgu/gollama/links"
+)
+
+// fakeShowHost is an Ollama server that lists the models in modelfiles and shows their Modelfiles, the models that
+// aren't in it aren't found. setModelfile changes them while it's running.
+type fakeShowHost struct {
+	client     *api.Client
+	mu         sync.Mutex
+	modelfiles map[string]string
+}
+
+func newFakeShowHost(t *testing.T, modelfiles map[string]string) *fakeShowHost {
+	host := &fakeShowHost{modelfiles: modelfiles}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host.mu.Lock()
+		defer host.mu.Unlock()
+		switch r.URL.Path {
+		case "/api/tags":
+			var list api.ListResponse
+			for name := range host.modelfiles {
+				list.Models = append(list.Models, api.ListModelResponse{Name: name, Model: name})
+			}
+			json.NewEncoder(w).Encode(list)
+		case "/api/show":
+			var req api.ShowRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Error(err)
+			}
+			modelfile, ok := host.modelfiles[req.Name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprintf(w, `{"error":"model '%s' not found"}`, req.Name)
+				return
+			}
+			json.NewEncoder(w).Encode(api.ShowResponse{Modelfile: modelfile})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	serverURL, _ := url.Parse(server.URL)
+	host.client = api.NewClient(serverURL, server.Client())
+	return host
+}
+
+// setModelfile changes a model's Modelfile, deleting the model if it's empty
+func (h *fakeShowHost) setModelfile(name, modelfile string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if modelfile == "" {
+		delete(h.modelfiles, name)
+		return
+	}
+	h.modelfiles[name] = modelfile
+}
+
+func TestFindAndRelinkStaleLinks(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	blobs := filepath.Join(dir, "blobs")
+	if err := os.MkdirAll(blobs, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	blob := func(digest string) string {
+		path := filepath.Join(blobs, "sha256-"+digest)
+		if err := os.WriteFile(path, []byte(digest), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	oldBlob, newBlob, deletedBlob, currentBlob := blob("old"), blob("new"), blob("deleted"), blob("current")
+
+	// The Ollama API knows the re-pulled models and the current one, gone:latest has been deleted
+	client := newFakeShowHost(t, map[string]string{
+		"repulled:latest": "FROM " + newBlob + "\n",
+		"dangling:latest": "FROM " + newBlob + "\n",
+		"current:latest":  "FROM " + currentBlob + "\n",
+	}).client
+
+	lmStudioDir := filepath.Join(dir, "lmstudio")
+	link := func(model, name, source string, exportConfig bool) string {
+		path := filepath.Join(lmStudioDir, "registry.ollama.ai", name, name+".gguf")
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(source, path); err != nil {
+			t.Fatal(err)
+		}
+		recordLinks(links.LMStudio, model, ModelFiles{MainModel: source}, path, "", nil, exportConfig)
+		return path
+	}
+	repulled := link("repulled:latest", "repulled", oldBlob, false)
+	dangling := link("dangling:latest", "dangling", deletedBlob, false)
+	gone := link("gone:latest", "gone", currentBlob, false)
+	link("current:latest", "current", currentBlob, false)
+	os.Remove(deletedBlob)
+
+	// Linking again without --export-config keeps the flag recorded by an earlier link
+	recordLinks(links.LMStudio, "repulled:latest", ModelFiles{MainModel: oldBlob}, repulled, "", nil, true)
+	recordLinks(links.LMStudio, "repulled:latest", ModelFiles{MainModel: oldBlob}, repulled, "", nil, false)
+
+	stale, err := findStaleLinks(client)
+	if err != nil {
+		t.Fatalf("findStaleLinks failed: %v", err)
+	}
+	found := make(map[string]staleLink)
+	for _, s := range stale {
+		found[s.Model] = s
+	}
+	if len(found) != 3 {
+		t.Fatalf("Expected 3 stale models, got %+v", stale)
+	}
+	if s := found["repulled:latest"]; s.Deleted || !s.exportConfig() || s.Reason != "the model link points at sha256:old, the model now uses sha256:new" {
+		t.Errorf("Unexpected re-pulled model %+v", s)
+	}
+	if s := found["dangling:latest"]; s.Deleted || s.exportConfig() || s.target().Dir() != lmStudioDir {
+		t.Errorf("Unexpected dangling model %+v", s)
+	}
+	if s := found["gone:latest"]; !s.Deleted {
+		t.Errorf("Expected gone:latest to be reported as deleted, got %+v", s)
+	}
+
+	// Exporting the config needs more of the API than the test server has, so only the links are relinked, in the
+	// background as the TUI does it
+	m := &AppModel{client: client}
+	msg, ok := m.relinkStaleLinks([]staleLink{found["dangling:latest"], found["gone:latest"]})().(genericMsg)
+	if !ok || !strings.Contains(msg.message, "Relinked dangling:latest in LM Studio") || !strings.Contains(msg.message, "Removed the links to gone:latest") {
+		t.Fatalf("Expected both models to be relinked, got %+v", msg)
+	}
+	if target, err := os.Readlink(dangling); err != nil || target != newBlob {
+		t.Errorf("Expected %s to be relinked to %s, got %s, %v", dangling, newBlob, target, err)
+	}
+	if _, err := os.Stat(filepath.Dir(gone)); !os.IsNotExist(err) {
+		t.Error("Expected the deleted model's link and directory to be removed")
+	}
+
+	reg, err := links.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link, ok := reg.Get(dangling); !ok || link.Digest != "sha256:new" {
+		t.Errorf("Expected the new link to be recorded, got %+v", link)
+	}
+	if _, ok := reg.Get(gone); ok {
+		t.Error("Expected the deleted model's link to be forgotten")
+	}
+	stale, err = findStaleLinks(client)
+	if err != nil || len(stale) != 1 || stale[0].Model != "repulled:latest" {
+		t.Errorf("Expected only repulled:latest to be left, got %+v, %v", stale, err)
+	}
+}
+
+func TestStaleLinksPromptWaitsForIdleView(t *testing.T) {
+	m := &AppModel{keys: *NewKeyMap(), view: HelpView}
+	m.list = list.New(nil, list.NewDefaultDelegate(), 80, 24)
+
+	m.Update(staleLinksMsg{stale: []staleLink{{Model: "llama3:latest"}}})
+	if m.confirmRelink {
+		t.Fatal("Expected the prompt to wait while the help is shown")
+	}
+	m.view = MainView
+	m.confirmDeletion = true
+	m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	if m.confirmRelink {
+		t.Fatal("Expected the prompt to wait for the deletion to be confirmed")
+	}
+	m.confirmDeletion = false
+	m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	if !m.confirmRelink || len(m.staleLinks) != 1 {
+		t.Errorf("Expected the prompt once the view is idle, got %v, %+v", m.confirmRelink, m.staleLinks)
+	}
+}
@@ -0,0 +1,132 @@
+// lint.go contains the `gollama lint` command and the pre-save Modelfile check used when editing models.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ollama/ollama/api"
+
+	"github.com/mipalgu/gollama/config"
+	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/modelfile"
+	"github.com/mipalgu/gollama/styles"
+)
+
+// lintHeaderPrefix marks the lint errors written to the top of a Modelfile when it's reopened for fixing
+const lintHeaderPrefix = "# gollama lint: "
+
+// modelfileLintError is returned when an edited Modelfile has errors and was not sent to the server
+type modelfileLintError struct {
+	diagnostics []modelfile.Diagnostic
+}
+
+func (e *modelfileLintError) Error() string {
+	lines := make([]string, 0, len(e.diagnostics))
+	for _, diag := range e.diagnostics {
+		if diag.Severity == modelfile.SeverityError {
+			lines = append(lines, diag.String())
+		}
+	}
+	return fmt.Sprintf("modelfile has %d error(s), not saved:\n%s", len(lines), strings.Join(lines, "\n"))
+}
+
+// lintModelfile checks Modelfile content before it's saved, returning the warnings if there are no errors
+func lintModelfile(content string) ([]modelfile.Diagnostic, error) {
+	diags := modelfile.Lint(stripLintHeader(content))
+	for _, diag := range diags {
+		logging.DebugLogger.Printf("Modelfile lint: %s\n", diag)
+	}
+	if modelfile.HasErrors(diags) {
+		return diags, &modelfileLintError{diagnostics: diags}
+	}
+	return diags, nil
+}
+
+// addLintHeader prefixes the content with the diagnostics as comments, replacing any previous header
+func addLintHeader(content string, diags []modelfile.Diagnostic) string {
+	var sb strings.Builder
+	sb.WriteString(lintHeaderPrefix + "fix the problems below and save again, save without changes to cancel\n")
+	for _, diag := range diags {
+		sb.WriteString(lintHeaderPrefix + diag.String() + "\n")
+	}
+	sb.WriteString(stripLintHeader(content))
+	return sb.String()
+}
+
+// stripLintHeader removes the comment lines added by addLintHeader
+func stripLintHeader(content string) string {
+	for strings.HasPrefix(content, lintHeaderPrefix) {
+		_, rest, found := strings.Cut(content, "\n")
+		if !found {
+			return ""
+		}
+		content = rest
+	}
+	return content
+}
+
+// formatLintDiagnostics renders diagnostics one per line, styled by severity
+func formatLintDiagnostics(diags []modelfile.Diagnostic) string {
+	lines := make([]string, len(diags))
+	for i, diag := range diags {
+		style := styles.WarningStyle()
+		if diag.Severity == modelfile.SeverityError {
+			style = styles.ErrorStyle()
+		}
+		lines[i] = style.Render(diag.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// runLintCommand implements `gollama lint <file|model>...`
+func runLintCommand(cfg *config.Config, args []string) int {
+	fs := newFlagSet("lint", "lint [flags] <Modelfile|model>...", cfg)
+	if err := fs.Parse(args); err != nil {
+		return flagExitCode(err)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	var client *api.Client
+	exitCode := 0
+	for _, target := range fs.Args() {
+		var content string
+		if data, err := os.ReadFile(target); err == nil {
+			content = string(data)
+		} else {
+			// Not a readable file, so treat it as a model name
+			if client == nil {
+				client, err = newAPIClient(cfg)
+				if err != nil {
+					return commandError("Error: %v", err)
+				}
+			}
+			resp, err := client.Show(context.Background(), &api.ShowRequest{Model: target})
+			if err != nil {
+				exitCode = commandError("%s: not a file and couldn't fetch the model's Modelfile: %v", target, err)
+				continue
+			}
+			content = resp.Modelfile
+		}
+
+		diags := modelfile.Lint(content)
+		if len(diags) == 0 {
+			fmt.Printf("%s: %s\n", target, styles.SuccessStyle().Render("ok"))
+			continue
+		}
+		for _, diag := range diags {
+			style := styles.WarningStyle()
+			if diag.Severity == modelfile.SeverityError {
+				style = styles.ErrorStyle()
+				exitCode = 1
+			}
+			fmt.Printf("%s: %s\n", target, style.Render(diag.String()))
+		}
+	}
+	return exitCode
+}
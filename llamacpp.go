@@ -0,0 +1,173 @@
+// llamacpp.go contains the `gollama export-llamacpp` command, which writes llama-swap configuration and llama-server
+// command lines that serve Ollama models from their blobs.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ollama/ollama/api"
+
+	"github.com/mipalgu/gollama/config"
+	"github.com/mipalgu/gollama/llamacpp"
+	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/modelfile"
+	"github.com/mipalgu/gollama/styles"
+	"github.com/mipalgu/gollama/utils"
+)
+
+// llamaCppModel resolves an Ollama model's blobs and Modelfile into a llama-server model. The Modelfile's template
+// is translated into templateDir unless templateDir is empty, falling back to the GGUF's chat template when it
+// can't be, which templateErr explains.
+func llamaCppModel(modelName, templateDir string, client *api.Client) (model llamacpp.Model, templateErr error, err error) {
+	modelFiles, err := getModelFiles(modelName, client)
+	if err != nil {
+		return model, nil, fmt.Errorf("error getting model files for %s: %v", modelName, err)
+	}
+	resp, err := client.Show(context.Background(), &api.ShowRequest{Name: modelName})
+	if err != nil {
+		return model, nil, fmt.Errorf("error getting the Modelfile for %s: %v", modelName, err)
+	}
+	parsed, err := modelfile.Parse(resp.Modelfile)
+	if err != nil {
+		logging.ErrorLogger.Printf("Warning: Failed to parse Modelfile for %s: %v\n", modelName, err)
+		parsed = &modelfile.Modelfile{}
+	}
+
+	model = llamacpp.Model{
+		Name:       modelName,
+		Path:       modelFiles.MainModel,
+		Projector:  modelFiles.Projector,
+		Parameters: parsed.ParameterMap(),
+	}
+	if templateDir == "" {
+		return model, nil, nil
+	}
+	template := resp.Template
+	if t, ok := parsed.Template(); ok {
+		template = t
+	}
+	jinja, err := llamacpp.ConvertChatTemplate(template)
+	if err != nil {
+		logging.InfoLogger.Printf("Using the chat template in the GGUF file for %s: %v\n", modelName, err)
+		return model, err, nil
+	}
+	if err := os.MkdirAll(templateDir, 0o755); err != nil {
+		return model, nil, fmt.Errorf("failed to create %s: %v", templateDir, err)
+	}
+	templateFile := filepath.Join(templateDir, flatLinkName(modelName)+".jinja")
+	if err := os.WriteFile(templateFile, []byte(jinja), 0o644); err != nil {
+		return model, nil, fmt.Errorf("failed to write the chat template for %s: %v", modelName, err)
+	}
+	model.ChatTemplateFile = templateFile
+	return model, nil, nil
+}
+
+// writeOutput writes data to path, or stdout if path is "-"
+func writeOutput(path string, data []byte, perm os.FileMode) error {
+	if path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, perm)
+}
+
+// runExportLlamaCppCommand implements `gollama export-llamacpp`
+func runExportLlamaCppCommand(cfg *config.Config, args []string) int {
+	fs := newFlagSet("export-llamacpp", "export-llamacpp [flags] [model...]", cfg)
+	swapPath := fs.String("llama-swap", "", "Write a llama-swap config.yaml to this file, - for stdout (the default if --commands isn't given)")
+	commandsPath := fs.String("commands", "", "Write a shell script of llama-server command lines to this file, - for stdout")
+	server := fs.String("server", "llama-server", "The llama-server binary to run")
+	port := fs.Int("port", 8080, "The port of the first llama-server command line, each model after it gets the next one")
+	ttl := fs.Int("ttl", 0, "Seconds llama-swap keeps an idle model loaded, 0 keeps it loaded until another model is needed")
+	templateDir := fs.String("template-dir", filepath.Join(utils.GetConfigDir(), "llamacpp"), "Directory the translated chat templates are written to")
+	noTemplate := fs.Bool("no-template", false, "Use the chat template in each model's GGUF file rather than translating the Modelfile's")
+	if err := fs.Parse(args); err != nil {
+		return flagExitCode(err)
+	}
+	if *swapPath == "" && *commandsPath == "" {
+		*swapPath = "-"
+	}
+	if *swapPath == "-" && *commandsPath == "-" {
+		return commandError("Error: only one of --llama-swap and --commands can be written to stdout")
+	}
+	if *noTemplate {
+		*templateDir = ""
+	}
+
+	client, err := newAPIClient(cfg)
+	if err != nil {
+		return commandError("Error: %v", err)
+	}
+	modelNames := fs.Args()
+	if len(modelNames) == 0 {
+		list, err := client.List(context.Background())
+		if err != nil {
+			return commandError("Error listing models: %v", err)
+		}
+		for _, model := range list.Models {
+			modelNames = append(modelNames, model.Name)
+		}
+		sort.Strings(modelNames)
+	}
+	if !utils.IsLocalhost(cfg.OllamaAPIURL) {
+		fmt.Fprintln(os.Stderr, styles.InfoStyle().Render(fmt.Sprintf("The model paths are where %s keeps its blobs", cfg.OllamaAPIURL)))
+	}
+
+	exitCode := 0
+	var names []string
+	serverArgs := make(map[string][]string)
+	unmapped := make(map[string][]string)
+	for _, name := range modelNames {
+		model, templateErr, err := llamaCppModel(name, *templateDir, client)
+		if err != nil {
+			exitCode = commandError("Error: %v", err)
+			continue
+		}
+		if templateErr != nil {
+			fmt.Fprintln(os.Stderr, styles.WarningStyle().Render(fmt.Sprintf("Using the chat template in the GGUF file for %s: %v", name, templateErr)))
+		}
+		args, modelUnmapped := model.Args()
+		names = append(names, name)
+		serverArgs[name] = args
+		if len(modelUnmapped) > 0 {
+			unmapped[name] = modelUnmapped
+		}
+	}
+
+	if *swapPath != "" {
+		data, err := llamacpp.NewSwapConfig(*server, *ttl, serverArgs).Marshal()
+		if err == nil {
+			err = writeOutput(*swapPath, data, 0o644)
+		}
+		if err != nil {
+			return commandError("Error writing the llama-swap config: %v", err)
+		}
+	}
+	if *commandsPath != "" {
+		var script strings.Builder
+		script.WriteString("#!/bin/sh\n# llama-server command lines for Ollama models, generated by gollama export-llamacpp.\n# Each runs its server in the foreground, run the ones you need.\n")
+		for i, name := range names {
+			fmt.Fprintf(&script, "\n# %s\n%s\n", name, llamacpp.Command(*server, serverArgs[name], strconv.Itoa(*port+i)))
+		}
+		if err := writeOutput(*commandsPath, []byte(script.String()), 0o755); err != nil {
+			return commandError("Error writing the llama-server commands: %v", err)
+		}
+	}
+
+	if len(unmapped) > 0 {
+		// stdout may be the config, so the summary goes to stderr
+		printUnmappedParameters(os.Stderr, "llama-server", unmapped)
+	}
+	for _, path := range []string{*swapPath, *commandsPath} {
+		if path != "" && path != "-" {
+			fmt.Fprintln(os.Stderr, styles.SuccessStyle().Render(fmt.Sprintf("Wrote %d models to %s", len(names), path)))
+		}
+	}
+	return exitCode
+}
@@ -0,0 +1,189 @@
+// Package llamacpp turns Ollama models into llama.cpp llama-server command lines and llama-swap configuration that
+// load the model's blobs in place, translating the Modelfile's parameters and chat template into llama-server flags.
+package llamacpp
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/mipalgu/gollama/lmstudio"
+)
+
+// DefaultContextSize is the context Ollama gives models that don't set num_ctx, llama-server would otherwise use
+// the model's full training context
+const DefaultContextSize = 4096
+
+// AllGPULayers offloads every layer, llama-server only offloads what it's asked to while Ollama offloads as many
+// layers as fit when num_gpu isn't set
+const AllGPULayers = 999
+
+// SwapPort is the macro llama-swap replaces with the port it gives each model
+const SwapPort = "${PORT}"
+
+// flags are the llama-server flags for Modelfile parameters that take a value
+var flags = map[string]string{
+	"num_ctx":           "--ctx-size",
+	"num_gpu":           "--n-gpu-layers",
+	"num_thread":        "--threads",
+	"num_batch":         "--batch-size",
+	"num_keep":          "--keep",
+	"num_predict":       "--n-predict",
+	"temperature":       "--temp",
+	"top_k":             "--top-k",
+	"top_p":             "--top-p",
+	"min_p":             "--min-p",
+	"typical_p":         "--typical",
+	"repeat_penalty":    "--repeat-penalty",
+	"repeat_last_n":     "--repeat-last-n",
+	"presence_penalty":  "--presence-penalty",
+	"frequency_penalty": "--frequency-penalty",
+	"mirostat":          "--mirostat",
+	"mirostat_eta":      "--mirostat-lr",
+	"mirostat_tau":      "--mirostat-ent",
+	"seed":              "--seed",
+	"stop":              "--reverse-prompt",
+}
+
+// switches are the llama-server flags for boolean Modelfile parameters, used when the parameter has the value
+var switches = map[string]struct {
+	value bool
+	flag  string
+}{
+	"use_mmap":  {false, "--no-mmap"},
+	"use_mlock": {true, "--mlock"},
+}
+
+// Model is an Ollama model served by llama-server
+type Model struct {
+	// Name is the Ollama model name, llama-server reports it as the model's alias
+	Name string
+	// Path is the model's main GGUF file and Projector its vision projector, "" if it has none
+	Path      string
+	Projector string
+	// ChatTemplateFile is a Jinja chat template to use instead of the one in the GGUF file, "" if there isn't one
+	ChatTemplateFile string
+	// Parameters are the Modelfile's parameters
+	Parameters map[string][]string
+}
+
+// Args returns the llama-server arguments that load the model with its parameters, without the port, and the
+// parameters with no llama-server equivalent
+func (m Model) Args() ([]string, []string) {
+	args := []string{"--model", m.Path, "--alias", m.Name}
+	if m.Projector != "" {
+		args = append(args, "--mmproj", m.Projector)
+	}
+	// --jinja uses the GGUF's chat template, or the translated one, with tool calls rather than llama.cpp's built
+	// in approximations
+	args = append(args, "--jinja")
+	if m.ChatTemplateFile != "" {
+		args = append(args, "--chat-template-file", m.ChatTemplateFile)
+	}
+
+	names := make([]string, 0, len(m.Parameters))
+	for name := range m.Parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if _, ok := m.Parameters["num_ctx"]; !ok {
+		args = append(args, "--ctx-size", strconv.Itoa(DefaultContextSize))
+	}
+	if _, ok := m.Parameters["num_gpu"]; !ok {
+		args = append(args, "--n-gpu-layers", strconv.Itoa(AllGPULayers))
+	}
+
+	var unmapped []string
+	for _, name := range names {
+		values := m.Parameters[name]
+		if len(values) == 0 {
+			continue
+		}
+		if flag, ok := flags[name]; ok {
+			for _, value := range values {
+				args = append(args, flag, value)
+			}
+			continue
+		}
+		if sw, ok := switches[name]; ok {
+			// llama-server, like Ollama, takes the last occurrence of a repeated parameter
+			value, err := strconv.ParseBool(values[len(values)-1])
+			if err == nil && value == sw.value {
+				args = append(args, sw.flag)
+			}
+			continue
+		}
+		unmapped = append(unmapped, name)
+	}
+	return args, unmapped
+}
+
+// ConvertChatTemplate converts an Ollama Go template to the Jinja chat template llama-server reads with
+// --chat-template-file. Only templates that use .Messages can be converted, llama-server passes the conversation
+// as messages rather than the .System and .Prompt of legacy templates.
+func ConvertChatTemplate(goTemplate string) (string, error) {
+	if strings.TrimSpace(goTemplate) == "" {
+		return "", fmt.Errorf("the model has no template")
+	}
+	if !strings.Contains(goTemplate, ".Messages") {
+		return "", fmt.Errorf("the template uses .System and .Prompt rather than .Messages, which llama-server doesn't provide")
+	}
+	return lmstudio.ConvertGoTemplateToJinja(goTemplate)
+}
+
+// Command returns a shell command line running server with args on port, a port number or SwapPort
+func Command(server string, args []string, port string) string {
+	quoted := []string{shellQuote(server), "--port", port}
+	for _, arg := range args {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote single quotes an argument if the shell, or llama-swap's command parser, would otherwise split or
+// expand it
+func shellQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$`!*?[]{}()<>|&;#~") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// SwapConfig is a llama-swap config.yaml
+type SwapConfig struct {
+	Models map[string]SwapModel `yaml:"models"`
+}
+
+// SwapModel is a model llama-swap starts on demand
+type SwapModel struct {
+	Cmd string `yaml:"cmd"`
+	// TTL is how many seconds llama-swap leaves the model loaded after its last request, 0 keeps it loaded
+	TTL int `yaml:"ttl,omitempty"`
+}
+
+// NewSwapConfig returns a llama-swap config starting each model with server, named by its Ollama name
+func NewSwapConfig(server string, ttl int, models map[string][]string) *SwapConfig {
+	config := &SwapConfig{Models: make(map[string]SwapModel)}
+	for name, args := range models {
+		config.Models[name] = SwapModel{Cmd: Command(server, args, SwapPort), TTL: ttl}
+	}
+	return config
+}
+
+// Marshal returns the config as YAML, indented like llama-swap's examples
+func (c *SwapConfig) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return nil, fmt.Errorf("failed to marshal llama-swap config: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,91 @@
+package llamacpp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestArgs(t *testing.T) {
+	model := Model{
+		Name:             "qwen2.5vl:7b",
+		Path:             "/blobs/sha256-model",
+		Projector:        "/blobs/sha256-projector",
+		ChatTemplateFile: "/templates/qwen2.5vl-7b.jinja",
+		Parameters: map[string][]string{
+			"num_ctx":     {"8192"},
+			"temperature": {"0.6"},
+			"stop":        {"<|im_start|>", "<|im_end|>"},
+			"use_mmap":    {"true", "false"},
+			"use_mlock":   {"true", "false"},
+			"penalize_nl": {"true"},
+		},
+	}
+	args, unmapped := model.Args()
+	want := []string{
+		"--model", "/blobs/sha256-model", "--alias", "qwen2.5vl:7b", "--mmproj", "/blobs/sha256-projector",
+		"--jinja", "--chat-template-file", "/templates/qwen2.5vl-7b.jinja", "--n-gpu-layers", "999",
+		"--ctx-size", "8192", "--reverse-prompt", "<|im_start|>", "--reverse-prompt", "<|im_end|>", "--temp", "0.6", "--no-mmap",
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("Args() = %v\nwant %v", args, want)
+	}
+	if !reflect.DeepEqual(unmapped, []string{"penalize_nl"}) {
+		t.Errorf("unmapped = %v, want [penalize_nl]", unmapped)
+	}
+
+	// Models without num_ctx get Ollama's default context rather than their full training context
+	args, _ = Model{Name: "llama3", Path: "/blobs/sha256-model", Parameters: map[string][]string{"num_gpu": {"20"}}}.Args()
+	want = []string{"--model", "/blobs/sha256-model", "--alias", "llama3", "--jinja", "--ctx-size", "4096", "--n-gpu-layers", "20"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("Args() = %v\nwant %v", args, want)
+	}
+}
+
+func TestConvertChatTemplate(t *testing.T) {
+	jinja, err := ConvertChatTemplate(`{{- range .Messages }}<|im_start|>{{ .Role }}
+{{ .Content }}<|im_end|>
+{{ end }}<|im_start|>assistant
+`)
+	if err != nil {
+		t.Fatalf("ConvertChatTemplate failed: %v", err)
+	}
+	if !strings.Contains(jinja, "for message in messages") {
+		t.Errorf("Expected a loop over the messages, got %s", jinja)
+	}
+
+	for _, template := range []string{"", "{{ .System }} USER: {{ .Prompt }} ASSISTANT:"} {
+		if _, err := ConvertChatTemplate(template); err == nil {
+			t.Errorf("Expected an error converting %q", template)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	got := Command("/opt/llama.cpp/llama-server", []string{"--model", "/Users/me/Ollama Models/blobs/sha256-abc", "--alias", "qwen3:8b", "--reverse-prompt", "it's"}, SwapPort)
+	want := `/opt/llama.cpp/llama-server --port ${PORT} --model '/Users/me/Ollama Models/blobs/sha256-abc' --alias qwen3:8b --reverse-prompt 'it'\''s'`
+	if got != want {
+		t.Errorf("Command() = %s\nwant %s", got, want)
+	}
+}
+
+func TestSwapConfig(t *testing.T) {
+	data, err := NewSwapConfig("llama-server", 300, map[string][]string{
+		"qwen3:8b":  {"--model", "/blobs/sha256-qwen"},
+		"llama3:8b": {"--model", "/blobs/sha256-llama"},
+	}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `models:
+  llama3:8b:
+    cmd: llama-server --port ${PORT} --model /blobs/sha256-llama
+    ttl: 300
+  qwen3:8b:
+    cmd: llama-server --port ${PORT} --model /blobs/sha256-qwen
+    ttl: 300
+`
+	if string(data) != want {
+		t.Errorf("Marshal() =\n%s\nwant\n%s", data, want)
+	}
+}
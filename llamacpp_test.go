@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLlamaCppModel(t *testing.T) {
+	client := newFakeShowHost(t, map[string]string{
+		"qwen2.5vl:7b":   "FROM /blobs/sha256-model\nFROM /blobs/sha256-projector\nTEMPLATE \"{{ range .Messages }}<|im_start|>{{ .Role }}\n{{ .Content }}<|im_end|>\n{{ end }}<|im_start|>assistant\n\"\nPARAMETER num_ctx 8192\n",
+		"legacy:latest":  "FROM /blobs/sha256-legacy\nTEMPLATE \"{{ .System }} USER: {{ .Prompt }} ASSISTANT:\"\n",
+		"invalid:latest": "FROM /blobs/sha256-invalid\nPARAMETER num_ctx\n",
+	}).client
+	templateDir := t.TempDir()
+
+	model, templateErr, err := llamaCppModel("qwen2.5vl:7b", templateDir, client)
+	if err != nil || templateErr != nil {
+		t.Fatalf("llamaCppModel failed: %v, %v", templateErr, err)
+	}
+	if model.Path != "/blobs/sha256-model" || model.Projector != "/blobs/sha256-projector" || model.Parameters["num_ctx"][0] != "8192" {
+		t.Errorf("Unexpected model %+v", model)
+	}
+	if model.ChatTemplateFile != filepath.Join(templateDir, "qwen2.5vl-7b.jinja") {
+		t.Errorf("Expected the template to be written to the template directory, got %q", model.ChatTemplateFile)
+	}
+	if data, err := os.ReadFile(model.ChatTemplateFile); err != nil || !strings.Contains(string(data), "messages") {
+		t.Errorf("Expected a Jinja chat template, got %q, %v", data, err)
+	}
+
+	// Legacy templates can't be translated, so the GGUF's own template is used
+	model, templateErr, err = llamaCppModel("legacy:latest", templateDir, client)
+	if err != nil || templateErr == nil || model.ChatTemplateFile != "" {
+		t.Errorf("Expected no chat template file for a legacy template, got %+v, %v, %v", model, templateErr, err)
+	}
+
+	// A Modelfile that can't be parsed still gives a model, without its parameters
+	model, _, err = llamaCppModel("invalid:latest", "", client)
+	if err != nil || model.Path != "/blobs/sha256-invalid" || len(model.Parameters) != 0 {
+		t.Errorf("Expected the model without parameters, got %+v, %v", model, err)
+	}
+}
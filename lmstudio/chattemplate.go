@@ -0,0 +1,229 @@
+package lmstudio
+
+import (
+	"strings"
+
+	"github.com/ollama/ollama/template"
+
+	"github.com/mipalgu/gollama/gguf"
+	"github.com/mipalgu/gollama/logging"
+)
+
+// ChatTemplate is an Ollama Go TEMPLATE and the stop parameters that go with it
+type ChatTemplate struct {
+	// Name is the Ollama template the GGUF chat template matched, or the family preset used instead
+	Name     string
+	Template string
+	Stop     []string
+	// FromGGUF is true when the template was translated from the model's own chat template rather than
+	// chosen from its architecture
+	FromGGUF bool
+}
+
+// chatPreset is a family's Go template and stop parameters, used when the GGUF chat template can't be translated
+type chatPreset struct {
+	name     string
+	template string
+	stop     []string
+	// markers are special tokens whose presence in a Jinja chat template identifies the family
+	markers []string
+}
+
+// chatPresets follow the templates in Ollama's library, in the order their markers are checked
+var chatPresets = []chatPreset{
+	{
+		name: "chatml",
+		template: `{{- range .Messages }}<|im_start|>{{ .Role }}
+{{ .Content }}<|im_end|>
+{{ end }}<|im_start|>assistant
+`,
+		stop:    []string{"<|im_start|>", "<|im_end|>"},
+		markers: []string{"<|im_start|>"},
+	},
+	{
+		name: "llama3",
+		template: `{{- range .Messages }}<|start_header_id|>{{ .Role }}<|end_header_id|>
+
+{{ .Content }}<|eot_id|>
+{{- end }}<|start_header_id|>assistant<|end_header_id|>
+
+`,
+		stop:    []string{"<|start_header_id|>", "<|end_header_id|>", "<|eot_id|>"},
+		markers: []string{"<|start_header_id|>"},
+	},
+	{
+		name: "gemma",
+		template: `{{- $system := "" }}
+{{- range .Messages }}
+{{- if eq .Role "system" }}
+{{- if not $system }}{{ $system = .Content }}
+{{- else }}{{ $system = printf "%s\n\n%s" $system .Content }}
+{{- end }}
+{{- continue }}
+{{- else if eq .Role "user" }}<start_of_turn>user
+{{- if $system }}
+{{ $system }}
+{{- $system = "" }}
+{{- end }}
+{{- else if eq .Role "assistant" }}<start_of_turn>model
+{{- end }}
+{{ .Content }}<end_of_turn>
+{{ end }}<start_of_turn>model
+`,
+		stop:    []string{"<end_of_turn>"},
+		markers: []string{"<start_of_turn>"},
+	},
+	{
+		name: "deepseek",
+		template: `{{- range .Messages }}
+{{- if eq .Role "system" }}{{ .Content }}
+{{- else if eq .Role "user" }}<｜User｜>{{ .Content }}
+{{- else if eq .Role "assistant" }}<｜Assistant｜>{{ .Content }}<｜end▁of▁sentence｜>
+{{- end }}
+{{- end }}<｜Assistant｜>`,
+		stop:    []string{"<｜begin▁of▁sentence｜>", "<｜end▁of▁sentence｜>", "<｜User｜>", "<｜Assistant｜>"},
+		markers: []string{"<｜User｜>"},
+	},
+	{
+		name: "phi-3",
+		template: `{{- range .Messages }}<|{{ .Role }}|>
+{{ .Content }}<|end|>
+{{ end }}<|assistant|>
+`,
+		stop:    []string{"<|end|>", "<|system|>", "<|user|>", "<|assistant|>"},
+		markers: []string{"<|user|>", "<|end|>"},
+	},
+	{
+		name: "mistral",
+		template: `[INST] {{ range $index, $_ := .Messages }}
+{{- if eq .Role "system" }}{{ .Content }}
+
+{{ else if eq .Role "user" }}{{ .Content }}[/INST]
+{{- else if eq .Role "assistant" }} {{ .Content }}</s>[INST] {{ end }}
+{{- end }}`,
+		stop:    []string{"[INST]", "[/INST]", "</s>"},
+		markers: []string{"[INST]"},
+	},
+}
+
+// architecturePresets maps GGUF architectures to the family preset their instruct models usually use.
+// llama is resolved from the vocabulary because Llama 3, Mistral and many fine-tunes share it.
+var architecturePresets = map[string]string{
+	"qwen2":     "chatml",
+	"qwen2moe":  "chatml",
+	"qwen2vl":   "chatml",
+	"qwen3":     "chatml",
+	"qwen3moe":  "chatml",
+	"internlm2": "chatml",
+	"minicpm":   "chatml",
+	"olmo2":     "chatml",
+	"gemma":     "gemma",
+	"gemma2":    "gemma",
+	"gemma3":    "gemma",
+	"gemma3n":   "gemma",
+	"phi3":      "phi-3",
+	"deepseek2": "deepseek",
+	"mistral3":  "mistral",
+}
+
+// ResolveChatTemplate chooses the Ollama template for a GGUF model file. The Jinja tokenizer.chat_template is
+// matched against Ollama's known templates, then by its special tokens, and if neither works a family preset
+// is chosen from the architecture. It returns nil when the file can't be read or nothing fits.
+func ResolveChatTemplate(path string) *ChatTemplate {
+	file, err := gguf.Open(path)
+	if err != nil {
+		logging.DebugLogger.Printf("Couldn't read a chat template from %s: %v", path, err)
+		return nil
+	}
+	return chatTemplateFor(file)
+}
+
+func chatTemplateFor(file *gguf.File) *ChatTemplate {
+	jinja := file.ChatTemplate()
+	if jinja != "" {
+		if named, err := template.Named(jinja); err == nil {
+			chat := &ChatTemplate{Name: named.Name, Template: string(named.Bytes), FromGGUF: true}
+			if named.Parameters != nil {
+				chat.Stop = named.Parameters.Stop
+			}
+			if len(chat.Stop) == 0 {
+				chat.Stop = specialTokens(file, jinja)
+			}
+			return chat
+		}
+		for _, preset := range chatPresets {
+			if containsAll(jinja, preset.markers) {
+				chat := preset.chatTemplate()
+				chat.FromGGUF = true
+				if stop := specialTokens(file, jinja); len(stop) > 0 {
+					chat.Stop = stop
+				}
+				return chat
+			}
+		}
+		logging.DebugLogger.Printf("Couldn't translate the %s chat template, choosing one from the architecture", file.Architecture())
+	}
+
+	name := architecturePresets[file.Architecture()]
+	if file.Architecture() == "llama" {
+		name = llamaPreset(file)
+	}
+	for _, preset := range chatPresets {
+		if preset.name == name {
+			return preset.chatTemplate()
+		}
+	}
+	return nil
+}
+
+func (p chatPreset) chatTemplate() *ChatTemplate {
+	return &ChatTemplate{Name: p.name, Template: p.template, Stop: append([]string(nil), p.stop...)}
+}
+
+// llamaPreset tells Llama 3 derived models from Mistral and Llama 2 style ones by their control tokens
+func llamaPreset(file *gguf.File) string {
+	tokens, _ := file.Strings("tokenizer.ggml.tokens")
+	for _, token := range tokens {
+		switch token {
+		case "<|eot_id|>":
+			return "llama3"
+		case "<|im_start|>":
+			return "chatml"
+		case "[INST]":
+			return "mistral"
+		}
+	}
+	return "mistral"
+}
+
+// specialTokens returns the control tokens used in a Jinja chat template, other than the BOS token, which are
+// the tokens a model emits to end its turn or start a new one
+func specialTokens(file *gguf.File, jinja string) []string {
+	const controlToken = 3
+	tokens, ok := file.Strings("tokenizer.ggml.tokens")
+	types, typesOK := file.Metadata["tokenizer.ggml.token_type"].(gguf.Array)
+	if !ok || !typesOK || len(types.Values) != len(tokens) {
+		return nil
+	}
+	bos, _ := file.Token("tokenizer.ggml.bos_token_id")
+
+	var stop []string
+	for i, token := range tokens {
+		if tokenType, ok := types.Values[i].(int32); !ok || tokenType != controlToken {
+			continue
+		}
+		if token != "" && token != bos && strings.Contains(jinja, token) {
+			stop = append(stop, token)
+		}
+	}
+	return stop
+}
+
+func containsAll(s string, substrings []string) bool {
+	for _, substring := range substrings {
+		if !strings.Contains(s, substring) {
+			return false
+		}
+	}
+	return true
+}
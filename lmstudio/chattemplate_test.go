@@ -0,0 +1,174 @@
+package lmstudio
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	texttemplate "text/template"
+
+	"github.com/ollama/ollama/template"
+
+	"github.com/mipalgu/gollama/gguf"
+	"github.com/mipalgu/gollama/modelfile"
+)
+
+// qwenTemplate is too far from any template Ollama knows to be matched by name, so it's recognised by its tokens
+const qwenTemplate = `{%- if tools %}{{- '<|im_start|>system\n' }}{%- if messages[0].role == 'system' %}{{- messages[0].content + '\n\n' }}{%- endif %}{{- "# Tools\n\nYou may call one or more functions to assist with the user query.\n\nYou are provided with function signatures within <tools></tools> XML tags:\n<tools>" }}{%- for tool in tools %}{{- "\n" }}{{- tool | tojson }}{%- endfor %}{{- "\n</tools>\n\nFor each function call, return a json object with function name and arguments within <tool_call></tool_call> XML tags" }}{%- else %}{%- if messages[0].role == 'system' %}{{- '<|im_start|>system\n' + messages[0].content + '<|im_end|>\n' }}{%- endif %}{%- endif %}{%- for message in messages %}{{- '<|im_start|>' + message.role + '\n' + message.content + '<|im_end|>' + '\n' }}{%- endfor %}{%- if add_generation_prompt %}{{- '<|im_start|>assistant\n' }}{%- endif %}`
+
+// controlTokens builds a vocabulary where the given tokens are control tokens and the rest normal text
+func controlTokens(control []string, normal ...string) map[string]any {
+	tokens := gguf.Array{Type: gguf.TypeString}
+	types := gguf.Array{Type: gguf.TypeInt32}
+	for _, token := range control {
+		tokens.Values = append(tokens.Values, token)
+		types.Values = append(types.Values, int32(3))
+	}
+	for _, token := range normal {
+		tokens.Values = append(tokens.Values, token)
+		types.Values = append(types.Values, int32(1))
+	}
+	return map[string]any{"tokenizer.ggml.tokens": tokens, "tokenizer.ggml.token_type": types}
+}
+
+func TestChatTemplateFor(t *testing.T) {
+	chatmlJinja := "{% for message in messages %}{{'<|im_start|>' + message['role'] + '\n' + message['content'] + '<|im_end|>' + '\n'}}{% endfor %}{% if add_generation_prompt %}{{ '<|im_start|>assistant\n' }}{% endif %}"
+
+	tests := []struct {
+		name     string
+		metadata map[string]any
+		want     *ChatTemplate
+	}{
+		{
+			name: "known template matched by name",
+			metadata: map[string]any{
+				"general.architecture":    "qwen2",
+				"tokenizer.chat_template": chatmlJinja,
+			},
+			want: &ChatTemplate{Name: "chatml", Stop: []string{"<|im_start|>", "<|im_end|>"}, FromGGUF: true},
+		},
+		{
+			name: "unknown template recognised by its special tokens",
+			metadata: mergeMetadata(controlTokens([]string{"<|endoftext|>", "<|im_start|>", "<|im_end|>"}, "<tool_call>"), map[string]any{
+				"general.architecture":        "qwen3",
+				"tokenizer.chat_template":     qwenTemplate,
+				"tokenizer.ggml.bos_token_id": uint32(0),
+			}),
+			want: &ChatTemplate{Name: "chatml", Stop: []string{"<|im_start|>", "<|im_end|>"}, FromGGUF: true},
+		},
+		{
+			name: "untranslatable template falls back to the architecture",
+			metadata: map[string]any{
+				"general.architecture":    "gemma3",
+				"tokenizer.chat_template": "{{ raise_exception('unsupported') }}",
+			},
+			want: &ChatTemplate{Name: "gemma", Stop: []string{"<end_of_turn>"}},
+		},
+		{
+			name: "llama 3 vocabulary without a template",
+			metadata: mergeMetadata(controlTokens([]string{"<|begin_of_text|>", "<|eot_id|>"}), map[string]any{
+				"general.architecture": "llama",
+			}),
+			want: &ChatTemplate{Name: "llama3", Stop: []string{"<|start_header_id|>", "<|end_header_id|>", "<|eot_id|>"}},
+		},
+		{
+			name:     "unknown architecture",
+			metadata: map[string]any{"general.architecture": "rwkv7"},
+			want:     nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := chatTemplateFor(&gguf.File{Metadata: tt.metadata})
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("Expected no template, got %s", got.Name)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("Expected a template, got nil")
+			}
+			if got.Name != tt.want.Name || got.FromGGUF != tt.want.FromGGUF || !reflect.DeepEqual(got.Stop, tt.want.Stop) {
+				t.Errorf("Got %s (from GGUF %v) stop %q, want %s (from GGUF %v) stop %q", got.Name, got.FromGGUF, got.Stop, tt.want.Name, tt.want.FromGGUF, tt.want.Stop)
+			}
+			if got.Template == "" {
+				t.Error("Template is empty")
+			}
+		})
+	}
+}
+
+func mergeMetadata(maps ...map[string]any) map[string]any {
+	merged := make(map[string]any)
+	for _, m := range maps {
+		for k, v := range m {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+func TestChatPresetsParse(t *testing.T) {
+	for _, preset := range chatPresets {
+		if _, err := template.Parse(preset.template); err != nil {
+			t.Errorf("%s preset doesn't parse as an Ollama template: %v", preset.name, err)
+		}
+	}
+	for arch, name := range architecturePresets {
+		found := false
+		for _, preset := range chatPresets {
+			found = found || preset.name == name
+		}
+		if !found {
+			t.Errorf("Architecture %s uses missing preset %s", arch, name)
+		}
+	}
+}
+
+func TestGenerateModelfileContentUsesChatTemplate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "model.gguf")
+	writeTestGGUF(t, path, map[string]any{"general.architecture": "phi3"})
+
+	content, err := generateModelfileContent("model", "/ollama/model.gguf", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"# Stop tokens for the phi-3 chat template", `PARAMETER stop "<|end|>"`, `TEMPLATE """{{- range .Messages }}<|{{ .Role }}|>`} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Modelfile doesn't contain %q:\n%s", want, content)
+		}
+	}
+	if strings.Contains(content, `PARAMETER stop "<|im_start|>"`) {
+		t.Errorf("Modelfile still has the ChatML stop tokens:\n%s", content)
+	}
+
+	// Without GGUF metadata the ChatML defaults are kept
+	other, _ := createTestFile(t, dir, "model.bin", "not gguf")
+	content, err = generateModelfileContent("model", "/ollama/model.bin", other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(content, `PARAMETER stop "<|im_start|>"`) || !strings.Contains(content, `# TEMPLATE "{{.Prompt}}"`) {
+		t.Errorf("Modelfile doesn't have the defaults:\n%s", content)
+	}
+}
+
+func TestModelfileQuotesStopStrings(t *testing.T) {
+	tmpl := texttemplate.Must(texttemplate.New("modelfile").Funcs(modelfileTemplateFuncs).Parse(ModelfileTemplate))
+	stop := []string{"<|end|>", `say "hi"`, `C:\path`, `"`}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, ModelfileData{ModelPath: "/ollama/model.gguf", TemplateName: "test", Stop: stop}); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := modelfile.Parse(buf.String())
+	if err != nil {
+		t.Fatalf("Modelfile doesn't parse: %v\n%s", err, buf.String())
+	}
+	if got := parsed.ParameterValues("stop"); !reflect.DeepEqual(got, stop) {
+		t.Errorf("Stop strings = %q, want %q", got, stop)
+	}
+}
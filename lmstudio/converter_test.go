@@ -42,9 +42,12 @@ func TestConvertGoTemplateToJinja(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := convertGoTemplateToJinja(tt.input)
+			result, err := ConvertGoTemplateToJinja(tt.input)
+			if err != nil {
+				t.Fatalf("ConvertGoTemplateToJinja() error = %v", err)
+			}
 			if result != tt.expected {
-				t.Errorf("convertGoTemplateToJinja() = %v, want %v", result, tt.expected)
+				t.Errorf("ConvertGoTemplateToJinja() = %v, want %v", result, tt.expected)
 			}
 		})
 	}
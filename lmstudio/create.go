@@ -6,13 +6,15 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/ollama/ollama/api"
+	"github.com/mipalgu/gollama/gguf"
+	"github.com/mipalgu/gollama/links"
 	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/ollamastore"
 	"github.com/mipalgu/gollama/utils"
 )
 
@@ -25,7 +27,23 @@ type LMStudioModel struct {
 	IsSymlinked bool     // Skip if already linked from Ollama
 	Publisher   string   // Extract from directory structure
 	ModelDir    string   // Publisher/model directory path
-	Size        int64    // File size in bytes
+	Size        int64    // File size in bytes, of every shard for split models
+
+	// Shards are the files of a model split by gguf-split in order, Path is the first of them, and MissingShards the
+	// names of the ones that aren't there. Both are empty for models in a single file.
+	Shards        []string
+	MissingShards []string
+
+	// Read from the GGUF metadata, empty or 0 for other files or if the metadata couldn't be read
+	Architecture  string
+	Quantization  string
+	ContextLength uint64
+	ChatTemplate  string
+}
+
+// OllamaName is the name CreateOllamaModel gives the model in Ollama
+func (m LMStudioModel) OllamaName() string {
+	return strings.ToLower(strings.ReplaceAll(m.Name, "/", "-"))
 }
 
 // ModelConfig contains configuration parameters for the model
@@ -81,18 +99,7 @@ var defaultConfig = ModelConfig{
 
 // calculateSHA256 calculates the SHA256 hash of a file
 func calculateSHA256(filePath string) (string, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to open file %s: %w", filePath, err)
-	}
-	defer file.Close()
-
-	hash := sha256.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return "", fmt.Errorf("failed to calculate hash for %s: %w", filePath, err)
-	}
-
-	return hex.EncodeToString(hash.Sum(nil)), nil
+	return ollamastore.HashFile(filePath, nil)
 }
 
 // isSymlink checks if a file is a symbolic link
@@ -129,8 +136,34 @@ func extractPublisherAndModel(modelPath, lmStudioDir string) (string, string, er
 	return publisher, model, nil
 }
 
+// isProjectorFile checks whether a model file is a vision projector from its GGUF metadata, falling back to
+// the mmproj naming convention for files that can't be parsed
+func isProjectorFile(path string) bool {
+	if !strings.EqualFold(filepath.Ext(path), ".gguf") {
+		return false
+	}
+	file, err := gguf.Open(path)
+	if err == nil {
+		return file.IsProjector()
+	}
+	logging.DebugLogger.Printf("Couldn't read GGUF metadata from %s, checking its name instead: %v", path, err)
+	return strings.Contains(strings.ToLower(filepath.Base(path)), "mmproj")
+}
+
+// projectorCache remembers which files are projectors, so a scan reads each GGUF header once
+type projectorCache map[string]bool
+
+func (c projectorCache) isProjector(path string) bool {
+	projector, ok := c[path]
+	if !ok {
+		projector = isProjectorFile(path)
+		c[path] = projector
+	}
+	return projector
+}
+
 // isVisionModel checks if a model directory contains vision projection files
-func isVisionModel(modelDir string) (bool, []string, error) {
+func isVisionModel(modelDir string, projectors projectorCache) (bool, []string, error) {
 	var visionFiles []string
 
 	err := filepath.Walk(modelDir, func(path string, info os.FileInfo, walkErr error) error {
@@ -142,9 +175,7 @@ func isVisionModel(modelDir string) (bool, []string, error) {
 			return nil
 		}
 
-		fileName := strings.ToLower(filepath.Base(path))
-		// Look for mmproj files (vision projection files)
-		if strings.Contains(fileName, "mmproj") && strings.HasSuffix(fileName, ".gguf") {
+		if projectors.isProjector(path) {
 			visionFiles = append(visionFiles, path)
 		}
 
@@ -162,6 +193,7 @@ func isVisionModel(modelDir string) (bool, []string, error) {
 func ScanUnlinkedModels(lmStudioDir string) ([]LMStudioModel, error) {
 	var models []LMStudioModel
 	seenDirs := make(map[string]bool)
+	projectors := make(projectorCache)
 
 	// First check if directory exists
 	if _, err := os.Stat(lmStudioDir); os.IsNotExist(err) {
@@ -189,8 +221,15 @@ func ScanUnlinkedModels(lmStudioDir string) ([]LMStudioModel, error) {
 				return nil
 			}
 
-			// Skip mmproj files as they'll be handled as vision files
-			if strings.Contains(strings.ToLower(filepath.Base(path)), "mmproj") {
+			// The shards of a split model are one model, found at its first shard
+			shards, missing := findShards(path)
+			if len(shards) > 0 && shards[0] != path {
+				logging.DebugLogger.Printf("Skipping %s, it's part of the split model %s", path, shards[0])
+				return nil
+			}
+
+			// Skip projector files as they'll be handled as vision files
+			if projectors.isProjector(path) {
 				return nil
 			}
 
@@ -220,7 +259,7 @@ func ScanUnlinkedModels(lmStudioDir string) ([]LMStudioModel, error) {
 			}
 			seenDirs[modelKey] = true
 
-			isVision, visionFiles, err := isVisionModel(modelDir)
+			isVision, visionFiles, err := isVisionModel(modelDir, projectors)
 			if err != nil {
 				logging.ErrorLogger.Printf("Error checking for vision files in %s: %v", modelDir, err)
 				// Continue processing as non-vision model
@@ -236,6 +275,15 @@ func ScanUnlinkedModels(lmStudioDir string) ([]LMStudioModel, error) {
 				ModelDir:    modelDir,
 				Size:        info.Size(),
 			}
+			if len(shards) > 0 {
+				model.Shards = shards
+				model.MissingShards = missing
+				model.Size = filesSize(shards)
+				logging.DebugLogger.Printf("Found split model: %s in %d files, missing %v", model.Name, len(shards), missing)
+			}
+			if ext == ".gguf" {
+				readGGUFMetadata(&model)
+			}
 
 			if isVision {
 				logging.DebugLogger.Printf("Found vision model: %s with %d vision files", model.Name, len(visionFiles))
@@ -262,6 +310,21 @@ func ScanUnlinkedModels(lmStudioDir string) ([]LMStudioModel, error) {
 	return models, nil
 }
 
+// readGGUFMetadata fills in the model's architecture, quantisation, context length and chat template
+func readGGUFMetadata(model *LMStudioModel) {
+	file, err := gguf.Open(model.Path)
+	if err != nil {
+		logging.DebugLogger.Printf("Couldn't read GGUF metadata from %s: %v", model.Path, err)
+		return
+	}
+	model.Architecture = file.Architecture()
+	if fileType, ok := file.FileType(); ok {
+		model.Quantization = fileType.String()
+	}
+	model.ContextLength = file.ContextLength()
+	model.ChatTemplate = file.ChatTemplate()
+}
+
 // generateManifest creates an OCI-compliant manifest for the model
 func generateManifest(model LMStudioModel, hashes map[string]string, config ModelConfig) (OllamaManifest, error) {
 	var layers []ManifestLayer
@@ -364,6 +427,29 @@ func cleanupBlobSymlinks(symlinks []string) {
 	}
 }
 
+// recordBlobSymlinks records the blob symlinks behind a created model in the link registry
+func recordBlobSymlinks(modelName string, symlinks []string, hashes map[string]string) {
+	sources := make(map[string]string, len(hashes))
+	for source, hash := range hashes {
+		sources[hash] = source
+	}
+	recorded := make([]links.Link, 0, len(symlinks))
+	for _, symlink := range symlinks {
+		hash := strings.TrimPrefix(filepath.Base(symlink), "sha256-")
+		recorded = append(recorded, links.Link{
+			Kind:      links.OllamaBlob,
+			Model:     modelName,
+			Digest:    "sha256:" + hash,
+			Source:    sources[hash],
+			Path:      symlink,
+			Projector: isProjectorFile(sources[hash]),
+		})
+	}
+	if err := links.Record(recorded...); err != nil {
+		logging.ErrorLogger.Printf("Warning: failed to record the blob symlinks for %s: %v", modelName, err)
+	}
+}
+
 // CreateOllamaModel creates an Ollama model from an LM Studio model
 func CreateOllamaModel(model LMStudioModel, dryRun bool, ollamaHost string, client *api.Client) error {
 	// Check if we're connecting to a local Ollama instance
@@ -371,7 +457,12 @@ func CreateOllamaModel(model LMStudioModel, dryRun bool, ollamaHost string, clie
 		return fmt.Errorf("creating Ollama models from LM Studio is only supported when connecting to a local Ollama instance (got %s)", ollamaHost)
 	}
 
-	modelName := strings.ToLower(strings.ReplaceAll(model.Name, "/", "-"))
+	modelName := model.OllamaName()
+	if err := splitModelError(model.Name, model.Shards, model.MissingShards); err != nil {
+		return err
+	}
+
+	chatTemplate := ResolveChatTemplate(model.Path)
 
 	if dryRun {
 		logging.InfoLogger.Printf("[DRY RUN] Would create Ollama model: %s", modelName)
@@ -379,6 +470,9 @@ func CreateOllamaModel(model LMStudioModel, dryRun bool, ollamaHost string, clie
 		if len(model.VisionFiles) > 0 {
 			logging.InfoLogger.Printf("[DRY RUN] Vision files: %v", model.VisionFiles)
 		}
+		if chatTemplate != nil {
+			logging.InfoLogger.Printf("[DRY RUN] Chat template: %s, stop: %q", chatTemplate.Name, chatTemplate.Stop)
+		}
 		return nil
 	}
 
@@ -475,6 +569,13 @@ func CreateOllamaModel(model LMStudioModel, dryRun bool, ollamaHost string, clie
 			"min_p":       config.MinP,
 		},
 	}
+	if chatTemplate != nil {
+		logging.DebugLogger.Printf("Using the %s chat template (from GGUF: %v)", chatTemplate.Name, chatTemplate.FromGGUF)
+		createRequest.Template = chatTemplate.Template
+		if len(chatTemplate.Stop) > 0 {
+			createRequest.Parameters["stop"] = chatTemplate.Stop
+		}
+	}
 
 	err = client.Create(context.Background(), &createRequest, func(resp api.ProgressResponse) error {
 		if resp.Status != "" {
@@ -489,6 +590,7 @@ func CreateOllamaModel(model LMStudioModel, dryRun bool, ollamaHost string, clie
 
 	// Success - don't clean up symlinks, Ollama needs them to access the model files
 	cleanupOnError = false
+	recordBlobSymlinks(modelName, createdSymlinks, hashes)
 	logging.InfoLogger.Printf("Successfully created Ollama model: %s", modelName)
 	logging.DebugLogger.Printf("Created %d permanent symlinks in Ollama blob store", len(createdSymlinks))
 	return nil
@@ -3,11 +3,14 @@ package lmstudio
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/mipalgu/gollama/internal/ggufwrite"
 )
 
 // createTestFile creates a temporary file with specified content and returns its path and SHA256 hash
@@ -211,3 +214,106 @@ func TestSymlinkIntegration(t *testing.T) {
 		}
 	}
 }
+
+// writeTestGGUF writes a GGUF header with the given metadata, which is all the scanner reads
+func writeTestGGUF(t *testing.T, path string, metadata map[string]any) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := ggufwrite.Write(f, metadata, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScanUnlinkedModelsReadsGGUFMetadata(t *testing.T) {
+	lmStudioDir := t.TempDir()
+	modelDir := filepath.Join(lmStudioDir, "publisher", "vision-model-GGUF")
+	writeTestGGUF(t, filepath.Join(modelDir, "vision-model-Q4_K_M.gguf"), map[string]any{
+		"general.architecture":    "gemma3",
+		"general.file_type":       uint32(15),
+		"gemma3.context_length":   uint32(131072),
+		"tokenizer.chat_template": "{{ messages }}",
+	})
+	// Projectors are found from their metadata, whatever they're called
+	writeTestGGUF(t, filepath.Join(modelDir, "vision-projector-f16.gguf"), map[string]any{
+		"general.architecture": "clip",
+		"general.file_type":    uint32(1),
+	})
+
+	models, err := ScanUnlinkedModels(lmStudioDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 {
+		t.Fatalf("Expected 1 model, got %d: %+v", len(models), models)
+	}
+	model := models[0]
+	if model.Name != "publisher/vision-model" {
+		t.Errorf("Name = %q", model.Name)
+	}
+	if model.Architecture != "gemma3" || model.Quantization != "Q4_K_M" || model.ContextLength != 131072 || model.ChatTemplate != "{{ messages }}" {
+		t.Errorf("Metadata not read: %+v", model)
+	}
+	if len(model.VisionFiles) != 1 || filepath.Base(model.VisionFiles[0]) != "vision-projector-f16.gguf" {
+		t.Errorf("VisionFiles = %v, want the clip projector", model.VisionFiles)
+	}
+}
+
+func TestIsProjectorFileFallsBackToName(t *testing.T) {
+	dir := t.TempDir()
+	mmproj, _ := createTestFile(t, dir, "mmproj-model-f16.gguf", "not really gguf")
+	model, _ := createTestFile(t, dir, "model-Q8_0.gguf", "not really gguf")
+	if !isProjectorFile(mmproj) {
+		t.Error("Unparseable mmproj files should still be treated as projectors")
+	}
+	if isProjectorFile(model) {
+		t.Error("Unparseable model files aren't projectors")
+	}
+}
+
+func TestScanSplitModels(t *testing.T) {
+	lmStudioDir := t.TempDir()
+	modelDir := filepath.Join(lmStudioDir, "publisher", "big-model-GGUF")
+	if err := os.MkdirAll(modelDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for i, content := range []string{"first", "second", "third"} {
+		createTestFile(t, modelDir, fmt.Sprintf("big-model-Q4_K_M-%05d-of-00003.gguf", i+1), content)
+	}
+
+	models, err := ScanUnlinkedModels(lmStudioDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 {
+		t.Fatalf("Expected the shards to be 1 model, got %d: %+v", len(models), models)
+	}
+	model := models[0]
+	if len(model.Shards) != 3 || model.Path != model.Shards[0] || len(model.MissingShards) != 0 || model.Size != int64(len("firstsecondthird")) {
+		t.Errorf("Unexpected split model %+v", model)
+	}
+	if err := CreateOllamaModel(model, true, "http://localhost:11434", nil); !errors.Is(err, ErrSplitModel) || !strings.Contains(err.Error(), "llama-gguf-split --merge") {
+		t.Errorf("Expected split models to be refused with how to merge them, got %v", err)
+	}
+
+	// Models with missing shards are still found, so they can be reported
+	if err := os.Remove(filepath.Join(modelDir, "big-model-Q4_K_M-00001-of-00003.gguf")); err != nil {
+		t.Fatal(err)
+	}
+	linkModels, err := ScanModels(lmStudioDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(linkModels) != 1 || linkModels[0].Name != "big-model-Q4_K_M" || len(linkModels[0].Shards) != 2 || linkModels[0].Size != int64(len("secondthird")) {
+		t.Fatalf("Unexpected models %+v", linkModels)
+	}
+	if err := LinkModelToOllama(linkModels[0], true, "http://localhost:11434", t.TempDir()); err == nil || !strings.Contains(err.Error(), "big-model-Q4_K_M-00001-of-00003.gguf") {
+		t.Errorf("Expected the missing shard to be reported, got %v", err)
+	}
+}
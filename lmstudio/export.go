@@ -5,12 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/ollama/ollama/api"
 	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/modelfile"
 )
 
 // LMStudioConfig represents the complete configuration for a model
@@ -113,6 +113,8 @@ type LMStudioPreset struct {
 	Changed    bool                `json:"changed"`
 	Operation  PresetOperation     `json:"operation"`
 	Load       PresetLoad          `json:"load"`
+	// Unmapped are the Modelfile parameters LM Studio has no equivalent for, they're not written to the preset
+	Unmapped []string `json:"-"`
 }
 
 // PresetOperation contains prediction configuration fields
@@ -165,8 +167,9 @@ type InferenceParams struct {
 }
 
 // ExportModelPreset exports Ollama Modelfile as an LM Studio preset
-// to ~/.lmstudio/config-presets/ for manual loading via LM Studio UI
-func ExportModelPreset(modelName, lmStudioModelName string, client *api.Client) error {
+// to ~/.lmstudio/config-presets/ for manual loading via LM Studio UI.
+// It returns the Modelfile parameters that couldn't be included in the preset.
+func ExportModelPreset(modelName, lmStudioModelName string, client *api.Client) ([]string, error) {
 	// Get the Modelfile from Ollama
 	ctx := context.Background()
 	req := &api.ShowRequest{
@@ -175,7 +178,7 @@ func ExportModelPreset(modelName, lmStudioModelName string, client *api.Client)
 
 	resp, err := client.Show(ctx, req)
 	if err != nil {
-		return fmt.Errorf("failed to get model info: %w", err)
+		return nil, fmt.Errorf("failed to get model info: %w", err)
 	}
 
 	// Parse the Modelfile
@@ -191,25 +194,23 @@ func ExportModelPreset(modelName, lmStudioModelName string, client *api.Client)
 	// Convert to LM Studio preset format
 	preset, err := ConvertToLMStudioPreset(parsed, lmStudioModelName)
 	if err != nil {
-		return fmt.Errorf("failed to convert to preset: %w", err)
+		return nil, fmt.Errorf("failed to convert to preset: %w", err)
 	}
 
 	// Determine preset directory path
-	homeDir, err := os.UserHomeDir()
+	presetDir, err := PresetDir()
 	if err != nil {
-		return fmt.Errorf("failed to get home directory: %w", err)
+		return nil, err
 	}
 
-	presetDir := fmt.Sprintf("%s/.lmstudio/config-presets", homeDir)
-
 	// Ensure directory exists
 	if err := os.MkdirAll(presetDir, 0755); err != nil {
-		return fmt.Errorf("failed to create preset directory: %w", err)
+		return nil, fmt.Errorf("failed to create preset directory: %w", err)
 	}
 
 	// Write preset file
 	presetPath := fmt.Sprintf("%s/%s.preset.json", presetDir, lmStudioModelName)
-	return WriteLMStudioPreset(preset, presetPath)
+	return preset.Unmapped, WriteLMStudioPreset(preset, presetPath)
 }
 
 // ParseOllamaModelfile extracts TEMPLATE, SYSTEM, and PARAMETER directives
@@ -222,146 +223,14 @@ func ParseOllamaModelfile(modelfileContent string) (*ParsedModelfile, error) {
 		return parsed, nil
 	}
 
-	lines := strings.Split(modelfileContent, "\n")
-	var templateLines []string
-	var systemLines []string
-	inTemplate := false
-	inMultilineTemplate := false
-	inDoubleQuotedTemplate := false
-	inSystem := false
-	inMultilineSystem := false
-	inDoubleQuotedSystem := false
-
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-
-		// Handle TEMPLATE directive
-		if strings.HasPrefix(trimmed, "TEMPLATE") && !inTemplate {
-			if strings.Contains(trimmed, `"""`) {
-				// Triple-quoted multi-line template
-				templateContent := strings.TrimPrefix(trimmed, "TEMPLATE ")
-				templateContent = strings.TrimSpace(templateContent)
-				if strings.HasPrefix(templateContent, `"""`) {
-					templateContent = strings.TrimPrefix(templateContent, `"""`)
-				}
-				inTemplate = true
-				inMultilineTemplate = true
-				if templateContent != "" {
-					templateLines = append(templateLines, templateContent)
-				}
-			} else if strings.HasPrefix(trimmed, `TEMPLATE "`) {
-				// Could be single-line or double-quoted multi-line
-				templateContent := strings.TrimPrefix(trimmed, "TEMPLATE ")
-				templateContent = strings.TrimSpace(templateContent)
-				templateContent = strings.TrimPrefix(templateContent, `"`)
-
-				// Check if closing quote is on the same line
-				if strings.HasSuffix(templateContent, `"`) {
-					// Single-line template
-					parsed.Template = strings.TrimSuffix(templateContent, `"`)
-				} else {
-					// Multi-line double-quoted template
-					inTemplate = true
-					inDoubleQuotedTemplate = true
-					if templateContent != "" {
-						templateLines = append(templateLines, templateContent)
-					}
-				}
-			}
-		} else if inTemplate {
-			if inMultilineTemplate && strings.HasSuffix(trimmed, `"""`) {
-				// End of triple-quoted template
-				line = strings.TrimSuffix(line, `"""`)
-				if line != "" {
-					templateLines = append(templateLines, line)
-				}
-				inTemplate = false
-				inMultilineTemplate = false
-			} else if inDoubleQuotedTemplate && strings.HasSuffix(trimmed, `"`) {
-				// End of double-quoted template
-				line = strings.TrimSuffix(line, `"`)
-				if line != "" {
-					templateLines = append(templateLines, line)
-				}
-				inTemplate = false
-				inDoubleQuotedTemplate = false
-			} else {
-				// Continue collecting template lines
-				templateLines = append(templateLines, line)
-			}
-		} else if strings.HasPrefix(trimmed, "SYSTEM") && !inSystem {
-			// Handle SYSTEM directive (similar to TEMPLATE)
-			if strings.Contains(trimmed, `"""`) {
-				// Triple-quoted multi-line system
-				systemContent := strings.TrimPrefix(trimmed, "SYSTEM ")
-				systemContent = strings.TrimSpace(systemContent)
-				if strings.HasPrefix(systemContent, `"""`) {
-					systemContent = strings.TrimPrefix(systemContent, `"""`)
-				}
-				inSystem = true
-				inMultilineSystem = true
-				if systemContent != "" {
-					systemLines = append(systemLines, systemContent)
-				}
-			} else if strings.HasPrefix(trimmed, `SYSTEM "`) {
-				// Could be single-line or double-quoted multi-line
-				systemContent := strings.TrimPrefix(trimmed, "SYSTEM ")
-				systemContent = strings.TrimSpace(systemContent)
-				systemContent = strings.TrimPrefix(systemContent, `"`)
-
-				// Check if closing quote is on the same line
-				if strings.HasSuffix(systemContent, `"`) {
-					// Single-line system
-					parsed.System = strings.TrimSuffix(systemContent, `"`)
-				} else {
-					// Multi-line double-quoted system
-					inSystem = true
-					inDoubleQuotedSystem = true
-					if systemContent != "" {
-						systemLines = append(systemLines, systemContent)
-					}
-				}
-			}
-		} else if inSystem {
-			if inMultilineSystem && strings.HasSuffix(trimmed, `"""`) {
-				// End of triple-quoted system
-				line = strings.TrimSuffix(line, `"""`)
-				if line != "" {
-					systemLines = append(systemLines, line)
-				}
-				inSystem = false
-				inMultilineSystem = false
-			} else if inDoubleQuotedSystem && strings.HasSuffix(trimmed, `"`) {
-				// End of double-quoted system
-				line = strings.TrimSuffix(line, `"`)
-				if line != "" {
-					systemLines = append(systemLines, line)
-				}
-				inSystem = false
-				inDoubleQuotedSystem = false
-			} else {
-				// Continue collecting system lines
-				systemLines = append(systemLines, line)
-			}
-		} else if strings.HasPrefix(trimmed, "PARAMETER ") {
-			// Parse parameter directive
-			paramStr := strings.TrimPrefix(trimmed, "PARAMETER ")
-			parts := strings.SplitN(paramStr, " ", 2)
-			if len(parts) == 2 {
-				key := parts[0]
-				value := strings.Trim(parts[1], `"`)
-				parsed.Parameters[key] = append(parsed.Parameters[key], value)
-			}
-		}
-	}
-
-	if len(templateLines) > 0 {
-		parsed.Template = strings.Join(templateLines, "\n")
+	mf, err := modelfile.Parse(modelfileContent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Modelfile: %w", err)
 	}
 
-	if len(systemLines) > 0 {
-		parsed.System = strings.Join(systemLines, "\n")
-	}
+	parsed.Template, _ = mf.Template()
+	parsed.System, _ = mf.System()
+	parsed.Parameters = mf.ParameterMap()
 
 	return parsed, nil
 }
@@ -384,7 +253,10 @@ func ConvertToLMStudioFormat(parsed *ParsedModelfile) (*LMStudioConfig, error) {
 
 		// Convert Go template to Jinja for LM Studio
 		// IMPORTANT: Do NOT prepend system prompt - it should be handled separately
-		jinjaTemplate := convertGoTemplateToJinja(parsed.Template)
+		jinjaTemplate, err := ConvertGoTemplateToJinja(parsed.Template)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert template to Jinja: %w", err)
+		}
 
 		// Log the template for debugging
 		logging.DebugLogger.Printf("Original template length: %d characters\n", len(parsed.Template))
@@ -505,6 +377,12 @@ func ConvertToLMStudioPreset(parsed *ParsedModelfile, modelName string) (*LMStud
 		Load:       PresetLoad{Fields: []PresetField{}},
 	}
 
+	// Inference parameters are exported whether or not there's a template
+	operation, load, unmapped := presetFields(parsed.Parameters)
+	preset.Operation.Fields = append(preset.Operation.Fields, operation...)
+	preset.Load.Fields = append(preset.Load.Fields, load...)
+	preset.Unmapped = unmapped
+
 	if parsed.Template == "" {
 		// No template - use simple defaults
 		return preset, nil
@@ -628,41 +506,3 @@ func extractEOSToken(template string) string {
 	}
 	return ""
 }
-
-// convertGoTemplateToJinja converts Ollama's Go template syntax to LM Studio's Jinja syntax
-func convertGoTemplateToJinja(goTemplate string) string {
-	// Replace variables
-	// {{ .System }} -> {{ system_message }}
-	// {{ .Prompt }} -> {{ user_message }}
-	// {{ .Response }} -> {{ model_response }} (though usually implied)
-	
-	result := goTemplate
-	
-	// Handle conditional blocks first
-	// {{ if .System }} -> {% if system_message %}
-	// {{if .System}} -> {% if system_message %}
-	reIfSystem := regexp.MustCompile(`{{\s*if\s+\.System\s*}}`)
-	result = reIfSystem.ReplaceAllString(result, "{% if system_message %}")
-
-	// {{ if .Prompt }} -> {% if user_message %}
-	// {{if .Prompt}} -> {% if user_message %}
-	reIfPrompt := regexp.MustCompile(`{{\s*if\s+\.Prompt\s*}}`)
-	result = reIfPrompt.ReplaceAllString(result, "{% if user_message %}")
-
-	// {{ end }} -> {% endif %}
-	// {{end}} -> {% endif %}
-	reEnd := regexp.MustCompile(`{{\s*end\s*}}`)
-	result = reEnd.ReplaceAllString(result, "{% endif %}")
-	
-	// Replace variable tags
-	reSystem := regexp.MustCompile(`{{\s*\.System\s*}}`)
-	result = reSystem.ReplaceAllString(result, "{{ system_message }}")
-	
-	rePrompt := regexp.MustCompile(`{{\s*\.Prompt\s*}}`)
-	result = rePrompt.ReplaceAllString(result, "{{ user_message }}")
-	
-	reResponse := regexp.MustCompile(`{{\s*\.Response\s*}}`)
-	result = reResponse.ReplaceAllString(result, "{{ model_response }}")
-	
-	return result
-}
@@ -2,6 +2,7 @@ package lmstudio
 
 import (
 	"encoding/json"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -74,3 +75,63 @@ func TestConvertToLMStudioFormat(t *testing.T) {
 		t.Errorf("JSON missing context length: %s", jsonStr)
 	}
 }
+
+func TestConvertToLMStudioPresetParameters(t *testing.T) {
+	parsed, err := ParseOllamaModelfile(`FROM llama3
+PARAMETER temperature 0.7
+PARAMETER top_p 0.9
+PARAMETER top_k 40
+PARAMETER min_p 0.05
+PARAMETER repeat_penalty 1.1
+PARAMETER num_predict 256
+PARAMETER num_ctx 8192
+PARAMETER num_batch 256
+PARAMETER seed 42
+PARAMETER num_gpu 0
+PARAMETER num_keep 24
+PARAMETER typical_p 0.9
+PARAMETER stop "<|eot_id|>"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Parameters are exported without a template too
+	preset, err := ConvertToLMStudioPreset(parsed, "llama3")
+	if err != nil {
+		t.Fatalf("ConvertToLMStudioPreset failed: %v", err)
+	}
+	if want := []string{"num_keep", "typical_p"}; !reflect.DeepEqual(preset.Unmapped, want) {
+		t.Errorf("Unmapped = %v, want %v", preset.Unmapped, want)
+	}
+	for _, field := range preset.Load.Fields {
+		if !strings.HasPrefix(field.Key, "llm.load.") {
+			t.Errorf("%s is in the load fields", field.Key)
+		}
+	}
+
+	// Importing the preset again gives back every mapped parameter
+	data, err := json.Marshal(preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "num_keep") {
+		t.Errorf("Unmapped parameters were written to the preset: %s", data)
+	}
+	imported, err := ParseLMStudioPreset(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{}
+	for name, values := range parsed.Parameters {
+		if name != "stop" && name != "num_keep" && name != "typical_p" {
+			want[name] = values
+		}
+	}
+	if got := imported.Modelfile.ParameterMap(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Round trip parameters = %v, want %v", got, want)
+	}
+	if len(imported.Skipped) != 0 {
+		t.Errorf("Unexpected skipped fields %v", imported.Skipped)
+	}
+}
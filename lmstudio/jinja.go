@@ -0,0 +1,713 @@
+package lmstudio
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"unicode"
+)
+
+// UntranslatableError lists the parts of an Ollama template that have no Jinja equivalent
+type UntranslatableError struct {
+	Constructs []string
+}
+
+func (e *UntranslatableError) Error() string {
+	return fmt.Sprintf("template has %d construct(s) that can't be converted to Jinja: %s", len(e.Constructs), strings.Join(e.Constructs, "; "))
+}
+
+// ollamaTemplateFuncs stands in for the functions Ollama adds to templates, only their names matter for parsing
+var ollamaTemplateFuncs = template.FuncMap{
+	"json":             func(any) string { return "" },
+	"currentDate":      func(...string) string { return "" },
+	"toTypeScriptType": func(any) string { return "" },
+}
+
+// rootFields maps the top level template values to the variables LM Studio passes to Jinja templates.
+// The legacy .System/.Prompt/.Response values keep the names gollama has always exported them as.
+var rootFields = map[string]string{
+	"System":     "system_message",
+	"Prompt":     "user_message",
+	"Response":   "model_response",
+	"Messages":   "messages",
+	"Tools":      "tools",
+	"Think":      "enable_thinking",
+	"ThinkLevel": "reasoning_effort",
+	"IsThinkSet": "(enable_thinking is defined)",
+}
+
+// fieldNames covers the message and tool fields whose Jinja names aren't the snake case of the Go name
+var fieldNames = map[string]string{
+	"Thinking": "reasoning_content",
+}
+
+// jsonFields print as JSON in Go templates because their types implement String, Jinja needs tojson
+var jsonFields = map[string]bool{
+	"Tools":      true,
+	"Function":   true,
+	"Parameters": true,
+	"Arguments":  true,
+}
+
+// systemFromMessages is Ollama's .System for templates that use .Messages, all the system messages joined
+const systemFromMessages = `messages | selectattr("role", "equalto", "system") | map(attribute="content") | join("\n\n")`
+
+// mapFields are ranged over as dictionaries rather than lists
+var mapFields = map[string]bool{
+	"Properties": true,
+	"Arguments":  true,
+}
+
+// loopItemNames name the loop variable when ranging over the known lists without declaring one
+var loopItemNames = map[string]string{
+	"messages":   "message",
+	"tools":      "tool",
+	"tool_calls": "tool_call",
+	"properties": "property",
+	"images":     "image",
+}
+
+// simpleExpression matches names, attribute and index accesses, numbers and string literals
+var simpleExpression = regexp.MustCompile(`^[\w.\[\]:]+$|^"(?:[^"\\]|\\.)*"$`)
+
+// ConvertGoTemplateToJinja converts an Ollama Go template to an equivalent Jinja template for LM Studio by
+// walking the parsed template. Constructs with no Jinja equivalent are returned as an *UntranslatableError.
+// Templates using .Messages become the usual messages-based chat templates other Jinja engines such as llama.cpp
+// read too, legacy .System/.Prompt templates use variables only LM Studio provides.
+func ConvertGoTemplateToJinja(goTemplate string) (string, error) {
+	if strings.TrimSpace(goTemplate) == "" {
+		return goTemplate, nil
+	}
+	tmpl, err := template.New("template").Funcs(ollamaTemplateFuncs).Parse(goTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	c := &jinjaConverter{tree: tmpl.Tree, dot: dotValue{root: true}, loopNames: make(map[string]int), jsonItems: make(map[string]bool)}
+	// In chat templates the system prompt is part of the messages rather than a separate value
+	c.usesMessages = strings.Contains(goTemplate, ".Messages")
+	for _, t := range tmpl.Templates() {
+		if t.Name() != tmpl.Name() {
+			c.errors = append(c.errors, fmt.Sprintf("{{ define %q }}: named templates aren't supported", t.Name()))
+		}
+	}
+	if tmpl.Tree != nil {
+		c.list(tmpl.Tree.Root)
+	}
+
+	jinja := c.render()
+	if len(c.errors) > 0 {
+		return jinja, &UntranslatableError{Constructs: c.errors}
+	}
+	return jinja, nil
+}
+
+// dotValue is what . refers to in the Go template, the template values at the top level and the current
+// element inside range and with
+type dotValue struct {
+	expr string
+	root bool
+}
+
+// jinjaSegment is a piece of output, blocks are {% %} tags which LM Studio's whitespace control applies to
+type jinjaSegment struct {
+	text  string
+	block bool
+	raw   bool
+}
+
+type jinjaConverter struct {
+	tree     *parse.Tree
+	segments []jinjaSegment
+	dot      dotValue
+	// scopeVars maps Go variables to Jinja expressions, a scope is pushed for each control structure
+	scopeVars    []map[string]string
+	loopNames    map[string]int
+	useNamespace bool
+	usesMessages bool
+	// jsonItems are loop variables whose elements print as JSON, like the tools
+	jsonItems map[string]bool
+	errors    []string
+}
+
+func (c *jinjaConverter) fail(node parse.Node, reason string) string {
+	location, _ := c.tree.ErrorContext(node)
+	c.errors = append(c.errors, fmt.Sprintf("%s: %s: %s", location, node, reason))
+	return "none"
+}
+
+func (c *jinjaConverter) text(s string) {
+	c.segments = append(c.segments, jinjaSegment{text: s, raw: true})
+}
+func (c *jinjaConverter) block(s string) {
+	c.segments = append(c.segments, jinjaSegment{text: "{% " + s + " %}", block: true})
+}
+func (c *jinjaConverter) expr(s string) {
+	c.segments = append(c.segments, jinjaSegment{text: "{{ " + s + " }}"})
+}
+
+func (c *jinjaConverter) pushScope() { c.scopeVars = append(c.scopeVars, map[string]string{}) }
+func (c *jinjaConverter) popScope()  { c.scopeVars = c.scopeVars[:len(c.scopeVars)-1] }
+
+func (c *jinjaConverter) setVar(name, expr string) {
+	if len(c.scopeVars) == 0 {
+		c.pushScope()
+	}
+	c.scopeVars[len(c.scopeVars)-1][name] = expr
+}
+
+func (c *jinjaConverter) lookupVar(name string) (string, bool) {
+	for i := len(c.scopeVars) - 1; i >= 0; i-- {
+		if expr, ok := c.scopeVars[i][name]; ok {
+			return expr, true
+		}
+	}
+	return "", false
+}
+
+// render joins the segments, protecting text from LM Studio's trim_blocks and lstrip_blocks whitespace
+// control, which would otherwise remove a newline after a tag or indentation before one
+func (c *jinjaConverter) render() string {
+	var b strings.Builder
+	if c.useNamespace {
+		c.segments = append([]jinjaSegment{{text: "{% set ns = namespace() %}", block: true}}, c.segments...)
+	}
+	for i, segment := range c.segments {
+		if !segment.raw {
+			b.WriteString(segment.text)
+			continue
+		}
+		text := segment.text
+		if strings.ContainsAny(text, "{}#%") && (strings.Contains(text, "{{") || strings.Contains(text, "{%") || strings.Contains(text, "{#")) {
+			b.WriteString("{{ " + jinjaString(text) + " }}")
+			continue
+		}
+		if i > 0 && c.segments[i-1].block && strings.HasPrefix(text, "\n") {
+			b.WriteString(`{{ "\n" }}`)
+			text = text[1:]
+		}
+		var indent string
+		if i+1 < len(c.segments) && c.segments[i+1].block {
+			start := strings.LastIndex(text, "\n") + 1
+			tail := text[start:]
+			atLineStart := start > 0 || b.Len() == 0 || strings.HasSuffix(b.String(), "\n")
+			if tail != "" && strings.Trim(tail, " \t") == "" && atLineStart {
+				text, indent = text[:start], tail
+			}
+		}
+		b.WriteString(text)
+		if indent != "" {
+			b.WriteString("{{ " + jinjaString(indent) + " }}")
+		}
+	}
+	return b.String()
+}
+
+func (c *jinjaConverter) list(list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+	for _, node := range list.Nodes {
+		c.node(node)
+	}
+}
+
+func (c *jinjaConverter) node(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.TextNode:
+		c.text(string(n.Text))
+	case *parse.ActionNode:
+		c.action(n)
+	case *parse.IfNode:
+		c.ifNode(n, "if")
+		c.block("endif")
+	case *parse.RangeNode:
+		c.rangeNode(n)
+	case *parse.WithNode:
+		c.withNode(n)
+	case *parse.BreakNode:
+		c.block("break")
+	case *parse.ContinueNode:
+		c.block("continue")
+	case *parse.CommentNode:
+	case *parse.TemplateNode:
+		c.fail(n, "named templates aren't supported")
+	default:
+		c.fail(n, "unsupported template node")
+	}
+}
+
+func (c *jinjaConverter) action(n *parse.ActionNode) {
+	if len(n.Pipe.Decl) == 0 {
+		value := c.pipe(n.Pipe)
+		if jsonFields[lastIdent(n.Pipe)] || c.jsonItems[value] {
+			value = wrap(value) + " | tojson"
+		}
+		c.expr(value)
+		return
+	}
+	if len(n.Pipe.Decl) > 1 {
+		c.fail(n, "multiple variable declarations aren't supported")
+		return
+	}
+	// Variables live in a namespace so assignments inside loops are seen after them, as they are in Go
+	name := "ns." + jinjaIdentifier(n.Pipe.Decl[0].Ident[0])
+	c.useNamespace = true
+	value := c.pipe(n.Pipe)
+	if !n.Pipe.IsAssign {
+		c.setVar(n.Pipe.Decl[0].Ident[0], name)
+	}
+	c.block("set " + name + " = " + value)
+}
+
+// ifNode writes an if or elif, an else containing only another if becomes elif
+func (c *jinjaConverter) ifNode(n *parse.IfNode, keyword string) {
+	if len(n.Pipe.Decl) > 0 {
+		c.fail(n.Pipe, "variable declarations in if aren't supported")
+	}
+	c.block(keyword + " " + c.pipe(n.Pipe))
+	c.pushScope()
+	c.list(n.List)
+	c.popScope()
+	if n.ElseList == nil {
+		return
+	}
+	if len(n.ElseList.Nodes) == 1 {
+		if elseIf, ok := n.ElseList.Nodes[0].(*parse.IfNode); ok {
+			c.ifNode(elseIf, "elif")
+			return
+		}
+	}
+	c.block("else")
+	c.pushScope()
+	c.list(n.ElseList)
+	c.popScope()
+}
+
+func (c *jinjaConverter) withNode(n *parse.WithNode) {
+	value := c.pipe(n.Pipe)
+	c.block("if " + value)
+	c.pushScope()
+	for _, v := range n.Pipe.Decl {
+		c.setVar(v.Ident[0], value)
+	}
+	outer := c.dot
+	c.dot = dotValue{expr: value}
+	c.list(n.List)
+	c.dot = outer
+	c.popScope()
+	if n.ElseList != nil {
+		c.block("else")
+		c.pushScope()
+		c.list(n.ElseList)
+		c.popScope()
+	}
+	c.block("endif")
+}
+
+func (c *jinjaConverter) rangeNode(n *parse.RangeNode) {
+	collection := c.pipe(n.Pipe)
+	isMap := false
+	if last := lastIdent(n.Pipe); last != "" {
+		isMap = mapFields[last]
+	}
+
+	var indexVar, elemVar string
+	switch len(n.Pipe.Decl) {
+	case 1:
+		elemVar = n.Pipe.Decl[0].Ident[0]
+	case 2:
+		indexVar, elemVar = n.Pipe.Decl[0].Ident[0], n.Pipe.Decl[1].Ident[0]
+	}
+
+	item := ""
+	if elemVar != "" && elemVar != "$_" {
+		item = jinjaIdentifier(elemVar)
+	} else {
+		item = c.loopItemName(collection)
+	}
+	if c.loopNames[item] > 0 {
+		item = fmt.Sprintf("%s%d", item, c.loopNames[item]+1)
+	}
+	c.loopNames[item]++
+	defer func() { c.loopNames[item]-- }()
+	if lastIdent(n.Pipe) == "Tools" {
+		c.jsonItems[item] = true
+		defer delete(c.jsonItems, item)
+	}
+
+	c.pushScope()
+	if _, err := fmt.Sscan(collection, new(int)); err == nil {
+		collection = "range(" + collection + ")"
+	}
+	switch {
+	case isMap:
+		key := "key"
+		if indexVar != "" && indexVar != "$_" {
+			key = jinjaIdentifier(indexVar)
+		}
+		c.block(fmt.Sprintf("for %s, %s in %s | dictsort", key, item, wrap(collection)))
+		if indexVar != "" {
+			c.setVar(indexVar, key)
+		}
+	default:
+		c.block(fmt.Sprintf("for %s in %s", item, collection))
+		if indexVar != "" && indexVar != "$_" {
+			c.block(fmt.Sprintf("set %s = loop.index0", jinjaIdentifier(indexVar)))
+			c.setVar(indexVar, jinjaIdentifier(indexVar))
+		}
+	}
+	if elemVar != "" {
+		c.setVar(elemVar, item)
+	}
+
+	outer := c.dot
+	c.dot = dotValue{expr: item}
+	c.list(n.List)
+	c.dot = outer
+	c.popScope()
+	if n.ElseList != nil {
+		c.block("else")
+		c.pushScope()
+		c.list(n.ElseList)
+		c.popScope()
+	}
+	c.block("endfor")
+}
+
+func (c *jinjaConverter) loopItemName(collection string) string {
+	last := collection[strings.LastIndex(collection, ".")+1:]
+	if name, ok := loopItemNames[last]; ok {
+		return name
+	}
+	return "item"
+}
+
+// lastIdent is the final field name of a single command pipeline, e.g. Properties for .Function.Parameters.Properties
+func lastIdent(pipe *parse.PipeNode) string {
+	if len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return ""
+	}
+	switch n := pipe.Cmds[0].Args[0].(type) {
+	case *parse.FieldNode:
+		return n.Ident[len(n.Ident)-1]
+	case *parse.VariableNode:
+		return n.Ident[len(n.Ident)-1]
+	}
+	return ""
+}
+
+func (c *jinjaConverter) pipe(pipe *parse.PipeNode) string {
+	var result string
+	for i, cmd := range pipe.Cmds {
+		if i == 0 {
+			result = c.command(cmd, "")
+		} else {
+			result = c.command(cmd, result)
+		}
+	}
+	return result
+}
+
+// command converts a command, piped is the previous command's result which Go passes as the final argument
+func (c *jinjaConverter) command(cmd *parse.CommandNode, piped string) string {
+	if ident, ok := cmd.Args[0].(*parse.IdentifierNode); ok {
+		args := make([]string, 0, len(cmd.Args))
+		for _, arg := range cmd.Args[1:] {
+			args = append(args, c.operand(arg))
+		}
+		if piped != "" {
+			args = append(args, piped)
+		}
+		return c.function(cmd, ident.Ident, args)
+	}
+	if len(cmd.Args) > 1 || piped != "" {
+		return c.fail(cmd, "method calls aren't supported")
+	}
+	return c.operand(cmd.Args[0])
+}
+
+func (c *jinjaConverter) operand(node parse.Node) string {
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		return c.fields(c.dot, n, n.Ident)
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" {
+			return c.fields(dotValue{root: true}, n, n.Ident[1:])
+		}
+		expr, ok := c.lookupVar(n.Ident[0])
+		if !ok {
+			return c.fail(n, "undefined variable")
+		}
+		return c.fields(dotValue{expr: expr}, n, n.Ident[1:])
+	case *parse.DotNode:
+		if c.dot.root {
+			return c.fail(n, "the top level values can't be used as a whole")
+		}
+		return c.dot.expr
+	case *parse.ChainNode:
+		return c.fields(dotValue{expr: wrap(c.operand(n.Node))}, n, n.Field)
+	case *parse.PipeNode:
+		return wrap(c.pipe(n))
+	case *parse.IdentifierNode:
+		return c.function(n, n.Ident, nil)
+	case *parse.StringNode:
+		return jinjaString(n.Text)
+	case *parse.NumberNode:
+		return n.Text
+	case *parse.BoolNode:
+		if n.True {
+			return "true"
+		}
+		return "false"
+	case *parse.NilNode:
+		return "none"
+	}
+	return c.fail(node, "unsupported operand")
+}
+
+// fields appends field accesses to a value, mapping the top level values to LM Studio's variables
+func (c *jinjaConverter) fields(base dotValue, node parse.Node, idents []string) string {
+	if len(idents) == 0 {
+		if base.root {
+			return c.fail(node, "the top level values can't be used as a whole")
+		}
+		return base.expr
+	}
+	expr := base.expr
+	if base.root {
+		name, ok := rootFields[idents[0]]
+		if idents[0] == "System" && c.usesMessages {
+			name = "(" + systemFromMessages + ")"
+		}
+		if !ok {
+			return c.fail(node, fmt.Sprintf("LM Studio has no equivalent of .%s", idents[0]))
+		}
+		expr, idents = name, idents[1:]
+	}
+	for _, ident := range idents {
+		name, ok := fieldNames[ident]
+		if !ok {
+			name = snakeCase(ident)
+		}
+		expr += "." + name
+	}
+	return expr
+}
+
+func (c *jinjaConverter) function(node parse.Node, name string, args []string) string {
+	comparisons := map[string]string{"eq": "==", "ne": "!=", "lt": "<", "le": "<=", "gt": ">", "ge": ">="}
+	wrapped := make([]string, len(args))
+	for i, arg := range args {
+		wrapped[i] = wrap(arg)
+	}
+
+	switch name {
+	case "eq", "ne", "lt", "le", "gt", "ge":
+		if len(args) < 2 {
+			break
+		}
+		// eq compares its first argument against each of the others
+		var parts []string
+		for _, arg := range wrapped[1:] {
+			parts = append(parts, wrapped[0]+" "+comparisons[name]+" "+arg)
+		}
+		if len(parts) == 1 {
+			return parts[0]
+		}
+		if name != "eq" {
+			break
+		}
+		return "(" + strings.Join(parts, " or ") + ")"
+	case "and", "or":
+		if len(args) > 0 {
+			return strings.Join(wrapped, " "+name+" ")
+		}
+	case "not":
+		if len(args) == 1 {
+			return "not " + wrapped[0]
+		}
+	case "len":
+		if len(args) == 1 {
+			return wrapped[0] + " | length"
+		}
+	case "index":
+		if len(args) > 0 {
+			expr := wrapped[0]
+			for _, arg := range args[1:] {
+				expr += "[" + arg + "]"
+			}
+			return expr
+		}
+	case "slice":
+		switch len(args) {
+		case 1:
+			return args[0]
+		case 2:
+			return wrapped[0] + "[" + args[1] + ":]"
+		case 3:
+			return wrapped[0] + "[" + args[1] + ":" + args[2] + "]"
+		}
+	case "json":
+		if len(args) == 1 {
+			return wrapped[0] + " | tojson"
+		}
+	case "print":
+		if len(args) > 0 {
+			return strings.Join(wrapped, " ~ ")
+		}
+	case "printf":
+		if len(args) > 0 {
+			if expr, ok := printfExpression(node, args); ok {
+				return expr
+			}
+			return c.fail(node, "only %s, %v and %d are supported in printf format strings")
+		}
+	case "currentDate":
+		return `strftime_now("%Y-%m-%d")`
+	case "toTypeScriptType":
+		return c.fail(node, "toTypeScriptType has no Jinja equivalent")
+	}
+	return c.fail(node, fmt.Sprintf("%s with %d argument(s) has no Jinja equivalent", name, len(args)))
+}
+
+// printfExpression turns printf with a literal format into a concatenation, e.g. printf "%s: %s" .A .B
+func printfExpression(node parse.Node, args []string) (string, bool) {
+	cmd, ok := node.(*parse.CommandNode)
+	if !ok || len(cmd.Args) < 2 {
+		return "", false
+	}
+	format, ok := cmd.Args[1].(*parse.StringNode)
+	if !ok {
+		return "", false
+	}
+
+	var parts []string
+	var literal strings.Builder
+	values := args[1:]
+	text := format.Text
+	for i := 0; i < len(text); i++ {
+		if text[i] != '%' {
+			literal.WriteByte(text[i])
+			continue
+		}
+		if i+1 >= len(text) {
+			return "", false
+		}
+		i++
+		switch text[i] {
+		case '%':
+			literal.WriteByte('%')
+		case 's', 'v', 'd':
+			if len(values) == 0 {
+				return "", false
+			}
+			if literal.Len() > 0 {
+				parts = append(parts, jinjaString(literal.String()))
+				literal.Reset()
+			}
+			parts = append(parts, wrap(values[0]))
+			values = values[1:]
+		default:
+			return "", false
+		}
+	}
+	if len(values) > 0 {
+		return "", false
+	}
+	if literal.Len() > 0 {
+		parts = append(parts, jinjaString(literal.String()))
+	}
+	if len(parts) == 0 {
+		return `""`, true
+	}
+	return strings.Join(parts, " ~ "), true
+}
+
+// wrap parenthesises an expression unless it's a single value, so it can be used as an operand
+func wrap(expr string) string {
+	if simpleExpression.MatchString(expr) || isParenthesised(expr) {
+		return expr
+	}
+	return "(" + expr + ")"
+}
+
+// isParenthesised reports whether the whole expression is inside one pair of parentheses
+func isParenthesised(expr string) bool {
+	if !strings.HasPrefix(expr, "(") || !strings.HasSuffix(expr, ")") {
+		return false
+	}
+	depth := 0
+	inString := false
+	for i := 0; i < len(expr); i++ {
+		switch ch := expr[i]; {
+		case inString && ch == '\\':
+			i++
+		case ch == '"':
+			inString = !inString
+		case inString:
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+			if depth == 0 && i < len(expr)-1 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+// jinjaString quotes s as a Jinja string literal
+func jinjaString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// jinjaIdentifier turns a Go template variable like $lastMessage into a Jinja name like last_message
+func jinjaIdentifier(variable string) string {
+	name := snakeCase(strings.TrimPrefix(variable, "$"))
+	if name == "" || name == "_" {
+		return "item"
+	}
+	return name
+}
+
+// snakeCase converts a Go field name to the snake case used in chat messages, e.g. ToolCallID to tool_call_id
+func snakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			previousLower := i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]))
+			nextLower := i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1])
+			if previousLower || nextLower {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,201 @@
+package lmstudio
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestConvertGoTemplateToJinjaModelFamilies(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		// want is the whole conversion, or fragments it must contain when it's long
+		want     string
+		contains []string
+	}{
+		{
+			name: "ChatML",
+			input: `{{- range .Messages }}<|im_start|>{{ .Role }}
+{{ .Content }}<|im_end|>
+{{ end }}<|im_start|>assistant
+`,
+			want: `{% for message in messages %}<|im_start|>{{ message.role }}
+{{ message.content }}<|im_end|>
+{% endfor %}<|im_start|>assistant
+`,
+		},
+		{
+			name: "Llama 3",
+			input: `{{- range .Messages }}<|start_header_id|>{{ .Role }}<|end_header_id|>
+
+{{ .Content }}<|eot_id|>
+{{- end }}<|start_header_id|>assistant<|end_header_id|>
+
+`,
+			want: `{% for message in messages %}<|start_header_id|>{{ message.role }}<|end_header_id|>
+
+{{ message.content }}<|eot_id|>{% endfor %}<|start_header_id|>assistant<|end_header_id|>
+
+`,
+		},
+		{
+			name: "Mistral with else if",
+			input: `[INST] {{ range $index, $_ := .Messages }}
+{{- if eq .Role "system" }}{{ .Content }}
+
+{{ else if eq .Role "user" }}{{ .Content }}[/INST]
+{{- else if eq .Role "assistant" }} {{ .Content }}</s>[INST] {{ end }}
+{{- end }}`,
+			want: `[INST] {% for message in messages %}{% set index = loop.index0 %}{% if message.role == "system" %}{{ message.content }}
+
+{% elif message.role == "user" %}{{ message.content }}[/INST]{% elif message.role == "assistant" %} {{ message.content }}</s>[INST] {% endif %}{% endfor %}`,
+		},
+		{
+			name: "Gemma 3 with slice, len and variables",
+			input: `{{- range $i, $_ := .Messages }}
+{{- $last := eq (len (slice $.Messages $i)) 1 }}
+{{- if eq .Role "user" }}<start_of_turn>user
+{{ .Content }}<end_of_turn>
+{{ else if eq .Role "assistant" }}<start_of_turn>model
+{{ .Content }}<end_of_turn>
+{{ end }}
+{{- if $last }}<start_of_turn>model
+{{ end }}
+{{- end }}`,
+			want: `{% set ns = namespace() %}{% for message in messages %}{% set i = loop.index0 %}{% set ns.last = (messages[i:] | length) == 1 %}{% if message.role == "user" %}<start_of_turn>user
+{{ message.content }}<end_of_turn>
+{% elif message.role == "assistant" %}<start_of_turn>model
+{{ message.content }}<end_of_turn>
+{% endif %}{% if ns.last %}<start_of_turn>model
+{% endif %}{% endfor %}`,
+		},
+		{
+			name: "Gemma system prompt folded into the first user turn",
+			input: `{{- $system := "" }}
+{{- range .Messages }}
+{{- if eq .Role "system" }}
+{{- if not $system }}{{ $system = .Content }}
+{{- else }}{{ $system = printf "%s\n\n%s" $system .Content }}
+{{- end }}
+{{- continue }}
+{{- else if eq .Role "user" }}<start_of_turn>user
+{{- if $system }}
+{{ $system }}
+{{- $system = "" }}
+{{- end }}
+{{- end }}
+{{ .Content }}<end_of_turn>
+{{ end }}<start_of_turn>model
+`,
+			contains: []string{
+				`{% set ns.system = "" %}`,
+				`{% set ns.system = ns.system ~ "\n\n" ~ message.content %}`,
+				`{% continue %}`,
+				`<start_of_turn>user{% if ns.system %}{{ "\n" }}{{ ns.system }}{% set ns.system = "" %}{% endif %}`,
+			},
+		},
+		{
+			name: "Qwen 2.5 with tools and tool calls",
+			input: `{{- if or .System .Tools }}<|im_start|>system
+{{- if .Tools }}
+<tools>
+{{- range $.Tools }}
+{"type": "function", "function": {{ .Function }}}
+{{- end }}
+</tools>
+{{- end }}<|im_end|>
+{{ end }}
+{{- range .Messages }}
+{{- if eq .Role "assistant" }}<|im_start|>assistant
+{{ if .Content }}{{ .Content }}
+{{- else if .ToolCalls }}<tool_call>
+{{ range .ToolCalls }}{"name": "{{ .Function.Name }}", "arguments": {{ .Function.Arguments }}}
+{{ end }}</tool_call>
+{{- end }}<|im_end|>
+{{ end }}
+{{- end }}`,
+			contains: []string{
+				`{% if (messages | selectattr("role", "equalto", "system") | map(attribute="content") | join("\n\n")) or tools %}`,
+				`{% for tool in tools %}{{ "\n" }}{"type": "function", "function": {{ tool.function | tojson }}}{% endfor %}`,
+				`{% elif message.tool_calls %}<tool_call>`,
+				`{% for tool_call in message.tool_calls %}{"name": "{{ tool_call.function.name }}", "arguments": {{ tool_call.function.arguments | tojson }}}`,
+			},
+		},
+		{
+			name: "Command R tool parameters",
+			input: `{{- range .Tools }}def {{ .Function.Name }}(
+{{- range $name, $property := .Function.Parameters.Properties }}{{ $name }}: {{ $property.Type }}, {{ end }})
+{{ end }}`,
+			want: `{% for tool in tools %}def {{ tool.function.name }}({% for name, property in tool.function.parameters.properties | dictsort %}{{ name }}: {{ property.type }}, {% endfor %})` + "\n" + `{% endfor %}`,
+		},
+		{
+			name:  "Thinking and with",
+			input: `{{ range .Messages }}{{ with .Thinking }}<think>{{ . }}</think>{{ end }}{{ end }}{{ if $.Think }}on{{ end }}`,
+			want:  `{% for message in messages %}{% if message.reasoning_content %}<think>{{ message.reasoning_content }}</think>{% endif %}{% endfor %}{% if enable_thinking %}on{% endif %}`,
+		},
+		{
+			name:  "Newlines next to tags are kept",
+			input: "{{ range .Messages }}\n  {{ if .Content }}{{ .Content }}{{ end }}\n{{ end }}",
+			want:  `{% for message in messages %}{{ "\n" }}  {% if message.content %}{{ message.content }}{% endif %}{{ "\n" }}{% endfor %}`,
+		},
+		{
+			name:  "Jinja syntax in text is quoted",
+			input: "{{ .Prompt }} {% raw %}",
+			want:  `{{ user_message }}{{ " {% raw %}" }}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertGoTemplateToJinja(tt.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tt.want != "" && got != tt.want {
+				t.Errorf("Got:\n%s\nWant:\n%s", got, tt.want)
+			}
+			for _, fragment := range tt.contains {
+				if !strings.Contains(got, fragment) {
+					t.Errorf("Output doesn't contain %s:\n%s", fragment, got)
+				}
+			}
+		})
+	}
+}
+
+func TestConvertGoTemplateToJinjaReportsUntranslatable(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"thinking outside a message", "{{ .Thinking }}", []string{"template:1:3: .Thinking: LM Studio has no equivalent of .Thinking"}},
+		{"suffix", "{{ .Prompt }}{{ .Suffix }}", []string{"template:1:16: .Suffix: LM Studio has no equivalent of .Suffix"}},
+		{"typescript types", "{{ range .Tools }}\n{{ toTypeScriptType .Function }}{{ end }}", []string{"template:2:3: toTypeScriptType .Function"}},
+		{"named templates", `{{ define "tools" }}x{{ end }}{{ template "tools" }}`, []string{`{{ define "tools" }}`, `{{template "tools"}}`}},
+		{"printf verbs", `{{ printf "%q" .Prompt }}`, []string{`printf "%q" .Prompt: only %s, %v and %d`}},
+		{"top level dot", `{{ json . }}`, []string{"the top level values can't be used as a whole"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ConvertGoTemplateToJinja(tt.input)
+			var untranslatable *UntranslatableError
+			if !errors.As(err, &untranslatable) {
+				t.Fatalf("Expected an UntranslatableError, got %v", err)
+			}
+			if len(untranslatable.Constructs) != len(tt.want) {
+				t.Errorf("Got %d constructs, want %d: %v", len(untranslatable.Constructs), len(tt.want), untranslatable.Constructs)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Error doesn't mention %s: %v", want, err)
+				}
+			}
+		})
+	}
+
+	if _, err := ConvertGoTemplateToJinja("{{ if .System }}"); err == nil || errors.As(err, new(*UntranslatableError)) {
+		t.Errorf("Expected a parse error for an unterminated if, got %v", err)
+	}
+}
@@ -9,7 +9,9 @@ import (
 	"text/template"
 	"time"
 
+	"github.com/mipalgu/gollama/links"
 	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/modelfile"
 	"github.com/mipalgu/gollama/utils"
 )
 
@@ -17,6 +19,13 @@ type Model struct {
 	Name     string
 	Path     string
 	FileType string // e.g., "gguf", "bin", etc.
+
+	// Shards are the files of a model split by gguf-split in order, Path is the first of them, and MissingShards the
+	// names of the ones that aren't there. Both are empty for models in a single file.
+	Shards        []string
+	MissingShards []string
+	// Size is the size of the model's file, or the combined size of its shards
+	Size int64
 }
 
 // ModelfileTemplate contains the default template for creating Modelfiles
@@ -43,7 +52,12 @@ PARAMETER min_p 0.01
 # PARAMETER top_k 20
 # PARAMETER repeat_penalty 1.05
 # PARAMETER presence_penalty 1.5
-
+{{ if .Stop }}
+# Stop tokens for the {{ .TemplateName }} chat template
+{{- range .Stop }}
+{{ stopParameter . }}
+{{- end }}
+{{ else }}
 # ChatML Family
 PARAMETER stop "<|im_start|>"
 PARAMETER stop "<|im_end|>"
@@ -69,14 +83,26 @@ PARAMETER stop "<|im_end|>"
 # PARAMETER stop "</tool_response>"
 # PARAMETER stop "</write_to_file>"
 # PARAMETER stop "</execute_command>"
-
-# TEMPLATE "{{.Prompt}}"
+{{ end }}
+{{ if .Template }}TEMPLATE """{{ .Template }}"""{{ else }}# TEMPLATE "{{.Prompt}}"{{ end }}
 # SYSTEM "You are a helpful assistant."
 `
 
+// modelfileTemplateFuncs quote the values from the GGUF file or a preset for ModelfileTemplate
+var modelfileTemplateFuncs = template.FuncMap{
+	// stopParameter writes a stop string as a PARAMETER with quotes that can hold it, it may contain quotes itself
+	"stopParameter": func(stop string) string {
+		return modelfile.Command{Instruction: modelfile.Parameter, Key: "stop", Value: stop, Quote: modelfile.QuoteDouble}.String()
+	},
+}
+
 type ModelfileData struct {
 	ModelPath string
 	Prompt    string
+	// Template and Stop come from the model's chat template, the ChatML stop tokens are used when they're empty
+	Template     string
+	TemplateName string
+	Stop         []string
 }
 
 // ScanModels scans the given directory for LM Studio model files
@@ -105,6 +131,16 @@ func ScanModels(dirPath string) ([]Model, error) {
 		if ext == ".gguf" || ext == ".bin" {
 			name := strings.TrimSuffix(filepath.Base(path), ext)
 
+			// The shards of a split model are one model, found at its first shard
+			shards, missing := findShards(path)
+			if len(shards) > 0 {
+				if shards[0] != path {
+					logging.DebugLogger.Printf("Skipping %s, it's part of the split model %s", path, shards[0])
+					return nil
+				}
+				name = shardsName(path)
+			}
+
 			// Basic name validation
 			if strings.ContainsAny(name, "/\\:*?\"<>|") {
 				logging.ErrorLogger.Printf("Skipping model with invalid characters in name: %s", name)
@@ -112,9 +148,15 @@ func ScanModels(dirPath string) ([]Model, error) {
 			}
 
 			model := Model{
-				Name:     name,
-				Path:     path,
-				FileType: strings.TrimPrefix(ext, "."),
+				Name:          name,
+				Path:          path,
+				FileType:      strings.TrimPrefix(ext, "."),
+				Shards:        shards,
+				MissingShards: missing,
+				Size:          info.Size(),
+			}
+			if len(shards) > 0 {
+				model.Size = filesSize(shards)
 			}
 
 			logging.DebugLogger.Printf("Found model: %s (%s)", model.Name, model.FileType)
@@ -147,9 +189,10 @@ func modelExists(modelName string) bool {
 	return strings.Contains(string(output), modelName)
 }
 
-// generateModelfileContent generates the Modelfile content as a string
-func generateModelfileContent(modelName string, modelPath string) (string, error) {
-	tmpl, err := template.New("modelfile").Parse(ModelfileTemplate)
+// generateModelfileContent generates the Modelfile content as a string, with the chat template read from the
+// GGUF file at sourcePath
+func generateModelfileContent(modelName string, modelPath string, sourcePath string) (string, error) {
+	tmpl, err := template.New("modelfile").Funcs(modelfileTemplateFuncs).Parse(ModelfileTemplate)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse Modelfile template: %w", err)
 	}
@@ -158,6 +201,11 @@ func generateModelfileContent(modelName string, modelPath string) (string, error
 		ModelPath: modelPath,     // Use full path instead of just the base name
 		Prompt:    "{{.Prompt}}", // Preserve this as a template variable for Ollama
 	}
+	if chatTemplate := ResolveChatTemplate(sourcePath); chatTemplate != nil {
+		data.Template = chatTemplate.Template
+		data.TemplateName = chatTemplate.Name
+		data.Stop = chatTemplate.Stop
+	}
 
 	var buf strings.Builder
 	if err := tmpl.Execute(&buf, data); err != nil {
@@ -168,7 +216,7 @@ func generateModelfileContent(modelName string, modelPath string) (string, error
 }
 
 // createModelfile creates a Modelfile for the given model
-func createModelfile(modelName string, modelPath string) error {
+func createModelfile(modelName string, modelPath string, sourcePath string) error {
 	modelfilePath := filepath.Join(filepath.Dir(modelPath), fmt.Sprintf("Modelfile.%s", modelName))
 
 	// Check if Modelfile already exists
@@ -178,7 +226,7 @@ func createModelfile(modelName string, modelPath string) error {
 	}
 
 	// Generate Modelfile content using the helper function
-	content, err := generateModelfileContent(modelName, modelPath)
+	content, err := generateModelfileContent(modelName, modelPath, sourcePath)
 	if err != nil {
 		return fmt.Errorf("failed to generate Modelfile content: %w", err)
 	}
@@ -203,6 +251,9 @@ func LinkModelToOllama(model Model, dryRun bool, ollamaHost string, ollamaDir st
 	if !utils.IsLocalhost(ollamaHost) {
 		return fmt.Errorf("linking LM Studio models to Ollama is only supported when connecting to a local Ollama instance (got %s)", ollamaHost)
 	}
+	if err := splitModelError(model.Name, model.Shards, model.MissingShards); err != nil {
+		return err
+	}
 
 	// Get the source model file's modification time
 	sourceInfo, err := os.Stat(model.Path)
@@ -242,6 +293,10 @@ func LinkModelToOllama(model Model, dryRun bool, ollamaHost string, ollamaDir st
 			if err := os.Chtimes(targetPath, sourceModTime, sourceModTime); err != nil {
 				logging.ErrorLogger.Printf("Warning: Failed to set symlink modification time: %v", err)
 			}
+			link := links.Link{Kind: links.OllamaModel, Model: model.Name, Source: model.Path, Path: targetPath}
+			if err := links.Record(link); err != nil {
+				logging.ErrorLogger.Printf("Warning: failed to record the symlink for %s: %v", model.Name, err)
+			}
 		}
 	}
 
@@ -256,7 +311,7 @@ func LinkModelToOllama(model Model, dryRun bool, ollamaHost string, ollamaDir st
 		fmt.Printf("\n\n[DRY RUN] *** Would create Modelfile at: %s ***\n", modelfilePath)
 
 		// Generate and display the Modelfile content that would be created
-		modelfileContent, err := generateModelfileContent(model.Name, targetPath)
+		modelfileContent, err := generateModelfileContent(model.Name, targetPath, model.Path)
 		if err != nil {
 			fmt.Printf("[DRY RUN] Error generating Modelfile content: %v\n", err)
 		} else {
@@ -270,7 +325,7 @@ func LinkModelToOllama(model Model, dryRun bool, ollamaHost string, ollamaDir st
 		return nil
 	}
 
-	if err := createModelfile(model.Name, targetPath); err != nil {
+	if err := createModelfile(model.Name, targetPath, model.Path); err != nil {
 		return fmt.Errorf("failed to create Modelfile for %s: %w", model.Name, err)
 	}
 
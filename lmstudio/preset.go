@@ -0,0 +1,469 @@
+package lmstudio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/template"
+
+	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/modelfile"
+)
+
+// presetParameter maps an LM Studio preset field to the Ollama parameter with the same meaning
+type presetParameter struct {
+	key       string
+	parameter string
+	// checked fields hold {"checked": bool, "value": v}, unchecked means LM Studio's default is used
+	checked bool
+}
+
+// presetParameters are the llm.prediction.* and llm.load.* fields with an Ollama equivalent
+var presetParameters = []presetParameter{
+	{key: "llm.prediction.temperature", parameter: "temperature"},
+	{key: "llm.prediction.topKSampling", parameter: "top_k"},
+	{key: "llm.prediction.topPSampling", parameter: "top_p", checked: true},
+	{key: "llm.prediction.minPSampling", parameter: "min_p", checked: true},
+	{key: "llm.prediction.repeatPenalty", parameter: "repeat_penalty", checked: true},
+	{key: "llm.prediction.maxPredictedTokens", parameter: "num_predict", checked: true},
+	{key: "llm.prediction.llama.presencePenalty", parameter: "presence_penalty", checked: true},
+	{key: "llm.prediction.llama.frequencyPenalty", parameter: "frequency_penalty", checked: true},
+	{key: "llm.prediction.llama.cpuThreads", parameter: "num_thread"},
+	{key: "llm.load.contextLength", parameter: "num_ctx"},
+	{key: "llm.load.llama.evalBatchSize", parameter: "num_batch"},
+	{key: "llm.load.seed", parameter: "seed", checked: true},
+	{key: "llm.load.llama.tryMmap", parameter: "use_mmap"},
+}
+
+// presetFields converts Modelfile parameters to preset fields, split into the operation and load lists LM Studio
+// keeps them in. It returns the parameters LM Studio has no equivalent for, sorted by name.
+func presetFields(params map[string][]string) (operation, load []PresetField, unmapped []string) {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		values := params[name]
+		if name == "stop" || len(values) == 0 {
+			// Stop strings have their own field
+			continue
+		}
+		if name == "num_gpu" && values[len(values)-1] == "0" {
+			load = append(load, PresetField{Key: "llm.load.llama.acceleration.offloadRatio", Value: 0.0})
+			continue
+		}
+
+		var field *PresetField
+		for _, param := range presetParameters {
+			if param.parameter != name {
+				continue
+			}
+			// As in Ollama the last value of a repeated parameter wins
+			value, err := typedParameter(name, values[len(values)-1])
+			if err != nil {
+				logging.DebugLogger.Printf("Not exporting %s: %v\n", name, err)
+				break
+			}
+			if param.checked {
+				value = map[string]any{"checked": true, "value": value}
+			}
+			field = &PresetField{Key: param.key, Value: value}
+			break
+		}
+
+		switch {
+		case field == nil:
+			unmapped = append(unmapped, name)
+		case strings.HasPrefix(field.Key, "llm.load."):
+			load = append(load, *field)
+		default:
+			operation = append(operation, *field)
+		}
+	}
+	return operation, load, unmapped
+}
+
+// typedParameter parses a Modelfile parameter value as the type Ollama gives it
+func typedParameter(name, value string) (any, error) {
+	switch modelfile.Params[name].Type {
+	case modelfile.ParamInt:
+		return strconv.Atoi(value)
+	case modelfile.ParamFloat:
+		return strconv.ParseFloat(value, 64)
+	case modelfile.ParamBool:
+		return strconv.ParseBool(value)
+	}
+	return value, nil
+}
+
+// legacyPresetParameters maps the inference_params and load_params of pre-0.3 presets to Ollama parameters
+var legacyPresetParameters = map[string]string{
+	"temp":              "temperature",
+	"top_k":             "top_k",
+	"top_p":             "top_p",
+	"min_p":             "min_p",
+	"typical_p":         "typical_p",
+	"repeat_penalty":    "repeat_penalty",
+	"repeat_last_n":     "repeat_last_n",
+	"presence_penalty":  "presence_penalty",
+	"frequency_penalty": "frequency_penalty",
+	"n_predict":         "num_predict",
+	"n_keep":            "num_keep",
+	"n_threads":         "num_thread",
+	"seed":              "seed",
+	"n_ctx":             "num_ctx",
+	"n_batch":           "num_batch",
+	"n_gpu_layers":      "num_gpu",
+	"main_gpu":          "main_gpu",
+	"use_mmap":          "use_mmap",
+}
+
+// legacyPromptParams are the legacy inference_params read into LMStudioPresetLegacy rather than parameters
+var legacyPromptParams = map[string]bool{
+	"input_prefix":      true,
+	"input_suffix":      true,
+	"pre_prompt":        true,
+	"pre_prompt_prefix": true,
+	"pre_prompt_suffix": true,
+	"antiprompt":        true,
+}
+
+// ImportedPreset is an LM Studio preset translated to Ollama Modelfile commands
+type ImportedPreset struct {
+	Name string
+	// Modelfile has the SYSTEM, TEMPLATE and PARAMETER commands from the preset, without a FROM
+	Modelfile *modelfile.Modelfile
+	// Skipped lists the preset fields that couldn't be carried over and why
+	Skipped []string
+}
+
+// PresetDir returns the directory LM Studio reads config presets from
+func PresetDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".lmstudio", "config-presets"), nil
+}
+
+// FindPreset resolves a preset argument, either the path of a preset file or the name of one in PresetDir
+func FindPreset(nameOrPath string) (string, error) {
+	if info, err := os.Stat(nameOrPath); err == nil && !info.IsDir() {
+		return nameOrPath, nil
+	}
+	dir, err := PresetDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, strings.TrimSuffix(nameOrPath, ".preset.json")+".preset.json")
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("no preset file %s and no preset named %s in %s", nameOrPath, nameOrPath, dir)
+	}
+	return path, nil
+}
+
+// ReadLMStudioPreset reads a preset file in either the v0.3+ or the legacy format
+func ReadLMStudioPreset(path string) (*ImportedPreset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read preset file: %w", err)
+	}
+	preset, err := ParseLMStudioPreset(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if preset.Name == "" {
+		preset.Name = strings.TrimSuffix(filepath.Base(path), ".preset.json")
+	}
+	return preset, nil
+}
+
+// ParseLMStudioPreset translates preset JSON to Modelfile commands, telling the formats apart by their top level keys
+func ParseLMStudioPreset(data []byte) (*ImportedPreset, error) {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse preset JSON: %w", err)
+	}
+	_, hasOperation := keys["operation"]
+	_, hasLoad := keys["load"]
+	_, hasInference := keys["inference_params"]
+	_, hasLoadParams := keys["load_params"]
+
+	switch {
+	case hasOperation || hasLoad:
+		var preset LMStudioPreset
+		if err := json.Unmarshal(data, &preset); err != nil {
+			return nil, fmt.Errorf("failed to parse preset: %w", err)
+		}
+		return importPreset(&preset), nil
+	case hasInference || hasLoadParams:
+		var preset LMStudioPresetLegacy
+		if err := json.Unmarshal(data, &preset); err != nil {
+			return nil, fmt.Errorf("failed to parse legacy preset: %w", err)
+		}
+		var params struct {
+			InferenceParams map[string]any `json:"inference_params"`
+			LoadParams      map[string]any `json:"load_params"`
+		}
+		if err := json.Unmarshal(data, &params); err != nil {
+			return nil, fmt.Errorf("failed to parse legacy preset: %w", err)
+		}
+		return importLegacyPreset(&preset, params.InferenceParams, params.LoadParams), nil
+	}
+	return nil, fmt.Errorf("not an LM Studio preset, expected operation and load or inference_params fields")
+}
+
+func importPreset(preset *LMStudioPreset) *ImportedPreset {
+	imported := &ImportedPreset{Name: preset.Name, Modelfile: &modelfile.Modelfile{}}
+	fields := append(append([]PresetField(nil), preset.Operation.Fields...), preset.Load.Fields...)
+
+	var templateStops []string
+	for _, field := range fields {
+		switch field.Key {
+		case "llm.prediction.systemPrompt":
+			if system, ok := field.Value.(string); ok && system != "" {
+				imported.Modelfile.SetSystem(system)
+			}
+		case "llm.prediction.stopStrings":
+			imported.setStop(field.Key, field.Value)
+		case "llm.prediction.promptTemplate":
+			templateStops = imported.setPromptTemplate(field.Value)
+		case "llm.load.llama.acceleration.offloadRatio":
+			// Ollama counts offloaded layers, so only turning offloading off has an equivalent without the model
+			if ratio, ok := field.Value.(float64); ok && ratio == 0 {
+				imported.Modelfile.SetParameter("num_gpu", "0")
+			} else {
+				imported.skip(field.Key, "Ollama sets GPU offload in layers rather than as a ratio")
+			}
+		default:
+			imported.setParameter(field)
+		}
+	}
+
+	// Stop strings saved with the prompt template are used when the preset doesn't set them separately
+	if len(templateStops) > 0 && len(imported.Modelfile.ParameterValues("stop")) == 0 {
+		imported.Modelfile.SetParameter("stop", templateStops...)
+	}
+	return imported
+}
+
+// setParameter adds the Ollama parameter for a preset field, or records why it was skipped
+func (p *ImportedPreset) setParameter(field PresetField) {
+	for _, param := range presetParameters {
+		if param.key != field.Key {
+			continue
+		}
+		value := field.Value
+		if param.checked {
+			checked, ok := value.(map[string]any)
+			if !ok {
+				p.skip(field.Key, "expected a {checked, value} object")
+				return
+			}
+			if enabled, _ := checked["checked"].(bool); !enabled {
+				return
+			}
+			value = checked["value"]
+		}
+		p.setValue(field.Key, param.parameter, value)
+		return
+	}
+	p.skip(field.Key, "no Ollama equivalent")
+}
+
+// setValue formats a JSON value as the parameter's Modelfile value, checking it has the type Ollama expects
+func (p *ImportedPreset) setValue(key, parameter string, value any) {
+	spec := modelfile.Params[parameter]
+	var formatted string
+	switch v := value.(type) {
+	case float64:
+		if spec.Type == modelfile.ParamInt && v != float64(int64(v)) {
+			p.skip(key, fmt.Sprintf("%s must be a whole number, got %v", parameter, v))
+			return
+		}
+		formatted = strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		formatted = strconv.FormatBool(v)
+	default:
+		p.skip(key, fmt.Sprintf("unexpected value %v for %s", value, parameter))
+		return
+	}
+	if (spec.Type == modelfile.ParamBool) != (formatted == "true" || formatted == "false") {
+		p.skip(key, fmt.Sprintf("%s must be %s, got %s", parameter, spec.Type, formatted))
+		return
+	}
+	p.Modelfile.SetParameter(parameter, formatted)
+}
+
+func (p *ImportedPreset) setStop(key string, value any) {
+	values, ok := value.([]any)
+	if !ok {
+		p.skip(key, "expected a list of strings")
+		return
+	}
+	var stops []string
+	for _, v := range values {
+		if stop, ok := v.(string); ok && stop != "" {
+			stops = append(stops, stop)
+		}
+	}
+	if len(stops) > 0 {
+		p.Modelfile.SetParameter("stop", stops...)
+	}
+}
+
+// setPromptTemplate sets the TEMPLATE from a manual or Jinja prompt template, returning the stop strings saved with it
+func (p *ImportedPreset) setPromptTemplate(value any) []string {
+	const key = "llm.prediction.promptTemplate"
+	data, err := json.Marshal(value)
+	if err != nil {
+		p.skip(key, err.Error())
+		return nil
+	}
+	var manual ManualPromptTemplateValue
+	var jinja JinjaPromptTemplate
+	if err := json.Unmarshal(data, &manual); err != nil {
+		p.skip(key, fmt.Sprintf("unexpected prompt template: %v", err))
+		return nil
+	}
+
+	switch manual.Type {
+	case "manual":
+		if tmpl := manualTemplateToGo(manual.ManualPromptTemplate); tmpl != "" {
+			p.Modelfile.SetTemplate(tmpl)
+		}
+		return manual.StopStrings
+	case "jinja":
+		if err := json.Unmarshal(data, &jinja); err != nil {
+			p.skip(key, fmt.Sprintf("unexpected prompt template: %v", err))
+			return nil
+		}
+		// Only Jinja templates Ollama recognises can be translated, the same as when creating models from LM Studio
+		named, err := template.Named(jinja.JinjaPromptTemplate.Template)
+		if err != nil {
+			p.skip(key, "the Jinja template doesn't match a template Ollama knows, the model's TEMPLATE is kept")
+			return nil
+		}
+		logging.DebugLogger.Printf("Preset Jinja template matched Ollama's %s template\n", named.Name)
+		p.Modelfile.SetTemplate(string(named.Bytes))
+		if len(jinja.StopStrings) == 0 && named.Parameters != nil {
+			return named.Parameters.Stop
+		}
+		return jinja.StopStrings
+	}
+	p.skip(key, fmt.Sprintf("unknown prompt template type %q", manual.Type))
+	return nil
+}
+
+func (p *ImportedPreset) skip(key, reason string) {
+	logging.DebugLogger.Printf("Skipping preset field %s: %s\n", key, reason)
+	p.Skipped = append(p.Skipped, key+": "+reason)
+}
+
+func importLegacyPreset(preset *LMStudioPresetLegacy, inference, load map[string]any) *ImportedPreset {
+	imported := &ImportedPreset{Name: preset.Name, Modelfile: &modelfile.Modelfile{}}
+	params := preset.InferenceParams
+
+	if params.PrePrompt != "" {
+		imported.Modelfile.SetSystem(params.PrePrompt)
+	}
+	tmpl := manualTemplateToGo(ManualPromptTemplate{
+		BeforeSystem: params.PrePromptPrefix,
+		AfterSystem:  params.PrePromptSuffix,
+		BeforeUser:   params.InputPrefix,
+		AfterUser:    params.InputSuffix,
+	})
+	if tmpl != "" {
+		imported.Modelfile.SetTemplate(tmpl)
+	}
+	if len(params.Antiprompt) > 0 {
+		imported.Modelfile.SetParameter("stop", params.Antiprompt...)
+	}
+
+	// load_params come second so the context and seed chosen for loading win over inference_params
+	for _, group := range []struct {
+		name   string
+		values map[string]any
+	}{{"inference_params", inference}, {"load_params", load}} {
+		keys := make([]string, 0, len(group.values))
+		for key := range group.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if group.name == "inference_params" && legacyPromptParams[key] {
+				continue
+			}
+			parameter, ok := legacyPresetParameters[key]
+			if !ok {
+				imported.skip(group.name+"."+key, "no Ollama equivalent")
+				continue
+			}
+			imported.setValue(group.name+"."+key, parameter, group.values[key])
+		}
+	}
+	return imported
+}
+
+// manualTemplateToGo builds an Ollama template from LM Studio's before and after strings for each role. LM Studio
+// puts the assistant's prefix in AfterUser, so the prompt ends with it and BeforeAssistant to start the reply.
+func manualTemplateToGo(t ManualPromptTemplate) string {
+	if t == (ManualPromptTemplate{}) {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(`{{ range .Messages }}`)
+	fmt.Fprintf(&sb, `{{ if eq .Role "system" }}%s{{ .Content }}%s`, templateText(t.BeforeSystem), templateText(t.AfterSystem))
+	fmt.Fprintf(&sb, `{{ else if eq .Role "user" }}%s{{ .Content }}%s`, templateText(t.BeforeUser), templateText(t.AfterUser))
+	fmt.Fprintf(&sb, `{{ else if eq .Role "assistant" }}%s{{ .Content }}%s`, templateText(t.BeforeAssistant), templateText(t.AfterAssistant))
+	sb.WriteString(`{{ end }}{{ end }}`)
+	sb.WriteString(templateText(t.BeforeAssistant))
+	return sb.String()
+}
+
+// templateText escapes text for a Go template, text that would start an action is written as a string
+func templateText(s string) string {
+	if strings.Contains(s, "{{") {
+		return "{{ " + strconv.Quote(s) + " }}"
+	}
+	return s
+}
+
+// ImportModelPreset applies an imported preset to an Ollama model. When newModelName is set a new model is
+// created from modelName with the preset's settings, otherwise modelName itself is updated. Settings the preset
+// doesn't mention are kept from the model.
+func ImportModelPreset(preset *ImportedPreset, modelName, newModelName string, client *api.Client) error {
+	target := modelName
+	if newModelName != "" {
+		target = newModelName
+	}
+
+	req := &api.CreateRequest{
+		Model:      target,
+		From:       modelName,
+		Parameters: preset.Modelfile.APIParameters(),
+	}
+	req.Template, _ = preset.Modelfile.Template()
+	req.System, _ = preset.Modelfile.System()
+
+	err := client.Create(context.Background(), req, func(resp api.ProgressResponse) error {
+		if resp.Status != "" {
+			logging.DebugLogger.Printf("Create progress: %s", resp.Status)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply preset %s to %s: %w", preset.Name, target, err)
+	}
+	logging.InfoLogger.Printf("Applied LM Studio preset %s to %s\n", preset.Name, target)
+	return nil
+}
@@ -0,0 +1,229 @@
+package lmstudio
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/template"
+)
+
+func TestParseLMStudioPreset(t *testing.T) {
+	preset := `{
+  "identifier": "@local:tuned",
+  "name": "Tuned",
+  "changed": true,
+  "operation": {
+    "fields": [
+      {"key": "llm.prediction.temperature", "value": 0.3},
+      {"key": "llm.prediction.topKSampling", "value": 20},
+      {"key": "llm.prediction.topPSampling", "value": {"checked": true, "value": 0.8}},
+      {"key": "llm.prediction.minPSampling", "value": {"checked": false, "value": 0.05}},
+      {"key": "llm.prediction.repeatPenalty", "value": {"checked": true, "value": 1.1}},
+      {"key": "llm.prediction.maxPredictedTokens", "value": {"checked": true, "value": 512.5}},
+      {"key": "llm.prediction.systemPrompt", "value": "Be brief."},
+      {"key": "llm.prediction.stopStrings", "value": ["<|end|>", "<|user|>"]},
+      {"key": "llm.prediction.llama.xtcProbability", "value": 0.5}
+    ]
+  },
+  "load": {
+    "fields": [
+      {"key": "llm.load.contextLength", "value": 8192},
+      {"key": "llm.load.seed", "value": {"checked": true, "value": 42}},
+      {"key": "llm.load.llama.tryMmap", "value": false},
+      {"key": "llm.load.llama.acceleration.offloadRatio", "value": 0.5}
+    ]
+  }
+}`
+	imported, err := ParseLMStudioPreset([]byte(preset))
+	if err != nil {
+		t.Fatalf("ParseLMStudioPreset failed: %v", err)
+	}
+	if imported.Name != "Tuned" {
+		t.Errorf("Expected name Tuned, got %s", imported.Name)
+	}
+
+	want := map[string][]string{
+		"temperature":    {"0.3"},
+		"top_k":          {"20"},
+		"top_p":          {"0.8"},
+		"repeat_penalty": {"1.1"},
+		"stop":           {"<|end|>", "<|user|>"},
+		"num_ctx":        {"8192"},
+		"seed":           {"42"},
+		"use_mmap":       {"false"},
+	}
+	if got := imported.Modelfile.ParameterMap(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Parameters = %v, want %v", got, want)
+	}
+	if system, _ := imported.Modelfile.System(); system != "Be brief." {
+		t.Errorf("Expected the system prompt, got %q", system)
+	}
+
+	// The fractional token count, the unknown field and the offload ratio are reported, the unchecked min_p isn't
+	if len(imported.Skipped) != 3 {
+		t.Fatalf("Expected 3 skipped fields, got %v", imported.Skipped)
+	}
+	for i, key := range []string{"llm.prediction.maxPredictedTokens", "llm.prediction.llama.xtcProbability", "llm.load.llama.acceleration.offloadRatio"} {
+		if !strings.HasPrefix(imported.Skipped[i], key+": ") {
+			t.Errorf("Skipped[%d] = %q, want it to be about %s", i, imported.Skipped[i], key)
+		}
+	}
+}
+
+func TestParseLMStudioPresetLegacy(t *testing.T) {
+	preset := `{
+  "name": "Legacy",
+  "inference_params": {
+    "input_prefix": "### Instruction:\n",
+    "input_suffix": "\n### Response:\n",
+    "pre_prompt": "You are helpful.",
+    "pre_prompt_prefix": "",
+    "pre_prompt_suffix": "\n\n",
+    "antiprompt": ["### Instruction:"],
+    "temp": 0.7,
+    "n_predict": -1,
+    "seed": -1,
+    "multiline_input": false
+  },
+  "load_params": {
+    "n_ctx": 4096,
+    "n_gpu_layers": 33,
+    "seed": 7
+  }
+}`
+	imported, err := ParseLMStudioPreset([]byte(preset))
+	if err != nil {
+		t.Fatalf("ParseLMStudioPreset failed: %v", err)
+	}
+	want := map[string][]string{
+		"stop":        {"### Instruction:"},
+		"temperature": {"0.7"},
+		"num_predict": {"-1"},
+		"seed":        {"7"},
+		"num_ctx":     {"4096"},
+		"num_gpu":     {"33"},
+	}
+	if got := imported.Modelfile.ParameterMap(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Parameters = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(imported.Skipped, []string{"inference_params.multiline_input: no Ollama equivalent"}) {
+		t.Errorf("Skipped = %v", imported.Skipped)
+	}
+
+	tmpl, ok := imported.Modelfile.Template()
+	if !ok {
+		t.Fatal("Expected a TEMPLATE from the prefixes and suffixes")
+	}
+	got := renderTemplate(t, tmpl, []api.Message{
+		{Role: "system", Content: "You are helpful."},
+		{Role: "user", Content: "Hi"},
+		{Role: "assistant", Content: "Hello"},
+		{Role: "user", Content: "Bye"},
+	})
+	wantPrompt := "You are helpful.\n\n### Instruction:\nHi\n### Response:\nHello### Instruction:\nBye\n### Response:\n"
+	if got != wantPrompt {
+		t.Errorf("Rendered prompt:\n%q\nwant:\n%q", got, wantPrompt)
+	}
+}
+
+func TestParseLMStudioPresetPromptTemplates(t *testing.T) {
+	manual := `{"name": "Manual", "operation": {"fields": [{"key": "llm.prediction.promptTemplate", "value": {
+  "type": "manual",
+  "stopStrings": ["<|end|>"],
+  "manualPromptTemplate": {"beforeSystem": "<|system|>\n", "afterSystem": "<|end|>\n", "beforeUser": "<|user|>\n",
+    "afterUser": "<|end|>\n<|assistant|>\n", "beforeAssistant": "", "afterAssistant": "<|end|>\n"}}}]}}`
+	imported, err := ParseLMStudioPreset([]byte(manual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, _ := imported.Modelfile.Template()
+	got := renderTemplate(t, tmpl, []api.Message{{Role: "system", Content: "S"}, {Role: "user", Content: "U"}})
+	if want := "<|system|>\nS<|end|>\n<|user|>\nU<|end|>\n<|assistant|>\n"; got != want {
+		t.Errorf("Rendered prompt %q, want %q", got, want)
+	}
+	if stop := imported.Modelfile.ParameterValues("stop"); !reflect.DeepEqual(stop, []string{"<|end|>"}) {
+		t.Errorf("Expected the template's stop strings, got %v", stop)
+	}
+
+	jinja := `{"name": "Jinja", "operation": {"fields": [{"key": "llm.prediction.promptTemplate", "value": {
+  "type": "jinja", "stopStrings": [],
+  "jinjaPromptTemplate": {"template": "{% for message in messages %}{{'<|im_start|>' + message['role'] + '\n' + message['content'] + '<|im_end|>' + '\n'}}{% endfor %}{% if add_generation_prompt %}{{ '<|im_start|>assistant\n' }}{% endif %}"}}}]}}`
+	imported, err = ParseLMStudioPreset([]byte(jinja))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl, _ := imported.Modelfile.Template(); !strings.Contains(tmpl, "<|im_start|>") {
+		t.Errorf("Expected Ollama's ChatML template, got %q", tmpl)
+	}
+	if stop := imported.Modelfile.ParameterValues("stop"); !reflect.DeepEqual(stop, []string{"<|im_start|>", "<|im_end|>"}) {
+		t.Errorf("Expected the ChatML stop parameters, got %v", stop)
+	}
+
+	unknown := `{"operation": {"fields": [{"key": "llm.prediction.promptTemplate", "value": {
+  "type": "jinja", "jinjaPromptTemplate": {"template": "{{ raise_exception('no') }}"}}}]}}`
+	imported, err = ParseLMStudioPreset([]byte(unknown))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := imported.Modelfile.Template(); ok || len(imported.Skipped) != 1 {
+		t.Errorf("Expected the unknown Jinja template to be skipped, got %v", imported.Skipped)
+	}
+
+	if _, err := ParseLMStudioPreset([]byte(`{"fields": []}`)); err == nil {
+		t.Error("Expected an error for JSON that isn't a preset")
+	}
+}
+
+func TestImportModelPreset(t *testing.T) {
+	var got api.CreateRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/create" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte(`{"status":"success"}` + "\n"))
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	client := api.NewClient(serverURL, server.Client())
+
+	imported, err := ParseLMStudioPreset([]byte(`{"name": "Tuned", "operation": {"fields": [
+  {"key": "llm.prediction.temperature", "value": 0.3},
+  {"key": "llm.prediction.systemPrompt", "value": "Be brief."}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ImportModelPreset(imported, "llama3:8b", "llama3:tuned", client); err != nil {
+		t.Fatalf("ImportModelPreset failed: %v", err)
+	}
+	if got.Model != "llama3:tuned" || got.From != "llama3:8b" || got.System != "Be brief." || got.Template != "" {
+		t.Errorf("Unexpected create request %+v", got)
+	}
+	if got.Parameters["temperature"] != 0.3 {
+		t.Errorf("Expected temperature 0.3, got %v", got.Parameters)
+	}
+}
+
+// renderTemplate executes an Ollama template the way the server does
+func renderTemplate(t *testing.T, tmpl string, messages []api.Message) string {
+	t.Helper()
+	parsed, err := template.Parse(tmpl)
+	if err != nil {
+		t.Fatalf("Template doesn't parse: %v\n%s", err, tmpl)
+	}
+	var buf bytes.Buffer
+	if err := parsed.Execute(&buf, template.Values{Messages: messages}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
@@ -0,0 +1,70 @@
+package lmstudio
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrSplitModel is returned for models split into shards, which Ollama can't create models from
+var ErrSplitModel = errors.New("the model is split into several GGUF files, which Ollama can't load")
+
+// shardPattern matches the names llama.cpp's gguf-split gives the files of a split model,
+// <name>-00001-of-00003.gguf
+var shardPattern = regexp.MustCompile(`(?i)^(.+)-(\d{5})(-of-)(\d{5})(\.gguf)$`)
+
+// findShards returns the files of the split model path belongs to in order and the names of the ones that are
+// missing, or nil if path isn't a shard
+func findShards(path string) (shards, missing []string) {
+	match := shardPattern.FindStringSubmatch(filepath.Base(path))
+	if match == nil {
+		return nil, nil
+	}
+	count, _ := strconv.Atoi(match[4])
+	dir := filepath.Dir(path)
+	for i := 1; i <= count; i++ {
+		// Keep the original's capitalisation
+		name := fmt.Sprintf("%s-%05d%s%s%s", match[1], i, match[3], match[4], match[5])
+		shard := filepath.Join(dir, name)
+		if _, err := os.Stat(shard); err != nil {
+			missing = append(missing, name)
+			continue
+		}
+		shards = append(shards, shard)
+	}
+	return shards, missing
+}
+
+// shardsName is the name of the model a shard belongs to, without the shard numbers
+func shardsName(path string) string {
+	return shardPattern.ReplaceAllString(filepath.Base(path), "$1")
+}
+
+// filesSize returns the total size of files, skipping any that can't be read
+func filesSize(files []string) int64 {
+	var size int64
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+// splitModelError explains why a split model can't be imported, and how to merge it when all of its shards are
+// there. It returns nil for models that aren't split.
+func splitModelError(name string, shards, missing []string) error {
+	if len(missing) > 0 {
+		return fmt.Errorf("%w and %s is missing %s, it may still be downloading", ErrSplitModel, name, strings.Join(missing, ", "))
+	}
+	if len(shards) == 0 {
+		return nil
+	}
+	merged := shardPattern.ReplaceAllString(filepath.Base(shards[0]), "$1$5")
+	return fmt.Errorf("%w, merge its %d files with `llama-gguf-split --merge %s %s` and create the model from %s with `ollama create`",
+		ErrSplitModel, len(shards), shards[0], merged, merged)
+}
@@ -5,8 +5,6 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"net/http"
-	"net/url"
 	"os"
 	"sort"
 	"strings"
@@ -22,11 +20,14 @@ import (
 	"golang.org/x/term"
 
 	"github.com/mipalgu/gollama/config"
+	"github.com/mipalgu/gollama/links"
 	"github.com/mipalgu/gollama/lmstudio"
 	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/modelfile"
+	"github.com/mipalgu/gollama/spit"
 	"github.com/mipalgu/gollama/styles"
+	"github.com/mipalgu/gollama/transport"
 	"github.com/mipalgu/gollama/vramestimator"
-	"github.com/sammcj/spitter/spitter"
 )
 
 type AppModel struct {
@@ -62,6 +63,21 @@ type AppModel struct {
 	externalEditing     bool
 	externalEditorFile  string
 	externalEditorModel string
+	externalEditorLint  []modelfile.Diagnostic
+	diskUsageKnown      bool
+	diskUsageTotal      int64
+	linkStrategy        links.Strategy
+	linkTarget          linkTarget
+	linkTargetErr       error // why the configured link target can't be used, reported when linking
+	confirmRelink       bool
+	staleLinks          []staleLink
+	spitting            bool
+	spit                *spitState
+	connections         []config.Connection
+	activeConnection    int
+	allHosts            bool
+	choosingHost        bool
+	hostCursor          int
 }
 
 // TODO: Refactor: we don't need unique message types for every single action
@@ -92,6 +108,10 @@ type genericMsg struct {
 	message string
 }
 
+type staleLinksMsg struct {
+	stale []staleLink
+}
+
 type View int
 
 var Version string // Version is set by the build system
@@ -106,6 +126,8 @@ func main() {
 		fmt.Println("Error loading config:", err)
 		os.Exit(1)
 	}
+	// The flags and --connection change cfg, so the config is saved from the settings as they were loaded
+	savedCfg := cfg
 
 	// Initialise themes
 	err = config.SaveThemes()
@@ -128,8 +150,13 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Subcommands such as `gollama lint` have their own flags and exit once they've run
+	if exitCode, ok := runSubcommand(&cfg, os.Args[1:]); ok {
+		os.Exit(exitCode)
+	}
+
 	listFlag := flag.Bool("l", false, "List all available Ollama models and exit")
-	linkFlag := flag.Bool("L", false, "Link Ollama models to LM Studio")
+	linkFlag := flag.Bool("L", false, "Link Ollama models to LM Studio, or the app chosen with --link-target")
 	exportConfigFlag := flag.Bool("export-config", false, "Export Ollama Modelfile configs to LM Studio when linking")
 	flag.BoolVar(exportConfigFlag, "x", false, "Export Ollama Modelfile configs to LM Studio when linking (alias for --export-config)")
 	linkLMStudioFlag := flag.Bool("link-lmstudio", false, "Link LM Studio models to Ollama")
@@ -140,12 +167,18 @@ func main() {
 	ollamaDirFlag := flag.String("ollama-dir", cfg.OllamaModelsDir, "Custom Ollama models directory")
 	lmStudioDirFlag := flag.String("lm-dir", cfg.LMStudioFilePaths, "Custom LM Studio models directory")
 	noCleanupFlag := flag.Bool("no-cleanup", false, "Don't cleanup broken symlinks")
+	linkStrategyFlag := flag.String("link-strategy", cfg.LinkStrategy, "How models are linked into LM Studio: symlink, hardlink, reflink or copy")
+	linkTargetFlag := flag.String("link-target", cfg.LinkTarget, "The app models are linked into: lmstudio, llamacpp or jan")
+	targetDirFlag := flag.String("target-dir", "", "Custom models directory of the link target")
 	cleanupFlag := flag.Bool("cleanup", false, "Remove all symlinked models and empty directories and exit")
 	searchFlag := flag.String("s", "", "Search - return a list of models that contain the search term in their name")
+	outputFlag := flag.String("output", "", "Output format for -l and -s (json, yaml, csv, tsv)")
+	flag.StringVar(outputFlag, "o", "", "Output format for -l and -s (alias for --output)")
 	unloadModelsFlag := flag.Bool("u", false, "Unload all models and exit")
 	versionFlag := flag.Bool("v", false, "Print the version and exit")
 	hostFlag := flag.String("h", "", "Override the config file to set the Ollama API host (e.g. http://localhost:11434)")
 	localHostFlag := flag.Bool("H", false, "Shortcut to connect to http://localhost:11434")
+	connectionFlag := flag.String("connection", "", "Name of the connection in the config to connect to, the TUI can switch to the others with H")
 	editFlag := flag.Bool("e", false, "Edit a model's modelfile")
 	logLevelFlag := flag.String("log-level", "", "Override log level (debug, info, warn, error)")
 	flag.StringVar(logLevelFlag, "log", "", "Override log level (debug, info, warn, error)")
@@ -159,7 +192,13 @@ func main() {
 	// Spitter flags
 	spitFlag := flag.String("spit", "", "Copy a model to a remote host (specify model name)")
 	spitAllFlag := flag.Bool("spit-all", false, "Copy all models to a remote host")
-	remoteHostFlag := flag.String("remote", "", "Remote host URL for spit operations (e.g., http://remote-host:11434)")
+	remoteHostFlag := flag.String("remote", "", "Remote host URL for spit operations (e.g., http://remote-host:11434), or the name of one of the remotes in the config")
+	spitParallelFlag := flag.Int("spit-parallel", spit.DefaultParallel, "Number of blobs to upload at once when copying models to a remote host")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: gollama [flags]\n       gollama <command> [flags] [args]\n\n%s\nFlags:\n", subcommandUsage())
+		flag.PrintDefaults()
+	}
 
 	flag.Parse()
 
@@ -168,6 +207,17 @@ func main() {
 		os.Exit(0)
 	}
 
+	outputFormat, err := parseOutputFormat(*outputFlag)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	linkStrategy, err := links.ParseStrategy(*linkStrategyFlag)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
 	if *localHostFlag {
 		*hostFlag = "http://localhost:11434"
 	}
@@ -176,6 +226,22 @@ func main() {
 		cfg.OllamaAPIURL = *hostFlag
 	}
 
+	// The top level connection is the one given with -h, and a connection named default replaces it
+	connections := connectionList(&cfg, *ollamaDirFlag)
+	if *connectionFlag == "" && hasConnection(cfg.Connections, config.DefaultConnectionName) {
+		*connectionFlag = config.DefaultConnectionName
+	}
+	activeConnection := 0
+	if *connectionFlag != "" {
+		activeConnection, err = findConnection(connections, *connectionFlag)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		cfg.UseConnection(connections[activeConnection])
+		*ollamaDirFlag = cfg.OllamaModelsDir
+	}
+
 	if *logLevelFlag != "" {
 		cfg.LogLevel = *logLevelFlag
 		// Reinitialise logging with the new level
@@ -188,11 +254,9 @@ func main() {
 
 	// Initialise the API client
 	ctx := context.Background()
-	httpClient := &http.Client{}
-	url, err := url.Parse(cfg.OllamaAPIURL)
-
+	client, err := newAPIClient(&cfg)
 	if err != nil {
-		message := fmt.Sprintf("Error parsing API URL: %v", err)
+		message := fmt.Sprintf("Error initialising API client: %v", err)
 		logging.ErrorLogger.Println(message)
 		fmt.Println(message)
 		os.Exit(1)
@@ -218,8 +282,9 @@ func main() {
 			quantLevel = *quantFlag
 		}
 
-		var isHuggingFaceModel = strings.Contains(baseModel, "/")
-		var isOllamaModel = !isHuggingFaceModel
+		var isGGUFFile = vramestimator.IsGGUFFile(baseModel)
+		var isHuggingFaceModel = !isGGUFFile && strings.Contains(baseModel, "/")
+		var isOllamaModel = !isGGUFFile && !isHuggingFaceModel
 
 		// Parse the context size
 		var topContext int
@@ -260,9 +325,21 @@ func main() {
 
 		// Fetch model information from appropriate source
 		var ollamaModelInfo *vramestimator.OllamaModelInfo
-		if isOllamaModel {
+		if isGGUFFile {
+			logging.DebugLogger.Printf("Reading model info from GGUF metadata in %s", baseModel)
+			ollamaModelInfo, err = vramestimator.GGUFModelInfo(baseModel)
+			if err != nil {
+				fmt.Printf("Error: Could not read GGUF model info: %v\n", err)
+				os.Exit(1)
+			}
+		} else if isOllamaModel {
 			logging.DebugLogger.Printf("Fetching model info from Ollama API for %s", baseModel)
-			ollamaModelInfo, err = vramestimator.FetchOllamaModelInfo(cfg.OllamaAPIURL, modelName)
+			httpClient, err := transport.NewHTTPClient(cfg.Transport())
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			ollamaModelInfo, err = vramestimator.FetchOllamaModelInfo(httpClient, cfg.OllamaAPIURL, modelName)
 			if err != nil {
 				fmt.Printf("Error: Could not fetch Ollama model info: %v\n", err)
 				os.Exit(1)
@@ -282,8 +359,6 @@ func main() {
 		os.Exit(0)
 	}
 
-	client := api.NewClient(url, httpClient)
-
 	resp, err := client.List(ctx)
 	if err != nil {
 		message := fmt.Sprintf("Error fetching models:\n- Error: %v\n- Configured API URL: %v", err, cfg.OllamaAPIURL)
@@ -293,6 +368,7 @@ func main() {
 	}
 
 	models := parseAPIResponse(resp)
+	diskUsageTotal, diskUsageKnown := applyDiskUsage(models, *ollamaDirFlag)
 
 	modelMap := make(map[string][]Model)
 	for _, model := range models {
@@ -305,24 +381,7 @@ func main() {
 		groupedModels = append(groupedModels, group...)
 	}
 
-	switch cfg.SortOrder {
-	case "name":
-		sort.Slice(groupedModels, func(i, j int) bool {
-			return groupedModels[i].Name < groupedModels[j].Name
-		})
-	case "size":
-		sort.Slice(groupedModels, func(i, j int) bool {
-			return groupedModels[i].Size > groupedModels[j].Size
-		})
-	case "modified":
-		sort.Slice(groupedModels, func(i, j int) bool {
-			return groupedModels[i].Modified.After(groupedModels[j].Modified)
-		})
-	case "family":
-		sort.Slice(groupedModels, func(i, j int) bool {
-			return groupedModels[i].Family < groupedModels[j].Family
-		})
-	}
+	sortModels(groupedModels, cfg.SortOrder)
 
 	items := make([]list.Item, len(groupedModels))
 	for i, model := range groupedModels {
@@ -345,11 +404,16 @@ func main() {
 		ollamaModelsDir:   *ollamaDirFlag,
 		lmStudioModelsDir: *lmStudioDirFlag,
 		noCleanup:         *noCleanupFlag,
+		linkStrategy:      linkStrategy,
+		diskUsageTotal:    diskUsageTotal,
+		diskUsageKnown:    diskUsageKnown,
 		cfg:               &cfg,
 		progress:          progress.New(progress.WithDefaultGradient()),
 		pullInput:         textinput.New(),
 		pulling:           false,
 		pullProgress:      0,
+		connections:       connections,
+		activeConnection:  activeConnection,
 	}
 
 	if *ollamaDirFlag == "" {
@@ -362,9 +426,10 @@ func main() {
 			// Update the config with the default LM Studio directory
 			logging.InfoLogger.Printf("Setting LM Studio directory to default: %s\n", app.lmStudioModelsDir)
 			cfg.LMStudioFilePaths = app.lmStudioModelsDir
-			cfg.SetModified()
+			savedCfg.LMStudioFilePaths = app.lmStudioModelsDir
+			savedCfg.SetModified()
 			logging.InfoLogger.Printf("Saving config with LM Studio directory: %s\n", cfg.LMStudioFilePaths)
-			if err := cfg.SaveIfModified(); err != nil {
+			if err := savedCfg.SaveIfModified(); err != nil {
 				logging.ErrorLogger.Printf("Error saving config: %v\n", err)
 			} else {
 				logging.InfoLogger.Printf("Config saved successfully\n")
@@ -376,12 +441,23 @@ func main() {
 	}
 
 	if *listFlag {
-		listModels(models)
+		listModels(models, outputFormat)
+		// Piped output is the plain table, which the styled total would break
+		if outputFormat == OutputText && stdoutIsTerminal() && diskUsageKnown && len(models) > 0 {
+			fmt.Println(diskUsageSummary(models, diskUsageTotal))
+		}
 		os.Exit(0)
 	}
 
+	// The target is only needed to link, so a bad link_target doesn't stop the modes that don't
+	app.linkTarget, app.linkTargetErr = resolveLinkTarget(*linkTargetFlag, *targetDirFlag, app.lmStudioModelsDir, &cfg)
+
 	if *cleanupFlag {
-		cleanupSymlinkedModels(app.lmStudioModelsDir)
+		if app.linkTargetErr != nil {
+			fmt.Println("Error:", app.linkTargetErr)
+			os.Exit(1)
+		}
+		app.linkTarget.RemoveAll()
 		os.Exit(0)
 	}
 
@@ -391,7 +467,7 @@ func main() {
 		if len(searchTerms) == 0 {
 			searchTerms = []string{*searchFlag}
 		}
-		searchModels(models, searchTerms...)
+		searchModels(models, outputFormat, searchTerms...)
 		os.Exit(0)
 	}
 
@@ -401,13 +477,17 @@ func main() {
 			fmt.Println("Error: Linking models is only supported on localhost")
 			os.Exit(1)
 		}
+		if app.linkTargetErr != nil {
+			fmt.Println("Error:", app.linkTargetErr)
+			os.Exit(1)
+		}
 
 		prefix := ""
 		if *dryRunFlag {
 			prefix = "[DRY RUN] "
-			fmt.Printf("%sWould link Ollama models to LM Studio (directory: %s)\n", prefix, app.lmStudioModelsDir)
+			fmt.Printf("%sWould link Ollama models to %s (directory: %s)\n", prefix, app.linkTarget.Name(), app.linkTarget.Dir())
 		} else {
-			fmt.Printf("Linking Ollama models to LM Studio (directory: %s)\n", app.lmStudioModelsDir)
+			fmt.Printf("Linking Ollama models to %s (directory: %s)\n", app.linkTarget.Name(), app.linkTarget.Dir())
 		}
 
 		fmt.Printf("\nFound %d models to link:\n", len(models))
@@ -419,11 +499,16 @@ func main() {
 
 		fmt.Printf("\nStarting linking process...\n\n")
 
+		var export *configExport
+		if *exportConfigFlag {
+			export = &configExport{unmapped: make(map[string][]string)}
+		}
+
 		// link all models
 		successCount := 0
 		for _, model := range models {
 			fmt.Printf("%sLinking model: %s... ", prefix, model.Name)
-			message, err := linkModel(model.Name, app.lmStudioModelsDir, false, *dryRunFlag, *exportConfigFlag, client)
+			message, err := linkModel(model.Name, app.linkTarget, linkStrategy, false, *dryRunFlag, export, client)
 
 			if err != nil {
 				logging.ErrorLogger.Printf("Error linking model %s: %v\n", model.Name, err)
@@ -446,6 +531,9 @@ func main() {
 		} else {
 			fmt.Printf("\nSummary: Successfully linked %d of %d models\n", successCount, len(models))
 		}
+		if export != nil {
+			printUnmappedParameters(os.Stdout, app.linkTarget.Name(), export.unmapped)
+		}
 		os.Exit(0)
 	}
 
@@ -483,10 +571,12 @@ func main() {
 		var successCount, failCount int
 
 		for _, model := range models {
+			fmt.Printf("%sProcessing model %s... ", prefix, model.Name)
+			if len(model.Shards) > 0 {
+				fmt.Printf("(split into %d files, %s) ", len(model.Shards)+len(model.MissingShards), formatSize(model.Size))
+			}
 			if *dryRunFlag {
-				fmt.Printf("%sProcessing model %s...\n", prefix, model.Name)
-			} else {
-				fmt.Printf("%sProcessing model %s... ", prefix, model.Name)
+				fmt.Println()
 			}
 			if err := lmstudio.LinkModelToOllama(model, *dryRunFlag, cfg.OllamaAPIURL, app.ollamaModelsDir); err != nil {
 				logging.ErrorLogger.Printf("Error linking model %s: %v\n", model.Name, err)
@@ -553,6 +643,9 @@ func main() {
 			if len(model.VisionFiles) > 0 {
 				fmt.Printf("(vision model with %d projection files) ", len(model.VisionFiles))
 			}
+			if len(model.Shards) > 0 {
+				fmt.Printf("(split into %d files, %s) ", len(model.Shards)+len(model.MissingShards), formatSize(model.Size))
+			}
 			if err := lmstudio.CreateOllamaModel(model, *dryRunFlag, cfg.OllamaAPIURL, client); err != nil {
 				logging.ErrorLogger.Printf("Error creating model %s: %v\n", model.Name, err)
 				fmt.Printf("failed: %v\n", err)
@@ -623,55 +716,21 @@ func main() {
 			os.Exit(1)
 		}
 
-		// Create a spitter configuration
-		config := spitter.SyncConfig{
-			RemoteServer: *remoteHostFlag,
-		}
-
-		// If Docker container is specified in the config, use it for the Ollama command
-		if cfg.DockerContainer != "" && strings.ToLower(cfg.DockerContainer) != "false" {
-			config.OllamaCommand = fmt.Sprintf("docker exec -it %s ollama", cfg.DockerContainer)
-		}
-
-		// Set the custom model directory if specified
-		if app.ollamaModelsDir != "" {
-			config.CustomModelDir = app.ollamaModelsDir
-		}
-
+		var modelNames []string
 		if *spitAllFlag {
-			// Copy all models
-			config.AllModels = true
-			fmt.Printf("Copying all models to remote host %s...\n", *remoteHostFlag)
-
-			// We still need to provide a model name even though we're copying all models
-			// The spitter package will ignore this when AllModels is true
-			if len(groupedModels) > 0 {
-				config.LocalModel = groupedModels[0].Name
+			for _, model := range groupedModels {
+				modelNames = append(modelNames, model.Name)
 			}
+			fmt.Printf("Copying all models to remote host %s...\n", *remoteHostFlag)
 		} else {
-			// Copy a single model
-			config.LocalModel = *spitFlag
-			fmt.Printf("Copying model %s to remote host %s...\n", *spitFlag, *remoteHostFlag)
-		}
-
-		// Sync the model(s)
-		err := spitter.Sync(config)
-		if err != nil {
-			fmt.Printf("Error copying model(s) to remote host: %v\n", err)
-			os.Exit(1)
-		}
-
-		if *spitAllFlag {
-			fmt.Printf("Successfully copied %d models to %s\n", len(groupedModels), *remoteHostFlag)
-		} else {
-			fmt.Printf("Successfully copied model %s to %s\n", *spitFlag, *remoteHostFlag)
+			modelNames = []string{*spitFlag}
 		}
-		os.Exit(0)
+		os.Exit(spitModels(&cfg, modelNames, resolveRemote(&cfg, *remoteHostFlag), app.ollamaModelsDir, *spitParallelFlag))
 	}
 
 	// TUI App
 	l := list.New(items, NewItemDelegate(&app), width, height-5)
-	l.Title = fmt.Sprintf("Ollama Models - Connected to %s", cfg.OllamaAPIURL)
+	l.Title = listTitle(app.activeConnectionLabel(), app.diskUsageTotal, app.diskUsageKnown)
 	l.Help.Styles.ShortDesc.Bold(true)
 	l.Help.Styles.ShortDesc.UnsetFaint()
 	l.Help.Styles.ShortDesc = styles.PromptStyle()
@@ -696,6 +755,8 @@ func main() {
 			keys.LinkAllModels,
 			keys.CopyModel,
 			keys.PushModel,
+			keys.SpitModel,
+			keys.SwitchHost,
 			keys.Top,
 			keys.EditModel,
 			keys.Help,
@@ -9,12 +9,23 @@ import (
 type Model struct {
 	Name              string
 	ID                string
+	Digest            string
 	Size              float64
+	SizeBytes         int64
 	QuantizationLevel string
 	Modified          time.Time
 	Selected          bool
 	Family            string
 	ParameterSize     string
+	// Host is the name of the connection the model is on in the TUI's all hosts view, empty otherwise
+	Host string
+	// UniqueSize and SharedSize are the bytes on disk only this model uses and the bytes it shares with other models,
+	// read from the local manifests so they're zero when the models directory isn't available
+	UniqueSize int64
+	SharedSize int64
+	// DiskUsageKnown is set when the model was found in the local manifests, as both sizes can be zero for a model
+	// whose blobs are all shared or symlinked
+	DiskUsageKnown bool
 }
 
 // EnhancedModelInfo contains detailed information from the Ollama show API
@@ -0,0 +1,159 @@
+package modelfile
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenNewline
+	tokenComment
+	tokenWord
+	tokenString
+	tokenText
+)
+
+// token is a lexical item, val holds the unquoted value for strings and the text after '#' for comments
+type token struct {
+	typ   tokenType
+	val   string
+	quote Quote
+	line  int
+	col   int
+}
+
+// lexer splits Modelfile source into tokens.
+// Instruction keywords and argument names are lexed as words, while argument values are lexed
+// by value() as either a quoted string (which may span lines) or the remaining text on the line.
+type lexer struct {
+	src  string
+	pos  int
+	line int
+	col  int
+	// lineStart is true until the first token on a line, '#' only starts a comment there
+	lineStart bool
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{
+		src:       strings.TrimPrefix(src, "\ufeff"),
+		line:      1,
+		col:       1,
+		lineStart: true,
+	}
+}
+
+func (l *lexer) errorf(line, col int, format string, args ...any) error {
+	return &ParseError{Line: line, Column: col, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (l *lexer) advance(n int) {
+	for i := 0; i < n && l.pos < len(l.src); i++ {
+		if l.src[l.pos] == '\n' {
+			l.line++
+			l.col = 1
+		} else {
+			l.col++
+		}
+		l.pos++
+	}
+}
+
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case ' ', '\t', '\r':
+			l.advance(1)
+		default:
+			return
+		}
+	}
+}
+
+// next returns the next newline, comment, word or EOF token
+func (l *lexer) next() token {
+	l.skipSpace()
+	line, col := l.line, l.col
+	if l.pos >= len(l.src) {
+		return token{typ: tokenEOF, line: line, col: col}
+	}
+
+	if l.src[l.pos] == '\n' {
+		l.advance(1)
+		l.lineStart = true
+		return token{typ: tokenNewline, line: line, col: col}
+	}
+
+	if l.lineStart && l.src[l.pos] == '#' {
+		l.advance(1)
+		text := l.restOfLine()
+		return token{typ: tokenComment, val: strings.TrimRight(text, "\r"), line: line, col: col}
+	}
+
+	l.lineStart = false
+	start := l.pos
+	for l.pos < len(l.src) && !strings.ContainsRune(" \t\r\n", rune(l.src[l.pos])) {
+		l.advance(1)
+	}
+	return token{typ: tokenWord, val: l.src[start:l.pos], line: line, col: col}
+}
+
+// value returns an argument value, either a quoted string or the rest of the line as text.
+// An EOF or newline token is returned if the line has no value.
+func (l *lexer) value() (token, error) {
+	l.skipSpace()
+	line, col := l.line, l.col
+	if l.pos >= len(l.src) {
+		return token{typ: tokenEOF, line: line, col: col}, nil
+	}
+	if l.src[l.pos] == '\n' {
+		return token{typ: tokenNewline, line: line, col: col}, nil
+	}
+	l.lineStart = false
+
+	rest := l.src[l.pos:]
+	var q Quote
+	switch {
+	case strings.HasPrefix(rest, `"""`):
+		q = QuoteTriple
+	case strings.HasPrefix(rest, `'''`):
+		q = QuoteTripleSingle
+	case strings.HasPrefix(rest, `"`):
+		q = QuoteDouble
+	case strings.HasPrefix(rest, `'`):
+		q = QuoteSingle
+	default:
+		text := strings.TrimRight(l.restOfLine(), " \t\r")
+		return token{typ: tokenText, val: text, line: line, col: col}, nil
+	}
+
+	delim := q.delimiter()
+	l.advance(len(delim))
+	end := strings.Index(l.src[l.pos:], delim)
+	if end < 0 {
+		return token{}, l.errorf(line, col, "unterminated %s quoted value", delim)
+	}
+	val := l.src[l.pos : l.pos+end]
+	l.advance(end + len(delim))
+
+	// Nothing but whitespace may follow a quoted value
+	l.skipSpace()
+	if l.pos < len(l.src) && l.src[l.pos] != '\n' {
+		return token{}, l.errorf(l.line, l.col, "unexpected %q after quoted value", strings.TrimSpace(l.restOfLine()))
+	}
+	return token{typ: tokenString, val: val, quote: q, line: line, col: col}, nil
+}
+
+// restOfLine consumes and returns the text up to, but not including, the next newline
+func (l *lexer) restOfLine() string {
+	start := l.pos
+	end := strings.IndexByte(l.src[start:], '\n')
+	if end < 0 {
+		end = len(l.src) - start
+	}
+	l.advance(end)
+	return l.src[start : start+end]
+}
@@ -0,0 +1,284 @@
+package modelfile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Severity is how serious a lint diagnostic is, errors would be rejected or misbehave on the server
+type Severity int
+
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Diagnostic is a single lint finding
+type Diagnostic struct {
+	Severity Severity
+	// Line is the line of the offending command, zero if the problem isn't tied to a line
+	Line    int
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	if d.Line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", d.Line, d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%s: %s", d.Severity, d.Message)
+}
+
+// ParamType is the value type Ollama expects for a parameter
+type ParamType int
+
+const (
+	ParamInt ParamType = iota
+	ParamFloat
+	ParamBool
+	ParamString
+)
+
+func (t ParamType) String() string {
+	switch t {
+	case ParamInt:
+		return "an integer"
+	case ParamFloat:
+		return "a number"
+	case ParamBool:
+		return "true or false"
+	}
+	return "a string"
+}
+
+// ParamSpec describes an Ollama parameter, Min and Max are inclusive and NaN when unbounded
+type ParamSpec struct {
+	Type ParamType
+	Min  float64
+	Max  float64
+}
+
+var unbounded = math.NaN()
+
+// Params are the parameters accepted by Ollama, from api.Options and api.Runner
+var Params = map[string]ParamSpec{
+	"num_keep":          {Type: ParamInt, Min: -1, Max: unbounded},
+	"seed":              {Type: ParamInt, Min: unbounded, Max: unbounded},
+	"num_predict":       {Type: ParamInt, Min: -2, Max: unbounded},
+	"top_k":             {Type: ParamInt, Min: 0, Max: unbounded},
+	"top_p":             {Type: ParamFloat, Min: 0, Max: 1},
+	"min_p":             {Type: ParamFloat, Min: 0, Max: 1},
+	"typical_p":         {Type: ParamFloat, Min: 0, Max: 1},
+	"repeat_last_n":     {Type: ParamInt, Min: -1, Max: unbounded},
+	"temperature":       {Type: ParamFloat, Min: 0, Max: unbounded},
+	"repeat_penalty":    {Type: ParamFloat, Min: 0, Max: unbounded},
+	"presence_penalty":  {Type: ParamFloat, Min: -2, Max: 2},
+	"frequency_penalty": {Type: ParamFloat, Min: -2, Max: 2},
+	"stop":              {Type: ParamString, Min: unbounded, Max: unbounded},
+	"num_ctx":           {Type: ParamInt, Min: 1, Max: unbounded},
+	"num_batch":         {Type: ParamInt, Min: 1, Max: unbounded},
+	"num_gpu":           {Type: ParamInt, Min: -1, Max: unbounded},
+	"main_gpu":          {Type: ParamInt, Min: 0, Max: unbounded},
+	"use_mmap":          {Type: ParamBool, Min: unbounded, Max: unbounded},
+	"num_thread":        {Type: ParamInt, Min: 0, Max: unbounded},
+}
+
+// deprecatedParams are accepted by older Ollama versions but now ignored
+var deprecatedParams = map[string]bool{
+	"penalize_newline": true,
+	"low_vram":         true,
+	"f16_kv":           true,
+	"logits_all":       true,
+	"vocab_only":       true,
+	"use_mlock":        true,
+	"mirostat":         true,
+	"mirostat_tau":     true,
+	"mirostat_eta":     true,
+}
+
+// templateFuncs mirrors the functions Ollama makes available to templates, only their names matter for parsing
+var templateFuncs = template.FuncMap{
+	"json":             func(v any) string { return "" },
+	"currentDate":      func(args ...string) string { return "" },
+	"toTypeScriptType": func(v any) string { return "" },
+}
+
+// Lint parses and checks Modelfile source, a syntax error is reported as a single error diagnostic
+func Lint(src string) []Diagnostic {
+	m, err := Parse(src)
+	if err != nil {
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			return []Diagnostic{{Severity: SeverityError, Line: parseErr.Line, Message: parseErr.Msg}}
+		}
+		return []Diagnostic{{Severity: SeverityError, Message: err.Error()}}
+	}
+	return m.Lint()
+}
+
+// Lint checks a parsed Modelfile for unknown parameters, bad values and template problems
+func (m *Modelfile) Lint() []Diagnostic {
+	var diags []Diagnostic
+	add := func(severity Severity, line int, format string, args ...any) {
+		diags = append(diags, Diagnostic{Severity: severity, Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if _, ok := m.last(From); !ok {
+		add(SeverityError, 0, "missing FROM, a Modelfile must name a base model or weights file")
+	}
+
+	seen := make(map[string]int)
+	for _, cmd := range m.Commands {
+		switch cmd.Instruction {
+		case Parameter:
+			if first, ok := seen[cmd.Key]; ok && cmd.Key != "stop" {
+				add(SeverityWarning, cmd.Line, "%s is already set on line %d, only the last value is used", cmd.Key, first)
+			} else if !ok {
+				seen[cmd.Key] = cmd.Line
+			}
+			diags = append(diags, lintParameter(cmd)...)
+		case Template:
+			if _, err := template.New("").Option("missingkey=zero").Funcs(templateFuncs).Parse(cmd.Value); err != nil {
+				offset, msg := templateError(err)
+				add(SeverityError, cmd.Line+offset, "TEMPLATE is not a valid Go template: %s", msg)
+			}
+		}
+	}
+
+	if tmpl, ok := m.Template(); ok {
+		for _, cmd := range m.Commands {
+			if cmd.Instruction == Parameter && cmd.Key == "stop" && cmd.Value != "" && !strings.Contains(tmpl, cmd.Value) {
+				add(SeverityWarning, cmd.Line, "stop sequence %q does not appear in the TEMPLATE", cmd.Value)
+			}
+		}
+	}
+
+	sort.SliceStable(diags, func(i, j int) bool { return diags[i].Line < diags[j].Line })
+	return diags
+}
+
+func lintParameter(cmd Command) []Diagnostic {
+	diag := func(severity Severity, format string, args ...any) []Diagnostic {
+		return []Diagnostic{{Severity: severity, Line: cmd.Line, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	if deprecatedParams[cmd.Key] {
+		return diag(SeverityWarning, "%s is deprecated and ignored by Ollama", cmd.Key)
+	}
+	spec, ok := Params[cmd.Key]
+	if !ok {
+		if suggestion := closestParam(cmd.Key); suggestion != "" {
+			return diag(SeverityError, "unknown parameter %s, did you mean %s?", cmd.Key, suggestion)
+		}
+		return diag(SeverityError, "unknown parameter %s", cmd.Key)
+	}
+
+	var value float64
+	switch spec.Type {
+	case ParamInt:
+		i, err := strconv.Atoi(cmd.Value)
+		if err != nil {
+			return diag(SeverityError, "%s must be %s, got %q", cmd.Key, spec.Type, cmd.Value)
+		}
+		value = float64(i)
+	case ParamFloat:
+		f, err := strconv.ParseFloat(cmd.Value, 64)
+		if err != nil {
+			return diag(SeverityError, "%s must be %s, got %q", cmd.Key, spec.Type, cmd.Value)
+		}
+		value = f
+	case ParamBool:
+		if cmd.Value != "true" && cmd.Value != "false" {
+			return diag(SeverityError, "%s must be %s, got %q", cmd.Key, spec.Type, cmd.Value)
+		}
+		return nil
+	case ParamString:
+		if cmd.Value == "" {
+			return diag(SeverityWarning, "%s is empty", cmd.Key)
+		}
+		return nil
+	}
+
+	if !math.IsNaN(spec.Min) && value < spec.Min || !math.IsNaN(spec.Max) && value > spec.Max {
+		return diag(SeverityError, "%s must be %s, got %s", cmd.Key, rangeDescription(spec), cmd.Value)
+	}
+	return nil
+}
+
+func rangeDescription(spec ParamSpec) string {
+	format := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	switch {
+	case math.IsNaN(spec.Max):
+		return "at least " + format(spec.Min)
+	case math.IsNaN(spec.Min):
+		return "at most " + format(spec.Max)
+	}
+	return fmt.Sprintf("between %s and %s", format(spec.Min), format(spec.Max))
+}
+
+// templateError splits a text/template error into the line offset within the template and the message
+func templateError(err error) (int, string) {
+	msg := strings.TrimPrefix(err.Error(), "template: :")
+	lineStr, rest, ok := strings.Cut(msg, ":")
+	if line, convErr := strconv.Atoi(lineStr); ok && convErr == nil {
+		return line - 1, strings.TrimSpace(rest)
+	}
+	return 0, msg
+}
+
+// closestParam suggests a known parameter within two edits of name
+func closestParam(name string) string {
+	best, bestDistance := "", 3
+	for known := range Params {
+		if d := editDistance(name, known); d < bestDistance || d == bestDistance && known < best {
+			best, bestDistance = known, d
+		}
+	}
+	return best
+}
+
+// editDistance is the optimal string alignment distance, counting adjacent transpositions such as num_cxt as one edit
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+// HasErrors reports whether any of the diagnostics is an error
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package modelfile
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected []string
+	}{
+		{
+			name: "Clean modelfile",
+			src:  showModelfile,
+		},
+		{
+			name:     "Misspelt parameter",
+			src:      "FROM llama3.2\nPARAMETER num_cxt 8192\n",
+			expected: []string{"line 2: error: unknown parameter num_cxt, did you mean num_ctx?"},
+		},
+		{
+			name:     "Unknown parameter",
+			src:      "FROM llama3.2\nPARAMETER flash_attention true\n",
+			expected: []string{"line 2: error: unknown parameter flash_attention"},
+		},
+		{
+			name:     "String for a float",
+			src:      "FROM llama3.2\nPARAMETER temperature warm\n",
+			expected: []string{`line 2: error: temperature must be a number, got "warm"`},
+		},
+		{
+			name:     "Float for an integer",
+			src:      "FROM llama3.2\nPARAMETER num_ctx 8192.5\n",
+			expected: []string{`line 2: error: num_ctx must be an integer, got "8192.5"`},
+		},
+		{
+			name:     "Out of range",
+			src:      "FROM llama3.2\nPARAMETER top_p 1.5\nPARAMETER num_ctx 0\n",
+			expected: []string{"line 2: error: top_p must be between 0 and 1, got 1.5", "line 3: error: num_ctx must be at least 1, got 0"},
+		},
+		{
+			name:     "Invalid boolean",
+			src:      "FROM llama3.2\nPARAMETER use_mmap yes\n",
+			expected: []string{`line 2: error: use_mmap must be true or false, got "yes"`},
+		},
+		{
+			name:     "Deprecated and duplicate parameters",
+			src:      "FROM llama3.2\nPARAMETER mirostat 2\nPARAMETER seed 1\nPARAMETER seed 2\n",
+			expected: []string{"line 2: warning: mirostat is deprecated and ignored by Ollama", "line 4: warning: seed is already set on line 3, only the last value is used"},
+		},
+		{
+			name:     "Stop sequence not in template",
+			src:      "FROM llama3.2\nTEMPLATE \"\"\"<|user|>{{ .Prompt }}<|end|>\"\"\"\nPARAMETER stop <|end|>\nPARAMETER stop <|eot_id|>\n",
+			expected: []string{`line 4: warning: stop sequence "<|eot_id|>" does not appear in the TEMPLATE`},
+		},
+		{
+			name:     "Template syntax error",
+			src:      "FROM llama3.2\nTEMPLATE \"\"\"{{ if .System }}\n{{ .System }}\n\"\"\"\n",
+			expected: []string{"line 4: error: TEMPLATE is not a valid Go template: unexpected EOF"},
+		},
+		{
+			name: "Template with Ollama functions",
+			src:  "FROM llama3.2\nTEMPLATE \"\"\"{{ currentDate }}{{ range .Tools }}{{ json . }}{{ end }}\"\"\"\n",
+		},
+		{
+			name:     "Missing FROM",
+			src:      "PARAMETER temperature 0.2\n",
+			expected: []string{"error: missing FROM, a Modelfile must name a base model or weights file"},
+		},
+		{
+			name:     "Syntax error",
+			src:      "FROM llama3.2\nSYSTEM \"\"\"unterminated\n",
+			expected: []string{`line 2: error: unterminated """ quoted value`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, diag := range Lint(tt.src) {
+				got = append(got, diag.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(tt.expected, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestHasErrors(t *testing.T) {
+	warnings := []Diagnostic{{Severity: SeverityWarning, Message: "w"}}
+	if HasErrors(warnings) {
+		t.Error("HasErrors() should be false for warnings only")
+	}
+	if !HasErrors(append(warnings, Diagnostic{Severity: SeverityError, Message: "e"})) {
+		t.Error("HasErrors() should be true when there is an error")
+	}
+}
@@ -0,0 +1,343 @@
+package modelfile
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Instruction is the keyword that starts a Modelfile command
+type Instruction string
+
+const (
+	From      Instruction = "FROM"
+	Parameter Instruction = "PARAMETER"
+	Template  Instruction = "TEMPLATE"
+	System    Instruction = "SYSTEM"
+	Adapter   Instruction = "ADAPTER"
+	License   Instruction = "LICENSE"
+	Message   Instruction = "MESSAGE"
+	Renderer  Instruction = "RENDERER"
+	Parser    Instruction = "PARSER"
+
+	// Comment and Blank are not instructions, they keep comments and empty lines for round-tripping
+	Comment Instruction = "#"
+	Blank   Instruction = ""
+)
+
+// Quote is the quoting style used for a command's value
+type Quote int
+
+const (
+	QuoteNone Quote = iota
+	QuoteDouble
+	QuoteSingle
+	QuoteTriple
+	QuoteTripleSingle
+)
+
+// delimiter returns the opening and closing delimiter for the quote style
+func (q Quote) delimiter() string {
+	switch q {
+	case QuoteDouble:
+		return `"`
+	case QuoteSingle:
+		return `'`
+	case QuoteTriple:
+		return `"""`
+	case QuoteTripleSingle:
+		return `'''`
+	}
+	return ""
+}
+
+// Command is a single line (or multi-line quoted block) of a Modelfile
+type Command struct {
+	Instruction Instruction
+	// Key is the parameter name for PARAMETER and the role for MESSAGE
+	Key string
+	// Value is the unquoted argument, or the comment text for comments
+	Value string
+	// Quote is the quoting style the value was written with
+	Quote Quote
+	// Line is the line the command started on, zero for commands added programmatically
+	Line int
+}
+
+// Modelfile is the parsed form of an Ollama Modelfile, in source order
+type Modelfile struct {
+	Commands []Command
+}
+
+// MessageEntry is a MESSAGE command
+type MessageEntry struct {
+	Role    string
+	Content string
+}
+
+// ParameterEntry is a PARAMETER command
+type ParameterEntry struct {
+	Name  string
+	Value string
+}
+
+// From returns the model or path from the FROM command
+func (m *Modelfile) From() string {
+	value, _ := m.last(From)
+	return value
+}
+
+// Template returns the TEMPLATE value and whether one is set, the last TEMPLATE wins as in Ollama
+func (m *Modelfile) Template() (string, bool) {
+	return m.last(Template)
+}
+
+// System returns the SYSTEM value and whether one is set, the last SYSTEM wins as in Ollama
+func (m *Modelfile) System() (string, bool) {
+	return m.last(System)
+}
+
+// Adapters returns the values of all ADAPTER commands
+func (m *Modelfile) Adapters() []string {
+	return m.all(Adapter)
+}
+
+// Licenses returns the values of all LICENSE commands
+func (m *Modelfile) Licenses() []string {
+	return m.all(License)
+}
+
+// Messages returns all MESSAGE commands in order
+func (m *Modelfile) Messages() []MessageEntry {
+	var messages []MessageEntry
+	for _, cmd := range m.Commands {
+		if cmd.Instruction == Message {
+			messages = append(messages, MessageEntry{Role: cmd.Key, Content: cmd.Value})
+		}
+	}
+	return messages
+}
+
+// Parameters returns all PARAMETER commands in order
+func (m *Modelfile) Parameters() []ParameterEntry {
+	var params []ParameterEntry
+	for _, cmd := range m.Commands {
+		if cmd.Instruction == Parameter {
+			params = append(params, ParameterEntry{Name: cmd.Key, Value: cmd.Value})
+		}
+	}
+	return params
+}
+
+// ParameterValues returns every value given for a parameter, e.g. all stop sequences
+func (m *Modelfile) ParameterValues(name string) []string {
+	var values []string
+	for _, cmd := range m.Commands {
+		if cmd.Instruction == Parameter && cmd.Key == name {
+			values = append(values, cmd.Value)
+		}
+	}
+	return values
+}
+
+// ParameterMap returns the parameters keyed by name, repeated parameters such as stop keep every value
+func (m *Modelfile) ParameterMap() map[string][]string {
+	params := make(map[string][]string)
+	for _, cmd := range m.Commands {
+		if cmd.Instruction == Parameter {
+			params[cmd.Key] = append(params[cmd.Key], cmd.Value)
+		}
+	}
+	return params
+}
+
+// APIParameters returns the parameters typed for an api.CreateRequest.
+// Numbers and booleans are converted, stop is always a list and other repeated parameters become lists.
+func (m *Modelfile) APIParameters() map[string]any {
+	params := make(map[string]any)
+	for name, values := range m.ParameterMap() {
+		if name == "stop" || len(values) > 1 {
+			params[name] = values
+			continue
+		}
+		params[name] = typedValue(values[0])
+	}
+	return params
+}
+
+func typedValue(value string) any {
+	if intVal, err := strconv.Atoi(value); err == nil {
+		return intVal
+	}
+	if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+		return floatVal
+	}
+	if boolVal, err := strconv.ParseBool(value); err == nil && (value == "true" || value == "false") {
+		return boolVal
+	}
+	return value
+}
+
+// SetFrom replaces the FROM command, adding one at the top if there isn't one
+func (m *Modelfile) SetFrom(value string) {
+	if i := m.lastIndex(From); i >= 0 {
+		m.Commands[i].Value = value
+		return
+	}
+	m.Commands = append([]Command{{Instruction: From, Value: value}}, m.Commands...)
+}
+
+// SetTemplate replaces the TEMPLATE value, adding a TEMPLATE command if there isn't one
+func (m *Modelfile) SetTemplate(value string) {
+	m.set(Template, value)
+}
+
+// SetSystem replaces the SYSTEM value, adding a SYSTEM command if there isn't one
+func (m *Modelfile) SetSystem(value string) {
+	m.set(System, value)
+}
+
+// SetParameter replaces all values of a parameter, keeping its position in the file
+func (m *Modelfile) SetParameter(name string, values ...string) {
+	insertAt := -1
+	var commands []Command
+	for _, cmd := range m.Commands {
+		if cmd.Instruction == Parameter && cmd.Key == name {
+			if insertAt < 0 {
+				insertAt = len(commands)
+			}
+			continue
+		}
+		commands = append(commands, cmd)
+	}
+	if insertAt < 0 {
+		insertAt = len(commands)
+	}
+
+	added := make([]Command, len(values))
+	for i, value := range values {
+		added[i] = Command{Instruction: Parameter, Key: name, Value: value}
+	}
+	m.Commands = append(commands[:insertAt], append(added, commands[insertAt:]...)...)
+}
+
+// RemoveParameter removes every value of a parameter
+func (m *Modelfile) RemoveParameter(name string) {
+	m.SetParameter(name)
+}
+
+// AddMessage appends a MESSAGE command
+func (m *Modelfile) AddMessage(role, content string) {
+	m.Commands = append(m.Commands, Command{Instruction: Message, Key: role, Value: content})
+}
+
+func (m *Modelfile) last(instruction Instruction) (string, bool) {
+	if i := m.lastIndex(instruction); i >= 0 {
+		return m.Commands[i].Value, true
+	}
+	return "", false
+}
+
+func (m *Modelfile) lastIndex(instruction Instruction) int {
+	for i := len(m.Commands) - 1; i >= 0; i-- {
+		if m.Commands[i].Instruction == instruction {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *Modelfile) all(instruction Instruction) []string {
+	var values []string
+	for _, cmd := range m.Commands {
+		if cmd.Instruction == instruction {
+			values = append(values, cmd.Value)
+		}
+	}
+	return values
+}
+
+func (m *Modelfile) set(instruction Instruction, value string) {
+	if i := m.lastIndex(instruction); i >= 0 {
+		m.Commands[i].Value = value
+		return
+	}
+	m.Commands = append(m.Commands, Command{Instruction: instruction, Value: value})
+}
+
+// String serialises the Modelfile back to text.
+// Comments, blank lines, ordering and quoting are preserved where the value still allows it.
+func (m *Modelfile) String() string {
+	var sb strings.Builder
+	for _, cmd := range m.Commands {
+		sb.WriteString(cmd.String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// String serialises a single command
+func (c Command) String() string {
+	switch c.Instruction {
+	case Blank:
+		return ""
+	case Comment:
+		return "#" + c.Value
+	case Parameter, Message:
+		return string(c.Instruction) + " " + c.Key + " " + quote(c.Value, c.quoteStyle())
+	}
+	return string(c.Instruction) + " " + quote(c.Value, c.quoteStyle())
+}
+
+// quoteStyle returns the quoting to use when serialising, keeping the original style if it can still hold the value
+func (c Command) quoteStyle() Quote {
+	if c.Line > 0 || c.Quote != QuoteNone {
+		if canQuote(c.Value, c.Quote) {
+			return c.Quote
+		}
+	}
+
+	switch c.Instruction {
+	case Template, System, License:
+		// Ollama writes these as triple-quoted blocks
+		if canQuote(c.Value, QuoteTriple) {
+			return QuoteTriple
+		}
+	default:
+		if canQuote(c.Value, QuoteNone) && !strings.ContainsAny(c.Value, " \t") {
+			return QuoteNone
+		}
+		if !strings.Contains(c.Value, "\n") && canQuote(c.Value, QuoteDouble) {
+			return QuoteDouble
+		}
+	}
+
+	for _, q := range []Quote{QuoteTriple, QuoteTripleSingle, QuoteDouble, QuoteSingle} {
+		if canQuote(c.Value, q) {
+			return q
+		}
+	}
+	return QuoteTriple
+}
+
+// canQuote reports whether value survives being written with the quote style and parsed again
+func canQuote(value string, q Quote) bool {
+	switch q {
+	case QuoteNone:
+		return value != "" &&
+			value == strings.TrimSpace(value) &&
+			!strings.ContainsAny(value, "\r\n") &&
+			!strings.HasPrefix(value, `"`) &&
+			!strings.HasPrefix(value, `'`)
+	case QuoteDouble, QuoteSingle:
+		return !strings.Contains(value, q.delimiter())
+	case QuoteTriple, QuoteTripleSingle:
+		delim := q.delimiter()
+		return !strings.Contains(value, delim) && !strings.HasSuffix(value, delim[:1])
+	}
+	return false
+}
+
+func quote(value string, q Quote) string {
+	delim := q.delimiter()
+	return delim + value + delim
+}
@@ -0,0 +1,235 @@
+package modelfile
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// showModelfile is the shape of a Modelfile returned by `ollama show --modelfile`
+const showModelfile = `# Modelfile generated by "ollama show"
+# To build a new Modelfile based on this, replace FROM with:
+# FROM qwen2.5:7b
+
+FROM /root/.ollama/models/blobs/sha256-2bada8a7450677000f678be90653b85d364de7db25eb5ea54136ada5f3933730
+TEMPLATE """{{- if .System }}<|im_start|>system
+{{ .System }}<|im_end|>
+{{ end }}{{- range $i, $_ := .Messages }}<|im_start|>{{ .Role }}
+{{ .Content }}<|im_end|>
+{{ end }}<|im_start|>assistant
+"""
+SYSTEM You are Qwen, created by Alibaba Cloud. You are a helpful assistant.
+PARAMETER stop <|im_start|>
+PARAMETER stop <|im_end|>
+PARAMETER temperature 0.7
+PARAMETER num_ctx 8192
+LICENSE """Apache License
+Version 2.0, January 2004"""
+`
+
+func TestParseShowModelfile(t *testing.T) {
+	m, err := Parse(showModelfile)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if !strings.HasPrefix(m.From(), "/root/.ollama/models/blobs/sha256-") {
+		t.Errorf("unexpected FROM %q", m.From())
+	}
+
+	template, ok := m.Template()
+	if !ok || !strings.HasPrefix(template, "{{- if .System }}<|im_start|>system\n") || !strings.HasSuffix(template, "<|im_start|>assistant\n") {
+		t.Errorf("unexpected TEMPLATE %q", template)
+	}
+
+	system, _ := m.System()
+	if system != "You are Qwen, created by Alibaba Cloud. You are a helpful assistant." {
+		t.Errorf("unexpected SYSTEM %q", system)
+	}
+
+	if stops := m.ParameterValues("stop"); !reflect.DeepEqual(stops, []string{"<|im_start|>", "<|im_end|>"}) {
+		t.Errorf("unexpected stop values %v", stops)
+	}
+
+	if licenses := m.Licenses(); len(licenses) != 1 || licenses[0] != "Apache License\nVersion 2.0, January 2004" {
+		t.Errorf("unexpected LICENSE %q", licenses)
+	}
+}
+
+func TestParseQuoting(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected Command
+	}{
+		{
+			name:     "Bare value",
+			src:      "SYSTEM You are a pirate.",
+			expected: Command{Instruction: System, Value: "You are a pirate.", Quote: QuoteNone},
+		},
+		{
+			name:     "Double quoted",
+			src:      `SYSTEM "You are a pirate."`,
+			expected: Command{Instruction: System, Value: "You are a pirate.", Quote: QuoteDouble},
+		},
+		{
+			name:     "Single quoted",
+			src:      `SYSTEM 'Say "arr"'`,
+			expected: Command{Instruction: System, Value: `Say "arr"`, Quote: QuoteSingle},
+		},
+		{
+			name:     "Double quoted across lines",
+			src:      "SYSTEM \"line one\nline two\"",
+			expected: Command{Instruction: System, Value: "line one\nline two", Quote: QuoteDouble},
+		},
+		{
+			name:     "Triple quoted",
+			src:      "TEMPLATE \"\"\"{{ .Prompt }}\n\"\"\"",
+			expected: Command{Instruction: Template, Value: "{{ .Prompt }}\n", Quote: QuoteTriple},
+		},
+		{
+			name:     "Triple single quoted",
+			src:      "TEMPLATE '''{{ \"\"\" }}'''",
+			expected: Command{Instruction: Template, Value: `{{ """ }}`, Quote: QuoteTripleSingle},
+		},
+		{
+			name:     "Quoted parameter",
+			src:      `PARAMETER stop "User:"`,
+			expected: Command{Instruction: Parameter, Key: "stop", Value: "User:", Quote: QuoteDouble},
+		},
+		{
+			name:     "Parameter with spaces",
+			src:      `parameter stop "### Instruction:"`,
+			expected: Command{Instruction: Parameter, Key: "stop", Value: "### Instruction:", Quote: QuoteDouble},
+		},
+		{
+			name:     "Message",
+			src:      `MESSAGE user """Is Toronto in Canada?"""`,
+			expected: Command{Instruction: Message, Key: "user", Value: "Is Toronto in Canada?", Quote: QuoteTriple},
+		},
+		{
+			name:     "Adapter",
+			src:      "ADAPTER ./lora.gguf",
+			expected: Command{Instruction: Adapter, Value: "./lora.gguf"},
+		},
+		{
+			name:     "Windows line ending",
+			src:      "FROM llama3.2\r\n",
+			expected: Command{Instruction: From, Value: "llama3.2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if len(m.Commands) != 1 {
+				t.Fatalf("expected 1 command, got %d: %+v", len(m.Commands), m.Commands)
+			}
+			got := m.Commands[0]
+			got.Line = 0
+			if got != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		line int
+	}{
+		{name: "Unknown instruction", src: "FROM llama3\nFOO bar", line: 2},
+		{name: "Missing value", src: "SYSTEM", line: 1},
+		{name: "Parameter without value", src: "PARAMETER temperature", line: 1},
+		{name: "Unterminated triple quote", src: "FROM x\n\nTEMPLATE \"\"\"{{ .Prompt }}", line: 3},
+		{name: "Text after quoted value", src: `SYSTEM "hi" there`, line: 1},
+		{name: "Invalid message role", src: "MESSAGE robot hello", line: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.src)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("expected a ParseError, got %v", err)
+			}
+			if parseErr.Line != tt.line {
+				t.Errorf("expected error on line %d, got %d (%v)", tt.line, parseErr.Line, err)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	sources := []string{
+		showModelfile,
+		"FROM llama3.2\nPARAMETER stop \"User:\"\nPARAMETER stop 'Assistant:'\nMESSAGE user Hi\nMESSAGE assistant \"\"\"Hello\nthere\"\"\"\n",
+		"# comment\n\n\nFROM ./model.gguf\nADAPTER ./adapter.gguf\n",
+	}
+
+	for _, src := range sources {
+		m, err := Parse(src)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		if got := m.String(); got != src {
+			t.Errorf("round trip mismatch\nexpected:\n%s\ngot:\n%s", src, got)
+		}
+	}
+}
+
+func TestSerialiseModifiedValues(t *testing.T) {
+	m, err := Parse("FROM llama3.2\nSYSTEM \"Be brief.\"\nPARAMETER stop <|eot_id|>\nPARAMETER temperature 0.7\n")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	m.SetSystem("Say \"hello\"\nthen stop.")
+	m.SetTemplate("{{ .Prompt }}")
+	m.SetParameter("stop", "<|eot_id|>", "<|end of turn|>")
+	m.SetParameter("num_ctx", "4096")
+
+	expected := `FROM llama3.2
+SYSTEM """Say "hello"
+then stop."""
+PARAMETER stop <|eot_id|>
+PARAMETER stop "<|end of turn|>"
+PARAMETER temperature 0.7
+TEMPLATE """{{ .Prompt }}"""
+PARAMETER num_ctx 4096
+`
+	if got := m.String(); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	reparsed, err := Parse(m.String())
+	if err != nil {
+		t.Fatalf("re-Parse() error = %v", err)
+	}
+	if system, _ := reparsed.System(); system != "Say \"hello\"\nthen stop." {
+		t.Errorf("SYSTEM did not survive serialisation: %q", system)
+	}
+}
+
+func TestAPIParameters(t *testing.T) {
+	m, err := Parse("PARAMETER temperature 0.7\nPARAMETER num_ctx 8192\nPARAMETER use_mmap false\nPARAMETER stop <|im_end|>\n")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	expected := map[string]any{
+		"temperature": 0.7,
+		"num_ctx":     8192,
+		"use_mmap":    false,
+		"stop":        []string{"<|im_end|>"},
+	}
+	if got := m.APIParameters(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,128 @@
+package modelfile
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseError reports a syntax error in a Modelfile
+type ParseError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+var instructions = map[string]Instruction{
+	"FROM":      From,
+	"PARAMETER": Parameter,
+	"TEMPLATE":  Template,
+	"SYSTEM":    System,
+	"ADAPTER":   Adapter,
+	"LICENSE":   License,
+	"MESSAGE":   Message,
+	"RENDERER":  Renderer,
+	"PARSER":    Parser,
+}
+
+// MessageRoles are the roles accepted by MESSAGE
+var MessageRoles = []string{"system", "user", "assistant"}
+
+// Parse parses Modelfile source. Instructions are case-insensitive and values may be bare,
+// single-quoted, double-quoted or triple-quoted with either quote character, quoted values may span lines.
+func Parse(src string) (*Modelfile, error) {
+	l := newLexer(src)
+	m := &Modelfile{}
+	// emptyLine tracks whether the current line has had any tokens, to record blank lines
+	emptyLine := true
+
+	for {
+		tok := l.next()
+		switch tok.typ {
+		case tokenEOF:
+			return m, nil
+		case tokenNewline:
+			if emptyLine {
+				m.Commands = append(m.Commands, Command{Instruction: Blank, Line: tok.line})
+			}
+			emptyLine = true
+			continue
+		case tokenComment:
+			m.Commands = append(m.Commands, Command{Instruction: Comment, Value: tok.val, Line: tok.line})
+		case tokenWord:
+			cmd, err := parseCommand(l, tok)
+			if err != nil {
+				return nil, err
+			}
+			m.Commands = append(m.Commands, cmd)
+		}
+
+		// Every command must end the line
+		emptyLine = false
+		if end := l.next(); end.typ != tokenNewline && end.typ != tokenEOF {
+			return nil, l.errorf(end.line, end.col, "unexpected %q at end of line", end.val)
+		} else if end.typ == tokenEOF {
+			return m, nil
+		}
+		emptyLine = true
+	}
+}
+
+// ParseReader reads and parses a Modelfile
+func ParseReader(r io.Reader) (*Modelfile, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(string(src))
+}
+
+func parseCommand(l *lexer, keyword token) (Command, error) {
+	instruction, ok := instructions[strings.ToUpper(keyword.val)]
+	if !ok {
+		return Command{}, l.errorf(keyword.line, keyword.col, "unknown instruction %q", keyword.val)
+	}
+	cmd := Command{Instruction: instruction, Line: keyword.line}
+
+	switch instruction {
+	case Parameter:
+		name := l.next()
+		if name.typ != tokenWord {
+			return Command{}, l.errorf(keyword.line, keyword.col, "PARAMETER requires a name and a value")
+		}
+		cmd.Key = strings.ToLower(name.val)
+	case Message:
+		role := l.next()
+		if role.typ != tokenWord {
+			return Command{}, l.errorf(keyword.line, keyword.col, "MESSAGE requires a role and a message")
+		}
+		cmd.Key = strings.ToLower(role.val)
+		if !isMessageRole(cmd.Key) {
+			return Command{}, l.errorf(role.line, role.col, "message role must be one of %s, got %q", strings.Join(MessageRoles, ", "), role.val)
+		}
+	}
+
+	value, err := l.value()
+	if err != nil {
+		return Command{}, err
+	}
+	if value.typ != tokenString && value.typ != tokenText {
+		return Command{}, l.errorf(keyword.line, keyword.col, "%s requires a value", instruction)
+	}
+	cmd.Value = value.val
+	cmd.Quote = value.quote
+	return cmd, nil
+}
+
+func isMessageRole(role string) bool {
+	for _, r := range MessageRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,306 @@
+package ollamastore
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdMagic starts every zstd frame, it's used to detect compressed archives on import
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// WriteArchive writes the models' manifests followed by every blob they reference to w as a tar archive,
+// optionally zstd compressed. The archive is laid out like the models directory, so it can also be extracted
+// into one with tar. Symlinked blobs are written as regular files. progress, if not nil, is called with the
+// blob bytes written.
+func (s *Store) WriteArchive(w io.Writer, models []Model, compress bool, progress func(written int64)) (err error) {
+	if compress {
+		encoder, err := zstd.NewWriter(w)
+		if err != nil {
+			return fmt.Errorf("error creating zstd writer: %v", err)
+		}
+		defer func() {
+			if closeErr := encoder.Close(); err == nil && closeErr != nil {
+				err = fmt.Errorf("error finishing zstd stream: %v", closeErr)
+			}
+		}()
+		w = encoder
+	}
+
+	tw := tar.NewWriter(w)
+	for _, model := range models {
+		data, err := os.ReadFile(model.Path)
+		if err != nil {
+			return fmt.Errorf("error reading manifest for %s: %v", model.Name, err)
+		}
+		host, namespace, name, tag := ParseName(model.Name)
+		header := &tar.Header{
+			Name:    path.Join("manifests", host, namespace, name, tag),
+			Mode:    0o644,
+			Size:    int64(len(data)),
+			ModTime: time.Now(),
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+
+	for _, blob := range BlobsToVerify(models) {
+		if err := s.writeArchiveBlob(tw, blob.Digest, progress); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func (s *Store) writeArchiveBlob(tw *tar.Writer, digest string, progress func(int64)) error {
+	// Open follows symlinks, so LM Studio imports are exported as the files they point to
+	file, err := os.Open(s.BlobPath(digest))
+	if err != nil {
+		return fmt.Errorf("error opening blob %s: %v", digest, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("error reading blob %s: %v", digest, err)
+	}
+
+	header := &tar.Header{
+		Name:    "blobs/" + filepath.Base(s.BlobPath(digest)),
+		Mode:    0o644,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	var r io.Reader = file
+	if progress != nil {
+		r = &progressReader{r: file, progress: progress}
+	}
+	if _, err := io.Copy(tw, r); err != nil {
+		return fmt.Errorf("error writing blob %s: %v", digest, err)
+	}
+	return nil
+}
+
+// ArchiveHandler receives the entries of an archive as it's read
+type ArchiveHandler struct {
+	// Manifest is called with each model's name and manifest file
+	Manifest func(name string, data []byte) error
+	// Blob is called with each blob's content. Reading it to the end returns an error instead of io.EOF if the
+	// content doesn't match the digest, so nothing read from it should be kept unless it's read without error.
+	Blob func(digest string, size int64, r io.Reader) error
+}
+
+// ReadArchive reads an archive written by WriteArchive, decompressing it if needed. Entries other than
+// manifests and blobs, or with unsafe paths, are rejected.
+func ReadArchive(r io.Reader, handler ArchiveHandler) error {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(len(zstdMagic)); err == nil && bytes.Equal(magic, zstdMagic) {
+		decoder, err := zstd.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("error creating zstd reader: %v", err)
+		}
+		defer decoder.Close()
+		r = decoder
+	} else {
+		r = br
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading archive: %v", err)
+		}
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+		if header.Typeflag != tar.TypeReg {
+			return fmt.Errorf("unexpected archive entry %s, only regular files are allowed", header.Name)
+		}
+
+		parts := strings.Split(path.Clean(header.Name), "/")
+		switch {
+		case len(parts) == 5 && parts[0] == "manifests" && safePathParts(parts[1:]):
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return fmt.Errorf("error reading %s: %v", header.Name, err)
+			}
+			if err := handler.Manifest(ShortName(parts[1], parts[2], parts[3], parts[4]), data); err != nil {
+				return err
+			}
+		case len(parts) == 2 && parts[0] == "blobs" && blobPattern.MatchString(parts[1]):
+			digest := digestFromBlobName(parts[1])
+			verifier := newDigestReader(tr, digest)
+			if err := handler.Blob(digest, header.Size, verifier); err != nil {
+				return err
+			}
+			// Make sure the whole blob was checked even if the handler didn't need to read it
+			if _, err := io.Copy(io.Discard, verifier); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected archive entry %s", header.Name)
+		}
+	}
+}
+
+func safePathParts(parts []string) bool {
+	for _, part := range parts {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			return false
+		}
+	}
+	return true
+}
+
+// digestReader hashes what's read through it and fails at the end if the content doesn't match the digest
+type digestReader struct {
+	r      io.Reader
+	hash   hash.Hash
+	digest string
+}
+
+func newDigestReader(r io.Reader, digest string) *digestReader {
+	return &digestReader{r: r, hash: sha256.New(), digest: digest}
+}
+
+func (d *digestReader) Read(b []byte) (int, error) {
+	n, err := d.r.Read(b)
+	d.hash.Write(b[:n])
+	if errors.Is(err, io.EOF) {
+		if actual := "sha256:" + hex.EncodeToString(d.hash.Sum(nil)); actual != d.digest {
+			return n, fmt.Errorf("blob %s is corrupt, its content hashes to %s", d.digest, actual)
+		}
+	}
+	return n, err
+}
+
+// ImportArchive restores the models in an archive into the store and returns their names. Each blob is
+// verified against its digest before it's moved into place, blobs already in the store are kept, and the
+// manifests are only written once every blob they reference is present.
+func (s *Store) ImportArchive(r io.Reader, progress func(read int64)) ([]string, error) {
+	if err := os.MkdirAll(s.BlobsDir(), 0o755); err != nil {
+		return nil, fmt.Errorf("error creating blobs directory: %v", err)
+	}
+
+	manifests := make(map[string][]byte)
+	var names []string
+	err := ReadArchive(r, ArchiveHandler{
+		Manifest: func(name string, data []byte) error {
+			if _, err := ParseManifest(data); err != nil {
+				return fmt.Errorf("error parsing manifest for %s: %v", name, err)
+			}
+			if _, ok := manifests[name]; !ok {
+				names = append(names, name)
+			}
+			manifests[name] = data
+			return nil
+		},
+		Blob: func(digest string, size int64, blob io.Reader) error {
+			if progress != nil {
+				blob = &progressReader{r: blob, progress: progress}
+			}
+			// Stat follows symlinks, so a blob linked from LM Studio is kept if the file it points to is intact. One of
+			// the same size could still be corrupt, so it's only kept if it hashes to its digest.
+			path := s.BlobPath(digest)
+			if info, err := os.Stat(path); err == nil && info.Size() == size {
+				if actual, err := HashFile(path, nil); err == nil && "sha256:"+actual == digest {
+					_, err := io.Copy(io.Discard, blob)
+					return err
+				}
+			}
+			return s.writeBlob(digest, blob)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("archive doesn't contain any models")
+	}
+
+	for _, name := range names {
+		manifest, err := ParseManifest(manifests[name])
+		if err != nil {
+			return nil, fmt.Errorf("error parsing manifest for %s: %v", name, err)
+		}
+		for _, blob := range manifest.Blobs() {
+			if _, err := os.Stat(s.BlobPath(blob.Digest)); err != nil {
+				return nil, fmt.Errorf("%s references blob %s which isn't in the archive or the store", name, blob.Digest)
+			}
+		}
+	}
+	for _, name := range names {
+		host, namespace, model, tag := ParseName(name)
+		if err := writeFileAtomic(filepath.Join(s.ManifestsDir(), host, namespace, model, tag), manifests[name]); err != nil {
+			return nil, fmt.Errorf("error writing manifest for %s: %v", name, err)
+		}
+	}
+	return names, nil
+}
+
+// writeBlob writes a blob to a temporary file and only moves it into place once it has been read in full,
+// which is when a digestReader reports a mismatch
+func (s *Store) writeBlob(digest string, r io.Reader) error {
+	tmp, err := os.CreateTemp(s.BlobsDir(), ".gollama-import-*")
+	if err != nil {
+		return fmt.Errorf("error creating blob %s: %v", digest, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing blob %s: %v", digest, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing blob %s: %v", digest, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.BlobPath(digest))
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".gollama-import-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
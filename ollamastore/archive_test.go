@@ -0,0 +1,148 @@
+package ollamastore
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		name := "tar"
+		if compress {
+			name = "zstd"
+		}
+		t.Run(name, func(t *testing.T) {
+			src := New(t.TempDir())
+			config := writeBlob(t, src, "config")
+			weights := writeBlob(t, src, strings.Repeat("weights", 1000))
+			writeManifest(t, src, "base:latest", config, weights)
+			writeManifest(t, src, "hf.co/user/repo:Q4_K_M", config, weights)
+			models, err := src.Models()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var archive bytes.Buffer
+			var written int64
+			if err := src.WriteArchive(&archive, models, compress, func(n int64) { written += n }); err != nil {
+				t.Fatalf("WriteArchive() error: %v", err)
+			}
+			if written != int64(len("config")+len(strings.Repeat("weights", 1000))) {
+				t.Errorf("shared blobs should be written once, wrote %d bytes", written)
+			}
+
+			dst := New(t.TempDir())
+			names, err := dst.ImportArchive(&archive, nil)
+			if err != nil {
+				t.Fatalf("ImportArchive() error: %v", err)
+			}
+			if strings.Join(names, ",") != "base:latest,hf.co/user/repo:Q4_K_M" {
+				t.Errorf("unexpected imported models: %v", names)
+			}
+
+			imported, err := dst.Models()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(imported) != 2 {
+				t.Fatalf("expected 2 imported models, got %d", len(imported))
+			}
+			for i := range models {
+				if imported[i].Name != models[i].Name || imported[i].Digest != models[i].Digest {
+					t.Errorf("expected %s (%s), got %s (%s)", models[i].Name, models[i].Digest, imported[i].Name, imported[i].Digest)
+				}
+			}
+			for _, result := range dst.VerifyBlobs(BlobsToVerify(imported), 1, nil) {
+				if result.Status != BlobOK {
+					t.Errorf("%s: %s", result.Digest, result)
+				}
+			}
+		})
+	}
+}
+
+func TestImportArchiveCorruptBlob(t *testing.T) {
+	src := New(t.TempDir())
+	config := writeBlob(t, src, "config")
+	weights := writeBlob(t, src, "weights")
+	writeManifest(t, src, "model:latest", config, weights)
+	models, err := src.Models()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	if err := src.WriteArchive(&archive, models, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	corrupted := bytes.Replace(archive.Bytes(), []byte("weights"), []byte("WEIGHTS"), 1)
+
+	dst := New(t.TempDir())
+	if _, err := dst.ImportArchive(bytes.NewReader(corrupted), nil); err == nil || !strings.Contains(err.Error(), "corrupt") {
+		t.Fatalf("expected a corrupt blob error, got %v", err)
+	}
+	if _, err := os.Stat(dst.BlobPath(weights)); !os.IsNotExist(err) {
+		t.Error("corrupt blob should not have been kept")
+	}
+	if _, err := os.Stat(dst.ManifestsDir()); !os.IsNotExist(err) {
+		t.Error("no manifests should be written when an import fails")
+	}
+}
+
+func TestImportArchiveReplacesCorruptStoreBlob(t *testing.T) {
+	src := New(t.TempDir())
+	config := writeBlob(t, src, "config")
+	weights := writeBlob(t, src, "weights")
+	writeManifest(t, src, "model:latest", config, weights)
+	models, err := src.Models()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archive bytes.Buffer
+	if err := src.WriteArchive(&archive, models, false, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// A blob of the right size with the wrong content isn't trusted
+	dst := New(t.TempDir())
+	if err := os.MkdirAll(dst.BlobsDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst.BlobPath(weights), []byte("WEIGHTS"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dst.ImportArchive(&archive, nil); err != nil {
+		t.Fatalf("ImportArchive() error: %v", err)
+	}
+	if data, err := os.ReadFile(dst.BlobPath(weights)); err != nil || string(data) != "weights" {
+		t.Errorf("expected the corrupt blob to be replaced, got %q, %v", data, err)
+	}
+}
+
+func TestReadArchiveRejectsUnsafePaths(t *testing.T) {
+	for _, name := range []string{"manifests/../../../etc/passwd/x", "../blobs/sha256-x", "other/file"} {
+		var archive bytes.Buffer
+		tw := tar.NewWriter(&archive)
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte("x")); err != nil {
+			t.Fatal(err)
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		err := ReadArchive(&archive, ArchiveHandler{
+			Manifest: func(string, []byte) error { t.Errorf("%s was accepted as a manifest", name); return nil },
+			Blob:     func(string, int64, io.Reader) error { t.Errorf("%s was accepted as a blob", name); return nil },
+		})
+		if err == nil {
+			t.Errorf("expected %s to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package ollamastore
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ollama/ollama/api"
+)
+
+// Layer media types used in Ollama manifests
+const (
+	MediaTypeModel     = "application/vnd.ollama.image.model"
+	MediaTypeProjector = "application/vnd.ollama.image.projector"
+	MediaTypeAdapter   = "application/vnd.ollama.image.adapter"
+	MediaTypeParams    = "application/vnd.ollama.image.params"
+	MediaTypeTemplate  = "application/vnd.ollama.image.template"
+	MediaTypeSystem    = "application/vnd.ollama.image.system"
+	MediaTypeLicense   = "application/vnd.ollama.image.license"
+	MediaTypeMessages  = "application/vnd.ollama.image.messages"
+)
+
+// CreateRequest builds the /api/create request that recreates a model from its manifest on a server that
+// already has its weight blobs, e.g. after uploading them to /api/blobs. readBlob is only called for the
+// small text and JSON layers, and the config blob.
+func CreateRequest(name string, manifest Manifest, readBlob func(digest string) ([]byte, error)) (*api.CreateRequest, error) {
+	req := &api.CreateRequest{Model: name, Files: make(map[string]string)}
+	var licenses []string
+
+	for _, layer := range manifest.Layers {
+		switch layer.MediaType {
+		case MediaTypeModel, MediaTypeProjector:
+			// The server works out which file is the projector from its metadata, the names only need the .gguf suffix
+			req.Files[blobFileName(layer.Digest)] = layer.Digest
+			continue
+		case MediaTypeAdapter:
+			if req.Adapters == nil {
+				req.Adapters = make(map[string]string)
+			}
+			req.Adapters[blobFileName(layer.Digest)] = layer.Digest
+			continue
+		}
+
+		data, err := readBlob(layer.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s layer %s: %v", layer.MediaType, layer.Digest, err)
+		}
+		switch layer.MediaType {
+		case MediaTypeTemplate:
+			req.Template = string(data)
+		case MediaTypeSystem:
+			req.System = string(data)
+		case MediaTypeLicense:
+			licenses = append(licenses, string(data))
+		case MediaTypeParams:
+			if err := json.Unmarshal(data, &req.Parameters); err != nil {
+				return nil, fmt.Errorf("error parsing parameters layer %s: %v", layer.Digest, err)
+			}
+		case MediaTypeMessages:
+			if err := json.Unmarshal(data, &req.Messages); err != nil {
+				return nil, fmt.Errorf("error parsing messages layer %s: %v", layer.Digest, err)
+			}
+		default:
+			return nil, fmt.Errorf("%s has a %s layer, which can't be recreated through the API", name, layer.MediaType)
+		}
+	}
+	if len(req.Files) == 0 {
+		return nil, fmt.Errorf("%s has no model weights in its manifest", name)
+	}
+	if len(licenses) > 0 {
+		req.License = licenses
+	}
+
+	// The renderer and parser are only stored in the config blob
+	if manifest.Config.Digest != "" {
+		data, err := readBlob(manifest.Config.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("error reading config %s: %v", manifest.Config.Digest, err)
+		}
+		var config struct {
+			Renderer string `json:"renderer"`
+			Parser   string `json:"parser"`
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("error parsing config %s: %v", manifest.Config.Digest, err)
+		}
+		req.Renderer, req.Parser = config.Renderer, config.Parser
+	}
+	return req, nil
+}
+
+// blobFileName names a blob for the files map in a create request
+func blobFileName(digest string) string {
+	return strings.Replace(digest, ":", "-", 1) + ".gguf"
+}
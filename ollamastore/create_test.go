@@ -0,0 +1,74 @@
+package ollamastore
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCreateRequest(t *testing.T) {
+	blobs := map[string]string{
+		"sha256:config":   `{"model_format":"gguf","renderer":"qwen3-coder","parser":"qwen3-coder"}`,
+		"sha256:template": "{{ .Prompt }}",
+		"sha256:system":   "You are helpful.",
+		"sha256:params":   `{"num_ctx":8192,"stop":["<|im_end|>"]}`,
+		"sha256:license":  "MIT",
+		"sha256:messages": `[{"role":"user","content":"hi"}]`,
+	}
+	readBlob := func(digest string) ([]byte, error) {
+		content, ok := blobs[digest]
+		if !ok {
+			return nil, fmt.Errorf("unexpected read of %s", digest)
+		}
+		return []byte(content), nil
+	}
+	manifest := Manifest{
+		Config: Layer{Digest: "sha256:config"},
+		Layers: []Layer{
+			{MediaType: MediaTypeModel, Digest: "sha256:weights"},
+			{MediaType: MediaTypeProjector, Digest: "sha256:projector"},
+			{MediaType: MediaTypeAdapter, Digest: "sha256:adapter"},
+			{MediaType: MediaTypeTemplate, Digest: "sha256:template"},
+			{MediaType: MediaTypeSystem, Digest: "sha256:system"},
+			{MediaType: MediaTypeParams, Digest: "sha256:params"},
+			{MediaType: MediaTypeLicense, Digest: "sha256:license"},
+			{MediaType: MediaTypeMessages, Digest: "sha256:messages"},
+		},
+	}
+
+	req, err := CreateRequest("model:latest", manifest, readBlob)
+	if err != nil {
+		t.Fatalf("CreateRequest() error: %v", err)
+	}
+	if req.Model != "model:latest" || req.Files["sha256-weights.gguf"] != "sha256:weights" || req.Files["sha256-projector.gguf"] != "sha256:projector" {
+		t.Errorf("unexpected model or files: %s %v", req.Model, req.Files)
+	}
+	if req.Adapters["sha256-adapter.gguf"] != "sha256:adapter" {
+		t.Errorf("unexpected adapters: %v", req.Adapters)
+	}
+	if req.Template != blobs["sha256:template"] || req.System != blobs["sha256:system"] {
+		t.Errorf("unexpected template or system: %q %q", req.Template, req.System)
+	}
+	if req.Parameters["num_ctx"] != float64(8192) || fmt.Sprint(req.Parameters["stop"]) != "[<|im_end|>]" {
+		t.Errorf("unexpected parameters: %v", req.Parameters)
+	}
+	if fmt.Sprint(req.License) != "[MIT]" || len(req.Messages) != 1 || req.Messages[0].Content != "hi" {
+		t.Errorf("unexpected license or messages: %v %v", req.License, req.Messages)
+	}
+	if req.Renderer != "qwen3-coder" || req.Parser != "qwen3-coder" {
+		t.Errorf("unexpected renderer or parser: %q %q", req.Renderer, req.Parser)
+	}
+}
+
+func TestCreateRequestUnsupportedLayer(t *testing.T) {
+	manifest := Manifest{Layers: []Layer{
+		{MediaType: MediaTypeModel, Digest: "sha256:weights"},
+		{MediaType: "application/vnd.ollama.image.embed", Digest: "sha256:embed"},
+	}}
+	readBlob := func(string) ([]byte, error) { return []byte("{}"), nil }
+	if _, err := CreateRequest("model:latest", manifest, readBlob); err == nil {
+		t.Error("expected an error for a layer that can't be recreated")
+	}
+	if _, err := CreateRequest("model:latest", Manifest{}, readBlob); err == nil {
+		t.Error("expected an error for a manifest without weights")
+	}
+}
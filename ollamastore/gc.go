@@ -0,0 +1,160 @@
+package ollamastore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// GarbageKind is why a file in the blobs directory can be removed
+type GarbageKind int
+
+const (
+	// Unreferenced is a blob no manifest references
+	Unreferenced GarbageKind = iota
+	// Partial is a stale download left by an interrupted pull
+	Partial
+	// DanglingSymlink is an unreferenced blob symlink whose target no longer exists
+	DanglingSymlink
+)
+
+func (k GarbageKind) String() string {
+	switch k {
+	case Partial:
+		return "partial download"
+	case DanglingSymlink:
+		return "dangling symlink"
+	}
+	return "unreferenced blob"
+}
+
+// Garbage is a file in the blobs directory that can be removed
+type Garbage struct {
+	Path string
+	Kind GarbageKind
+	// Size is the space freed by removing the file, zero for symlinks as their target isn't removed
+	Size    int64
+	ModTime time.Time
+}
+
+// BrokenBlob is a blob a manifest references that is missing or a symlink to a missing file.
+// These are never removed, but mean the model referencing them won't load.
+type BrokenBlob struct {
+	Digest string
+	Models []string
+}
+
+// GarbageReport is the result of scanning the store for garbage
+type GarbageReport struct {
+	Garbage []Garbage
+	// Reclaimable is the total size of the garbage
+	Reclaimable int64
+	Broken      []BrokenBlob
+}
+
+var (
+	blobPattern    = regexp.MustCompile(`^sha256-[0-9a-f]{64}$`)
+	partialPattern = regexp.MustCompile(`^sha256-[0-9a-f]{64}-partial(-\d+)?$`)
+)
+
+// FindGarbage works out the blobs reachable from the manifests and returns everything else in the blobs
+// directory that Ollama created. Files modified within minAge are skipped as they may belong to a pull
+// that is still running, whose manifest is only written once every blob has downloaded.
+//
+// Blob symlinks, such as those created when importing LM Studio models, are only ever reported as
+// garbage themselves, the files they point to are left alone.
+func (s *Store) FindGarbage(minAge time.Duration) (*GarbageReport, error) {
+	models, err := s.Models()
+	if err != nil {
+		return nil, err
+	}
+	if len(models) == 0 {
+		return nil, fmt.Errorf("no manifests found in %s, refusing to treat every blob as unreferenced", s.ManifestsDir())
+	}
+
+	referencedBy := make(map[string][]string)
+	for _, model := range models {
+		for digest := range modelDigests(model) {
+			referencedBy[digest] = append(referencedBy[digest], model.Name)
+		}
+	}
+
+	entries, err := os.ReadDir(s.BlobsDir())
+	if err != nil {
+		return nil, fmt.Errorf("error reading blobs in %s: %v", s.BlobsDir(), err)
+	}
+
+	report := &GarbageReport{}
+	cutoff := time.Now().Add(-minAge)
+	for _, entry := range entries {
+		name := entry.Name()
+		path := filepath.Join(s.BlobsDir(), name)
+
+		var kind GarbageKind
+		switch {
+		case partialPattern.MatchString(name):
+			kind = Partial
+		case blobPattern.MatchString(name):
+			if _, ok := referencedBy[digestFromBlobName(name)]; ok {
+				continue
+			}
+			kind = Unreferenced
+		default:
+			// Not something Ollama created, leave it alone
+			continue
+		}
+
+		info, err := os.Lstat(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", path, err)
+		}
+		if info.ModTime().After(cutoff) {
+			continue
+		}
+
+		garbage := Garbage{Path: path, Kind: kind, ModTime: info.ModTime()}
+		if info.Mode()&os.ModeSymlink != 0 {
+			if _, err := os.Stat(path); err != nil {
+				garbage.Kind = DanglingSymlink
+			}
+		} else {
+			garbage.Size = info.Size()
+		}
+		report.Garbage = append(report.Garbage, garbage)
+		report.Reclaimable += garbage.Size
+	}
+
+	for digest, names := range referencedBy {
+		// Stat follows symlinks, so a symlink to a deleted LM Studio model counts as missing
+		if _, err := os.Stat(s.BlobPath(digest)); err != nil {
+			report.Broken = append(report.Broken, BrokenBlob{Digest: digest, Models: names})
+		}
+	}
+	sort.Slice(report.Broken, func(i, j int) bool { return report.Broken[i].Digest < report.Broken[j].Digest })
+	return report, nil
+}
+
+// RemoveGarbage removes the garbage found by FindGarbage, a symlink is removed without touching its target.
+// It carries on after a failure and returns the space freed along with the first error.
+func (s *Store) RemoveGarbage(garbage []Garbage) (int64, error) {
+	var freed int64
+	var firstErr error
+	for _, g := range garbage {
+		if err := os.Remove(g.Path); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("error removing %s: %v", g.Path, err)
+			}
+			continue
+		}
+		freed += g.Size
+	}
+	return freed, firstErr
+}
+
+// digestFromBlobName converts a blob file name, sha256-<hex>, back to its digest
+func digestFromBlobName(name string) string {
+	return "sha256:" + name[len("sha256-"):]
+}
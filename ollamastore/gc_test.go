@@ -0,0 +1,134 @@
+package ollamastore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindGarbage(t *testing.T) {
+	s := New(t.TempDir())
+	config := writeBlob(t, s, "config")
+	weights := writeBlob(t, s, "weights")
+	orphan := writeBlob(t, s, "orphaned weights")
+	writeManifest(t, s, "model:latest", config, weights)
+
+	partial := s.BlobPath(orphan) + "-partial-0"
+	if err := os.WriteFile(partial, []byte("half"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A referenced LM Studio symlink is kept, an unreferenced one pointing at a deleted file is garbage
+	lmStudioDir := t.TempDir()
+	linkedFile := filepath.Join(lmStudioDir, "linked.gguf")
+	if err := os.WriteFile(linkedFile, []byte("linked"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	linked := "sha256:" + strings.Repeat("1", 64)
+	dangling := "sha256:" + strings.Repeat("2", 64)
+	if err := os.Symlink(linkedFile, s.BlobPath(linked)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(lmStudioDir, "deleted.gguf"), s.BlobPath(dangling)); err != nil {
+		t.Fatal(err)
+	}
+	writeManifest(t, s, "linked:latest", config, linked)
+
+	// Files Ollama didn't create are ignored
+	if err := os.WriteFile(filepath.Join(s.BlobsDir(), "notes.txt"), []byte("keep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := s.FindGarbage(0)
+	if err != nil {
+		t.Fatalf("FindGarbage() error: %v", err)
+	}
+
+	found := make(map[string]GarbageKind)
+	for _, g := range report.Garbage {
+		found[filepath.Base(g.Path)] = g.Kind
+	}
+	expected := map[string]GarbageKind{
+		filepath.Base(s.BlobPath(orphan)):   Unreferenced,
+		filepath.Base(partial):              Partial,
+		filepath.Base(s.BlobPath(dangling)): DanglingSymlink,
+	}
+	if len(found) != len(expected) {
+		t.Errorf("expected %v, got %v", expected, found)
+	}
+	for name, kind := range expected {
+		if found[name] != kind {
+			t.Errorf("%s: expected %s, got %s", name, kind, found[name])
+		}
+	}
+	if want := int64(len("orphaned weights") + len("half")); report.Reclaimable != want {
+		t.Errorf("expected %d reclaimable bytes, got %d", want, report.Reclaimable)
+	}
+	if len(report.Broken) != 0 {
+		t.Errorf("unexpected broken blobs: %+v", report.Broken)
+	}
+
+	freed, err := s.RemoveGarbage(report.Garbage)
+	if err != nil {
+		t.Fatalf("RemoveGarbage() error: %v", err)
+	}
+	if freed != report.Reclaimable {
+		t.Errorf("expected %d bytes freed, got %d", report.Reclaimable, freed)
+	}
+	for _, path := range []string{s.BlobPath(config), s.BlobPath(weights), s.BlobPath(linked), linkedFile} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s should have been kept: %v", path, err)
+		}
+	}
+	if _, err := os.Lstat(s.BlobPath(dangling)); !os.IsNotExist(err) {
+		t.Error("dangling symlink should have been removed")
+	}
+}
+
+func TestFindGarbageSkipsRecent(t *testing.T) {
+	s := New(t.TempDir())
+	config := writeBlob(t, s, "config")
+	writeManifest(t, s, "model:latest", config)
+	old := writeBlob(t, s, "orphaned weights")
+	writeBlob(t, s, "still downloading")
+	hourAgo := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(s.BlobPath(old), hourAgo, hourAgo); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := s.FindGarbage(time.Minute)
+	if err != nil {
+		t.Fatalf("FindGarbage() error: %v", err)
+	}
+	if len(report.Garbage) != 1 || report.Garbage[0].Path != s.BlobPath(old) {
+		t.Errorf("expected only the old blob, got %+v", report.Garbage)
+	}
+}
+
+func TestFindGarbageBrokenModel(t *testing.T) {
+	s := New(t.TempDir())
+	config := writeBlob(t, s, "config")
+	missing := "sha256:" + strings.Repeat("3", 64)
+	writeManifest(t, s, "broken:latest", config, missing)
+
+	report, err := s.FindGarbage(0)
+	if err != nil {
+		t.Fatalf("FindGarbage() error: %v", err)
+	}
+	if len(report.Broken) != 1 || report.Broken[0].Digest != missing || report.Broken[0].Models[0] != "broken:latest" {
+		t.Errorf("expected the missing blob to be reported, got %+v", report.Broken)
+	}
+}
+
+func TestFindGarbageNoManifests(t *testing.T) {
+	s := New(t.TempDir())
+	writeBlob(t, s, "weights")
+	if err := os.MkdirAll(s.ManifestsDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.FindGarbage(0); err == nil {
+		t.Error("FindGarbage() should refuse to run without any manifests")
+	}
+}
@@ -0,0 +1,171 @@
+package ollamastore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	DefaultHost      = "registry.ollama.ai"
+	DefaultNamespace = "library"
+	DefaultTag       = "latest"
+)
+
+// Layer is a blob referenced by a manifest, the config blob uses the same shape
+type Layer struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+	From      string `json:"from,omitempty"`
+}
+
+// Manifest is an Ollama model manifest as stored under manifests/
+type Manifest struct {
+	SchemaVersion int     `json:"schemaVersion"`
+	MediaType     string  `json:"mediaType"`
+	Config        Layer   `json:"config"`
+	Layers        []Layer `json:"layers"`
+}
+
+// Blobs returns the config blob, if there is one, followed by the layers
+func (m *Manifest) Blobs() []Layer {
+	blobs := make([]Layer, 0, len(m.Layers)+1)
+	if m.Config.Digest != "" {
+		blobs = append(blobs, m.Config)
+	}
+	return append(blobs, m.Layers...)
+}
+
+// Model is a manifest found in the store
+type Model struct {
+	// Name is the name shown by `ollama list`, e.g. llama3.2:latest or hf.co/user/repo:Q4_K_M
+	Name string
+	Path string
+	// Digest is the sha256 of the manifest file in hex, the digest reported by the API's list endpoint
+	Digest   string
+	Manifest Manifest
+}
+
+// Store is an Ollama models directory containing manifests/ and blobs/
+type Store struct {
+	Dir string
+}
+
+func New(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+func (s *Store) ManifestsDir() string {
+	return filepath.Join(s.Dir, "manifests")
+}
+
+func (s *Store) BlobsDir() string {
+	return filepath.Join(s.Dir, "blobs")
+}
+
+// BlobPath returns the path of the blob with the given digest, sha256:<hex> is stored as blobs/sha256-<hex>
+func (s *Store) BlobPath(digest string) string {
+	return filepath.Join(s.BlobsDir(), strings.Replace(digest, ":", "-", 1))
+}
+
+// Models reads every manifest in the store, sorted by name.
+// An unreadable manifest is an error rather than being skipped, so callers never act on a partial view of the store.
+func (s *Store) Models() ([]Model, error) {
+	root := s.ManifestsDir()
+	var models []Model
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if len(parts) != 4 {
+			return nil
+		}
+		model, err := readModel(ShortName(parts[0], parts[1], parts[2], parts[3]), path)
+		if err != nil {
+			return err
+		}
+		models = append(models, *model)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifests in %s: %v", root, err)
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].Name < models[j].Name })
+	return models, nil
+}
+
+// Model reads the manifest for a model name such as llama3.2, llama3.2:1b or hf.co/user/repo:tag
+func (s *Store) Model(name string) (*Model, error) {
+	host, namespace, model, tag := ParseName(name)
+	path := filepath.Join(s.ManifestsDir(), host, namespace, model, tag)
+	return readModel(ShortName(host, namespace, model, tag), path)
+}
+
+func readModel(name, path string) (*Model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := ParseManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing manifest %s: %v", path, err)
+	}
+	sum := sha256.Sum256(data)
+	return &Model{Name: name, Path: path, Digest: hex.EncodeToString(sum[:]), Manifest: *manifest}, nil
+}
+
+// ParseManifest decodes a manifest file's content
+func ParseManifest(data []byte) (*Manifest, error) {
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// ParseName splits a model name into its registry host, namespace, model and tag, filling in Ollama's defaults
+func ParseName(name string) (host, namespace, model, tag string) {
+	host, namespace, tag = DefaultHost, DefaultNamespace, DefaultTag
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, tag = name[:i], name[i+1:]
+	}
+	parts := strings.Split(name, "/")
+	switch len(parts) {
+	case 1:
+		model = parts[0]
+	case 2:
+		namespace, model = parts[0], parts[1]
+	default:
+		host = parts[0]
+		namespace = strings.Join(parts[1:len(parts)-1], "/")
+		model = parts[len(parts)-1]
+	}
+	return host, namespace, model, tag
+}
+
+// ShortName formats a model name the way `ollama list` does, leaving out the default host and namespace
+func ShortName(host, namespace, model, tag string) string {
+	name := model + ":" + tag
+	switch {
+	case host != DefaultHost:
+		return host + "/" + namespace + "/" + name
+	case namespace != DefaultNamespace:
+		return namespace + "/" + name
+	}
+	return name
+}
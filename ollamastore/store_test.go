@@ -0,0 +1,133 @@
+package ollamastore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeBlob stores content as a blob and returns its digest
+func writeBlob(t *testing.T, s *Store, content string) string {
+	t.Helper()
+	sum := sha256.Sum256([]byte(content))
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	if err := os.MkdirAll(s.BlobsDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(s.BlobPath(digest), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return digest
+}
+
+// writeManifest stores a manifest for name referencing the given config and layer digests
+func writeManifest(t *testing.T, s *Store, name string, config string, layers ...string) {
+	t.Helper()
+	var modelLayers []Layer
+	for _, digest := range layers {
+		modelLayers = append(modelLayers, Layer{MediaType: "application/vnd.ollama.image.model", Digest: digest})
+	}
+	writeManifestLayers(t, s, name, Layer{MediaType: "application/vnd.docker.container.image.v1+json", Digest: config}, modelLayers...)
+}
+
+// writeManifestLayers stores a manifest for name with the given layers
+func writeManifestLayers(t *testing.T, s *Store, name string, config Layer, layers ...Layer) {
+	t.Helper()
+	manifest := Manifest{
+		SchemaVersion: 2,
+		MediaType:     "application/vnd.docker.distribution.manifest.v2+json",
+		Config:        config,
+		Layers:        layers,
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, namespace, model, tag := ParseName(name)
+	path := filepath.Join(s.ManifestsDir(), host, namespace, model, tag)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseName(t *testing.T) {
+	tests := []struct {
+		name                        string
+		host, namespace, model, tag string
+		short                       string
+	}{
+		{"llama3.2", DefaultHost, DefaultNamespace, "llama3.2", DefaultTag, "llama3.2:latest"},
+		{"llama3.2:1b", DefaultHost, DefaultNamespace, "llama3.2", "1b", "llama3.2:1b"},
+		{"sammcj/qwen:7b", DefaultHost, "sammcj", "qwen", "7b", "sammcj/qwen:7b"},
+		{"hf.co/user/repo:Q4_K_M", "hf.co", "user", "repo", "Q4_K_M", "hf.co/user/repo:Q4_K_M"},
+		{"localhost:5000/library/model", "localhost:5000", "library", "model", DefaultTag, "localhost:5000/library/model:latest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, namespace, model, tag := ParseName(tt.name)
+			if host != tt.host || namespace != tt.namespace || model != tt.model || tag != tt.tag {
+				t.Errorf("ParseName(%q) = %q, %q, %q, %q", tt.name, host, namespace, model, tag)
+			}
+			if short := ShortName(host, namespace, model, tag); short != tt.short {
+				t.Errorf("ShortName() = %q, expected %q", short, tt.short)
+			}
+		})
+	}
+}
+
+func TestModels(t *testing.T) {
+	s := New(t.TempDir())
+	config := writeBlob(t, s, "config")
+	weights := writeBlob(t, s, "weights")
+	writeManifest(t, s, "llama3.2:1b", config, weights)
+	writeManifest(t, s, "hf.co/user/repo:Q4_K_M", config, weights)
+	if err := os.WriteFile(filepath.Join(s.ManifestsDir(), ".DS_Store"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	models, err := s.Models()
+	if err != nil {
+		t.Fatalf("Models() error: %v", err)
+	}
+	if len(models) != 2 || models[0].Name != "hf.co/user/repo:Q4_K_M" || models[1].Name != "llama3.2:1b" {
+		t.Fatalf("unexpected models: %+v", models)
+	}
+	if blobs := models[1].Manifest.Blobs(); len(blobs) != 2 || blobs[0].Digest != config || blobs[1].Digest != weights {
+		t.Errorf("unexpected blobs: %+v", blobs)
+	}
+
+	model, err := s.Model("llama3.2:1b")
+	if err != nil || model.Name != "llama3.2:1b" {
+		t.Fatalf("Model() = %+v, %v", model, err)
+	}
+	data, err := os.ReadFile(model.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum := sha256.Sum256(data); model.Digest != hex.EncodeToString(sum[:]) {
+		t.Errorf("expected the manifest digest, got %q", model.Digest)
+	}
+	if _, err := s.Model("missing"); err == nil {
+		t.Error("Model() should fail for a missing model")
+	}
+}
+
+func TestModelsInvalidManifest(t *testing.T) {
+	s := New(t.TempDir())
+	path := filepath.Join(s.ManifestsDir(), DefaultHost, DefaultNamespace, "broken", DefaultTag)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Models(); err == nil {
+		t.Error("Models() should fail when a manifest can't be parsed")
+	}
+}
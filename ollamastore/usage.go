@@ -0,0 +1,80 @@
+package ollamastore
+
+import (
+	"os"
+)
+
+// Usage is the disk space used by a model's blobs
+type Usage struct {
+	// Digest is the digest of the model's manifest, to check the usage is for the model the API reports
+	Digest string
+	// Unique is the size of the blobs no other model references, freed when the model is deleted
+	Unique int64
+	// Shared is the size of the blobs also referenced by other models
+	Shared int64
+}
+
+// DiskUsage attributes the blobs in a store to the models referencing them
+type DiskUsage struct {
+	Models map[string]Usage
+	// Total is the size of every referenced blob counted once
+	Total int64
+}
+
+// BlobSize returns the bytes a blob occupies in the store. Symlinked blobs, such as those created when
+// importing LM Studio models, take no space in the store and have size zero. Missing blobs report false.
+func (s *Store) BlobSize(digest string) (int64, bool) {
+	info, err := os.Lstat(s.BlobPath(digest))
+	if err != nil {
+		return 0, false
+	}
+	if !info.Mode().IsRegular() {
+		return 0, true
+	}
+	return info.Size(), true
+}
+
+// DiskUsage reads every manifest and works out how much of each model's size is unique to it
+func (s *Store) DiskUsage() (*DiskUsage, error) {
+	models, err := s.Models()
+	if err != nil {
+		return nil, err
+	}
+
+	// references counts the models using each blob, a model listing a blob twice counts once
+	references := make(map[string]int)
+	for _, model := range models {
+		for digest := range modelDigests(model) {
+			references[digest]++
+		}
+	}
+
+	sizes := make(map[string]int64, len(references))
+	usage := &DiskUsage{Models: make(map[string]Usage, len(models))}
+	for digest := range references {
+		size, _ := s.BlobSize(digest)
+		sizes[digest] = size
+		usage.Total += size
+	}
+
+	for _, model := range models {
+		u := Usage{Digest: model.Digest}
+		for digest := range modelDigests(model) {
+			if references[digest] > 1 {
+				u.Shared += sizes[digest]
+			} else {
+				u.Unique += sizes[digest]
+			}
+		}
+		usage.Models[model.Name] = u
+	}
+	return usage, nil
+}
+
+func modelDigests(model Model) map[string]bool {
+	digests := make(map[string]bool)
+	for _, blob := range model.Manifest.Blobs() {
+		digests[blob.Digest] = true
+	}
+	return digests
+}
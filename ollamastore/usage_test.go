@@ -0,0 +1,56 @@
+package ollamastore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiskUsage(t *testing.T) {
+	s := New(t.TempDir())
+	config1 := writeBlob(t, s, "config one")
+	config2 := writeBlob(t, s, "config two")
+	weights := writeBlob(t, s, "shared weights")
+	adapter := writeBlob(t, s, "adapter")
+	writeManifest(t, s, "base:latest", config1, weights)
+	writeManifest(t, s, "tuned:latest", config2, weights, adapter, adapter)
+
+	// A blob symlinked from LM Studio takes no space in the store
+	external := filepath.Join(t.TempDir(), "model.gguf")
+	if err := os.WriteFile(external, []byte("external weights"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	linked := "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+	if err := os.Symlink(external, s.BlobPath(linked)); err != nil {
+		t.Fatal(err)
+	}
+	writeManifest(t, s, "linked:latest", config1, linked)
+
+	usage, err := s.DiskUsage()
+	if err != nil {
+		t.Fatalf("DiskUsage() error: %v", err)
+	}
+
+	size := func(content string) int64 { return int64(len(content)) }
+	expected := map[string]Usage{
+		"base:latest":   {Unique: 0, Shared: size("config one") + size("shared weights")},
+		"tuned:latest":  {Unique: size("config two") + size("adapter"), Shared: size("shared weights")},
+		"linked:latest": {Unique: 0, Shared: size("config one")},
+	}
+	for name, want := range expected {
+		if got := usage.Models[name]; got.Unique != want.Unique || got.Shared != want.Shared {
+			t.Errorf("%s: expected %+v, got %+v", name, want, got)
+		}
+	}
+	total := size("config one") + size("config two") + size("shared weights") + size("adapter")
+	if usage.Total != total {
+		t.Errorf("expected total %d, got %d", total, usage.Total)
+	}
+}
+
+func TestBlobSizeMissing(t *testing.T) {
+	s := New(t.TempDir())
+	if _, ok := s.BlobSize("sha256:missing"); ok {
+		t.Error("BlobSize() should report a missing blob")
+	}
+}
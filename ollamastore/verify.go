@@ -0,0 +1,214 @@
+package ollamastore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BlobStatus is the outcome of verifying a blob
+type BlobStatus int
+
+const (
+	BlobOK BlobStatus = iota
+	// BlobMissing is a blob that doesn't exist, or a symlink whose target doesn't
+	BlobMissing
+	// BlobTruncated is a blob smaller than the size in the manifest
+	BlobTruncated
+	// BlobSizeMismatch is a blob larger than the size in the manifest
+	BlobSizeMismatch
+	// BlobDigestMismatch is a blob of the right size whose content doesn't match its digest
+	BlobDigestMismatch
+	// BlobUnreadable is a blob that couldn't be read, e.g. an I/O error from the disk
+	BlobUnreadable
+)
+
+func (s BlobStatus) String() string {
+	switch s {
+	case BlobOK:
+		return "ok"
+	case BlobMissing:
+		return "missing"
+	case BlobTruncated:
+		return "truncated"
+	case BlobSizeMismatch:
+		return "size mismatch"
+	case BlobDigestMismatch:
+		return "digest mismatch"
+	}
+	return "unreadable"
+}
+
+// BlobRef is a blob along with the models that reference it
+type BlobRef struct {
+	Layer
+	Models []string
+}
+
+// BlobResult is the result of verifying a blob
+type BlobResult struct {
+	BlobRef
+	Path   string
+	Status BlobStatus
+	// ActualSize is the size of the blob on disk and ActualDigest its digest, when they could be read
+	ActualSize   int64
+	ActualDigest string
+	Err          error
+}
+
+func (r BlobResult) String() string {
+	switch r.Status {
+	case BlobTruncated, BlobSizeMismatch:
+		return fmt.Sprintf("%s: expected %d bytes, found %d", r.Status, r.Size, r.ActualSize)
+	case BlobDigestMismatch:
+		return fmt.Sprintf("%s: content hashes to %s", r.Status, r.ActualDigest)
+	case BlobMissing:
+		return fmt.Sprintf("%s: %s", r.Status, r.Path)
+	case BlobUnreadable:
+		if r.Err != nil {
+			return fmt.Sprintf("%s: %v", r.Status, r.Err)
+		}
+	}
+	return r.Status.String()
+}
+
+// BlobsToVerify returns the distinct blobs referenced by the models, sorted by digest
+func BlobsToVerify(models []Model) []BlobRef {
+	refs := make(map[string]*BlobRef)
+	for _, model := range models {
+		for _, blob := range model.Manifest.Blobs() {
+			ref, ok := refs[blob.Digest]
+			if !ok {
+				ref = &BlobRef{Layer: blob}
+				refs[blob.Digest] = ref
+			}
+			if len(ref.Models) == 0 || ref.Models[len(ref.Models)-1] != model.Name {
+				ref.Models = append(ref.Models, model.Name)
+			}
+		}
+	}
+
+	blobs := make([]BlobRef, 0, len(refs))
+	for _, ref := range refs {
+		blobs = append(blobs, *ref)
+	}
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].Digest < blobs[j].Digest })
+	return blobs
+}
+
+// VerifyBlobs re-hashes the blobs using the given number of workers and returns the results in the same order.
+// progress, if not nil, is called from the workers with the number of bytes just read so it must be safe for
+// concurrent use. Blobs that fail the size check are not hashed, their size still counts towards progress.
+func (s *Store) VerifyBlobs(blobs []BlobRef, workers int, progress func(read int64)) []BlobResult {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]BlobResult, len(blobs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = s.VerifyBlob(blobs[i], progress)
+			}
+		}()
+	}
+	for i := range blobs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// VerifyBlob checks the blob's size against the manifest and then its content against its digest.
+// Symlinked blobs are followed, so LM Studio imports are checked against the file they point to.
+func (s *Store) VerifyBlob(blob BlobRef, progress func(read int64)) BlobResult {
+	result := BlobResult{BlobRef: blob, Path: s.BlobPath(blob.Digest)}
+	// skipped reports the bytes that won't be read as done, so progress still reaches the total
+	skipped := func() {
+		if progress != nil {
+			progress(blob.Size)
+		}
+	}
+
+	algorithm, _, _ := strings.Cut(blob.Digest, ":")
+	if algorithm != "sha256" {
+		result.Status, result.Err = BlobUnreadable, fmt.Errorf("unsupported digest %s", blob.Digest)
+		skipped()
+		return result
+	}
+
+	info, err := os.Stat(result.Path)
+	if err != nil {
+		result.Status, result.Err = BlobMissing, err
+		if !os.IsNotExist(err) {
+			result.Status = BlobUnreadable
+		}
+		skipped()
+		return result
+	}
+	result.ActualSize = info.Size()
+	// The config layer size is sometimes left out of manifests, so only check sizes the manifest records
+	if blob.Size > 0 && result.ActualSize < blob.Size {
+		result.Status = BlobTruncated
+		skipped()
+		return result
+	}
+	if blob.Size > 0 && result.ActualSize > blob.Size {
+		result.Status = BlobSizeMismatch
+		skipped()
+		return result
+	}
+
+	digest, err := HashFile(result.Path, progress)
+	if err != nil {
+		result.Status, result.Err = BlobUnreadable, err
+		return result
+	}
+	result.ActualDigest = "sha256:" + digest
+	if result.ActualDigest != blob.Digest {
+		result.Status = BlobDigestMismatch
+	}
+	return result
+}
+
+// progressReader calls progress with the bytes read through it
+type progressReader struct {
+	r        io.Reader
+	progress func(read int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.progress(int64(n))
+	}
+	return n, err
+}
+
+// HashFile calculates the SHA256 hash of a file in hex, calling progress with the bytes read if it's not nil
+func HashFile(path string, progress func(read int64)) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if progress != nil {
+		r = &progressReader{r: file, progress: progress}
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", fmt.Errorf("failed to calculate hash for %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,89 @@
+package ollamastore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestVerifyBlobs(t *testing.T) {
+	s := New(t.TempDir())
+	layer := func(content string) Layer {
+		return Layer{MediaType: "application/vnd.ollama.image.model", Digest: writeBlob(t, s, content), Size: int64(len(content))}
+	}
+
+	good := layer("good weights")
+	truncated := layer("truncated weights")
+	if err := os.Truncate(s.BlobPath(truncated.Digest), 4); err != nil {
+		t.Fatal(err)
+	}
+	corrupted := layer("corrupted weights")
+	if err := os.WriteFile(s.BlobPath(corrupted.Digest), []byte("CORRUPTED weights"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	missing := Layer{Digest: "sha256:" + strings.Repeat("4", 64), Size: 10}
+
+	// Symlinked blobs are checked against the file they point to
+	linkedContent := "linked weights"
+	linked := layer(linkedContent)
+	external := filepath.Join(t.TempDir(), "model.gguf")
+	if err := os.WriteFile(external, []byte(linkedContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(s.BlobPath(linked.Digest)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(external, s.BlobPath(linked.Digest)); err != nil {
+		t.Fatal(err)
+	}
+
+	writeManifestLayers(t, s, "first:latest", good, truncated, corrupted)
+	writeManifestLayers(t, s, "second:latest", good, missing, linked)
+
+	models, err := s.Models()
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobs := BlobsToVerify(models)
+	if len(blobs) != 5 {
+		t.Fatalf("expected 5 distinct blobs, got %d", len(blobs))
+	}
+
+	var read atomic.Int64
+	results := s.VerifyBlobs(blobs, 3, func(n int64) { read.Add(n) })
+
+	expected := map[string]BlobStatus{
+		good.Digest:      BlobOK,
+		truncated.Digest: BlobTruncated,
+		corrupted.Digest: BlobDigestMismatch,
+		missing.Digest:   BlobMissing,
+		linked.Digest:    BlobOK,
+	}
+	var total int64
+	for _, result := range results {
+		total += result.Size
+		if result.Status != expected[result.Digest] {
+			t.Errorf("%s: expected %s, got %s", result.Digest, expected[result.Digest], result)
+		}
+		if result.Digest == good.Digest && strings.Join(result.Models, ",") != "first:latest,second:latest" {
+			t.Errorf("expected the shared blob to list both models, got %v", result.Models)
+		}
+	}
+	if read.Load() != total {
+		t.Errorf("expected progress to reach %d bytes, got %d", total, read.Load())
+	}
+}
+
+func TestHashFile(t *testing.T) {
+	s := New(t.TempDir())
+	digest := writeBlob(t, s, "content")
+	hash, err := HashFile(s.BlobPath(digest), nil)
+	if err != nil {
+		t.Fatalf("HashFile() error: %v", err)
+	}
+	if "sha256:"+hash != digest {
+		t.Errorf("expected %s, got sha256:%s", digest, hash)
+	}
+}
@@ -0,0 +1,136 @@
+// Package openwebui writes Ollama models as the model JSON Open WebUI imports from Workspace > Models, so a model's
+// system prompt and parameters show up as an Open WebUI model built on it.
+package openwebui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/ollama/ollama/api"
+	ollama_model "github.com/ollama/ollama/types/model"
+
+	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/modelfile"
+)
+
+// DefaultProfileImageURL is the icon Open WebUI gives models it creates itself
+const DefaultProfileImageURL = "/static/favicon.png"
+
+// params are the Open WebUI names of the Modelfile parameters it has a setting for
+var params = map[string]string{
+	"num_ctx":           "num_ctx",
+	"num_batch":         "num_batch",
+	"num_keep":          "num_keep",
+	"num_gpu":           "num_gpu",
+	"num_thread":        "num_thread",
+	"num_predict":       "max_tokens",
+	"temperature":       "temperature",
+	"top_k":             "top_k",
+	"top_p":             "top_p",
+	"min_p":             "min_p",
+	"tfs_z":             "tfs_z",
+	"repeat_penalty":    "repeat_penalty",
+	"repeat_last_n":     "repeat_last_n",
+	"presence_penalty":  "presence_penalty",
+	"frequency_penalty": "frequency_penalty",
+	"mirostat":          "mirostat",
+	"mirostat_eta":      "mirostat_eta",
+	"mirostat_tau":      "mirostat_tau",
+	"seed":              "seed",
+	"stop":              "stop",
+	"use_mmap":          "use_mmap",
+	"use_mlock":         "use_mlock",
+}
+
+// Model is a model in the JSON Open WebUI imports, a list of them
+type Model struct {
+	ID string `json:"id"`
+	// BaseModelID is the Ollama model requests are sent to
+	BaseModelID string         `json:"base_model_id"`
+	Name        string         `json:"name"`
+	Meta        Meta           `json:"meta"`
+	Params      map[string]any `json:"params"`
+	// AccessControl is nil for models every user can use
+	AccessControl any  `json:"access_control"`
+	IsActive      bool `json:"is_active"`
+}
+
+// Meta is how Open WebUI shows the model
+type Meta struct {
+	ProfileImageURL string          `json:"profile_image_url"`
+	Description     string          `json:"description"`
+	Capabilities    map[string]bool `json:"capabilities"`
+	Tags            []Tag           `json:"tags"`
+}
+
+// Tag is a label models can be filtered by
+type Tag struct {
+	Name string `json:"name"`
+}
+
+// NewModel builds the Open WebUI model for an Ollama model from its Modelfile. id is the Open WebUI model's ID and
+// displayName the name it's shown as, vision is whether the model can read images. Open WebUI sends the
+// conversation to Ollama, which applies the template, so the Modelfile's TEMPLATE isn't needed. It also returns the
+// parameters that have no Open WebUI equivalent.
+func NewModel(id, modelName, displayName string, parsed *modelfile.Modelfile, vision bool) (*Model, []string) {
+	model := &Model{
+		ID:          id,
+		BaseModelID: modelName,
+		Name:        displayName,
+		Meta: Meta{
+			ProfileImageURL: DefaultProfileImageURL,
+			Description:     fmt.Sprintf("%s from Ollama, exported by gollama", modelName),
+			Capabilities:    map[string]bool{"vision": vision, "citations": true},
+			Tags:            []Tag{{Name: "ollama"}},
+		},
+		Params:   make(map[string]any),
+		IsActive: true,
+	}
+
+	var unmapped []string
+	for name, value := range parsed.APIParameters() {
+		param, ok := params[name]
+		if !ok {
+			unmapped = append(unmapped, name)
+			continue
+		}
+		model.Params[param] = value
+	}
+	if system, ok := parsed.System(); ok && system != "" {
+		model.Params["system"] = system
+	}
+	sort.Strings(unmapped)
+	return model, unmapped
+}
+
+// ExportModel builds the Open WebUI model for an installed Ollama model, see NewModel
+func ExportModel(id, modelName, displayName string, client *api.Client) (*Model, []string, error) {
+	resp, err := client.Show(context.Background(), &api.ShowRequest{Name: modelName})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get model info: %w", err)
+	}
+
+	parsed, err := modelfile.Parse(resp.Modelfile)
+	if err != nil {
+		logging.ErrorLogger.Printf("Warning: Failed to parse Modelfile for %s: %v\n", modelName, err)
+		parsed = &modelfile.Modelfile{}
+	}
+	vision := slices.Contains(resp.Capabilities, ollama_model.CapabilityVision)
+	model, unmapped := NewModel(id, modelName, displayName, parsed, vision)
+	return model, unmapped, nil
+}
+
+// Marshal returns the models as the JSON list Open WebUI imports
+func Marshal(models []*Model) ([]byte, error) {
+	if models == nil {
+		models = []*Model{}
+	}
+	data, err := json.MarshalIndent(models, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal models: %w", err)
+	}
+	return append(data, '\n'), nil
+}
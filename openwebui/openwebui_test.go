@@ -0,0 +1,44 @@
+package openwebui
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/mipalgu/gollama/modelfile"
+)
+
+func TestNewModel(t *testing.T) {
+	parsed, err := modelfile.Parse("FROM qwen3:8b\nSYSTEM \"You are terse.\"\nPARAMETER num_ctx 16384\nPARAMETER num_predict 512\nPARAMETER temperature 0.7\nPARAMETER stop <|im_start|>\nPARAMETER stop <|im_end|>\nPARAMETER penalize_nl true\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, unmapped := NewModel("qwen3-8b", "qwen3:8b", "Terse Qwen", parsed, true)
+
+	if model.ID != "qwen3-8b" || model.BaseModelID != "qwen3:8b" || model.Name != "Terse Qwen" || !model.Meta.Capabilities["vision"] {
+		t.Errorf("Unexpected model %+v", model)
+	}
+	want := map[string]any{
+		"system":      "You are terse.",
+		"num_ctx":     16384,
+		"max_tokens":  512,
+		"temperature": 0.7,
+		"stop":        []string{"<|im_start|>", "<|im_end|>"},
+	}
+	if !reflect.DeepEqual(model.Params, want) {
+		t.Errorf("Params = %v\nwant %v", model.Params, want)
+	}
+	if !reflect.DeepEqual(unmapped, []string{"penalize_nl"}) {
+		t.Errorf("unmapped = %v, want [penalize_nl]", unmapped)
+	}
+
+	// Open WebUI imports a list, even of one model
+	data, err := Marshal([]*Model{model})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var imported []map[string]any
+	if err := json.Unmarshal(data, &imported); err != nil || len(imported) != 1 || imported[0]["base_model_id"] != "qwen3:8b" {
+		t.Errorf("Unexpected JSON %s, %v", data, err)
+	}
+}
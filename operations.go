@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -16,10 +17,10 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/ollama/ollama/api"
-	ollama_model "github.com/ollama/ollama/types/model"
 	"github.com/mipalgu/gollama/config"
-	"github.com/mipalgu/gollama/lmstudio"
+	"github.com/mipalgu/gollama/links"
 	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/modelfile"
 	"github.com/mipalgu/gollama/styles"
 )
 
@@ -100,6 +101,7 @@ func copyModel(m *AppModel, client *api.Client, oldName string, newName string)
 		return
 	}
 	m.models = parseAPIResponse(resp)
+	m.updateDiskUsage()
 	m.refreshList()
 }
 
@@ -115,6 +117,7 @@ func renameModel(m *AppModel, oldName string, newName string) error {
 			break
 		}
 	}
+	m.updateDiskUsage()
 
 	message := fmt.Sprintf("Successfully renamed model %s to %s", oldName, newName)
 	logging.InfoLogger.Printf("%s", message)
@@ -192,27 +195,25 @@ func getModelParams(modelName string, client *api.Client) (map[string]string, st
 		logging.ErrorLogger.Printf("Error getting parameters for model %s: %v\n", modelName, err)
 		return nil, "", err
 	}
-	output := []byte(resp.Modelfile)
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	params := make(map[string]string)
-	var template string
-
-	for _, line := range lines {
-		if strings.HasPrefix(line, "TEMPLATE") {
-			template = strings.TrimPrefix(line, "TEMPLATE ")
-			template = strings.Trim(template, "\"")
-		} else if strings.HasPrefix(line, "PARAMETER") {
-			parts := strings.SplitN(line, " ", 2)
-			if len(parts) == 2 {
-				key := parts[1]
-				value := strings.TrimSpace(parts[1])
-				params[key] = value
-			}
-		}
+	parsed, err := modelfile.Parse(resp.Modelfile)
+	if err != nil {
+		logging.ErrorLogger.Printf("Error parsing modelfile for model %s: %v\n", modelName, err)
+		return nil, "", err
 	}
+	params, template := modelfileParams(parsed)
 	return params, template, nil
 }
 
+// modelfileParams returns a Modelfile's parameters for display, with repeated values such as stop joined, and its template
+func modelfileParams(parsed *modelfile.Modelfile) (map[string]string, string) {
+	params := make(map[string]string)
+	for name, values := range parsed.ParameterMap() {
+		params[name] = strings.Join(values, ", ")
+	}
+	template, _ := parsed.Template()
+	return params, template
+}
+
 // getEnhancedModelInfo fetches detailed model information using the Ollama show API
 func getEnhancedModelInfo(modelName string, client *api.Client) (*EnhancedModelInfo, error) {
 	logging.InfoLogger.Printf("Getting enhanced model information for: %s\n", modelName)
@@ -349,29 +350,58 @@ func editModelfile(client *api.Client, modelName string) (string, error) {
 		return "", fmt.Errorf("error writing modelfile to temp file: %v", err)
 	}
 
-	// Open the local modelfile in the editor
-	cmd := exec.Command(editor, newModelfilePath)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err = cmd.Run()
-	if err != nil {
-		return "", fmt.Errorf("error running editor: %v", err)
-	}
+	// Open the local modelfile in the editor, reopening it with the problems listed at the top until it passes linting
+	var newModelfileContent []byte
+	var rejectedContent string
+	for {
+		cmd := exec.Command(editor, newModelfilePath)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		err = cmd.Run()
+		if err != nil {
+			return "", fmt.Errorf("error running editor: %v", err)
+		}
 
-	// Read the edited content from the local file
-	newModelfileContent, err := os.ReadFile(newModelfilePath)
-	if err != nil {
-		return "", fmt.Errorf("error reading edited modelfile: %v", err)
-	}
+		// Read the edited content from the local file
+		newModelfileContent, err = os.ReadFile(newModelfilePath)
+		if err != nil {
+			return "", fmt.Errorf("error reading edited modelfile: %v", err)
+		}
 
-	// If there were no changes, return early
-	if string(newModelfileContent) == modelfileContent {
-		return fmt.Sprintf("No changes made to model %s", modelName), nil
+		// If there were no changes, return early
+		if string(newModelfileContent) == modelfileContent {
+			return fmt.Sprintf("No changes made to model %s", modelName), nil
+		}
+
+		diags, lintErr := lintModelfile(string(newModelfileContent))
+		if lintErr == nil {
+			newModelfileContent = []byte(stripLintHeader(string(newModelfileContent)))
+			for _, diag := range diags {
+				fmt.Printf("Warning: %s\n", diag)
+			}
+			break
+		}
+
+		// Saving again without fixing anything gives up on the edit
+		if string(newModelfileContent) == rejectedContent {
+			return "", lintErr
+		}
+		rejectedContent = addLintHeader(string(newModelfileContent), diags)
+		if err := os.WriteFile(newModelfilePath, []byte(rejectedContent), 0600); err != nil {
+			return "", fmt.Errorf("error writing modelfile to temp file: %v", err)
+		}
 	}
 
 	// Extract TEMPLATE, SYSTEM, and parameters from both original and new content
-	var origTemplate, origSystem, newTemplate, newSystem string
+	origModelfile, err := modelfile.Parse(modelfileContent)
+	if err != nil {
+		return "", fmt.Errorf("error parsing original modelfile: %v", err)
+	}
+	newModelfile, err := modelfile.Parse(string(newModelfileContent))
+	if err != nil {
+		return "", fmt.Errorf("error parsing edited modelfile: %v", err)
+	}
 
 	// Create request with base fields
 	createReq := &api.CreateRequest{
@@ -379,8 +409,10 @@ func editModelfile(client *api.Client, modelName string) (string, error) {
 		From:  modelName, // Required: use the model's own name as the base
 	}
 
-	origTemplate, origSystem = extractTemplateAndSystem(modelfileContent)
-	newTemplate, newSystem = extractTemplateAndSystem(string(newModelfileContent))
+	origTemplate, _ := origModelfile.Template()
+	origSystem, _ := origModelfile.System()
+	newTemplate, _ := newModelfile.Template()
+	newSystem, _ := newModelfile.System()
 
 	// Only include template if it was changed
 	if newTemplate != origTemplate {
@@ -394,7 +426,7 @@ func editModelfile(client *api.Client, modelName string) (string, error) {
 	}
 
 	// Add parameters if any were found
-	parameters := extractParameters(string(newModelfileContent))
+	parameters := newModelfile.APIParameters()
 	if len(parameters) > 0 {
 		createReq.Parameters = parameters
 	}
@@ -604,6 +636,13 @@ func finishExternalEdit(client *api.Client, modelName, tempFilePath string) (str
 		return "", fmt.Errorf("error reading edited modelfile: %v", err)
 	}
 
+	// Check the modelfile before anything is sent to the server, keeping the file so the errors can be fixed
+	warnings, err := lintModelfile(string(newModelfileContent))
+	if err != nil {
+		return "", err
+	}
+	newModelfileContent = []byte(stripLintHeader(string(newModelfileContent)))
+
 	// Clean up the temporary file
 	defer os.Remove(tempFilePath)
 
@@ -620,7 +659,14 @@ func finishExternalEdit(client *api.Client, modelName, tempFilePath string) (str
 	}
 
 	// Extract TEMPLATE, SYSTEM, and parameters from both original and new content
-	var origTemplate, origSystem, newTemplate, newSystem string
+	origModelfile, err := modelfile.Parse(originalContent)
+	if err != nil {
+		return "", fmt.Errorf("error parsing original modelfile: %v", err)
+	}
+	newModelfile, err := modelfile.Parse(string(newModelfileContent))
+	if err != nil {
+		return "", fmt.Errorf("error parsing edited modelfile: %v", err)
+	}
 
 	// Create request with base fields
 	createReq := &api.CreateRequest{
@@ -628,8 +674,10 @@ func finishExternalEdit(client *api.Client, modelName, tempFilePath string) (str
 		From:  modelName, // Required: use the model's own name as the base
 	}
 
-	origTemplate, origSystem = extractTemplateAndSystem(originalContent)
-	newTemplate, newSystem = extractTemplateAndSystem(string(newModelfileContent))
+	origTemplate, _ := origModelfile.Template()
+	origSystem, _ := origModelfile.System()
+	newTemplate, _ := newModelfile.Template()
+	newSystem, _ := newModelfile.System()
 
 	// Only include template if it was changed
 	if newTemplate != origTemplate {
@@ -642,40 +690,7 @@ func finishExternalEdit(client *api.Client, modelName, tempFilePath string) (str
 		createReq.System = newSystem
 	}
 
-	// Parse new content for parameters
-	newLines := strings.Split(string(newModelfileContent), "\n")
-	params := make(map[string]interface{})
-	for _, line := range newLines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		// Skip TEMPLATE and SYSTEM lines
-		if strings.HasPrefix(strings.ToUpper(line), "TEMPLATE") ||
-			strings.HasPrefix(strings.ToUpper(line), "SYSTEM") ||
-			strings.HasPrefix(strings.ToUpper(line), "FROM") {
-			continue
-		}
-
-		if strings.HasPrefix(strings.ToUpper(line), "PARAMETER") {
-			parts := strings.SplitN(line, " ", 3)
-			if len(parts) >= 3 {
-				paramName := strings.ToLower(parts[1])
-				paramValue := strings.TrimSpace(parts[2])
-
-				if floatVal, err := strconv.ParseFloat(paramValue, 64); err == nil {
-					params[paramName] = floatVal
-				} else if intVal, err := strconv.Atoi(paramValue); err == nil {
-					params[paramName] = intVal
-				} else {
-					params[paramName] = paramValue
-				}
-			}
-		}
-	}
-
-	if len(params) > 0 {
+	if params := newModelfile.APIParameters(); len(params) > 0 {
 		createReq.Parameters = params
 	}
 
@@ -689,6 +704,9 @@ func finishExternalEdit(client *api.Client, modelName, tempFilePath string) (str
 		return "", fmt.Errorf("error updating model: %v", err)
 	}
 
+	if len(warnings) > 0 {
+		return fmt.Sprintf("Successfully updated model %s with %d lint warning(s)", modelName, len(warnings)), nil
+	}
 	return fmt.Sprintf("Successfully updated model %s", modelName), nil
 }
 
@@ -916,7 +934,11 @@ func isBrokenSymlink(symlinkPath string) bool {
 	return false
 }
 
+// cleanBrokenSymlinks removes the broken symlinks gollama created in the LM Studio models directory and empty
+// directories, broken symlinks from other tools are left alone
 func cleanBrokenSymlinks(lmStudioModelsDir string) {
+	reg := loadLinkRegistry()
+	var removed []string
 	err := filepath.Walk(lmStudioModelsDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -935,17 +957,28 @@ func cleanBrokenSymlinks(lmStudioModelsDir string) {
 			}
 		} else if info.Mode()&os.ModeSymlink != 0 {
 			// Only remove truly broken symlinks (where target doesn't exist)
-			// Don't remove valid symlinks from other tools
+			// Don't remove valid symlinks or symlinks from other tools
 			if isBrokenSymlink(path) {
+				// Recorded links are left for the stale link check to relink, they break when a model is pulled again
+				if link, ok := recordedLink(reg, path); ok && link.Check() == links.Broken {
+					logging.DebugLogger.Printf("Keeping broken symlink %s to %s so it can be relinked\n", path, link.Model)
+					return nil
+				}
+				if !managedLink(reg, path, lmStudioModelsDir) {
+					logging.DebugLogger.Printf("Preserving broken symlink not created by gollama: %s\n", path)
+					return nil
+				}
 				logging.InfoLogger.Printf("Removing broken symlink: %s\n", path)
 				err = os.Remove(path)
 				if err != nil {
 					return err
 				}
+				removed = append(removed, path)
 			}
 		}
 		return nil
 	})
+	reconcileLinks(lmStudioModelsDir, removed)
 	if err != nil {
 		logging.ErrorLogger.Printf("Error walking LM Studio models directory: %v\n", err)
 		return
@@ -1011,19 +1044,7 @@ func (m *AppModel) startPullModelPreserveConfig(modelName string) tea.Cmd {
 
 		// Add parameters if any were found
 		if len(currentParams) > 0 {
-			// Convert map[string]string to map[string]any
-			parameters := make(map[string]any)
-			for k, v := range currentParams {
-				// Try to convert numeric values
-				if floatVal, err := strconv.ParseFloat(v, 64); err == nil {
-					parameters[k] = floatVal
-				} else if intVal, err := strconv.Atoi(v); err == nil {
-					parameters[k] = intVal
-				} else {
-					parameters[k] = v
-				}
-			}
-			createReq.Parameters = parameters
+			createReq.Parameters = currentParams
 		}
 
 		// Apply the configuration
@@ -1040,39 +1061,50 @@ func (m *AppModel) startPullModelPreserveConfig(modelName string) tea.Cmd {
 
 type editorFinishedMsg struct{ err error }
 
+// cleanupSymlinkedModels removes every model gollama linked into LM Studio, found from the link registry and, for
+// links made before it existed, by isGollamaSymlink, then removes the empty directories left behind
 func cleanupSymlinkedModels(lmStudioModelsDir string) {
-	// First pass: remove broken symlinks and gollama-created symlinks
+	reg := loadLinkRegistry()
+	var removed []string
+
+	// First pass: remove the recorded links, wherever they are
+	if reg != nil {
+		for _, link := range reg.Links() {
+			if link.Kind != links.LMStudio {
+				continue
+			}
+			if status := link.Check(); status != links.OK && status != links.Broken {
+				continue
+			}
+			logging.InfoLogger.Printf("Removing gollama-created %s for %s: %s\n", link.Strategy, link.Model, link.Path)
+			if err := os.Remove(link.Path); err != nil {
+				logging.ErrorLogger.Printf("Error removing link %s: %v\n", link.Path, err)
+				continue
+			}
+			removed = append(removed, link.Path)
+		}
+	}
+
+	// Second pass: remove unrecorded gollama-created symlinks in the models directory
 	err := filepath.Walk(lmStudioModelsDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 		if info.Mode()&os.ModeSymlink != 0 {
-			// Only remove symlinks that are either:
-			// 1. Created by gollama, or
-			// 2. Broken (safe to remove)
-			shouldRemove := false
-			reason := ""
-
-			if isBrokenSymlink(path) {
-				shouldRemove = true
-				reason = "broken symlink"
-			} else if isGollamaSymlink(path, lmStudioModelsDir) {
-				shouldRemove = true
-				reason = "gollama-created symlink"
-			}
-
-			if shouldRemove {
-				logging.InfoLogger.Printf("Removing %s: %s\n", reason, path)
+			if managedLink(reg, path, lmStudioModelsDir) {
+				logging.InfoLogger.Printf("Removing gollama-created symlink: %s\n", path)
 				err = os.Remove(path)
 				if err != nil {
 					return err
 				}
+				removed = append(removed, path)
 			} else {
 				logging.DebugLogger.Printf("Preserving non-gollama symlink: %s\n", path)
 			}
 		}
 		return nil
 	})
+	reconcileLinks(lmStudioModelsDir, removed)
 	if err != nil {
 		logging.ErrorLogger.Printf("Error removing symlinks: %v\n", err)
 		return
@@ -1124,9 +1156,8 @@ func cleanupSymlinkedModels(lmStudioModelsDir string) {
 	}
 }
 
-func searchModels(models []Model, searchTerms ...string) {
-	logging.InfoLogger.Printf("Searching for models with terms: %v\n", searchTerms)
-
+// filterModels returns the models whose names contain all of the search terms, sorted by name
+func filterModels(models []Model, searchTerms ...string) []Model {
 	var searchResults []Model
 	for _, model := range models {
 		if containsAllTerms(model.Name, searchTerms...) {
@@ -1137,6 +1168,24 @@ func searchModels(models []Model, searchTerms ...string) {
 	sort.Slice(searchResults, func(i, j int) bool {
 		return strings.ToLower(searchResults[i].Name) < strings.ToLower(searchResults[j].Name)
 	})
+	return searchResults
+}
+
+func searchModels(models []Model, format OutputFormat, searchTerms ...string) {
+	logging.InfoLogger.Printf("Searching for models with terms: %v\n", searchTerms)
+
+	searchResults := filterModels(models, searchTerms...)
+
+	// Machine-readable formats and piped output are printed without styling
+	if format != OutputText || !stdoutIsTerminal() {
+		if err := printModelsPlain(searchResults, format); err != nil {
+			logging.ErrorLogger.Printf("Error writing search results: %v\n", err)
+			fmt.Fprintln(os.Stderr, "Error writing search results:", err)
+			os.Exit(1)
+		}
+		logging.InfoLogger.Printf("Found %d matching models\n", len(searchResults))
+		return
+	}
 
 	baseStyle, highlightStyle, headerStyle := styles.SearchHighlightStyle(), styles.SearchTextStyle(), styles.SearchHeaderStyle()
 
@@ -1162,29 +1211,46 @@ func searchModels(models []Model, searchTerms ...string) {
 	}
 }
 
-func linkModel(modelName, lmStudioModelsDir string, noCleanup bool, dryRun bool, exportConfig bool, client *api.Client) (string, error) {
-	modelFiles, err := getModelFiles(modelName, client)
-	if err != nil {
-		return "", fmt.Errorf("error getting model files for %s: %v", modelName, err)
+// configExport asks linkModel to export the model's Modelfile configuration to the link target, and collects the
+// parameters that couldn't be exported for a summary
+type configExport struct {
+	// unmapped are the parameters the target has no equivalent for, by model
+	unmapped map[string][]string
+}
+
+// printUnmappedParameters summarises the parameters left out of configs exported to app, listing the models using each
+func printUnmappedParameters(w io.Writer, app string, unmapped map[string][]string) {
+	if len(unmapped) == 0 {
+		return
+	}
+	byParameter := make(map[string][]string)
+	for model, params := range unmapped {
+		for _, param := range params {
+			byParameter[param] = append(byParameter[param], model)
+		}
+	}
+	params := make([]string, 0, len(byParameter))
+	for param := range byParameter {
+		params = append(params, param)
 	}
-	fullModelName := ollama_model.ParseName(modelName)
+	sort.Strings(params)
 
-	// Extract author from model name, e.g.:
-	// * "fleo/tiny-r1-32b-preview:latest" -> "registry.ollama.ai/fleo/tiny-r1-32b-preview" -> "fleo"
-	// * "huggingface.co/fleo/tiny-r1-32b-preview" -> "fleo"
-	// * "tiny-r1-32b-preview:latest" -> "registry.ollama.ai/library/tiny-r1-32b-preview" -> "registry.ollama.ai" (Use host as author if the namespace is default `library`)
-	author := fullModelName.Namespace
-	if author == ollama_model.DefaultName().Namespace {
-		author = fullModelName.Host
+	fmt.Fprintf(w, "\nParameters not exported as %s has no equivalent:\n", app)
+	for _, param := range params {
+		models := byParameter[param]
+		sort.Strings(models)
+		fmt.Fprintf(w, "  %s: %s\n", param, strings.Join(models, ", "))
 	}
+}
 
-	// Create a clean model name for the file
-	// * model tag is appended to the model name if it's not the default `latest` tag
-	lmStudioModelName := fullModelName.Model
-	if fullModelName.Tag != ollama_model.DefaultName().Tag {
-		lmStudioModelName += "-" + fullModelName.Tag
+// linkModel links a model's files into target with strategy, replacing outdated links gollama made, and writes the
+// target's sidecar config
+func linkModel(modelName string, target linkTarget, strategy links.Strategy, noCleanup bool, dryRun bool, export *configExport, client *api.Client) (string, error) {
+	exportConfig := export != nil
+	modelFiles, err := getModelFiles(modelName, client)
+	if err != nil {
+		return "", fmt.Errorf("error getting model files for %s: %v", modelName, err)
 	}
-	lmStudioModelDir := filepath.Join(lmStudioModelsDir, author, lmStudioModelName)
 
 	// Check if the main model path is a valid file
 	fileInfo, err := os.Stat(modelFiles.MainModel)
@@ -1202,61 +1268,38 @@ func linkModel(modelName, lmStudioModelsDir string, noCleanup bool, dryRun bool,
 	}
 
 	// Define target paths for both files
-	lmStudioMainPath := filepath.Join(lmStudioModelDir, lmStudioModelName+".gguf")
-	var lmStudioProjPath string
-	if modelFiles.Projector != "" {
-		// Extract filename from projector path to preserve the mmproj naming
-		projFileName := filepath.Base(modelFiles.Projector)
-		// Create an mmproj filename based on the model name if the original doesn't contain mmproj
-		if !strings.Contains(strings.ToLower(projFileName), "mmproj") {
-			projFileName = "mmproj-" + lmStudioModelName + ".gguf"
-		} else {
-			// Keep original naming but replace model-specific parts with our naming
-			projFileName = "mmproj-" + lmStudioModelName + filepath.Ext(projFileName)
-		}
-		lmStudioProjPath = filepath.Join(lmStudioModelDir, projFileName)
-	}
+	mainPath, projPath := target.Paths(modelName, modelFiles)
+	modelDir := filepath.Dir(mainPath)
 
-	// Check if the main model symlink already exists and is valid
-	mainLinkExists := false
-	if _, err := os.Lstat(lmStudioMainPath); err == nil {
-		if isValidSymlink(lmStudioMainPath, modelFiles.MainModel) {
-			mainLinkExists = true
-		} else {
-			// Remove the invalid symlink
-			err = os.Remove(lmStudioMainPath)
-			if err != nil {
-				message := "failed to remove invalid main model symlink %s: %v"
-				logging.ErrorLogger.Printf(message+"\n", lmStudioMainPath, err)
-				return "", fmt.Errorf(message, lmStudioMainPath, err)
-			}
-		}
+	// Check if the main model link already exists and is up to date, replacing it if it's an outdated link
+	// gollama created
+	reg := loadLinkRegistry()
+	mainLinkExists, err := existingLink(reg, mainPath, modelFiles.MainModel, target.Dir(), strategy, dryRun)
+	if err != nil {
+		logging.ErrorLogger.Println(err)
+		return "", err
 	}
 
-	// Check projector symlink if applicable
+	// Check projector link if applicable
 	projLinkExists := false
-	if modelFiles.Projector != "" && lmStudioProjPath != "" {
-		if _, err := os.Lstat(lmStudioProjPath); err == nil {
-			if isValidSymlink(lmStudioProjPath, modelFiles.Projector) {
-				projLinkExists = true
-			} else {
-				// Remove the invalid projector symlink
-				err = os.Remove(lmStudioProjPath)
-				if err != nil {
-					logging.ErrorLogger.Printf("failed to remove invalid projector symlink %s: %v\n", lmStudioProjPath, err)
-					// Don't fail completely for projector issues, just log
-				}
-			}
+	if modelFiles.Projector != "" && projPath != "" {
+		projLinkExists, err = existingLink(reg, projPath, modelFiles.Projector, target.Dir(), strategy, dryRun)
+		if err != nil {
+			// Don't fail completely for projector issues, just log
+			logging.ErrorLogger.Println(err)
+			projPath = ""
 		}
 	}
 
-	// If all required symlinks exist, we're done unless we need to export the config
+	// If all required links exist, we're done unless we need to export the config
 	if mainLinkExists && (modelFiles.Projector == "" || projLinkExists) {
+		// Links made before the registry existed are recorded now
+		recordLinks(target.Kind(), modelName, modelFiles, mainPath, projPath, nil, exportConfig)
 		if !exportConfig {
-			message := "Model %s is already symlinked to %s"
-			logging.InfoLogger.Printf(message+"\n", modelName, lmStudioMainPath)
+			message := "Model %s is already linked to %s"
+			logging.InfoLogger.Printf(message+"\n", modelName, mainPath)
 			if modelFiles.Projector != "" {
-				logging.InfoLogger.Printf("Vision projector also symlinked to %s\n", lmStudioProjPath)
+				logging.InfoLogger.Printf("Vision projector also linked to %s\n", projPath)
 			}
 			return "", nil
 		}
@@ -1264,93 +1307,74 @@ func linkModel(modelName, lmStudioModelsDir string, noCleanup bool, dryRun bool,
 
 	if dryRun {
 		// Log the full paths for debugging
-		logging.InfoLogger.Printf("LM Studio models directory: %s\n", lmStudioModelsDir)
+		logging.InfoLogger.Printf("%s models directory: %s\n", target.Name(), target.Dir())
 
 		// Create message with full paths for display
-		message := "[DRY RUN] Would create directory %s and symlink %s to %s"
-		fullPathMessage := fmt.Sprintf(message, lmStudioModelDir, modelName, lmStudioMainPath)
+		message := "[DRY RUN] Would create directory %s and %s %s to %s"
+		fullPathMessage := fmt.Sprintf(message, modelDir, strategy, modelName, mainPath)
 		logging.InfoLogger.Println(fullPathMessage)
 
 		if modelFiles.Projector != "" {
-			projMessage := fmt.Sprintf("[DRY RUN] Would also symlink vision projector to %s", lmStudioProjPath)
+			projMessage := fmt.Sprintf("[DRY RUN] Would also %s vision projector to %s", strategy, projPath)
 			logging.InfoLogger.Println(projMessage)
 			fullPathMessage += "\n" + projMessage
 		}
 
-		if exportConfig {
-			configPath := filepath.Join(lmStudioModelDir, lmStudioModelName+".config.json")
-			exportMessage := fmt.Sprintf("[DRY RUN] Would export Modelfile config to %s", configPath)
-			logging.InfoLogger.Println(exportMessage)
-			fullPathMessage += "\n" + exportMessage
+		if sidecarMessage, _ := target.WriteSidecar(modelName, mainPath, projPath, export, true, client); sidecarMessage != "" {
+			fullPathMessage += "\n" + sidecarMessage
 		}
 
 		return fullPathMessage, nil
 	} else {
 		// Create the directory
-		err = os.MkdirAll(lmStudioModelDir, os.ModePerm)
+		err = os.MkdirAll(modelDir, os.ModePerm)
 		if err != nil {
 			message := "failed to create directory %s: %v"
-			logging.ErrorLogger.Printf(message+"\n", lmStudioModelDir, err)
-			return "", fmt.Errorf(message, lmStudioModelDir, err)
+			logging.ErrorLogger.Printf(message+"\n", modelDir, err)
+			return "", fmt.Errorf(message, modelDir, err)
 		}
 
-		// Create the main model symlink
+		// Link the main model, hardlinks and reflinks fall back to copies
+		placed := make(map[string]links.Strategy)
 		if !mainLinkExists {
-			err = os.Symlink(modelFiles.MainModel, lmStudioMainPath)
+			used, err := links.Place(strategy, modelFiles.MainModel, mainPath, blobDigest(modelFiles.MainModel))
 			if err != nil {
-				message := "failed to symlink main model %s: %v"
-				logging.ErrorLogger.Printf(message+"\n", modelName, err)
-				return "", fmt.Errorf(message, modelName, err)
+				message := "failed to %s main model %s: %v"
+				logging.ErrorLogger.Printf(message+"\n", strategy, modelName, err)
+				return "", fmt.Errorf(message, strategy, modelName, err)
 			}
+			placed[mainPath] = used
 		}
 
-		// Create the projector symlink if needed
-		if modelFiles.Projector != "" && !projLinkExists && lmStudioProjPath != "" {
-			err = os.Symlink(modelFiles.Projector, lmStudioProjPath)
+		// Link the projector if needed
+		if modelFiles.Projector != "" && !projLinkExists && projPath != "" {
+			used, err := links.Place(strategy, modelFiles.Projector, projPath, blobDigest(modelFiles.Projector))
 			if err != nil {
 				// Log error but don't fail completely for projector issues
-				logging.ErrorLogger.Printf("failed to symlink projector for %s: %v (continuing with main model only)\n", modelName, err)
+				logging.ErrorLogger.Printf("failed to %s projector for %s: %v (continuing with main model only)\n", strategy, modelName, err)
+				projPath = ""
 			} else {
-				logging.InfoLogger.Printf("Created vision projector symlink: %s\n", lmStudioProjPath)
+				placed[projPath] = used
+				logging.InfoLogger.Printf("Created vision projector %s: %s\n", used, projPath)
 			}
 		}
 
+		recordLinks(target.Kind(), modelName, modelFiles, mainPath, projPath, placed, exportConfig)
+
 		if !noCleanup {
-			cleanBrokenSymlinks(lmStudioModelsDir)
+			target.CleanBroken()
 		}
 
-		// Export configuration if requested
-		if exportConfig {
-			configPath := filepath.Join(lmStudioModelDir, lmStudioModelName+".config.json")
-
-			if dryRun {
-				logging.InfoLogger.Printf("[DRY RUN] Would export Modelfile config to %s\n", configPath)
-				logging.InfoLogger.Printf("[DRY RUN] Would export preset to ~/.lmstudio/config-presets/%s.preset.json\n", lmStudioModelName)
-			} else {
-				// Export sidecar config file
-				err := lmstudio.ExportModelConfig(modelName, configPath, client)
-				if err != nil {
-					logging.ErrorLogger.Printf("Warning: Failed to export config for %s: %v\n", modelName, err)
-					// Don't fail entire operation for config export errors
-				} else {
-					logging.InfoLogger.Printf("Exported configuration to %s\n", configPath)
-				}
-
-				// Export preset file for LM Studio to discover
-				err = lmstudio.ExportModelPreset(modelName, lmStudioModelName, client)
-				if err != nil {
-					logging.ErrorLogger.Printf("Warning: Failed to export preset for %s: %v\n", modelName, err)
-					// Don't fail entire operation for preset export errors
-				} else {
-					logging.InfoLogger.Printf("Exported preset to ~/.lmstudio/config-presets/%s.preset.json\n", lmStudioModelName)
-				}
-			}
+		// Write the target's config for the model, LM Studio's only if it was requested
+		if _, err := target.WriteSidecar(modelName, mainPath, projPath, export, false, client); err != nil {
+			logging.ErrorLogger.Println(err)
+			return "", err
 		}
 
-		message := "Symlinked %s to %s"
-		logging.InfoLogger.Printf(message+"\n", modelName, lmStudioMainPath)
-		if modelFiles.Projector != "" && lmStudioProjPath != "" {
-			logging.InfoLogger.Printf("Vision projector symlinked to %s\n", lmStudioProjPath)
+		message := "Linked %s to %s"
+		logging.InfoLogger.Printf(message+"\n", modelName, mainPath)
+		if modelFiles.Projector != "" && projPath != "" {
+			logging.InfoLogger.Printf("Vision projector linked to %s\n", projPath)
 		}
 		return "", nil
 	}
@@ -1429,63 +1453,8 @@ func containsAnyTerm(s string, terms ...string) bool {
 	return false
 }
 
-// extractTemplateAndSystem extracts TEMPLATE and SYSTEM values from modelfile content
-// preserving any template syntax within them
-func extractTemplateAndSystem(content string) (template string, system string) {
-	lines := strings.Split(content, "\n")
-	var templateLines []string
-	inTemplate := false
-	inMultilineTemplate := false
-
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-
-		// Handle TEMPLATE directive
-		if strings.HasPrefix(trimmed, "TEMPLATE") {
-			if strings.Contains(trimmed, `"""`) {
-				// Multi-line template
-				templateContent := strings.TrimPrefix(trimmed, "TEMPLATE ")
-				templateContent = strings.TrimSpace(templateContent)
-				if strings.HasPrefix(templateContent, `"""`) {
-					templateContent = strings.TrimPrefix(templateContent, `"""`)
-				}
-				inTemplate = true
-				inMultilineTemplate = true
-				if templateContent != "" {
-					templateLines = append(templateLines, templateContent)
-				}
-			} else {
-				// Single-line template
-				template = strings.TrimPrefix(trimmed, "TEMPLATE ")
-				template = strings.Trim(template, `"`)
-			}
-		} else if inTemplate {
-			if inMultilineTemplate && strings.HasSuffix(trimmed, `"""`) {
-				line = strings.TrimSuffix(line, `"""`)
-				if line != "" {
-					templateLines = append(templateLines, line)
-				}
-				inTemplate = false
-				inMultilineTemplate = false
-			} else {
-				templateLines = append(templateLines, line)
-			}
-		} else if strings.HasPrefix(trimmed, "SYSTEM") {
-			system = strings.TrimPrefix(trimmed, "SYSTEM ")
-			// Remove surrounding quotes if present
-			system = strings.Trim(system, `"`)
-		}
-	}
-
-	if len(templateLines) > 0 {
-		template = strings.Join(templateLines, "\n")
-	}
-
-	return template, system
-}
-
 // getModelParamsWithSystem extracts parameters, template, and system prompt from a model's modelfile
-func getModelParamsWithSystem(modelName string, client *api.Client) (map[string]string, string, string, error) {
+func getModelParamsWithSystem(modelName string, client *api.Client) (map[string]any, string, string, error) {
 	logging.InfoLogger.Printf("Getting parameters and system prompt for model: %s\n", modelName)
 	ctx := context.Background()
 	req := &api.ShowRequest{Name: modelName}
@@ -1494,108 +1463,12 @@ func getModelParamsWithSystem(modelName string, client *api.Client) (map[string]
 		logging.ErrorLogger.Printf("Error getting modelfile for %s: %v\n", modelName, err)
 		return nil, "", "", err
 	}
-	output := []byte(resp.Modelfile)
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	params := make(map[string]string)
-	var template string
-	var system string
-
-	inTemplate := false
-	inMultilineTemplate := false
-	var templateLines []string
-
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-
-		// Handle TEMPLATE directive
-		if strings.HasPrefix(trimmed, "TEMPLATE") {
-			if strings.Contains(trimmed, `"""`) {
-				// Multi-line template
-				templateContent := strings.TrimPrefix(trimmed, "TEMPLATE ")
-				templateContent = strings.TrimSpace(templateContent)
-				if strings.HasPrefix(templateContent, `"""`) {
-					templateContent = strings.TrimPrefix(templateContent, `"""`)
-				}
-				inTemplate = true
-				inMultilineTemplate = true
-				if templateContent != "" {
-					templateLines = append(templateLines, templateContent)
-				}
-			} else {
-				// Single-line template
-				template = strings.TrimPrefix(trimmed, "TEMPLATE ")
-				template = strings.Trim(template, `"`)
-			}
-		} else if inTemplate {
-			if inMultilineTemplate && strings.HasSuffix(trimmed, `"""`) {
-				line = strings.TrimSuffix(line, `"""`)
-				if line != "" {
-					templateLines = append(templateLines, line)
-				}
-				inTemplate = false
-				inMultilineTemplate = false
-			} else {
-				templateLines = append(templateLines, line)
-			}
-		} else if strings.HasPrefix(trimmed, "SYSTEM") {
-			system = strings.TrimPrefix(trimmed, "SYSTEM ")
-			// Remove surrounding quotes if present
-			system = strings.Trim(system, `"`)
-		} else if strings.HasPrefix(trimmed, "PARAMETER") {
-			parts := strings.SplitN(trimmed, " ", 3)
-			if len(parts) >= 3 {
-				key := parts[1]
-				value := strings.TrimSpace(parts[2])
-				params[key] = value
-			}
-		}
-	}
-
-	if len(templateLines) > 0 {
-		template = strings.Join(templateLines, "\n")
-	}
-
-	return params, template, system, nil
-}
-
-// extractParameters extracts parameter values from modelfile content
-func extractParameters(content string) map[string]any {
-	parameters := make(map[string]any)
-	lines := strings.Split(content, "\n")
-
-	// Handle multiple stop parameters
-	var stopValues []string
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "PARAMETER") {
-			parts := strings.Fields(line)
-			if len(parts) >= 3 {
-				paramName := parts[1]
-				paramValue := strings.Join(parts[2:], " ")
-
-				// Special handling for stop parameters
-				if paramName == "stop" {
-					stopValues = append(stopValues, paramValue)
-					continue
-				}
-
-				// Convert numeric values to appropriate types
-				if num, err := strconv.Atoi(paramValue); err == nil {
-					parameters[paramName] = num
-				} else if num, err := strconv.ParseFloat(paramValue, 64); err == nil {
-					parameters[paramName] = num
-				} else {
-					parameters[paramName] = paramValue
-				}
-			}
-		}
-	}
-
-	// Add stop values as an array if any were found
-	if len(stopValues) > 0 {
-		parameters["stop"] = stopValues
+	parsed, err := modelfile.Parse(resp.Modelfile)
+	if err != nil {
+		logging.ErrorLogger.Printf("Error parsing modelfile for %s: %v\n", modelName, err)
+		return nil, "", "", err
 	}
-
-	return parameters
+	template, _ := parsed.Template()
+	system, _ := parsed.System()
+	return parsed.APIParameters(), template, system, nil
 }
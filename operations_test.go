@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/mipalgu/gollama/config"
+	"github.com/mipalgu/gollama/links"
 )
 
 func TestRunModel(t *testing.T) {
@@ -175,3 +176,156 @@ func TestIsGollamaSymlink(t *testing.T) {
 		})
 	}
 }
+
+func TestCleanupSymlinkedModelsUsesRegistry(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	// Neither path matches what isGollamaSymlink looks for
+	blob := filepath.Join(dir, "usr", "share", "ollama", "models", "blobs", "sha256-abc")
+	lmStudioDir := filepath.Join(dir, "lmstudio")
+	for _, d := range []string{filepath.Dir(blob), filepath.Join(lmStudioDir, "library", "model"), filepath.Join(lmStudioDir, "other")} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(blob, []byte("weights"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	managed := filepath.Join(lmStudioDir, "library", "model", "renamed.gguf")
+	otherTool := filepath.Join(lmStudioDir, "other", "model.gguf")
+	otherBroken := filepath.Join(lmStudioDir, "other", "gone.gguf")
+	for link, target := range map[string]string{managed: blob, otherTool: blob, otherBroken: filepath.Join(dir, "gone")} {
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recordLinks(links.LMStudio, "model:latest", ModelFiles{MainModel: blob}, managed, "", nil, false)
+
+	reg, err := links.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link, ok := reg.Get(managed); !ok || link.Digest != "sha256:abc" {
+		t.Fatalf("Expected the link to be recorded with its digest, got %+v", link)
+	}
+
+	// Only the recorded link is removed, with the directory it leaves empty
+	cleanupSymlinkedModels(lmStudioDir)
+	if _, err := os.Lstat(managed); !os.IsNotExist(err) {
+		t.Error("The recorded link wasn't removed")
+	}
+	if _, err := os.Stat(filepath.Join(lmStudioDir, "library")); !os.IsNotExist(err) {
+		t.Error("The empty directories weren't removed")
+	}
+	for _, link := range []string{otherTool, otherBroken} {
+		if _, err := os.Lstat(link); err != nil {
+			t.Errorf("Another tool's link %s was removed", link)
+		}
+	}
+	if reg, _ = links.Load(); len(reg.Links()) != 0 {
+		t.Errorf("Expected the removed link to be forgotten, got %v", reg.Links())
+	}
+}
+
+func TestExistingLink(t *testing.T) {
+	dir := t.TempDir()
+	blobs := filepath.Join(dir, "blobs")
+	if err := os.MkdirAll(blobs, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	oldBlob, newBlob := filepath.Join(blobs, "sha256-old"), filepath.Join(blobs, "sha256-new")
+	for _, blob := range []string{oldBlob, newBlob} {
+		if err := os.WriteFile(blob, []byte(blob), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, "model.gguf")
+
+	if exists, err := existingLink(nil, path, newBlob, dir, links.Symlink, false); exists || err != nil {
+		t.Errorf("Nothing at the path: got %v, %v", exists, err)
+	}
+
+	// A link to the model's previous blob is replaced, in a dry run it's left in place
+	if err := os.Symlink(oldBlob, path); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := existingLink(nil, path, newBlob, dir, links.Symlink, true); exists || err != nil {
+		t.Errorf("Outdated link in a dry run: got %v, %v", exists, err)
+	}
+	if _, err := os.Lstat(path); err != nil {
+		t.Error("The dry run removed the link")
+	}
+	if exists, err := existingLink(nil, path, newBlob, dir, links.Symlink, false); exists || err != nil {
+		t.Errorf("Outdated link: got %v, %v", exists, err)
+	}
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Error("The outdated link wasn't removed")
+	}
+
+	if err := os.Symlink(newBlob, path); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := existingLink(nil, path, newBlob, dir, links.Symlink, false); !exists || err != nil {
+		t.Errorf("Current link: got %v, %v", exists, err)
+	}
+
+	// A real file is never replaced
+	os.Remove(path)
+	if err := os.WriteFile(path, []byte("downloaded in LM Studio"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := existingLink(nil, path, newBlob, dir, links.Symlink, false); err == nil {
+		t.Error("Expected an error for a file gollama didn't create")
+	}
+}
+
+func TestExistingLinkStrategies(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	blob := filepath.Join(dir, "blobs", "sha256-abc")
+	if err := os.MkdirAll(filepath.Dir(blob), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(blob, []byte("weights"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "lmstudio", "model.gguf")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	used, err := links.Place(links.Hardlink, blob, path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordLinks(links.LMStudio, "model:latest", ModelFiles{MainModel: blob}, path, "", map[string]links.Strategy{path: used}, false)
+	reg := loadLinkRegistry()
+	if link, ok := reg.Get(path); !ok || link.Strategy != used || link.Size != 7 {
+		t.Fatalf("Expected the %s to be recorded with its size, got %+v", used, link)
+	}
+
+	if exists, err := existingLink(reg, path, blob, dir, links.Hardlink, false); !exists || err != nil {
+		t.Errorf("Recorded hardlink: got %v, %v", exists, err)
+	}
+
+	// Asking for another strategy replaces the recorded link
+	if exists, err := existingLink(reg, path, blob, dir, links.Symlink, false); exists || err != nil {
+		t.Errorf("Hardlink when a symlink is wanted: got %v, %v", exists, err)
+	}
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Error("The hardlink wasn't removed")
+	}
+
+	// A copy that's been edited since belongs to the user
+	if _, err := links.Place(links.Copy, blob, path, ""); err != nil {
+		t.Fatal(err)
+	}
+	recordLinks(links.LMStudio, "model:latest", ModelFiles{MainModel: blob}, path, "", map[string]links.Strategy{path: links.Copy}, false)
+	if err := os.WriteFile(path, []byte("fine-tuned weights"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := existingLink(loadLinkRegistry(), path, blob, dir, links.Copy, false); err == nil {
+		t.Error("Expected an error for a copy that's been replaced")
+	}
+}
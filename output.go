@@ -0,0 +1,197 @@
+// output.go contains the machine-readable output formats used by the list and search commands.
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+	"golang.org/x/term"
+)
+
+// OutputFormat is the format used when printing models from the command line
+type OutputFormat string
+
+const (
+	OutputText OutputFormat = "text"
+	OutputJSON OutputFormat = "json"
+	OutputYAML OutputFormat = "yaml"
+	OutputCSV  OutputFormat = "csv"
+	OutputTSV  OutputFormat = "tsv"
+)
+
+// modelRecord is the unstyled representation of a Model used for machine-readable output
+type modelRecord struct {
+	Name              string    `json:"name" yaml:"name"`
+	ID                string    `json:"id" yaml:"id"`
+	Digest            string    `json:"digest" yaml:"digest"`
+	Size              int64     `json:"size" yaml:"size"`
+	UniqueSize        *int64    `json:"unique_size,omitempty" yaml:"unique_size,omitempty"`
+	SharedSize        *int64    `json:"shared_size,omitempty" yaml:"shared_size,omitempty"`
+	QuantizationLevel string    `json:"quantization_level" yaml:"quantization_level"`
+	Family            string    `json:"family" yaml:"family"`
+	ParameterSize     string    `json:"parameter_size" yaml:"parameter_size"`
+	Modified          time.Time `json:"modified" yaml:"modified"`
+}
+
+var modelRecordHeader = []string{"name", "id", "digest", "size", "unique_size", "shared_size", "quantization_level", "family", "parameter_size", "modified"}
+
+// parseOutputFormat validates an --output flag value, an empty value selects the default text output
+func parseOutputFormat(value string) (OutputFormat, error) {
+	switch OutputFormat(strings.ToLower(strings.TrimSpace(value))) {
+	case "", OutputText:
+		return OutputText, nil
+	case OutputJSON:
+		return OutputJSON, nil
+	case OutputYAML, "yml":
+		return OutputYAML, nil
+	case OutputCSV:
+		return OutputCSV, nil
+	case OutputTSV:
+		return OutputTSV, nil
+	}
+	return "", fmt.Errorf("unknown output format %q (expected json, yaml, csv or tsv)", value)
+}
+
+// stdoutIsTerminal reports whether stdout is attached to a terminal
+func stdoutIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// newModelRecord converts a model, the unique and shared sizes are left out when the disk usage isn't known
+func newModelRecord(model Model, withUsage bool) modelRecord {
+	record := modelRecord{
+		Name:              model.Name,
+		ID:                model.ID,
+		Digest:            model.Digest,
+		Size:              model.SizeBytes,
+		QuantizationLevel: model.QuantizationLevel,
+		Family:            model.Family,
+		ParameterSize:     model.ParameterSize,
+		Modified:          model.Modified,
+	}
+	if withUsage {
+		record.UniqueSize, record.SharedSize = &model.UniqueSize, &model.SharedSize
+	}
+	return record
+}
+
+func formatOptionalSize(size *int64) string {
+	if size == nil {
+		return ""
+	}
+	return strconv.FormatInt(*size, 10)
+}
+
+func (r modelRecord) fields() []string {
+	return []string{
+		r.Name,
+		r.ID,
+		r.Digest,
+		strconv.FormatInt(r.Size, 10),
+		formatOptionalSize(r.UniqueSize),
+		formatOptionalSize(r.SharedSize),
+		r.QuantizationLevel,
+		r.Family,
+		r.ParameterSize,
+		r.Modified.Format(time.RFC3339),
+	}
+}
+
+// writeModels writes the models to w in the given machine-readable format
+func writeModels(w io.Writer, models []Model, format OutputFormat) error {
+	withUsage := hasDiskUsage(models)
+	records := make([]modelRecord, len(models))
+	for i, model := range models {
+		records[i] = newModelRecord(model, withUsage)
+	}
+
+	switch format {
+	case OutputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	case OutputYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(records); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case OutputCSV, OutputTSV:
+		writer := csv.NewWriter(w)
+		if format == OutputTSV {
+			writer.Comma = '\t'
+		}
+		if err := writer.Write(modelRecordHeader); err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err := writer.Write(record.fields()); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case OutputText:
+		return writePlainModels(w, models)
+	}
+	return fmt.Errorf("unsupported output format %q", format)
+}
+
+// writePlainModels writes an unstyled, aligned table of models, used when stdout is not a terminal
+func writePlainModels(w io.Writer, models []Model) error {
+	nameWidth := len("Name")
+	for _, model := range models {
+		if len(model.Name) > nameWidth {
+			nameWidth = len(model.Name)
+		}
+	}
+
+	// The unique and shared columns are only included when the local manifests could be read
+	withUsage := hasDiskUsage(models)
+	usageColumns := func(unique, shared string) string {
+		if !withUsage {
+			return ""
+		}
+		return fmt.Sprintf("%-10s  %-10s  ", unique, shared)
+	}
+
+	format := "%-*s  %-10s  %s%-10s  %-10s  %-14s  %-10s  %s\n"
+	if _, err := fmt.Fprintf(w, format, nameWidth, "Name", "Size", usageColumns("Unique", "Shared"), "Params", "Quant", "Family", "Modified", "ID"); err != nil {
+		return err
+	}
+	for _, model := range models {
+		_, err := fmt.Fprintf(w, format,
+			nameWidth, model.Name,
+			fmt.Sprintf("%.2fGB", model.Size),
+			usageColumns(fmt.Sprintf("%.2fGB", bytesToGB(model.UniqueSize)), fmt.Sprintf("%.2fGB", bytesToGB(model.SharedSize))),
+			model.ParameterSize,
+			model.QuantizationLevel,
+			model.Family,
+			model.Modified.Format("2006-01-02"),
+			model.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printModelsPlain writes the models to stdout without any styling, the text format is used when stdout is not a terminal
+func printModelsPlain(models []Model, format OutputFormat) error {
+	if format == OutputText {
+		if len(models) == 0 {
+			fmt.Println("No models available to display.")
+			return nil
+		}
+		return writePlainModels(os.Stdout, models)
+	}
+	return writeModels(os.Stdout, models, format)
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+func testOutputModels() []Model {
+	modified := time.Date(2025, 3, 14, 9, 26, 53, 0, time.UTC)
+	return []Model{
+		{
+			Name:              "llama3.1:8b-instruct-q6_K",
+			ID:                "3c46ab1",
+			Digest:            "3c46ab11d9a5e3e2a7c1f8a9f6a6f1d92b2c9a8b4e0d6f4a1b2c3d4e5f6a7b8c",
+			Size:              6.14,
+			SizeBytes:         6596006368,
+			QuantizationLevel: "Q6_K",
+			Family:            "llama",
+			ParameterSize:     "8.0B",
+			Modified:          modified,
+		},
+		{
+			Name:              "nomic-embed-text:latest",
+			ID:                "0a109f4",
+			Digest:            "0a109f422b47e3a30ba2b10eca18548e944e8a23073ee3f3e947efcf3c45e59f",
+			Size:              0.26,
+			SizeBytes:         274302450,
+			QuantizationLevel: "F16",
+			Family:            "nomic-bert",
+			ParameterSize:     "137M",
+			Modified:          modified,
+		},
+	}
+}
+
+func TestParseOutputFormat(t *testing.T) {
+	tests := []struct {
+		value       string
+		expected    OutputFormat
+		expectError bool
+	}{
+		{value: "", expected: OutputText},
+		{value: "text", expected: OutputText},
+		{value: "JSON", expected: OutputJSON},
+		{value: "yaml", expected: OutputYAML},
+		{value: "yml", expected: OutputYAML},
+		{value: " csv ", expected: OutputCSV},
+		{value: "tsv", expected: OutputTSV},
+		{value: "xml", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			format, err := parseOutputFormat(tt.value)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("parseOutputFormat(%q) error = %v, expectError %v", tt.value, err, tt.expectError)
+			}
+			if format != tt.expected {
+				t.Errorf("parseOutputFormat(%q) = %q, expected %q", tt.value, format, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWriteModelsJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeModels(&buf, testOutputModels(), OutputJSON); err != nil {
+		t.Fatalf("writeModels() error = %v", err)
+	}
+
+	var records []modelRecord
+	if err := json.Unmarshal(buf.Bytes(), &records); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	expected := newModelRecord(testOutputModels()[0], false)
+	if records[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, records[0])
+	}
+	if !strings.Contains(buf.String(), `"size": 6596006368`) {
+		t.Errorf("expected size in bytes in output:\n%s", buf.String())
+	}
+	if strings.Contains(buf.String(), "unique_size") {
+		t.Errorf("unique size should be left out when the disk usage isn't known:\n%s", buf.String())
+	}
+}
+
+func TestWriteModelsDiskUsage(t *testing.T) {
+	models := testOutputModels()
+	models[0].UniqueSize, models[0].SharedSize = 1979711488, 4616294880
+	models[1].SharedSize = 274302450
+	models[0].DiskUsageKnown, models[1].DiskUsageKnown = true, true
+
+	var buf bytes.Buffer
+	if err := writeModels(&buf, models, OutputJSON); err != nil {
+		t.Fatalf("writeModels() error = %v", err)
+	}
+	var records []modelRecord
+	if err := json.Unmarshal(buf.Bytes(), &records); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if records[0].UniqueSize == nil || *records[0].UniqueSize != 1979711488 || *records[0].SharedSize != 4616294880 {
+		t.Errorf("unexpected sizes for %s: %+v", records[0].Name, records[0])
+	}
+	// A model with nothing unique still reports zero rather than leaving the field out
+	if records[1].UniqueSize == nil || *records[1].UniqueSize != 0 {
+		t.Errorf("expected a zero unique size for %s: %+v", records[1].Name, records[1])
+	}
+
+	buf.Reset()
+	if err := writeModels(&buf, models, OutputText); err != nil {
+		t.Fatalf("writeModels() error = %v", err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if !strings.Contains(lines[0], "Unique") || !strings.Contains(lines[1], "1.84GB") || !strings.Contains(lines[1], "4.30GB") {
+		t.Errorf("expected unique and shared columns:\n%s", buf.String())
+	}
+}
+
+func TestWriteModelsYAML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeModels(&buf, testOutputModels(), OutputYAML); err != nil {
+		t.Fatalf("writeModels() error = %v", err)
+	}
+
+	var records []modelRecord
+	if err := yaml.Unmarshal(buf.Bytes(), &records); err != nil {
+		t.Fatalf("output is not valid YAML: %v\n%s", err, buf.String())
+	}
+	if len(records) != 2 || records[1].Digest != testOutputModels()[1].Digest {
+		t.Errorf("unexpected YAML records: %+v", records)
+	}
+}
+
+func TestWriteModelsDelimited(t *testing.T) {
+	tests := []struct {
+		format OutputFormat
+		comma  rune
+	}{
+		{format: OutputCSV, comma: ','},
+		{format: OutputTSV, comma: '\t'},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeModels(&buf, testOutputModels(), tt.format); err != nil {
+				t.Fatalf("writeModels() error = %v", err)
+			}
+
+			reader := csv.NewReader(&buf)
+			reader.Comma = tt.comma
+			rows, err := reader.ReadAll()
+			if err != nil {
+				t.Fatalf("failed to read output: %v", err)
+			}
+			if len(rows) != 3 {
+				t.Fatalf("expected header and 2 rows, got %d", len(rows))
+			}
+			if strings.Join(rows[0], ",") != strings.Join(modelRecordHeader, ",") {
+				t.Errorf("unexpected header: %v", rows[0])
+			}
+			expected := []string{
+				"llama3.1:8b-instruct-q6_K",
+				"3c46ab1",
+				"3c46ab11d9a5e3e2a7c1f8a9f6a6f1d92b2c9a8b4e0d6f4a1b2c3d4e5f6a7b8c",
+				"6596006368",
+				"",
+				"",
+				"Q6_K",
+				"llama",
+				"8.0B",
+				"2025-03-14T09:26:53Z",
+			}
+			if strings.Join(rows[1], ",") != strings.Join(expected, ",") {
+				t.Errorf("expected row %v, got %v", expected, rows[1])
+			}
+		})
+	}
+}
+
+func TestWriteModelsText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeModels(&buf, testOutputModels(), OutputText); err != nil {
+		t.Fatalf("writeModels() error = %v", err)
+	}
+
+	output := buf.String()
+	if strings.Contains(output, "\x1b[") {
+		t.Errorf("plain output should not contain ANSI escape codes:\n%s", output)
+	}
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected header and 2 rows, got %d lines:\n%s", len(lines), output)
+	}
+	if !strings.HasPrefix(lines[1], "llama3.1:8b-instruct-q6_K") || !strings.Contains(lines[1], "6.14GB") {
+		t.Errorf("unexpected row: %q", lines[1])
+	}
+}
+
+func TestWriteModelsDiskUsageAllShared(t *testing.T) {
+	// A model whose blobs are all symlinked uses nothing on disk, which is still known
+	models := testOutputModels()[:1]
+	models[0].DiskUsageKnown = true
+
+	var buf bytes.Buffer
+	if err := writeModels(&buf, models, OutputJSON); err != nil {
+		t.Fatalf("writeModels() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), `"unique_size": 0`) || !strings.Contains(buf.String(), `"shared_size": 0`) {
+		t.Errorf("expected zero sizes rather than unknown ones:\n%s", buf.String())
+	}
+}
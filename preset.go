@@ -0,0 +1,229 @@
+// preset.go contains the `gollama import-preset` command, which applies LM Studio presets to Ollama models, and the
+// `gollama export-preset` command, which exports Ollama models as Open WebUI and Jan models.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ollama/ollama/api"
+
+	"github.com/mipalgu/gollama/config"
+	"github.com/mipalgu/gollama/jan"
+	"github.com/mipalgu/gollama/lmstudio"
+	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/modelfile"
+	"github.com/mipalgu/gollama/openwebui"
+	"github.com/mipalgu/gollama/styles"
+	"github.com/mipalgu/gollama/utils"
+)
+
+// runImportPresetCommand implements `gollama import-preset <preset> <model>`
+func runImportPresetCommand(cfg *config.Config, args []string) int {
+	fs := newFlagSet("import-preset", "import-preset [flags] <preset file|preset name> <model>", cfg)
+	newName := fs.String("as", "", "Create a new model with the preset's settings instead of updating the model")
+	dryRun := fs.Bool("n", false, "Show the Modelfile that would be created without changing anything (dry-run mode)")
+	fs.BoolVar(dryRun, "dry-run", false, "Show the Modelfile that would be created without changing anything (dry-run mode)")
+	if err := fs.Parse(args); err != nil {
+		return flagExitCode(err)
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	presetArg, modelName := fs.Arg(0), fs.Arg(1)
+
+	path, err := lmstudio.FindPreset(presetArg)
+	if err != nil {
+		return commandError("Error: %v", err)
+	}
+	preset, err := lmstudio.ReadLMStudioPreset(path)
+	if err != nil {
+		return commandError("Error: %v", err)
+	}
+
+	// The preset's commands with a FROM are the Modelfile Ollama applies on top of the model
+	mf := &modelfile.Modelfile{Commands: append([]modelfile.Command(nil), preset.Modelfile.Commands...)}
+	mf.SetFrom(modelName)
+	diags := mf.Lint()
+	if modelfile.HasErrors(diags) {
+		fmt.Println(formatLintDiagnostics(diags))
+		return commandError("The settings from %s aren't valid for Ollama, nothing was changed", path)
+	}
+
+	for _, skipped := range preset.Skipped {
+		fmt.Println(styles.WarningStyle().Render("Skipped " + skipped))
+	}
+	if len(preset.Modelfile.Commands) == 0 {
+		fmt.Println(styles.InfoStyle().Render(fmt.Sprintf("Preset %s has no settings Ollama can use, nothing to do", preset.Name)))
+		return 0
+	}
+
+	target := modelName
+	if *newName != "" {
+		target = *newName
+	}
+	if *dryRun {
+		fmt.Printf("[DRY RUN] Would create %s from this Modelfile:\n%s", target, mf.String())
+		return 0
+	}
+
+	client, err := newAPIClient(cfg)
+	if err != nil {
+		return commandError("Error: %v", err)
+	}
+	// Check the model exists first so a typo doesn't turn into a pull from the registry
+	if _, err := client.Show(context.Background(), &api.ShowRequest{Model: modelName}); err != nil {
+		return commandError("Error: couldn't find model %s: %v", modelName, err)
+	}
+	if err := lmstudio.ImportModelPreset(preset, modelName, *newName, client); err != nil {
+		return commandError("Error: %v", err)
+	}
+
+	settings := make([]string, 0, len(preset.Modelfile.Commands))
+	for _, cmd := range preset.Modelfile.Commands {
+		if cmd.Instruction == modelfile.Parameter {
+			settings = append(settings, cmd.Key)
+		} else {
+			settings = append(settings, string(cmd.Instruction))
+		}
+	}
+	fmt.Println(styles.SuccessStyle().Render(fmt.Sprintf("Applied preset %s to %s: %s", preset.Name, target, strings.Join(dedupe(settings), ", "))))
+	return 0
+}
+
+// dedupe removes repeated strings, keeping the first of each
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var unique []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// presetFormats are the apps export-preset writes models for, by --format
+var presetFormats = map[string]string{
+	"openwebui": "Open WebUI",
+	"jan":       "Jan",
+}
+
+// runExportPresetCommand implements `gollama export-preset`
+func runExportPresetCommand(cfg *config.Config, args []string) int {
+	fs := newFlagSet("export-preset", "export-preset --format openwebui|jan [flags] [model...]", cfg)
+	format := fs.String("format", "openwebui", "The app to export the models for, openwebui or jan")
+	output := fs.String("o", "", "Where to write the models, a file or - for stdout with openwebui (the default), a models directory with jan (jan_models_dir by default)")
+	displayName := fs.String("name", "", "The name the model is shown as, the Ollama model name by default (only with a single model)")
+	if err := fs.Parse(args); err != nil {
+		return flagExitCode(err)
+	}
+	app, ok := presetFormats[*format]
+	if !ok {
+		return commandError("Error: unknown format %q, use openwebui or jan", *format)
+	}
+	modelNames := fs.Args()
+	if *displayName != "" && len(modelNames) != 1 {
+		return commandError("Error: --name can only be used when exporting a single model")
+	}
+
+	client, err := newAPIClient(cfg)
+	if err != nil {
+		return commandError("Error: %v", err)
+	}
+	if len(modelNames) == 0 {
+		list, err := client.List(context.Background())
+		if err != nil {
+			return commandError("Error listing models: %v", err)
+		}
+		for _, model := range list.Models {
+			modelNames = append(modelNames, model.Name)
+		}
+		sort.Strings(modelNames)
+	}
+	if *format == "jan" && !utils.IsLocalhost(cfg.OllamaAPIURL) {
+		fmt.Fprintln(os.Stderr, styles.InfoStyle().Render(fmt.Sprintf("The model paths are where %s keeps its blobs", cfg.OllamaAPIURL)))
+	}
+
+	exitCode := 0
+	unmapped := make(map[string][]string)
+	var exported []string
+	var webUIModels []*openwebui.Model
+	for _, modelName := range modelNames {
+		name := modelName
+		if *displayName != "" {
+			name = *displayName
+		}
+		var modelUnmapped []string
+		switch *format {
+		case "openwebui":
+			var model *openwebui.Model
+			model, modelUnmapped, err = openwebui.ExportModel(strings.ToLower(flatLinkName(modelName)), modelName, name, client)
+			if err == nil {
+				webUIModels = append(webUIModels, model)
+			}
+		case "jan":
+			modelUnmapped, err = exportJanPreset(modelName, name, janPresetDir(cfg, *output), client)
+		}
+		if err != nil {
+			exitCode = commandError("Error exporting %s: %v", modelName, err)
+			continue
+		}
+		logging.InfoLogger.Printf("Exported %s for %s\n", modelName, app)
+		exported = append(exported, modelName)
+		if len(modelUnmapped) > 0 {
+			unmapped[modelName] = modelUnmapped
+		}
+	}
+
+	destination := janPresetDir(cfg, *output)
+	if *format == "openwebui" {
+		destination = *output
+		if destination == "" {
+			destination = "-"
+		}
+		data, err := openwebui.Marshal(webUIModels)
+		if err == nil {
+			err = writeOutput(destination, data, 0o644)
+		}
+		if err != nil {
+			return commandError("Error writing the Open WebUI models: %v", err)
+		}
+	}
+
+	// stdout may be the models, so the summary goes to stderr
+	printUnmappedParameters(os.Stderr, app, unmapped)
+	if destination != "-" && len(exported) > 0 {
+		fmt.Fprintln(os.Stderr, styles.SuccessStyle().Render(fmt.Sprintf("Exported %d models to %s", len(exported), destination)))
+	}
+	return exitCode
+}
+
+// janPresetDir is the Jan models directory export-preset writes to
+func janPresetDir(cfg *config.Config, output string) string {
+	if output != "" {
+		return output
+	}
+	if cfg.JanModelsDir != "" {
+		return cfg.JanModelsDir
+	}
+	return config.GetJanModelsDir()
+}
+
+// exportJanPreset writes a model.json that loads an Ollama model's blobs in place into its directory in modelsDir,
+// the directory it's linked into by the jan link target
+func exportJanPreset(modelName, displayName, modelsDir string, client *api.Client) ([]string, error) {
+	modelFiles, err := getModelFiles(modelName, client)
+	if err != nil {
+		return nil, fmt.Errorf("error getting model files: %v", err)
+	}
+	id := flatLinkName(modelName)
+	author, _ := linkNames(modelName)
+	return jan.ExportModelPreset(modelName, displayName, author, filepath.Join(modelsDir, id), modelFiles.MainModel, modelFiles.Projector, client)
+}
@@ -0,0 +1,152 @@
+// registry.go contains the `gollama registry serve` command, which lets other Ollama hosts pull the local models.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mipalgu/gollama/config"
+	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/ollamastore"
+	"github.com/mipalgu/gollama/registry"
+	"github.com/mipalgu/gollama/styles"
+)
+
+// runRegistryCommand implements `gollama registry serve`
+func runRegistryCommand(cfg *config.Config, args []string) int {
+	if len(args) > 0 && args[0] == "serve" {
+		return runRegistryServeCommand(cfg, args[1:])
+	}
+	fmt.Fprintln(os.Stderr, "Usage: gollama registry serve [flags]")
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+		return 0
+	}
+	return 2
+}
+
+// runRegistryServeCommand implements `gollama registry serve`, serving the local models read-only to `ollama pull`
+// until it's interrupted
+func runRegistryServeCommand(cfg *config.Config, args []string) int {
+	fs := newFlagSet("registry serve", "registry serve [flags]", cfg)
+	addr := fs.String("addr", ":5000", "The address to listen on")
+	allow := fs.String("allow", "", "Comma separated model name patterns that can be pulled, e.g. 'qwen3*,llama3.2:*', all models if empty")
+	basicAuth := fs.String("basic-auth", os.Getenv("GOLLAMA_REGISTRY_AUTH"), "user:password clients other than Ollama can authenticate with, $GOLLAMA_REGISTRY_AUTH by default")
+	authorizedKeys := fs.String("authorized-keys", "", "A file of the Ollama public keys (~/.ollama/id_ed25519.pub) of the hosts that can pull, one per line")
+	tlsCert := fs.String("tls-cert", "", "Certificate file to serve HTTPS with, so hosts can pull without --insecure")
+	tlsKey := fs.String("tls-key", "", "Key file of the --tls-cert certificate")
+	ollamaDir := addOllamaDirFlag(fs, cfg)
+	if err := fs.Parse(args); err != nil {
+		return flagExitCode(err)
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+	if (*tlsCert == "") != (*tlsKey == "") {
+		return commandError("Error: --tls-cert and --tls-key have to be given together")
+	}
+
+	server := &registry.Server{Store: ollamastore.New(*ollamaDir)}
+	if *allow != "" {
+		filter, err := newSyncFilter(strings.Split(*allow, ","), nil)
+		if err != nil {
+			return commandError("Error: %v", err)
+		}
+		server.Allow = filter.allows
+	}
+	if *basicAuth != "" {
+		username, password, ok := strings.Cut(*basicAuth, ":")
+		if !ok || username == "" || password == "" {
+			return commandError("Error: --basic-auth should be user:password")
+		}
+		server.Username, server.Password = username, password
+	}
+	if *authorizedKeys != "" {
+		data, err := os.ReadFile(*authorizedKeys)
+		if err != nil {
+			return commandError("Error reading the authorized keys: %v", err)
+		}
+		server.AuthorizedKeys = strings.Split(string(data), "\n")
+	}
+
+	models, err := server.Store.Models()
+	if err != nil {
+		return commandError("Error: %v", err)
+	}
+	var served []string
+	for _, model := range models {
+		if server.Allow == nil || server.Allow(model.Name) {
+			served = append(served, model.Name)
+		}
+	}
+	if len(served) == 0 {
+		fmt.Println(styles.WarningStyle().Render("No models match --allow, nothing can be pulled"))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return commandError("Error: %v", err)
+	}
+	httpServer := &http.Server{Handler: server, ReadHeaderTimeout: 30 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	pullHost := registryPullHost(listener.Addr())
+	pullFlags := "--insecure "
+	if *tlsCert != "" {
+		pullFlags = ""
+	}
+	fmt.Println(styles.InfoStyle().Render(fmt.Sprintf("Serving %d models from %s on %s, press Ctrl+C to stop", len(served), *ollamaDir, listener.Addr())))
+	for _, name := range served {
+		fmt.Printf("  ollama pull %s%s\n", pullFlags, registryPullName(pullHost, name))
+	}
+	if server.Username != "" && len(server.AuthorizedKeys) == 0 {
+		fmt.Println(styles.WarningStyle().Render("ollama pull can't send a username and password, add the hosts' ~/.ollama/id_ed25519.pub to --authorized-keys so they can pull"))
+	}
+	logging.InfoLogger.Printf("Serving the models in %s as a registry on %s\n", *ollamaDir, listener.Addr())
+
+	if *tlsCert != "" {
+		err = httpServer.ServeTLS(listener, *tlsCert, *tlsKey)
+	} else {
+		err = httpServer.Serve(listener)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return commandError("Error: %v", err)
+	}
+	return 0
+}
+
+// registryPullHost is the host and port other hosts pull from, the machine's hostname if it's listening on all
+// addresses
+func registryPullHost(addr net.Addr) string {
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		if hostname, err := os.Hostname(); err == nil {
+			host = hostname
+		}
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// registryPullName is the name a model is pulled from the registry as, <host>/<namespace>/<model>:<tag>
+func registryPullName(pullHost, name string) string {
+	_, namespace, model, tag := ollamastore.ParseName(name)
+	return fmt.Sprintf("%s/%s/%s:%s", pullHost, namespace, model, tag)
+}
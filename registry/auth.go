@@ -0,0 +1,150 @@
+package registry
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mipalgu/gollama/logging"
+)
+
+// maxClockSkew is how far the time in a signed token request can be from the server's
+const maxClockSkew = 10 * time.Minute
+
+// authorize checks the request's credentials, writing the challenge that tells clients how to authenticate if
+// they're missing or wrong. repo is the repository asked for, "" for the endpoints that aren't about one.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request, repo string) bool {
+	if !s.authRequired() || s.validBasicAuth(r) {
+		return true
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && s.validToken(token) {
+		return true
+	}
+
+	// Ollama reads the first challenge and asks the realm for a token, other clients pick the one they support
+	challenge := fmt.Sprintf(`Bearer realm="%s/v2/token",service="gollama"`, baseURL(r))
+	if repo != "" {
+		challenge += fmt.Sprintf(`,scope="repository:%s:pull"`, repo)
+	}
+	w.Header().Add("WWW-Authenticate", challenge)
+	if s.Username != "" {
+		w.Header().Add("WWW-Authenticate", `Basic realm="gollama"`)
+	}
+	writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "authentication required")
+	return false
+}
+
+// serveToken issues a token to clients that give the username and password, or that sign the request with one of
+// the authorized Ollama keys
+func (s *Server) serveToken(w http.ResponseWriter, r *http.Request) {
+	if !s.authRequired() {
+		writeJSON(w, map[string]any{"token": "", "access_token": ""})
+		return
+	}
+	if !s.validBasicAuth(r) {
+		if err := s.verifyOllamaSignature(r); err != nil {
+			logging.InfoLogger.Printf("Refused a registry token to %s: %v\n", r.RemoteAddr, err)
+			if s.Username != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="gollama"`)
+			}
+			writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+			return
+		}
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(tokenExpiry).Unix(), 10)
+	token := expires + "." + s.sign("token", expires)
+	writeJSON(w, map[string]any{
+		"token":        token,
+		"access_token": token,
+		"expires_in":   int(tokenExpiry.Seconds()),
+	})
+}
+
+// validToken reports whether a token was issued by serveToken and hasn't expired
+func (s *Server) validToken(token string) bool {
+	expires, signature, ok := strings.Cut(token, ".")
+	return ok && s.validSignature(signature, expires, "token")
+}
+
+// verifyOllamaSignature checks the Authorization header Ollama sends with token requests, <public key>:<signature>,
+// where the signature is of "GET,<request URL>,<base64 of the hex SHA-256 of the empty body>" made with the host's
+// ed25519 key
+func (s *Server) verifyOllamaSignature(r *http.Request) error {
+	if len(s.keys) == 0 {
+		return errors.New("no username and password given")
+	}
+	key, signature, ok := strings.Cut(r.Header.Get("Authorization"), ":")
+	if !ok || strings.Contains(key, " ") {
+		return errors.New("no username and password or Ollama key signature given")
+	}
+	if !s.keys[key] {
+		return errors.New("the Ollama key isn't authorized, add its public key to the authorized keys")
+	}
+	publicKey, err := parsePublicKey(key)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+
+	ts, err := strconv.ParseInt(r.URL.Query().Get("ts"), 10, 64)
+	if err != nil {
+		return errors.New("the token request has no time")
+	}
+	if skew := time.Since(time.Unix(ts, 0)); skew > maxClockSkew || skew < -maxClockSkew {
+		return errors.New("the token request has expired, check the clocks of both hosts")
+	}
+
+	emptySum := sha256.Sum256(nil)
+	data := fmt.Sprintf("%s,%s%s,%s", http.MethodGet, baseURL(r), r.URL.RequestURI(), base64.StdEncoding.EncodeToString([]byte(hex.EncodeToString(emptySum[:]))))
+	if !ed25519.Verify(publicKey, []byte(data), sig) {
+		return errors.New("the Ollama key signature doesn't match")
+	}
+	return nil
+}
+
+// authorizedKey returns the base64 key of a line from an Ollama public key file, "ssh-ed25519 <key> [comment]", or
+// the key itself if it's only the key
+func authorizedKey(line string) string {
+	fields := strings.Fields(line)
+	switch {
+	case len(fields) == 0 || strings.HasPrefix(fields[0], "#"):
+		return ""
+	case len(fields) == 1:
+		return fields[0]
+	}
+	return fields[1]
+}
+
+// parsePublicKey decodes an ed25519 key in the SSH wire format, a length prefixed "ssh-ed25519" followed by the
+// length prefixed key
+func parsePublicKey(key string) (ed25519.PublicKey, error) {
+	data, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	var fields [][]byte
+	for len(data) >= 4 && len(fields) < 2 {
+		n := binary.BigEndian.Uint32(data)
+		if uint64(len(data)-4) < uint64(n) {
+			break
+		}
+		fields = append(fields, data[4:4+n])
+		data = data[4+n:]
+	}
+	if len(fields) != 2 || string(fields[0]) != "ssh-ed25519" || len(fields[1]) != ed25519.PublicKeySize {
+		return nil, errors.New("the public key isn't an ed25519 key")
+	}
+	return ed25519.PublicKey(fields[1]), nil
+}
@@ -0,0 +1,377 @@
+// Package registry serves the models in an Ollama models directory read-only through the registry v2 endpoints
+// `ollama pull` uses, so other Ollama hosts can pull models from a workstation rather than having them pushed.
+package registry
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/ollamastore"
+)
+
+const (
+	// ManifestMediaType is the media type of Ollama's manifests
+	ManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+	// tokenExpiry is how long a token from the token endpoint can be used for
+	tokenExpiry = time.Hour
+	// directURLExpiry is how long the signed blob URLs handed to clients can be used for, long enough to download
+	// the biggest blobs over a slow link
+	directURLExpiry = 6 * time.Hour
+)
+
+var (
+	namePattern   = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9._-]{0,79}$`)
+	tagPattern    = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9._-]{0,79}$`)
+	digestPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+)
+
+// Server is an http.Handler serving the models in Store. Models are pulled as <host>/<namespace>/<model>:<tag>,
+// e.g. workstation:5000/library/llama3.2:latest, whichever registry they were pulled from.
+type Server struct {
+	Store *ollamastore.Store
+	// Allow reports whether a model, named as `ollama list` names it, can be pulled. Every model can be if it's nil.
+	Allow func(name string) bool
+	// Username and Password are the credentials clients can give with basic auth, auth is off if both Username and
+	// AuthorizedKeys are empty
+	Username string
+	Password string
+	// AuthorizedKeys are the Ollama public keys that can pull, the contents of a host's ~/.ollama/id_ed25519.pub.
+	// `ollama pull` can't send a username and password, it signs a token request with its key instead.
+	AuthorizedKeys []string
+
+	once   sync.Once
+	secret []byte
+	keys   map[string]bool
+}
+
+// init creates the secret tokens and signed URLs are signed with, they stop working when the server restarts
+func (s *Server) init() {
+	s.once.Do(func() {
+		s.secret = make([]byte, 32)
+		if _, err := rand.Read(s.secret); err != nil {
+			panic(fmt.Sprintf("error creating the registry secret: %v", err))
+		}
+		s.keys = make(map[string]bool)
+		for _, key := range s.AuthorizedKeys {
+			if key = authorizedKey(key); key != "" {
+				s.keys[key] = true
+			}
+		}
+	})
+}
+
+// authRequired reports whether clients have to authenticate
+func (s *Server) authRequired() bool {
+	return s.Username != "" || len(s.AuthorizedKeys) > 0
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.init()
+	logging.DebugLogger.Printf("Registry request from %s: %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "the registry is read-only")
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/v2")
+	switch {
+	case path == r.URL.Path:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "not a registry v2 endpoint")
+	case path == "" || path == "/":
+		if s.authorize(w, r, "") {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, "{}")
+		}
+	case path == "/token":
+		s.serveToken(w, r)
+	case path == "/_catalog":
+		if s.authorize(w, r, "") {
+			s.serveCatalog(w)
+		}
+	default:
+		s.serveRepository(w, r, strings.TrimPrefix(path, "/"))
+	}
+}
+
+// serveRepository serves the manifest, blob and tag list endpoints under /v2/<namespace>/<model>/
+func (s *Server) serveRepository(w http.ResponseWriter, r *http.Request, path string) {
+	parts := strings.Split(path, "/")
+	if len(parts) < 4 || !namePattern.MatchString(parts[0]) || !namePattern.MatchString(parts[1]) {
+		writeError(w, http.StatusNotFound, "NAME_INVALID", "repositories are named <namespace>/<model>")
+		return
+	}
+	repo := parts[0] + "/" + parts[1]
+	endpoint, reference := parts[2], strings.Join(parts[3:], "/")
+
+	// Blobs can be fetched with the signed URL they redirect to, without credentials
+	if endpoint == "blobs" && s.validDirectURL(r.URL.Query(), reference) {
+		s.serveBlob(w, r, repo, reference)
+		return
+	}
+	if !s.authorize(w, r, repo) {
+		return
+	}
+	switch {
+	case endpoint == "manifests":
+		s.serveManifest(w, r, repo, reference)
+	case endpoint == "blobs":
+		s.serveBlob(w, r, repo, reference)
+	case endpoint == "tags" && reference == "list":
+		s.serveTags(w, repo)
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "unknown endpoint")
+	}
+}
+
+// localManifest is a manifest of a repository in the store
+type localManifest struct {
+	name string
+	tag  string
+	data []byte
+}
+
+// digest is the manifest's digest, what the registry and the API's list endpoint identify it by
+func (m localManifest) digest() string {
+	sum := sha256.Sum256(m.data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// manifests reads the allowed manifests of a repository by tag. Models pulled from different registries can share
+// a namespace and model name, the one from Ollama's registry wins and the rest are sorted by registry.
+func (s *Server) manifests(repo string) map[string]localManifest {
+	hosts, err := os.ReadDir(s.Store.ManifestsDir())
+	if err != nil {
+		logging.ErrorLogger.Printf("Error reading manifests in %s: %v\n", s.Store.ManifestsDir(), err)
+		return nil
+	}
+	names := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		if host.IsDir() && host.Name() != ollamastore.DefaultHost {
+			names = append(names, host.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	names = append(names, ollamastore.DefaultHost)
+
+	namespace, model, _ := strings.Cut(repo, "/")
+	manifests := make(map[string]localManifest)
+	for _, host := range names {
+		dir := filepath.Join(s.Store.ManifestsDir(), host, namespace, model)
+		tags, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, tag := range tags {
+			if tag.IsDir() || !tagPattern.MatchString(tag.Name()) {
+				continue
+			}
+			name := ollamastore.ShortName(host, namespace, model, tag.Name())
+			if s.Allow != nil && !s.Allow(name) {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(dir, tag.Name()))
+			if err != nil {
+				logging.ErrorLogger.Printf("Error reading the manifest of %s: %v\n", name, err)
+				continue
+			}
+			manifests[tag.Name()] = localManifest{name: name, tag: tag.Name(), data: data}
+		}
+	}
+	return manifests
+}
+
+// serveManifest serves a manifest by tag or digest
+func (s *Server) serveManifest(w http.ResponseWriter, r *http.Request, repo, reference string) {
+	manifests := s.manifests(repo)
+	manifest, ok := manifests[reference]
+	if digestPattern.MatchString(reference) {
+		for _, m := range manifests {
+			if m.digest() == reference {
+				manifest, ok = m, true
+				break
+			}
+		}
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", fmt.Sprintf("%s:%s isn't available", repo, reference))
+		return
+	}
+
+	logging.InfoLogger.Printf("Serving the manifest of %s to %s\n", manifest.name, r.RemoteAddr)
+	w.Header().Set("Content-Type", ManifestMediaType)
+	w.Header().Set("Docker-Content-Digest", manifest.digest())
+	w.Header().Set("Content-Length", strconv.Itoa(len(manifest.data)))
+	if r.Method == http.MethodGet {
+		w.Write(manifest.data)
+	}
+}
+
+// serveBlob serves a blob referenced by one of the repository's allowed manifests, with range requests
+func (s *Server) serveBlob(w http.ResponseWriter, r *http.Request, repo, digest string) {
+	if !digestPattern.MatchString(digest) || !s.referenced(repo, digest) {
+		writeError(w, http.StatusNotFound, "BLOB_UNKNOWN", fmt.Sprintf("%s isn't a blob of %s", digest, repo))
+		return
+	}
+	file, err := os.Open(s.Store.BlobPath(digest))
+	if err != nil {
+		logging.ErrorLogger.Printf("Error opening blob %s: %v\n", digest, err)
+		writeError(w, http.StatusNotFound, "BLOB_UNKNOWN", fmt.Sprintf("%s is missing", digest))
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "UNKNOWN", err.Error())
+		return
+	}
+
+	// Ollama asks for the URL to download a blob's parts from, as its registry redirects to a CDN, and reads it
+	// from the Location header of the response. The parts are downloaded without credentials, so when auth is on
+	// the URL is signed.
+	if r.Method == http.MethodGet && r.Header.Get("Range") == "" {
+		w.Header().Set("Location", s.directURL(r, repo, digest))
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Docker-Content-Digest", digest)
+	w.Header().Set("ETag", `"`+digest+`"`)
+	http.ServeContent(w, r, "", info.ModTime(), file)
+}
+
+// referenced reports whether one of the repository's allowed manifests uses the blob
+func (s *Server) referenced(repo, digest string) bool {
+	for _, m := range s.manifests(repo) {
+		manifest, err := ollamastore.ParseManifest(m.data)
+		if err != nil {
+			continue
+		}
+		for _, layer := range manifest.Blobs() {
+			if layer.Digest == digest {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serveTags lists the repository's allowed tags
+func (s *Server) serveTags(w http.ResponseWriter, repo string) {
+	manifests := s.manifests(repo)
+	if len(manifests) == 0 {
+		writeError(w, http.StatusNotFound, "NAME_UNKNOWN", fmt.Sprintf("%s isn't available", repo))
+		return
+	}
+	tags := make([]string, 0, len(manifests))
+	for tag := range manifests {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	writeJSON(w, map[string]any{"name": repo, "tags": tags})
+}
+
+// serveCatalog lists the repositories with an allowed model
+func (s *Server) serveCatalog(w http.ResponseWriter) {
+	repos := make(map[string]bool)
+	models, err := s.Store.Models()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "UNKNOWN", err.Error())
+		return
+	}
+	for _, model := range models {
+		if s.Allow != nil && !s.Allow(model.Name) {
+			continue
+		}
+		_, namespace, name, _ := ollamastore.ParseName(model.Name)
+		repos[namespace+"/"+name] = true
+	}
+	sorted := make([]string, 0, len(repos))
+	for repo := range repos {
+		sorted = append(sorted, repo)
+	}
+	sort.Strings(sorted)
+	writeJSON(w, map[string]any{"repositories": sorted})
+}
+
+// baseURL is the URL clients reach the server at
+func baseURL(r *http.Request) string {
+	if r.TLS != nil {
+		return "https://" + r.Host
+	}
+	return "http://" + r.Host
+}
+
+// directURL returns the URL a blob's parts are downloaded from, signed when auth is on
+func (s *Server) directURL(r *http.Request, repo, digest string) string {
+	direct := fmt.Sprintf("%s/v2/%s/blobs/%s", baseURL(r), repo, digest)
+	if !s.authRequired() {
+		return direct
+	}
+	expires := strconv.FormatInt(time.Now().Add(directURLExpiry).Unix(), 10)
+	query := url.Values{"expires": {expires}, "signature": {s.sign("blob", digest, expires)}}
+	return direct + "?" + query.Encode()
+}
+
+// validDirectURL reports whether query has an unexpired signature for the blob
+func (s *Server) validDirectURL(query url.Values, digest string) bool {
+	expires, signature := query.Get("expires"), query.Get("signature")
+	if expires == "" || signature == "" {
+		return false
+	}
+	return s.validSignature(signature, expires, "blob", digest)
+}
+
+// sign signs the parts with the server's secret
+func (s *Server) sign(parts ...string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validSignature reports whether signature signs the parts followed by expires, and expires hasn't passed
+func (s *Server) validSignature(signature, expires string, parts ...string) bool {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return false
+	}
+	expected := s.sign(append(parts, expires)...)
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+// writeError writes a registry error response
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"errors": []map[string]string{{"code": code, "message": message}}})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// validBasicAuth reports whether the request has the server's username and password
+func (s *Server) validBasicAuth(r *http.Request) bool {
+	username, password, ok := r.BasicAuth()
+	if !ok || s.Username == "" {
+		return false
+	}
+	usernameOK := subtle.ConstantTimeCompare([]byte(username), []byte(s.Username)) == 1
+	passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(s.Password)) == 1
+	return usernameOK && passwordOK
+}
@@ -0,0 +1,243 @@
+package registry
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mipalgu/gollama/ollamastore"
+)
+
+// newTestStore creates a store with llama3:latest and qwen3:8b, returning the weights blob of each
+func newTestStore(t *testing.T) (*ollamastore.Store, map[string]string) {
+	t.Helper()
+	store := ollamastore.New(t.TempDir())
+	weights := make(map[string]string)
+	for _, m := range []struct{ model, tag string }{{"llama3", "latest"}, {"qwen3", "8b"}} {
+		content := strings.Repeat(m.model, 1000)
+		sum := sha256.Sum256([]byte(content))
+		digest := "sha256:" + hex.EncodeToString(sum[:])
+		if err := os.MkdirAll(store.BlobsDir(), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(store.BlobPath(digest), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := json.Marshal(ollamastore.Manifest{SchemaVersion: 2, Layers: []ollamastore.Layer{
+			{MediaType: ollamastore.MediaTypeModel, Digest: digest, Size: int64(len(content))},
+		}})
+		path := filepath.Join(store.ManifestsDir(), ollamastore.DefaultHost, "library", m.model, m.tag)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		weights[m.model] = digest
+	}
+	return store, weights
+}
+
+func get(t *testing.T, server *httptest.Server, path string, header http.Header) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, body
+}
+
+func TestServeModels(t *testing.T) {
+	store, weights := newTestStore(t)
+	server := httptest.NewServer(&Server{Store: store, Allow: func(name string) bool { return name != "qwen3:8b" }})
+	defer server.Close()
+
+	resp, manifest := get(t, server, "/v2/library/llama3/manifests/latest", nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != ManifestMediaType {
+		t.Fatalf("Expected the manifest, got %s %s", resp.Status, resp.Header.Get("Content-Type"))
+	}
+	sum := sha256.Sum256(manifest)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	if resp.Header.Get("Docker-Content-Digest") != digest {
+		t.Errorf("Expected the digest %s, got %s", digest, resp.Header.Get("Docker-Content-Digest"))
+	}
+	if resp, body := get(t, server, "/v2/library/llama3/manifests/"+digest, nil); resp.StatusCode != http.StatusOK || string(body) != string(manifest) {
+		t.Errorf("Expected the manifest by digest, got %s", resp.Status)
+	}
+
+	blob := "/v2/library/llama3/blobs/" + weights["llama3"]
+	resp, body := get(t, server, blob, nil)
+	if resp.StatusCode != http.StatusOK || len(body) != 6000 {
+		t.Errorf("Expected the blob, got %s with %d bytes", resp.Status, len(body))
+	}
+	if resp.Header.Get("Location") != server.URL+blob {
+		t.Errorf("Expected the blob's URL in the Location header, got %q", resp.Header.Get("Location"))
+	}
+	resp, body = get(t, server, blob, http.Header{"Range": {"bytes=6-11"}})
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "llama3" {
+		t.Errorf("Expected a part of the blob, got %s %q", resp.Status, body)
+	}
+
+	// qwen3 isn't allowed, and the blob of another model can't be fetched through llama3
+	for _, path := range []string{
+		"/v2/library/qwen3/manifests/8b",
+		"/v2/library/qwen3/blobs/" + weights["qwen3"],
+		"/v2/library/llama3/blobs/" + weights["qwen3"],
+		"/v2/library/llama3/manifests/8b",
+	} {
+		if resp, _ := get(t, server, path, nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected %s to be not found, got %s", path, resp.Status)
+		}
+	}
+
+	_, body = get(t, server, "/v2/_catalog", nil)
+	if strings.TrimSpace(string(body)) != `{"repositories":["library/llama3"]}` {
+		t.Errorf("Expected only llama3 in the catalog, got %s", body)
+	}
+
+	resp, err := server.Client().Post(server.URL+"/v2/library/llama3/blobs/uploads/", "application/octet-stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected pushes to be refused, got %s", resp.Status)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	store, weights := newTestStore(t)
+	server := httptest.NewServer(&Server{Store: store, Username: "user", Password: "secret"})
+	defer server.Close()
+
+	resp, _ := get(t, server, "/v2/library/llama3/manifests/latest", nil)
+	if resp.StatusCode != http.StatusUnauthorized || len(resp.Header.Values("WWW-Authenticate")) != 2 {
+		t.Fatalf("Expected a bearer and a basic challenge, got %s %v", resp.Status, resp.Header.Values("WWW-Authenticate"))
+	}
+	wrong := http.Header{"Authorization": {"Basic " + base64.StdEncoding.EncodeToString([]byte("user:wrong"))}}
+	if resp, _ := get(t, server, "/v2/library/llama3/manifests/latest", wrong); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a wrong password to be refused, got %s", resp.Status)
+	}
+	basic := http.Header{"Authorization": {"Basic " + base64.StdEncoding.EncodeToString([]byte("user:secret"))}}
+	if resp, _ := get(t, server, "/v2/library/llama3/manifests/latest", basic); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the password to be accepted, got %s", resp.Status)
+	}
+
+	// The blob's signed URL works without credentials, until it's tampered with
+	resp, _ = get(t, server, "/v2/library/llama3/blobs/"+weights["llama3"], basic)
+	location := resp.Header.Get("Location")
+	if !strings.Contains(location, "signature=") {
+		t.Fatalf("Expected a signed Location, got %q", location)
+	}
+	direct := strings.TrimPrefix(location, server.URL)
+	if resp, _ := get(t, server, direct, http.Header{"Range": {"bytes=0-5"}}); resp.StatusCode != http.StatusPartialContent {
+		t.Errorf("Expected the signed URL to work without credentials, got %s", resp.Status)
+	}
+	if resp, _ := get(t, server, strings.Replace(direct, "signature=", "signature=0", 1), nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a tampered URL to be refused, got %s", resp.Status)
+	}
+}
+
+// ollamaKey creates an ed25519 key and its public key as Ollama writes it to ~/.ollama/id_ed25519.pub
+func ollamaKey(t *testing.T) (ed25519.PrivateKey, string) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wire []byte
+	for _, field := range [][]byte{[]byte("ssh-ed25519"), public} {
+		wire = binary.BigEndian.AppendUint32(wire, uint32(len(field)))
+		wire = append(wire, field...)
+	}
+	return private, base64.StdEncoding.EncodeToString(wire)
+}
+
+// ollamaToken asks for a token the way `ollama pull` does, following the challenge and signing the request
+func ollamaToken(t *testing.T, server *httptest.Server, challenge string, private ed25519.PrivateKey, public string) (int, string) {
+	t.Helper()
+	params := make(map[string]string)
+	for _, param := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		key, value, _ := strings.Cut(param, "=")
+		params[key] = strings.Trim(value, `"`)
+	}
+	tokenURL, err := url.Parse(params["realm"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := tokenURL.Query()
+	query.Add("service", params["service"])
+	query.Add("scope", params["scope"])
+	query.Add("ts", strconv.FormatInt(time.Now().Unix(), 10))
+	query.Add("nonce", "abc")
+	tokenURL.RawQuery = query.Encode()
+
+	emptySum := sha256.Sum256(nil)
+	data := fmt.Sprintf("GET,%s,%s", tokenURL, base64.StdEncoding.EncodeToString([]byte(hex.EncodeToString(emptySum[:]))))
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(private, []byte(data)))
+
+	resp, body := get(t, server, strings.TrimPrefix(tokenURL.String(), server.URL), http.Header{"Authorization": {public + ":" + signature}})
+	var token struct {
+		Token string `json:"token"`
+	}
+	json.Unmarshal(body, &token)
+	return resp.StatusCode, token.Token
+}
+
+func TestOllamaKeyAuth(t *testing.T) {
+	store, _ := newTestStore(t)
+	private, public := ollamaKey(t)
+	otherPrivate, otherPublic := ollamaKey(t)
+	server := httptest.NewServer(&Server{Store: store, AuthorizedKeys: []string{"# gpu1", "ssh-ed25519 " + public + " gpu1", ""}})
+	defer server.Close()
+
+	resp, _ := get(t, server, "/v2/library/llama3/manifests/latest", nil)
+	challenge := resp.Header.Get("WWW-Authenticate")
+	if resp.StatusCode != http.StatusUnauthorized || !strings.Contains(challenge, `scope="repository:library/llama3:pull"`) {
+		t.Fatalf("Expected a bearer challenge, got %s %q", resp.Status, challenge)
+	}
+
+	if status, _ := ollamaToken(t, server, challenge, otherPrivate, otherPublic); status != http.StatusUnauthorized {
+		t.Errorf("Expected a key that isn't authorized to be refused, got %d", status)
+	}
+	if status, _ := ollamaToken(t, server, challenge, otherPrivate, public); status != http.StatusUnauthorized {
+		t.Errorf("Expected a signature by another key to be refused, got %d", status)
+	}
+	status, token := ollamaToken(t, server, challenge, private, public)
+	if status != http.StatusOK || token == "" {
+		t.Fatalf("Expected a token, got %d %q", status, token)
+	}
+	if resp, _ := get(t, server, "/v2/library/llama3/manifests/latest", http.Header{"Authorization": {"Bearer " + token}}); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the token to be accepted, got %s", resp.Status)
+	}
+	if resp, _ := get(t, server, "/v2/library/llama3/manifests/latest", http.Header{"Authorization": {"Bearer x" + token}}); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a tampered token to be refused, got %s", resp.Status)
+	}
+}
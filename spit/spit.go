@@ -0,0 +1,248 @@
+// Package spit copies models from an Ollama models directory to another Ollama server through its API. It only
+// uploads the weight blobs the server doesn't already have, so a copy that was interrupted carries on from the
+// blobs it hadn't finished when it's run again, then creates the model from them with /api/create.
+package spit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ollama/ollama/api"
+
+	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/ollamastore"
+)
+
+// DefaultParallel is how many blobs are uploaded at once when Copier.Parallel isn't set
+const DefaultParallel = 2
+
+// Status is what's happening to a blob or a model
+type Status string
+
+const (
+	// Skipped blobs are already on the server
+	Skipped   Status = "skipped"
+	Uploading Status = "uploading"
+	Uploaded  Status = "uploaded"
+	// Creating is reported for the model while the server creates it, with the server's status in Detail
+	Creating Status = "creating"
+	Done     Status = "done"
+)
+
+// Progress is an update on a model's copy
+type Progress struct {
+	Model  string
+	Status Status
+	// Digest is the blob the update is about, "" for updates about the model
+	Digest string
+	// BlobDone and BlobTotal are the bytes of the blob uploaded so far and its size
+	BlobDone, BlobTotal int64
+	// Done and Total are the bytes of the model's blobs that are on the server so far and their total size
+	Done, Total int64
+	// Detail is the server's status while the model is created
+	Detail string
+}
+
+// Copier copies models from Store to the Ollama server Client talks to
+type Copier struct {
+	Store  *ollamastore.Store
+	Client *api.Client
+	// URL is the server's URL, used for the blob checks the API client doesn't have
+	URL string
+	// HTTPClient makes the blob checks, http.DefaultClient if nil
+	HTTPClient *http.Client
+	// Parallel is how many blobs are uploaded at once, DefaultParallel if it's 0
+	Parallel int
+	// Progress, if not nil, is called with updates on the copy. Calls are never concurrent.
+	Progress func(Progress)
+
+	mu sync.Mutex
+}
+
+// report calls Progress, one update at a time as blobs are uploaded in parallel
+func (c *Copier) report(p Progress) {
+	if c.Progress == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Progress(p)
+}
+
+// Copy copies a model to the server under the same name
+func (c *Copier) Copy(ctx context.Context, name string) error {
+	model, err := c.Store.Model(name)
+	if err != nil {
+		return fmt.Errorf("error reading the manifest for %s: %v", name, err)
+	}
+	// The template, system prompt, parameters and config are sent in the create request rather than uploaded
+	req, err := ollamastore.CreateRequest(model.Name, model.Manifest, func(digest string) ([]byte, error) {
+		return os.ReadFile(c.Store.BlobPath(digest))
+	})
+	if err != nil {
+		return err
+	}
+
+	sizes := make(map[string]int64)
+	for _, layer := range model.Manifest.Layers {
+		sizes[layer.Digest] = layer.Size
+	}
+	// A blob can be named by more than one file, or be both a file and an adapter, but is uploaded once
+	var blobs []string
+	var total int64
+	seen := make(map[string]bool)
+	for _, files := range []map[string]string{req.Files, req.Adapters} {
+		for _, digest := range files {
+			if seen[digest] {
+				continue
+			}
+			seen[digest] = true
+			blobs = append(blobs, digest)
+			total += sizes[digest]
+		}
+	}
+	sort.Strings(blobs)
+
+	if err := c.uploadBlobs(ctx, model.Name, blobs, sizes, total); err != nil {
+		return err
+	}
+
+	c.report(Progress{Model: model.Name, Status: Creating, Done: total, Total: total})
+	err = c.Client.Create(ctx, req, func(resp api.ProgressResponse) error {
+		c.report(Progress{Model: model.Name, Status: Creating, Done: total, Total: total, Detail: resp.Status})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", model.Name, err)
+	}
+	c.report(Progress{Model: model.Name, Status: Done, Done: total, Total: total})
+	logging.InfoLogger.Printf("Copied %s to %s\n", model.Name, c.URL)
+	return nil
+}
+
+// uploadBlobs uploads the blobs the server doesn't have, Parallel at a time, stopping at the first error
+func (c *Copier) uploadBlobs(ctx context.Context, modelName string, blobs []string, sizes map[string]int64, total int64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	parallel := c.Parallel
+	if parallel < 1 {
+		parallel = DefaultParallel
+	}
+	var done int64
+	var doneMu sync.Mutex
+	// progress adds n bytes of digest to the model's progress and reports it
+	progress := func(digest string, status Status, blobDone, n int64) {
+		doneMu.Lock()
+		done += n
+		p := Progress{Model: modelName, Status: status, Digest: digest, BlobDone: blobDone, BlobTotal: sizes[digest], Done: done, Total: total}
+		doneMu.Unlock()
+		c.report(p)
+	}
+
+	jobs := make(chan string)
+	errs := make(chan error, len(blobs))
+	var wg sync.WaitGroup
+	for range parallel {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for digest := range jobs {
+				if err := c.uploadBlob(ctx, digest, sizes[digest], progress); err != nil {
+					errs <- err
+					cancel()
+				}
+			}
+		}()
+	}
+	for _, digest := range blobs {
+		select {
+		case jobs <- digest:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// uploadBlob uploads a blob unless the server already has it
+func (c *Copier) uploadBlob(ctx context.Context, digest string, size int64, progress func(digest string, status Status, blobDone, n int64)) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+	exists, err := BlobExists(ctx, c.HTTPClient, c.URL, digest)
+	if err != nil {
+		return err
+	}
+	if exists {
+		logging.DebugLogger.Printf("%s already has blob %s\n", c.URL, digest)
+		progress(digest, Skipped, size, size)
+		return nil
+	}
+
+	file, err := os.Open(c.Store.BlobPath(digest))
+	if err != nil {
+		return fmt.Errorf("error opening blob %s: %v", digest, err)
+	}
+	defer file.Close()
+	progress(digest, Uploading, 0, 0)
+	reader := &progressReader{r: file, progress: func(blobDone, n int64) { progress(digest, Uploading, blobDone, n) }}
+	if err := c.Client.CreateBlob(ctx, digest, reader); err != nil {
+		// The server only keeps whole blobs, so what was uploaded of this one counts for nothing
+		progress(digest, Uploading, 0, -reader.read)
+		return fmt.Errorf("error uploading blob %s: %v", digest, err)
+	}
+	progress(digest, Uploaded, size, 0)
+	return nil
+}
+
+// progressReader reports how much has been read from r
+type progressReader struct {
+	r        io.Reader
+	read     int64
+	progress func(read, n int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.read += int64(n)
+		p.progress(p.read, int64(n))
+	}
+	return n, err
+}
+
+// BlobExists asks the server at serverURL whether it already has a blob with HEAD /api/blobs/:digest. httpClient
+// is http.DefaultClient if nil.
+func BlobExists(ctx context.Context, httpClient *http.Client, serverURL, digest string) (bool, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, strings.TrimSuffix(serverURL, "/")+"/api/blobs/"+digest, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("error checking for blob %s: %v", digest, err)
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("error checking for blob %s: %s", digest, resp.Status)
+}
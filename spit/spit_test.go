@@ -0,0 +1,176 @@
+package spit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ollama/ollama/api"
+
+	"github.com/mipalgu/gollama/ollamastore"
+)
+
+// writeBlob writes a blob to the store and returns its layer
+func writeBlob(t *testing.T, store *ollamastore.Store, mediaType, content string) ollamastore.Layer {
+	t.Helper()
+	sum := sha256.Sum256([]byte(content))
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	if err := os.MkdirAll(store.BlobsDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(store.BlobPath(digest), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return ollamastore.Layer{MediaType: mediaType, Digest: digest, Size: int64(len(content))}
+}
+
+// writeManifest writes the manifest of a library model with layers to the store
+func writeManifest(t *testing.T, store *ollamastore.Store, name string, layers ...ollamastore.Layer) {
+	t.Helper()
+	data, _ := json.Marshal(ollamastore.Manifest{SchemaVersion: 2, Layers: layers})
+	manifestPath := filepath.Join(store.ManifestsDir(), "registry.ollama.ai", "library", name, "latest")
+	if err := os.MkdirAll(filepath.Dir(manifestPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(manifestPath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeServer is an Ollama server that keeps uploaded blobs and create requests
+type fakeServer struct {
+	mu      sync.Mutex
+	blobs   map[string]bool
+	uploads []string
+	creates []api.CreateRequest
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/api/blobs/"):
+		digest := strings.TrimPrefix(r.URL.Path, "/api/blobs/")
+		if r.Method == http.MethodHead {
+			if !f.blobs[digest] {
+				w.WriteHeader(http.StatusNotFound)
+			}
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		sum := sha256.Sum256(data)
+		if "sha256:"+hex.EncodeToString(sum[:]) != digest {
+			http.Error(w, "digest mismatch", http.StatusBadRequest)
+			return
+		}
+		f.blobs[digest] = true
+		f.uploads = append(f.uploads, digest)
+		w.WriteHeader(http.StatusCreated)
+	case r.URL.Path == "/api/create":
+		var req api.CreateRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		for _, digest := range req.Files {
+			if !f.blobs[digest] {
+				http.Error(w, fmt.Sprintf(`{"error":"missing blob %s"}`, digest), http.StatusBadRequest)
+				return
+			}
+		}
+		f.creates = append(f.creates, req)
+		json.NewEncoder(w).Encode(api.ProgressResponse{Status: "success"})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	store := ollamastore.New(t.TempDir())
+	weights := writeBlob(t, store, ollamastore.MediaTypeModel, strings.Repeat("weights", 10000))
+	projector := writeBlob(t, store, ollamastore.MediaTypeProjector, "projector")
+	template := writeBlob(t, store, ollamastore.MediaTypeTemplate, "{{ .Prompt }}")
+	writeManifest(t, store, "tiny", weights, projector, template)
+
+	// The server already has the projector, as it would after an interrupted copy
+	fake := &fakeServer{blobs: map[string]bool{projector.Digest: true}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	var updates []Progress
+	copier := &Copier{
+		Store:    store,
+		Client:   api.NewClient(serverURL, server.Client()),
+		URL:      server.URL,
+		Parallel: 2,
+		Progress: func(p Progress) { updates = append(updates, p) },
+	}
+	if err := copier.Copy(context.Background(), "tiny"); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	if len(fake.uploads) != 1 || fake.uploads[0] != weights.Digest {
+		t.Errorf("Expected only the weights to be uploaded, got %v", fake.uploads)
+	}
+	if len(fake.creates) != 1 || fake.creates[0].Model != "tiny:latest" || fake.creates[0].Template != "{{ .Prompt }}" || len(fake.creates[0].Files) != 2 {
+		t.Errorf("Unexpected create requests %+v", fake.creates)
+	}
+
+	statuses := make(map[Status]bool)
+	for _, p := range updates {
+		statuses[p.Status] = true
+		if p.Done > p.Total || p.Total != weights.Size+projector.Size {
+			t.Errorf("Progress out of range: %+v", p)
+		}
+	}
+	for _, status := range []Status{Skipped, Uploading, Uploaded, Creating, Done} {
+		if !statuses[status] {
+			t.Errorf("Expected a %s update", status)
+		}
+	}
+	if last := updates[len(updates)-1]; last.Status != Done || last.Done != last.Total {
+		t.Errorf("Expected the last update to be done, got %+v", last)
+	}
+}
+
+func TestCopySharedBlob(t *testing.T) {
+	store := ollamastore.New(t.TempDir())
+	weights := writeBlob(t, store, ollamastore.MediaTypeModel, strings.Repeat("weights", 10000))
+	adapter := weights
+	adapter.MediaType = ollamastore.MediaTypeAdapter
+	writeManifest(t, store, "shared", weights, adapter)
+
+	fake := &fakeServer{blobs: make(map[string]bool)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	var updates []Progress
+	copier := &Copier{
+		Store:    store,
+		Client:   api.NewClient(serverURL, server.Client()),
+		URL:      server.URL,
+		Parallel: 2,
+		Progress: func(p Progress) { updates = append(updates, p) },
+	}
+	if err := copier.Copy(context.Background(), "shared"); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	// A blob that's both the model and its adapter is uploaded and counted once
+	if len(fake.uploads) != 1 {
+		t.Errorf("Expected the blob to be uploaded once, got %v", fake.uploads)
+	}
+	if last := updates[len(updates)-1]; last.Total != weights.Size {
+		t.Errorf("Expected a total of %d, got %+v", weights.Size, last)
+	}
+}
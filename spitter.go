@@ -1,15 +1,25 @@
-// spitter.go contains the functions for copying Ollama models to remote hosts using the spitter package.
+// spitter.go contains the functions for copying Ollama models to remote hosts with the spit package, from the
+// command line with --spit and from the TUI.
 package main
 
 import (
+	"context"
 	"fmt"
-	"os/exec"
+	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/term"
+
+	"github.com/mipalgu/gollama/config"
 	"github.com/mipalgu/gollama/logging"
-	"github.com/sammcj/spitter/spitter"
+	"github.com/mipalgu/gollama/ollamastore"
+	"github.com/mipalgu/gollama/spit"
+	"github.com/mipalgu/gollama/styles"
 )
 
 // spitterSuccessMsg is sent when a model is successfully copied to a remote host
@@ -27,59 +37,207 @@ type spitterErrorMsg struct {
 	remoteHost string
 }
 
-// syncModelToRemote copies a model to a remote host using the spitter package
-func (m *AppModel) syncModelToRemote(modelName, remoteHost string, allModels bool) tea.Cmd {
-	logging.InfoLogger.Printf("Syncing model: %s to remote host: %s (all models: %v)\n", modelName, remoteHost, allModels)
+// spitTickMsg redraws the copy's progress in the TUI
+type spitTickMsg struct{}
 
-	return func() tea.Msg {
-		var err error
+// newSpitCopier returns a copier from the models directory to remoteHost
+func newSpitCopier(cfg *config.Config, remoteHost, ollamaModelsDir string, parallel int, progress func(spit.Progress)) (*spit.Copier, error) {
+	client, httpClient, err := remoteClient(cfg, remoteHost)
+	if err != nil {
+		return nil, err
+	}
+	return &spit.Copier{
+		Store:      ollamastore.New(ollamaModelsDir),
+		Client:     client,
+		HTTPClient: httpClient,
+		URL:        remoteHost,
+		Parallel:   parallel,
+		Progress:   progress,
+	}, nil
+}
 
-		if allModels {
-			// For all models, use the standard spitter.Sync function
-			config := spitter.SyncConfig{
-				RemoteServer:   remoteHost,
-				CustomModelDir: m.ollamaModelsDir,
-				AllModels:      true,
-			}
+// spitModels copies models to remoteHost, showing each blob as it's uploaded or skipped and the copy's progress
+// on terminals, and returns the exit code
+func spitModels(cfg *config.Config, modelNames []string, remoteHost, ollamaModelsDir string, parallel int) int {
+	reporter := &spitReporter{terminal: term.IsTerminal(int(os.Stderr.Fd()))}
+	copier, err := newSpitCopier(cfg, remoteHost, ollamaModelsDir, parallel, reporter.report)
+	if err != nil {
+		return commandError("Error: %v", err)
+	}
 
-			// If Docker container is specified in the config, use it for the Ollama command
-			if m.cfg.DockerContainer != "" && strings.ToLower(m.cfg.DockerContainer) != "false" {
-				config.OllamaCommand = fmt.Sprintf("docker exec -it %s ollama", m.cfg.DockerContainer)
-			}
+	exitCode := 0
+	for i, name := range modelNames {
+		fmt.Printf("Copying %s to %s (%d/%d)\n", name, remoteHost, i+1, len(modelNames))
+		if err := copier.Copy(context.Background(), name); err != nil {
+			reporter.clear()
+			exitCode = commandError("Error copying %s to %s: %v", name, remoteHost, err)
+			continue
+		}
+		reporter.clear()
+		fmt.Println(styles.SuccessStyle().Render(fmt.Sprintf("Copied %s to %s", name, remoteHost)))
+	}
+	return exitCode
+}
+
+// spitReporter prints a line for each blob that's uploaded or skipped and, on terminals, keeps a status line on
+// stderr with the model's progress and the blobs being uploaded
+type spitReporter struct {
+	terminal  bool
+	lastDraw  time.Time
+	uploading map[string]spit.Progress
+}
 
-			err = spitter.Sync(config)
-		} else {
-			// For a single model, use our custom sync function that handles template variables
-			err = syncSingleModelWithTemplateHandling(modelName, remoteHost, m.ollamaModelsDir, m.cfg.DockerContainer)
+func (r *spitReporter) report(p spit.Progress) {
+	if r.uploading == nil {
+		r.uploading = make(map[string]spit.Progress)
+	}
+	switch p.Status {
+	case spit.Skipped:
+		r.println(fmt.Sprintf("  %s (%s) is already on the remote host", p.Digest, formatSize(p.BlobTotal)))
+	case spit.Uploaded:
+		delete(r.uploading, p.Digest)
+		r.println(fmt.Sprintf("  Uploaded %s (%s)", p.Digest, formatSize(p.BlobTotal)))
+	case spit.Uploading:
+		r.uploading[p.Digest] = p
+	case spit.Creating:
+		if p.Detail != "" {
+			logging.DebugLogger.Printf("Creating %s on the remote host: %s\n", p.Model, p.Detail)
 		}
+	}
+	if !r.terminal || time.Since(r.lastDraw) < 200*time.Millisecond {
+		return
+	}
+	r.lastDraw = time.Now()
+	fmt.Fprintf(os.Stderr, "\r\033[K%s", spitStatus(p, r.uploading))
+}
+
+// println prints a line above the status line
+func (r *spitReporter) println(line string) {
+	r.clear()
+	fmt.Println(line)
+}
+
+// clear removes the status line
+func (r *spitReporter) clear() {
+	if r.terminal {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+	r.lastDraw = time.Time{}
+}
+
+// spitStatus describes a model's copy and the blobs being uploaded in one line
+func spitStatus(p spit.Progress, uploading map[string]spit.Progress) string {
+	if p.Status == spit.Creating || p.Status == spit.Done {
+		return fmt.Sprintf("%s: creating the model on the remote host", p.Model)
+	}
+	digests := make([]string, 0, len(uploading))
+	for digest := range uploading {
+		digests = append(digests, digest)
+	}
+	sort.Strings(digests)
+	blobs := make([]string, 0, len(digests))
+	for _, digest := range digests {
+		blob := uploading[digest]
+		blobs = append(blobs, fmt.Sprintf("%s %.0f%%", shortDigest(digest), percentage(blob.BlobDone, blob.BlobTotal)))
+	}
+	status := fmt.Sprintf("%s: %s / %s (%.0f%%)", p.Model, formatSize(p.Done), formatSize(p.Total), percentage(p.Done, p.Total))
+	if len(blobs) > 0 {
+		status += ", uploading " + strings.Join(blobs, ", ")
+	}
+	return status
+}
+
+// shortDigest shortens a digest to the 12 characters of its hash ollama shows
+func shortDigest(digest string) string {
+	hash := strings.TrimPrefix(digest, "sha256:")
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+// spitState is the progress of a copy the TUI is showing, updated by the copy as it runs
+type spitState struct {
+	mu        sync.Mutex
+	cancel    context.CancelFunc
+	model     int
+	models    int
+	latest    spit.Progress
+	uploading map[string]spit.Progress
+}
+
+func (s *spitState) report(p spit.Progress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch p.Status {
+	case spit.Uploading:
+		s.uploading[p.Digest] = p
+	case spit.Uploaded, spit.Skipped:
+		delete(s.uploading, p.Digest)
+	}
+	s.latest = p
+}
+
+// view returns the copy's progress as a fraction of the current model and a description of it
+func (s *spitState) view() (float64, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.latest.Model == "" {
+		return 0, fmt.Sprintf("Checking which blobs the remote host has (%d/%d)", s.model, s.models)
+	}
+	status := fmt.Sprintf("%s (%d/%d)", spitStatus(s.latest, s.uploading), s.model, s.models)
+	return percentage(s.latest.Done, s.latest.Total) / 100, status
+}
+
+// syncModelToRemote copies models to a remote host, reporting progress to m.spit as it goes
+func (m *AppModel) syncModelToRemote(modelNames []string, remoteHost string, allModels bool) tea.Cmd {
+	logging.InfoLogger.Printf("Syncing models: %v to remote host: %s (all models: %v)\n", modelNames, remoteHost, allModels)
+	ctx, cancel := context.WithCancel(context.Background())
+	state := &spitState{cancel: cancel, models: len(modelNames), uploading: make(map[string]spit.Progress)}
+	m.spit = state
+	m.spitting = true
+
+	return tea.Batch(spitTickCmd(), func() tea.Msg {
+		defer cancel()
+		copier, err := newSpitCopier(m.cfg, remoteHost, m.ollamaModelsDir, 0, state.report)
 		if err != nil {
-			return spitterErrorMsg{
-				err:        err,
-				modelName:  modelName,
-				remoteHost: remoteHost,
+			return spitterErrorMsg{err: err, remoteHost: remoteHost}
+		}
+		for i, name := range modelNames {
+			state.mu.Lock()
+			state.model = i + 1
+			state.latest = spit.Progress{}
+			state.mu.Unlock()
+			if err := copier.Copy(ctx, name); err != nil {
+				if ctx.Err() != nil {
+					err = ctx.Err()
+				}
+				return spitterErrorMsg{err: err, modelName: name, remoteHost: remoteHost}
 			}
 		}
 
-		// Count the number of models synced if AllModels is true
-		modelsCount := 1
-		if allModels {
-			modelsCount = len(m.models)
+		modelName := ""
+		if len(modelNames) == 1 {
+			modelName = modelNames[0]
 		}
-
 		return spitterSuccessMsg{
 			modelName:   modelName,
 			remoteHost:  remoteHost,
 			allModels:   allModels,
-			modelsCount: modelsCount,
+			modelsCount: len(modelNames),
 		}
-	}
+	})
+}
+
+func spitTickCmd() tea.Cmd {
+	return tea.Tick(200*time.Millisecond, func(time.Time) tea.Msg { return spitTickMsg{} })
 }
 
 // promptForRemoteHost prompts the user for a remote host URL
 func promptForRemoteHost() string {
 	// Create a text input for the remote host URL
 	input := textinput.New()
-	input.Placeholder = "Enter remote host URL (e.g., http://192.168.0.75:11434)"
+	input.Placeholder = "Enter remote host URL (e.g., http://192.168.0.75:11434) or name"
 	input.Focus()
 
 	// Create a simple program to handle the text input
@@ -127,113 +285,7 @@ func (m remoteHostInputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (m remoteHostInputModel) View() string {
 	return fmt.Sprintf(
-		"Enter remote host URL (e.g., http://192.168.0.75:11434):\n\n%s\n\n(Enter to confirm, Esc to cancel)",
+		"Enter remote host URL (e.g., http://192.168.0.75:11434) or name:\n\n%s\n\n(Enter to confirm, Esc to cancel)",
 		m.input.View(),
 	)
 }
-
-// getModelfileContent gets the modelfile content for a model
-func getModelfileContent(modelName, ollamaCommand string) (string, error) {
-	var cmd *exec.Cmd
-	var output []byte
-	var err error
-
-	// Try with the provided custom command if specified
-	if ollamaCommand != "" {
-		logging.InfoLogger.Printf("Using custom Ollama command: %s\n", ollamaCommand)
-		parts := strings.Fields(ollamaCommand)
-		if len(parts) == 0 {
-			return "", fmt.Errorf("invalid ollama command: %s", ollamaCommand)
-		}
-
-		args := append(parts[1:], "show", modelName, "--modelfile")
-		cmd = exec.Command(parts[0], args...)
-		output, err = cmd.CombinedOutput()
-		if err == nil {
-			return string(output), nil
-		}
-		logging.ErrorLogger.Printf("Custom command failed: %v\n", err)
-	}
-
-	// Try with the local ollama binary
-	logging.InfoLogger.Println("Trying local ollama binary...")
-	cmd = exec.Command("ollama", "show", modelName, "--modelfile")
-	output, err = cmd.CombinedOutput()
-	if err == nil {
-		return string(output), nil
-	}
-
-	// If all attempts fail, return an error
-	return "", fmt.Errorf("could not get ollama Modelfile: %w", err)
-}
-
-// processModelfile processes the modelfile to handle template variables
-func processModelfile(modelfile string) string {
-	// Split the modelfile into lines
-	lines := strings.Split(modelfile, "\n")
-	var processedLines []string
-	inTemplate := false
-
-	for _, line := range lines {
-		// Check if we're entering or exiting a template block
-		if strings.HasPrefix(strings.TrimSpace(line), "TEMPLATE") {
-			inTemplate = true
-			processedLines = append(processedLines, line)
-			continue
-		}
-		if inTemplate && (strings.Contains(line, "\"\"\"") || strings.Contains(line, "'''")) {
-			inTemplate = false
-			processedLines = append(processedLines, line)
-			continue
-		}
-
-		// If we're in a template block, escape any $ variables
-		if inTemplate {
-			// Replace $i with ${i} to properly escape it
-			line = strings.ReplaceAll(line, "$i", "${i}")
-			// Also handle other common template variables
-			line = strings.ReplaceAll(line, "$1", "${1}")
-			line = strings.ReplaceAll(line, "$2", "${2}")
-			line = strings.ReplaceAll(line, "$3", "${3}")
-		}
-
-		processedLines = append(processedLines, line)
-	}
-
-	return strings.Join(processedLines, "\n")
-}
-
-// syncSingleModelWithTemplateHandling syncs a single model to a remote host with special handling for template variables
-func syncSingleModelWithTemplateHandling(modelName, remoteHost, customModelDir, dockerContainer string) error {
-	logging.InfoLogger.Printf("Syncing model %s to remote host %s with template handling\n", modelName, remoteHost)
-
-	// Create the standard spitter configuration
-	config := spitter.SyncConfig{
-		LocalModel:     modelName,
-		RemoteServer:   remoteHost,
-		CustomModelDir: customModelDir,
-	}
-
-	// If Docker container is specified, use it for the Ollama command
-	if dockerContainer != "" && strings.ToLower(dockerContainer) != "false" {
-		config.OllamaCommand = fmt.Sprintf("docker exec -it %s ollama", dockerContainer)
-	}
-
-	// First, let's check if the model exists
-	checkCmd := exec.Command("ollama", "list")
-	checkOutput, err := checkCmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("error checking models: %w - %s", err, string(checkOutput))
-	}
-
-	if !strings.Contains(string(checkOutput), modelName) {
-		return fmt.Errorf("model %s not found locally", modelName)
-	}
-
-	logging.InfoLogger.Printf("Model %s found locally\n", modelName)
-
-	// Use the standard spitter.Sync function
-	// The spitter package will handle the model files and transfer them to the remote host
-	// It will only modify the FROM line in the modelfile, replacing it with the files line containing the SHA
-	return spitter.Sync(config)
-}
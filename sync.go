@@ -0,0 +1,401 @@
+// sync.go contains the `gollama sync` command, which links Ollama models into LM Studio or another link target,
+// optionally creates Ollama models from LM Studio downloads and, with --watch, keeps doing so as models are added,
+// updated and removed.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/ollama/ollama/api"
+
+	"github.com/mipalgu/gollama/config"
+	"github.com/mipalgu/gollama/links"
+	"github.com/mipalgu/gollama/lmstudio"
+	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/ollamastore"
+	"github.com/mipalgu/gollama/styles"
+	"github.com/mipalgu/gollama/utils"
+)
+
+// syncFilter limits syncing to the models matching an include pattern, if there are any, and no exclude pattern
+type syncFilter struct {
+	include []string
+	exclude []string
+}
+
+// newSyncFilter checks the configured patterns are valid globs
+func newSyncFilter(include, exclude []string) (syncFilter, error) {
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return syncFilter{}, fmt.Errorf("invalid sync pattern %q: %v", pattern, err)
+		}
+	}
+	return syncFilter{include: include, exclude: exclude}, nil
+}
+
+func (f syncFilter) allows(name string) bool {
+	if len(f.include) > 0 && !matchesAny(f.include, name) {
+		return false
+	}
+	return !matchesAny(f.exclude, name)
+}
+
+// matchesAny reports whether a pattern matches the model name, the name without a :latest tag or the part of the
+// name after the last /, ignoring case. `qwen3*` matches both qwen3:8b and lmstudio-community/Qwen3-8B-GGUF.
+func matchesAny(patterns []string, name string) bool {
+	name = strings.ToLower(name)
+	candidates := []string{name, strings.TrimSuffix(name, ":latest"), path.Base(name)}
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		for _, candidate := range candidates {
+			if ok, _ := path.Match(pattern, candidate); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// syncer brings a link target into line with Ollama
+type syncer struct {
+	client     *api.Client
+	ollamaHost string
+	target     linkTarget
+	// lmStudioModelsDir is where --create looks for LM Studio models, whatever the target is
+	lmStudioModelsDir string
+	strategy          links.Strategy
+	filter            syncFilter
+	create            bool
+	exportConfig      bool
+	dryRun            bool
+	// reported is the last problem logged for each model, so watching doesn't repeat it on every change
+	reported map[string]string
+}
+
+// sync links new Ollama models into the target, relinks updated ones and removes the links of deleted ones. With
+// create it also creates Ollama models from LM Studio models that aren't in Ollama. It returns the number of
+// models that couldn't be synced, an error means nothing could be.
+func (s *syncer) sync() (int, error) {
+	models, err := s.client.List(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("error listing Ollama models: %v", err)
+	}
+	stale, err := findStaleLinks(s.client)
+	if err != nil {
+		return 0, err
+	}
+
+	failed := 0
+	// Stale links in other targets are left for syncing them or `gollama links check`
+	var targetStale []staleLink
+	for _, st := range stale {
+		if st.target().Kind() == s.target.Kind() && withinDir(st.links[0].Path, s.target.Dir()) {
+			targetStale = append(targetStale, st)
+		}
+	}
+	stale = targetStale
+	for _, st := range stale {
+		if !s.filter.allows(st.Model) {
+			continue
+		}
+		if !s.relink(st) {
+			failed++
+		}
+	}
+
+	reg := loadLinkRegistry()
+	linked := make(map[string]bool)
+	imported := make(map[string]bool)
+	if reg != nil {
+		for _, link := range reg.Links() {
+			switch {
+			case link.Kind == s.target.Kind() && withinDir(link.Path, s.target.Dir()):
+				linked[link.Model] = true
+			case link.Kind == links.OllamaBlob:
+				imported[link.Source] = true
+				if link.Check() == links.Broken {
+					s.report(link.Path, "warning", fmt.Sprintf("%s was deleted from LM Studio, the Ollama model %s made from it won't load until it's removed", link.Source, link.Model))
+				}
+			}
+		}
+	}
+	for _, st := range stale {
+		linked[st.Model] = true
+	}
+
+	var export *configExport
+	if s.exportConfig {
+		export = &configExport{unmapped: make(map[string][]string)}
+	}
+	existing := make(map[string]bool)
+	for _, model := range models.Models {
+		existing[model.Name] = true
+		if linked[model.Name] || !s.filter.allows(model.Name) {
+			continue
+		}
+		if !s.link(model.Name, export) {
+			failed++
+		}
+	}
+	if export != nil {
+		printUnmappedParameters(os.Stdout, s.target.Name(), export.unmapped)
+	}
+
+	if s.create {
+		lmStudioModels, err := lmstudio.ScanUnlinkedModels(s.lmStudioModelsDir)
+		if err != nil {
+			return failed, err
+		}
+		for _, model := range lmStudioModels {
+			name := model.OllamaName()
+			source, _ := filepath.Abs(model.Path)
+			if imported[source] || existing[name] || existing[name+":latest"] || !s.filter.allows(model.Name) {
+				continue
+			}
+			if err := lmstudio.CreateOllamaModel(model, s.dryRun, s.ollamaHost, s.client); err != nil {
+				// Split models are skipped every time, so they're not failures
+				if errors.Is(err, lmstudio.ErrSplitModel) {
+					s.report(model.Name, "warning", fmt.Sprintf("Not creating an Ollama model from %s: %v", model.Path, err))
+					continue
+				}
+				s.report(model.Name, "error", fmt.Sprintf("Error creating Ollama model %s from %s: %v", name, model.Path, err))
+				failed++
+				continue
+			}
+			s.action("Created Ollama model %s from %s", name, model.Path)
+		}
+	}
+	return failed, nil
+}
+
+// link links an Ollama model into the target, skipping models that were created from LM Studio files. export
+// collects the parameters left out of the exported config, it's nil unless the config is exported.
+func (s *syncer) link(modelName string, export *configExport) bool {
+	modelFiles, err := getModelFiles(modelName, s.client)
+	if err != nil {
+		s.report(modelName, "error", fmt.Sprintf("Error getting model files for %s: %v", modelName, err))
+		return false
+	}
+	if info, err := os.Lstat(modelFiles.MainModel); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		logging.DebugLogger.Printf("Not linking %s into %s, its blob is a symlink to %s\n", modelName, s.target.Name(), modelFiles.MainModel)
+		return true
+	}
+
+	if _, err := linkModel(modelName, s.target, s.strategy, true, s.dryRun, export, s.client); err != nil {
+		s.report(modelName, "error", fmt.Sprintf("Error linking %s: %v", modelName, err))
+		return false
+	}
+	s.action("Linked %s into %s", modelName, s.target.Name())
+	return true
+}
+
+// relink relinks a model that's been updated, or removes the links of one that's been deleted
+func (s *syncer) relink(stale staleLink) bool {
+	if s.dryRun {
+		if stale.Deleted {
+			s.action("Would remove the links to %s, %s", stale.Model, stale.Reason)
+		} else {
+			s.action("Would relink %s, %s", stale.Model, stale.Reason)
+		}
+		return true
+	}
+	message, err := relinkStale(stale, nil, s.client)
+	if err != nil {
+		s.report(stale.Model, "error", fmt.Sprintf("Error relinking %s: %v", stale.Model, err))
+		return false
+	}
+	// The message already says why a deleted model's links were removed
+	if stale.Deleted {
+		s.action("%s", message)
+	} else {
+		s.action("%s, %s", message, stale.Reason)
+	}
+	return true
+}
+
+// action logs something sync has done
+func (s *syncer) action(format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	if s.dryRun {
+		message = "[DRY RUN] " + message
+	}
+	logging.InfoLogger.Println(message)
+	fmt.Printf("%s %s\n", time.Now().Format("15:04:05"), message)
+}
+
+// report logs a problem with a model unless it's the same as the last problem logged for the model
+func (s *syncer) report(key, level, message string) {
+	if s.reported[key] == message {
+		return
+	}
+	s.reported[key] = message
+	logging.ErrorLogger.Println(message)
+	style := styles.ErrorStyle()
+	if level == "warning" {
+		style = styles.WarningStyle()
+	}
+	fmt.Printf("%s %s\n", time.Now().Format("15:04:05"), style.Render(message))
+}
+
+// watch syncs whenever something changes under dirs, waiting until they've been quiet for delay so pulls and
+// downloads have finished. It returns when ctx is done.
+func (s *syncer) watch(ctx context.Context, dirs []string, delay time.Duration) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error creating file watcher: %v", err)
+	}
+	defer watcher.Close()
+	for _, dir := range dirs {
+		// The manifests directory isn't there until the first pull, so missing directories are created to be watched
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create %s: %v", dir, err)
+		}
+		if err := watchTree(watcher, dir); err != nil {
+			return err
+		}
+	}
+
+	var quiet <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			logging.DebugLogger.Printf("sync: %s\n", event)
+			// fsnotify doesn't watch subdirectories, so new ones are added as they're created
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := watchTree(watcher, event.Name); err != nil {
+						logging.ErrorLogger.Println(err)
+					}
+				}
+			}
+			quiet = time.After(delay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logging.ErrorLogger.Printf("Error watching for changes: %v\n", err)
+		case <-quiet:
+			quiet = nil
+			if _, err := s.sync(); err != nil {
+				s.report("", "error", fmt.Sprintf("Error syncing: %v", err))
+			} else {
+				delete(s.reported, "")
+			}
+		}
+	}
+}
+
+// watchTree watches dir and every directory below it
+func watchTree(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if err := watcher.Add(path); err != nil {
+			return fmt.Errorf("error watching %s: %v", path, err)
+		}
+		return nil
+	})
+}
+
+// runSyncCommand implements `gollama sync`
+func runSyncCommand(cfg *config.Config, args []string) int {
+	fs := newFlagSet("sync", "sync [flags]", cfg)
+	ollamaDir := addOllamaDirFlag(fs, cfg)
+	lmStudioDir := fs.String("lm-dir", cfg.LMStudioFilePaths, "Custom LM Studio models directory")
+	linkStrategy := fs.String("link-strategy", cfg.LinkStrategy, "How models are linked: symlink, hardlink, reflink or copy")
+	linkTargetName := fs.String("link-target", cfg.LinkTarget, "The app models are linked into: lmstudio, llamacpp or jan")
+	targetDir := fs.String("target-dir", "", "Custom models directory of the link target")
+	watch := fs.Bool("watch", false, "Keep watching the Ollama manifests, and with --create the LM Studio models, syncing whenever they change")
+	delay := fs.Duration("delay", 5*time.Second, "With --watch, how long the directories must be quiet before syncing, so pulls and downloads can finish")
+	create := fs.Bool("create", false, "Also create Ollama models from LM Studio models that aren't in Ollama")
+	exportConfig := fs.Bool("export-config", false, "Export the Modelfile config and an LM Studio preset for each model linked into LM Studio")
+	fs.BoolVar(exportConfig, "x", false, "Export the Modelfile config and an LM Studio preset for each model linked into LM Studio (alias for --export-config)")
+	dryRun := fs.Bool("n", false, "Show what would be synced without changing anything (dry-run mode)")
+	fs.BoolVar(dryRun, "dry-run", false, "Show what would be synced without changing anything (dry-run mode)")
+	if err := fs.Parse(args); err != nil {
+		return flagExitCode(err)
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+
+	if !utils.IsLocalhost(cfg.OllamaAPIURL) {
+		return commandError("Error: sync links files on disk, so it needs a local Ollama but the API is %s", cfg.OllamaAPIURL)
+	}
+	if *lmStudioDir == "" {
+		*lmStudioDir = config.GetLMStudioModelDir()
+	}
+	filter, err := newSyncFilter(cfg.SyncInclude, cfg.SyncExclude)
+	if err != nil {
+		return commandError("Error: %v", err)
+	}
+	strategy, err := links.ParseStrategy(*linkStrategy)
+	if err != nil {
+		return commandError("Error: %v", err)
+	}
+	target, err := resolveLinkTarget(*linkTargetName, *targetDir, *lmStudioDir, cfg)
+	if err != nil {
+		return commandError("Error: %v", err)
+	}
+	client, err := newAPIClient(cfg)
+	if err != nil {
+		return commandError("Error: %v", err)
+	}
+
+	s := &syncer{
+		client:            client,
+		ollamaHost:        cfg.OllamaAPIURL,
+		target:            target,
+		lmStudioModelsDir: *lmStudioDir,
+		strategy:          strategy,
+		filter:            filter,
+		create:            *create,
+		exportConfig:      *exportConfig,
+		dryRun:            *dryRun,
+		reported:          make(map[string]string),
+	}
+	failed, err := s.sync()
+	if err != nil {
+		return commandError("Error: %v", err)
+	}
+	if !*watch {
+		if failed > 0 {
+			return 1
+		}
+		return 0
+	}
+
+	manifestsDir := ollamastore.New(*ollamaDir).ManifestsDir()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// The target isn't watched as syncing writes to it, which would only start another pass
+	dirs := []string{manifestsDir}
+	if *create {
+		dirs = append(dirs, *lmStudioDir)
+	}
+	fmt.Println(styles.InfoStyle().Render(fmt.Sprintf("Watching %s for changes, press Ctrl+C to stop", strings.Join(dirs, ", "))))
+	if err := s.watch(ctx, dirs, *delay); err != nil {
+		return commandError("Error: %v", err)
+	}
+	return 0
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSyncFilter(t *testing.T) {
+	filter, err := newSyncFilter([]string{"qwen3*", "llama3"}, []string{"*:70b", "*-base"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]bool{
+		"qwen3:8b":                         true,
+		"QWEN3:8B":                         true,
+		"lmstudio-community/Qwen3-8B-GGUF": true,
+		"llama3:latest":                    true,
+		"llama3:8b":                        false,
+		"qwen3:70b":                        false,
+		"publisher/qwen3-base":             false,
+		"mistral:latest":                   false,
+	}
+	for name, want := range tests {
+		if got := filter.allows(name); got != want {
+			t.Errorf("allows(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	if !(syncFilter{}).allows("anything") {
+		t.Error("An empty filter should allow every model")
+	}
+	if _, err := newSyncFilter(nil, []string{"[unclosed"}); err == nil {
+		t.Error("Expected an error for an invalid pattern")
+	}
+}
+
+func TestSyncLinksUpdatesAndRemoves(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	blobs := filepath.Join(dir, "blobs")
+	if err := os.MkdirAll(blobs, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	blob := func(digest string) string {
+		path := filepath.Join(blobs, "sha256-"+digest)
+		if err := os.WriteFile(path, []byte(digest), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// The models in Ollama and their blobs, changed as the test goes
+	qwenBlob := blob("qwen")
+	host := newFakeShowHost(t, map[string]string{"qwen3:8b": "FROM " + qwenBlob + "\n", "llama3:latest": "FROM " + blob("llama") + "\n"})
+
+	lmStudioDir := filepath.Join(dir, "lmstudio")
+	filter, _ := newSyncFilter(nil, []string{"llama*"})
+	s := &syncer{
+		client:            host.client,
+		target:            lmStudioTarget{dir: lmStudioDir},
+		lmStudioModelsDir: lmStudioDir,
+		filter:            filter,
+		reported:          make(map[string]string),
+	}
+	link := filepath.Join(lmStudioDir, "registry.ollama.ai", "qwen3-8b", "qwen3-8b.gguf")
+	runSync := func() {
+		t.Helper()
+		if failed, err := s.sync(); err != nil || failed != 0 {
+			t.Fatalf("sync failed: %d, %v", failed, err)
+		}
+	}
+	target := func() string {
+		t.Helper()
+		target, err := os.Readlink(link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return target
+	}
+
+	// New models are linked unless they're excluded
+	runSync()
+	if target() != qwenBlob {
+		t.Errorf("Expected %s to link to %s", link, qwenBlob)
+	}
+	if _, err := os.Stat(filepath.Join(lmStudioDir, "registry.ollama.ai", "llama3")); !os.IsNotExist(err) {
+		t.Error("The excluded model was linked")
+	}
+
+	// A re-pulled model is relinked to its new blob
+	qwenBlob = blob("qwen-updated")
+	host.setModelfile("qwen3:8b", "FROM "+qwenBlob+"\n")
+	runSync()
+	if target() != qwenBlob {
+		t.Errorf("Expected the link to be updated to %s, got %s", qwenBlob, target())
+	}
+
+	// A deleted model's link is removed
+	host.setModelfile("qwen3:8b", "")
+	runSync()
+	if _, err := os.Lstat(link); !os.IsNotExist(err) {
+		t.Error("The deleted model's link is still there")
+	}
+}
+
+func TestWatchCreatesMissingDirectories(t *testing.T) {
+	manifestsDir := filepath.Join(t.TempDir(), "models", "manifests")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := &syncer{reported: make(map[string]string)}
+	if err := s.watch(ctx, []string{manifestsDir}, time.Second); err != nil {
+		t.Fatalf("watch failed: %v", err)
+	}
+	if info, err := os.Stat(manifestsDir); err != nil || !info.IsDir() {
+		t.Errorf("Expected %s to be created, got %v", manifestsDir, err)
+	}
+}
@@ -0,0 +1,339 @@
+// targets.go contains the apps Ollama models can be linked into: LM Studio, a flat llama.cpp models directory and Jan.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ollama/ollama/api"
+	ollama_model "github.com/ollama/ollama/types/model"
+
+	"github.com/mipalgu/gollama/config"
+	"github.com/mipalgu/gollama/jan"
+	"github.com/mipalgu/gollama/links"
+	"github.com/mipalgu/gollama/lmstudio"
+	"github.com/mipalgu/gollama/logging"
+)
+
+// linkTarget is an app Ollama models are linked into. It decides where a model's files are linked, writes the
+// config the app reads alongside them and knows what to clean up when they're removed.
+type linkTarget interface {
+	// Kind names the target in the config and on the command line, and is the kind its links are recorded with
+	Kind() links.Kind
+	// Name is the app's name in messages
+	Name() string
+	// Dir is the models directory the target links into
+	Dir() string
+	// Paths returns where the model's main file and projector are linked, projector is "" if files has none
+	Paths(modelName string, files ModelFiles) (main, projector string)
+	// WriteSidecar writes the config the app reads alongside a linked model. export is nil unless the config was
+	// asked for, targets that can't load a model without one write it regardless. Dry runs return what they would
+	// have written.
+	WriteSidecar(modelName, mainPath, projPath string, export *configExport, dryRun bool, client *api.Client) (string, error)
+	// Remove removes a link, and the sidecars and directories that were only there for it
+	Remove(path string) error
+	// CleanBroken removes broken links gollama made that the stale link check can't relink
+	CleanBroken()
+	// RemoveAll removes every link gollama made in the target (--cleanup)
+	RemoveAll()
+}
+
+// linkTargetKinds are the targets --link-target accepts
+var linkTargetKinds = []links.Kind{links.LMStudio, links.LlamaCpp, links.Jan}
+
+// parseLinkTarget returns the target kind with the given name, an empty name is LM Studio
+func parseLinkTarget(name string) (links.Kind, error) {
+	if name == "" {
+		return links.LMStudio, nil
+	}
+	for _, kind := range linkTargetKinds {
+		if strings.EqualFold(name, string(kind)) {
+			return kind, nil
+		}
+	}
+	return "", fmt.Errorf("unknown link target %q, expected one of lmstudio, llamacpp or jan", name)
+}
+
+// resolveLinkTarget returns the named target linking into dir. If dir is empty LM Studio links into
+// lmStudioModelsDir and the other targets into their directory in cfg.
+func resolveLinkTarget(name, dir, lmStudioModelsDir string, cfg *config.Config) (linkTarget, error) {
+	kind, err := parseLinkTarget(name)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case links.LlamaCpp:
+		if dir == "" {
+			dir = cfg.LlamaCppModelsDir
+		}
+		if dir == "" {
+			return nil, fmt.Errorf("no llama.cpp models directory, set llamacpp_models_dir in the config or use --target-dir")
+		}
+		return llamaCppTarget{dir: dir}, nil
+	case links.Jan:
+		if dir == "" {
+			dir = cfg.JanModelsDir
+		}
+		if dir == "" {
+			dir = config.GetJanModelsDir()
+		}
+		return janTarget{dir: dir}, nil
+	}
+	if dir == "" {
+		dir = lmStudioModelsDir
+	}
+	if dir == "" {
+		dir = config.GetLMStudioModelDir()
+	}
+	return lmStudioTarget{dir: dir}, nil
+}
+
+// linkTargetOf returns the target a recorded link was made in, working its models directory out from the link's path
+func linkTargetOf(link links.Link) linkTarget {
+	switch link.Kind {
+	case links.LlamaCpp:
+		return llamaCppTarget{dir: filepath.Dir(link.Path)}
+	case links.Jan:
+		return janTarget{dir: filepath.Dir(filepath.Dir(link.Path))}
+	}
+	return lmStudioTarget{dir: filepath.Dir(filepath.Dir(filepath.Dir(link.Path)))}
+}
+
+// linkNames returns the author and the name a model's files are linked as, e.g.:
+// * "fleo/tiny-r1-32b-preview:latest" -> "registry.ollama.ai/fleo/tiny-r1-32b-preview" -> "fleo", "tiny-r1-32b-preview"
+// * "huggingface.co/fleo/tiny-r1-32b-preview" -> "fleo", "tiny-r1-32b-preview"
+// * "qwen3:8b" -> "registry.ollama.ai/library/qwen3:8b" -> "registry.ollama.ai", "qwen3-8b" (Use host as author if the
+// namespace is default `library`, the model tag is appended to the name if it's not the default `latest` tag)
+func linkNames(modelName string) (author, name string) {
+	fullModelName := ollama_model.ParseName(modelName)
+	author = fullModelName.Namespace
+	if author == ollama_model.DefaultName().Namespace {
+		author = fullModelName.Host
+	}
+	name = fullModelName.Model
+	if fullModelName.Tag != ollama_model.DefaultName().Tag {
+		name += "-" + fullModelName.Tag
+	}
+	return author, name
+}
+
+// flatLinkName is the name a model is linked as in a directory without an author level, the author is prefixed
+// unless the model is from the default `library` namespace so models with the same name don't collide
+func flatLinkName(modelName string) string {
+	author, name := linkNames(modelName)
+	if ollama_model.ParseName(modelName).Namespace == ollama_model.DefaultName().Namespace {
+		return name
+	}
+	return author + "-" + name
+}
+
+// projectorFileName is the file name a model's projector is linked as, it's always an mmproj- file so apps that
+// look for projectors by name find it
+func projectorFileName(projector, name string) string {
+	// Keep the original extension if the projector's already named as one
+	if strings.Contains(strings.ToLower(filepath.Base(projector)), "mmproj") && filepath.Ext(projector) != "" {
+		return "mmproj-" + name + filepath.Ext(projector)
+	}
+	return "mmproj-" + name + ".gguf"
+}
+
+// removeRecordedLinks removes every recorded link in the target, for targets gollama has always recorded its links in
+func removeRecordedLinks(target linkTarget) {
+	reg := loadLinkRegistry()
+	if reg == nil {
+		return
+	}
+	var removed []string
+	for _, link := range reg.Links() {
+		if link.Kind != target.Kind() || !withinDir(link.Path, target.Dir()) {
+			continue
+		}
+		if status := link.Check(); status != links.OK && status != links.Broken {
+			continue
+		}
+		logging.InfoLogger.Printf("Removing gollama-created %s for %s: %s\n", link.Strategy, link.Model, link.Path)
+		if err := target.Remove(link.Path); err != nil {
+			logging.ErrorLogger.Printf("Error removing link %s: %v\n", link.Path, err)
+			continue
+		}
+		removed = append(removed, link.Path)
+	}
+	reconcileLinks(target.Dir(), removed)
+}
+
+// lmStudioTarget links models into LM Studio's models directory as <dir>/<author>/<model>/<model>.gguf, with the
+// Modelfile config and an LM Studio preset exported alongside when asked for
+type lmStudioTarget struct {
+	dir string
+}
+
+func (t lmStudioTarget) Kind() links.Kind { return links.LMStudio }
+func (t lmStudioTarget) Name() string     { return "LM Studio" }
+func (t lmStudioTarget) Dir() string      { return t.dir }
+
+func (t lmStudioTarget) Paths(modelName string, files ModelFiles) (string, string) {
+	author, name := linkNames(modelName)
+	modelDir := filepath.Join(t.dir, author, name)
+	var projector string
+	if files.Projector != "" {
+		projector = filepath.Join(modelDir, projectorFileName(files.Projector, name))
+	}
+	return filepath.Join(modelDir, name+".gguf"), projector
+}
+
+func (t lmStudioTarget) WriteSidecar(modelName, mainPath, projPath string, export *configExport, dryRun bool, client *api.Client) (string, error) {
+	if export == nil {
+		return "", nil
+	}
+	_, name := linkNames(modelName)
+	configPath := strings.TrimSuffix(mainPath, ".gguf") + ".config.json"
+	if dryRun {
+		message := fmt.Sprintf("[DRY RUN] Would export Modelfile config to %s", configPath)
+		logging.InfoLogger.Println(message)
+		logging.InfoLogger.Printf("[DRY RUN] Would export preset to ~/.lmstudio/config-presets/%s.preset.json\n", name)
+		return message, nil
+	}
+
+	// Export sidecar config file
+	err := lmstudio.ExportModelConfig(modelName, configPath, client)
+	if err != nil {
+		logging.ErrorLogger.Printf("Warning: Failed to export config for %s: %v\n", modelName, err)
+		// Don't fail entire operation for config export errors
+	} else {
+		logging.InfoLogger.Printf("Exported configuration to %s\n", configPath)
+	}
+
+	// Export preset file for LM Studio to discover
+	unmapped, err := lmstudio.ExportModelPreset(modelName, name, client)
+	if err != nil {
+		logging.ErrorLogger.Printf("Warning: Failed to export preset for %s: %v\n", modelName, err)
+		// Don't fail entire operation for preset export errors
+	} else {
+		logging.InfoLogger.Printf("Exported preset to ~/.lmstudio/config-presets/%s.preset.json\n", name)
+		if len(unmapped) > 0 {
+			logging.InfoLogger.Printf("Parameters of %s with no LM Studio equivalent: %v\n", modelName, unmapped)
+			export.unmapped[modelName] = unmapped
+		}
+	}
+	return "", nil
+}
+
+// Remove removes the link and the model's directory if that leaves it empty
+func (t lmStudioTarget) Remove(path string) error {
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	os.Remove(filepath.Dir(path))
+	return nil
+}
+
+// CleanBroken removes broken symlinks gollama made before the link registry existed, and empty directories
+func (t lmStudioTarget) CleanBroken() { cleanBrokenSymlinks(t.dir) }
+
+func (t lmStudioTarget) RemoveAll() { cleanupSymlinkedModels(t.dir) }
+
+// llamaCppTarget links models into a flat directory as <dir>/<model>.gguf, the layout llama.cpp's llama-server
+// --models-dir and koboldcpp load models from. They take their settings on the command line, so there's no sidecar.
+type llamaCppTarget struct {
+	dir string
+}
+
+func (t llamaCppTarget) Kind() links.Kind { return links.LlamaCpp }
+func (t llamaCppTarget) Name() string     { return "llama.cpp" }
+func (t llamaCppTarget) Dir() string      { return t.dir }
+
+func (t llamaCppTarget) Paths(modelName string, files ModelFiles) (string, string) {
+	name := flatLinkName(modelName)
+	var projector string
+	if files.Projector != "" {
+		projector = filepath.Join(t.dir, projectorFileName(files.Projector, name))
+	}
+	return filepath.Join(t.dir, name+".gguf"), projector
+}
+
+func (t llamaCppTarget) WriteSidecar(string, string, string, *configExport, bool, *api.Client) (string, error) {
+	return "", nil
+}
+
+func (t llamaCppTarget) Remove(path string) error { return os.Remove(path) }
+
+// CleanBroken leaves broken links alone, they're all recorded so the stale link check relinks them. The directory
+// is shared with files that aren't gollama's, so nothing else in it is touched.
+func (t llamaCppTarget) CleanBroken() {}
+
+func (t llamaCppTarget) RemoveAll() { removeRecordedLinks(t) }
+
+// janTarget links models into Jan's models directory as <dir>/<id>/<id>.gguf, with the model.json Jan needs to
+// find the model
+type janTarget struct {
+	dir string
+}
+
+func (t janTarget) Kind() links.Kind { return links.Jan }
+func (t janTarget) Name() string     { return "Jan" }
+func (t janTarget) Dir() string      { return t.dir }
+
+func (t janTarget) Paths(modelName string, files ModelFiles) (string, string) {
+	id := flatLinkName(modelName)
+	modelDir := filepath.Join(t.dir, id)
+	var projector string
+	if files.Projector != "" {
+		projector = filepath.Join(modelDir, projectorFileName(files.Projector, id))
+	}
+	return filepath.Join(modelDir, id+".gguf"), projector
+}
+
+// WriteSidecar writes the model's model.json whether or not the config was asked for, Jan doesn't list models
+// without one
+func (t janTarget) WriteSidecar(modelName, mainPath, projPath string, export *configExport, dryRun bool, client *api.Client) (string, error) {
+	modelDir := filepath.Dir(mainPath)
+	modelJSON := filepath.Join(modelDir, jan.ModelFileName)
+	if dryRun {
+		message := fmt.Sprintf("[DRY RUN] Would write %s", modelJSON)
+		logging.InfoLogger.Println(message)
+		return message, nil
+	}
+
+	var projector string
+	if projPath != "" {
+		projector = filepath.Base(projPath)
+	}
+	author, _ := linkNames(modelName)
+	unmapped, err := jan.ExportModel(modelName, author, modelDir, filepath.Base(mainPath), projector, client)
+	if err != nil {
+		return "", fmt.Errorf("failed to write %s for %s: %v", modelJSON, modelName, err)
+	}
+	logging.InfoLogger.Printf("Wrote %s\n", modelJSON)
+	if len(unmapped) > 0 && export != nil {
+		logging.InfoLogger.Printf("Parameters of %s with no Jan equivalent: %v\n", modelName, unmapped)
+		export.unmapped[modelName] = unmapped
+	}
+	return "", nil
+}
+
+// Remove removes the link, and the model's model.json and directory once none of its links are left
+func (t janTarget) Remove(path string) error {
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	modelDir := filepath.Dir(path)
+	entries, err := os.ReadDir(modelDir)
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		if entry.Name() != jan.ModelFileName {
+			return nil
+		}
+	}
+	os.Remove(filepath.Join(modelDir, jan.ModelFileName))
+	os.Remove(modelDir)
+	return nil
+}
+
+// CleanBroken leaves broken links alone, they're all recorded so the stale link check relinks them
+func (t janTarget) CleanBroken() {}
+
+func (t janTarget) RemoveAll() { removeRecordedLinks(t) }
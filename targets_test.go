@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mipalgu/gollama/config"
+	"github.com/mipalgu/gollama/jan"
+	"github.com/mipalgu/gollama/links"
+)
+
+func TestLinkTargetPaths(t *testing.T) {
+	files := ModelFiles{MainModel: "/blobs/sha256-abc", Projector: "/blobs/sha256-def"}
+	tests := []struct {
+		target          linkTarget
+		model           string
+		main, projector string
+	}{
+		{lmStudioTarget{dir: "/lms"}, "qwen3:8b", "/lms/registry.ollama.ai/qwen3-8b/qwen3-8b.gguf", "/lms/registry.ollama.ai/qwen3-8b/mmproj-qwen3-8b.gguf"},
+		{lmStudioTarget{dir: "/lms"}, "fleo/tiny:latest", "/lms/fleo/tiny/tiny.gguf", "/lms/fleo/tiny/mmproj-tiny.gguf"},
+		{llamaCppTarget{dir: "/models"}, "qwen3:8b", "/models/qwen3-8b.gguf", "/models/mmproj-qwen3-8b.gguf"},
+		{llamaCppTarget{dir: "/models"}, "hf.co/bartowski/Phi-4:Q4_K_M", "/models/bartowski-Phi-4-Q4_K_M.gguf", "/models/mmproj-bartowski-Phi-4-Q4_K_M.gguf"},
+		{janTarget{dir: "/jan/models"}, "llama3:latest", "/jan/models/llama3/llama3.gguf", "/jan/models/llama3/mmproj-llama3.gguf"},
+	}
+	for _, test := range tests {
+		main, projector := test.target.Paths(test.model, files)
+		if main != filepath.FromSlash(test.main) || projector != filepath.FromSlash(test.projector) {
+			t.Errorf("%s Paths(%s) = %s, %s, want %s, %s", test.target.Name(), test.model, main, projector, test.main, test.projector)
+		}
+		// The target is worked out again from the links it records
+		link := links.Link{Kind: test.target.Kind(), Path: main}
+		if got := linkTargetOf(link); got != test.target {
+			t.Errorf("linkTargetOf(%s) = %+v, want %+v", main, got, test.target)
+		}
+	}
+
+	if main, projector := (janTarget{dir: "/jan"}).Paths("llama3", ModelFiles{MainModel: "/blobs/sha256-abc"}); main == "" || projector != "" {
+		t.Errorf("Expected no projector path for a model without one, got %s, %s", main, projector)
+	}
+}
+
+func TestResolveLinkTarget(t *testing.T) {
+	cfg := &config.Config{JanModelsDir: "/jan/models"}
+	if target, err := resolveLinkTarget("", "", "/lms", cfg); err != nil || target != (lmStudioTarget{dir: "/lms"}) {
+		t.Errorf("Expected LM Studio by default, got %+v, %v", target, err)
+	}
+	if target, err := resolveLinkTarget("Jan", "", "/lms", cfg); err != nil || target != (janTarget{dir: "/jan/models"}) {
+		t.Errorf("Expected Jan's directory from the config, got %+v, %v", target, err)
+	}
+	if target, err := resolveLinkTarget("llamacpp", "/models", "/lms", cfg); err != nil || target != (llamaCppTarget{dir: "/models"}) {
+		t.Errorf("Expected --target-dir to be used, got %+v, %v", target, err)
+	}
+	if _, err := resolveLinkTarget("llamacpp", "", "/lms", cfg); err == nil {
+		t.Error("Expected an error for llama.cpp without a models directory")
+	}
+	if _, err := resolveLinkTarget("koboldcpp", "", "/lms", cfg); err == nil {
+		t.Error("Expected an error for an unknown target")
+	}
+}
+
+func TestLinkModelIntoJan(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	model, projector := filepath.Join(dir, "sha256-model"), filepath.Join(dir, "sha256-projector")
+	for _, path := range []string{model, projector} {
+		if err := os.WriteFile(path, []byte(filepath.Base(path)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := newFakeShowHost(t, map[string]string{
+		"qwen2.5vl:7b": "FROM " + model + "\nFROM " + projector + "\nPARAMETER num_ctx 8192\nPARAMETER temperature 0.6\nPARAMETER stop <|im_end|>\nPARAMETER mirostat 2\n",
+	}).client
+
+	target := janTarget{dir: filepath.Join(dir, "jan", "models")}
+	export := &configExport{unmapped: make(map[string][]string)}
+	if _, err := linkModel("qwen2.5vl:7b", target, links.Symlink, false, false, export, client); err != nil {
+		t.Fatalf("linkModel failed: %v", err)
+	}
+
+	modelDir := filepath.Join(target.dir, "qwen2.5vl-7b")
+	if source, err := os.Readlink(filepath.Join(modelDir, "qwen2.5vl-7b.gguf")); err != nil || source != model {
+		t.Errorf("Expected the model to be linked to %s, got %s, %v", model, source, err)
+	}
+	data, err := os.ReadFile(filepath.Join(modelDir, jan.ModelFileName))
+	if err != nil {
+		t.Fatalf("Expected model.json to be written: %v", err)
+	}
+	var written jan.Model
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatal(err)
+	}
+	if written.ID != "qwen2.5vl-7b" || written.Settings.LlamaModelPath != "qwen2.5vl-7b.gguf" || written.Settings.Mmproj != "mmproj-qwen2.5vl-7b.gguf" || written.Settings.CtxLen != 8192 {
+		t.Errorf("Unexpected model.json %+v", written)
+	}
+	if got := export.unmapped["qwen2.5vl:7b"]; len(got) != 1 || got[0] != "mirostat" {
+		t.Errorf("Expected mirostat to be reported as unmapped, got %v", got)
+	}
+
+	reg, err := links.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := reg.Links()
+	if len(recorded) != 2 || recorded[0].Kind != links.Jan || recorded[1].Kind != links.Jan {
+		t.Errorf("Expected the model and projector links to be recorded as Jan links, got %+v", recorded)
+	}
+
+	// Removing the links removes model.json and the model's directory with them
+	target.RemoveAll()
+	if _, err := os.Stat(modelDir); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed", modelDir)
+	}
+	if reg, err := links.Load(); err != nil || len(reg.Links()) != 0 {
+		t.Errorf("Expected the links to be forgotten, got %+v, %v", reg, err)
+	}
+}
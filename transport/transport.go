@@ -0,0 +1,105 @@
+// Package transport creates the HTTP clients used to talk to Ollama APIs, so ones behind a reverse proxy can be
+// reached with an API key, custom headers, a private CA, client certificates, a proxy and a timeout.
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/mipalgu/gollama/config"
+)
+
+// NewHTTPClient creates an HTTP client that connects with settings. The timeout limits how long connecting and
+// waiting for a response take rather than the whole request, so streamed pulls and uploads of large blobs aren't
+// cut off.
+func NewHTTPClient(settings config.Transport) (*http.Client, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	if settings.CACert != "" || settings.ClientCert != "" || settings.ClientKey != "" {
+		tlsConfig, err := tlsConfig(settings)
+		if err != nil {
+			return nil, err
+		}
+		t.TLSClientConfig = tlsConfig
+	}
+
+	if settings.Proxy != "" {
+		proxyURL, err := url.Parse(settings.Proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", settings.Proxy)
+		}
+		t.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if settings.Timeout != "" {
+		timeout, err := time.ParseDuration(settings.Timeout)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid request timeout %q, expected a duration such as 30s", settings.Timeout)
+		}
+		t.DialContext = (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext
+		t.TLSHandshakeTimeout = timeout
+		t.ResponseHeaderTimeout = timeout
+	}
+
+	var rt http.RoundTripper = t
+	if settings.APIKey != "" || len(settings.Headers) > 0 {
+		rt = &headerTransport{base: t, apiKey: settings.APIKey, headers: settings.Headers}
+	}
+	return &http.Client{Transport: rt}, nil
+}
+
+// tlsConfig trusts the CA bundle as well as the system's CAs and presents the client certificate
+func tlsConfig(settings config.Transport) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if settings.CACert != "" {
+		pem, err := os.ReadFile(settings.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", settings.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if settings.ClientCert != "" || settings.ClientKey != "" {
+		if settings.ClientCert == "" || settings.ClientKey == "" {
+			return nil, fmt.Errorf("both a client certificate and its key are needed for mutual TLS")
+		}
+		cert, err := tls.LoadX509KeyPair(settings.ClientCert, settings.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// headerTransport adds the custom headers and the API key to each request
+type headerTransport struct {
+	base    http.RoundTripper
+	apiKey  string
+	headers map[string]string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrip mustn't change the caller's request
+	req = req.Clone(req.Context())
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	// Ollama's client signs requests to ollama.com itself, which takes precedence over the API key
+	if t.apiKey != "" && req.Header.Get("Authorization") == "" {
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,213 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mipalgu/gollama/config"
+)
+
+func get(t *testing.T, client *http.Client, url string, header http.Header) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := client.Do(req)
+	if err == nil {
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func TestHeaders(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	client, err := NewHTTPClient(config.Transport{APIKey: "secret", Headers: map[string]string{"X-Team": "ml"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := get(t, client, server.URL+"/api/tags", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got.Get("Authorization") != "Bearer secret" || got.Get("X-Team") != "ml" {
+		t.Errorf("Expected the API key and the custom header, got %v", got)
+	}
+
+	// An Authorization header set by the caller, as Ollama's client does for ollama.com, is kept
+	if _, err := get(t, client, server.URL+"/api/tags", http.Header{"Authorization": {"signed"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Get("Authorization") != "signed" {
+		t.Errorf("Expected the caller's Authorization header, got %q", got.Get("Authorization"))
+	}
+
+	// A custom Authorization header takes the place of the API key
+	client, err = NewHTTPClient(config.Transport{APIKey: "secret", Headers: map[string]string{"Authorization": "Basic abc"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := get(t, client, server.URL+"/api/tags", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got.Get("Authorization") != "Basic abc" {
+		t.Errorf("Expected the custom Authorization header, got %q", got.Get("Authorization"))
+	}
+}
+
+// writePEM writes a PEM block to a file in dir and returns its path
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// clientCert creates a self-signed client certificate, returning it and the paths of its certificate and key
+func clientCert(t *testing.T, dir string) (*x509.Certificate, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gollama"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, writePEM(t, dir, "client.pem", "CERTIFICATE", der), writePEM(t, dir, "client-key.pem", "EC PRIVATE KEY", keyDER)
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	cert, certPath, keyPath := clientCert(t, dir)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+	caPath := writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	for _, tt := range []struct {
+		name     string
+		settings config.Transport
+		ok       bool
+	}{
+		{name: "Untrusted server", settings: config.Transport{ClientCert: certPath, ClientKey: keyPath}},
+		{name: "No client certificate", settings: config.Transport{CACert: caPath}},
+		{name: "CA bundle and client certificate", settings: config.Transport{CACert: caPath, ClientCert: certPath, ClientKey: keyPath}, ok: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewHTTPClient(tt.settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = get(t, client, server.URL, nil)
+			if (err == nil) != tt.ok {
+				t.Errorf("Expected success %v, got %v", tt.ok, err)
+			}
+		})
+	}
+}
+
+func TestProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	client, err := NewHTTPClient(config.Transport{Proxy: proxy.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := get(t, client, "http://ollama.invalid:11434/api/tags", nil); err != nil {
+		t.Fatal(err)
+	}
+	if proxied != "http://ollama.invalid:11434/api/tags" {
+		t.Errorf("Expected the request to go through the proxy, got %q", proxied)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client, err := NewHTTPClient(config.Transport{Timeout: "50ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := get(t, client, server.URL, nil); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Errorf("Expected the request to time out, got %v", err)
+	}
+}
+
+func TestInvalidSettings(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, certPath, _ := clientCert(t, dir)
+
+	for _, tt := range []struct {
+		name     string
+		settings config.Transport
+		err      string
+	}{
+		{name: "Timeout", settings: config.Transport{Timeout: "30"}, err: "invalid request timeout"},
+		{name: "Proxy", settings: config.Transport{Proxy: "proxy:3128"}, err: "invalid proxy URL"},
+		{name: "Missing CA bundle", settings: config.Transport{CACert: filepath.Join(dir, "missing.pem")}, err: "failed to read CA bundle"},
+		{name: "Empty CA bundle", settings: config.Transport{CACert: notPEM}, err: "no certificates found"},
+		{name: "Client certificate without key", settings: config.Transport{ClientCert: certPath}, err: "both a client certificate and its key"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewHTTPClient(tt.settings); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+// verify.go contains the `gollama verify` command, which re-hashes the blobs in the Ollama models directory to find corruption.
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mipalgu/gollama/config"
+	"github.com/mipalgu/gollama/logging"
+	"github.com/mipalgu/gollama/ollamastore"
+	"github.com/mipalgu/gollama/styles"
+)
+
+// runVerifyCommand implements `gollama verify [model...]`
+func runVerifyCommand(cfg *config.Config, args []string) int {
+	fs := newFlagSet("verify", "verify [flags] [model...]", cfg)
+	ollamaDir := addOllamaDirFlag(fs, cfg)
+	workers := fs.Int("j", 4, "Number of blobs to hash in parallel")
+	if err := fs.Parse(args); err != nil {
+		return flagExitCode(err)
+	}
+
+	store := ollamastore.New(*ollamaDir)
+	var models []ollamastore.Model
+	if fs.NArg() == 0 {
+		var err error
+		models, err = store.Models()
+		if err != nil {
+			return commandError("Error reading models: %v", err)
+		}
+	} else {
+		for _, name := range fs.Args() {
+			model, err := store.Model(name)
+			if err != nil {
+				return commandError("Error reading the manifest for %s: %v", name, err)
+			}
+			models = append(models, *model)
+		}
+	}
+	if len(models) == 0 {
+		fmt.Println("No models to verify in " + store.Dir)
+		return 0
+	}
+
+	blobs := ollamastore.BlobsToVerify(models)
+	var total int64
+	for _, blob := range blobs {
+		total += blob.Size
+	}
+	logging.InfoLogger.Printf("Verifying %d blobs (%d bytes) for %d models\n", len(blobs), total, len(models))
+
+	progress, stopProgress := startProgress(fmt.Sprintf("Verifying %d blobs", len(blobs)), total)
+	results := store.VerifyBlobs(blobs, *workers, progress)
+	stopProgress()
+
+	problems := make(map[string]int)
+	failed := 0
+	for _, result := range results {
+		if result.Status == ollamastore.BlobOK {
+			continue
+		}
+		failed++
+		for _, name := range result.Models {
+			problems[name]++
+		}
+		message := fmt.Sprintf("%s (%s): %s", result.Digest, strings.Join(result.Models, ", "), result)
+		logging.ErrorLogger.Println("verify: " + message)
+		fmt.Println(styles.ErrorStyle().Render(message))
+	}
+
+	for _, model := range models {
+		if count := problems[model.Name]; count > 0 {
+			fmt.Printf("%s: %s\n", model.Name, styles.ErrorStyle().Render(fmt.Sprintf("%d bad blob(s)", count)))
+		} else {
+			fmt.Printf("%s: %s\n", model.Name, styles.SuccessStyle().Render("ok"))
+		}
+	}
+
+	summary := fmt.Sprintf("Verified %d blobs (%s) in %d models, %d failed", len(blobs), formatSize(total), len(models), failed)
+	if failed > 0 {
+		fmt.Println(styles.ErrorStyle().Render(summary))
+		return 1
+	}
+	fmt.Println(styles.SuccessStyle().Render(summary))
+	return 0
+}
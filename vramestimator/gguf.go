@@ -0,0 +1,65 @@
+package vramestimator
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mipalgu/gollama/gguf"
+)
+
+// IsGGUFFile reports whether the model identifier is the path of a local .gguf file
+func IsGGUFFile(modelID string) bool {
+	if !strings.HasSuffix(strings.ToLower(modelID), ".gguf") {
+		return false
+	}
+	info, err := os.Stat(modelID)
+	return err == nil && !info.IsDir()
+}
+
+// GGUFModelInfo reads the model information the estimator needs from a GGUF file's metadata, in the same shape
+// as FetchOllamaModelInfo returns, so plain .gguf files can be estimated without Ollama or Hugging Face
+func GGUFModelInfo(path string) (*OllamaModelInfo, error) {
+	file, err := gguf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if file.IsProjector() {
+		return nil, fmt.Errorf("%s is a vision projector, not a language model", path)
+	}
+	arch := file.Architecture()
+	if arch == "" {
+		return nil, fmt.Errorf("%s doesn't record its architecture", path)
+	}
+
+	var info OllamaModelInfo
+	paramCount := file.ParameterCount()
+	info.Details.ParameterSize = fmt.Sprintf("%.1fB", float64(paramCount)/1e9)
+	if fileType, ok := file.FileType(); ok {
+		info.Details.QuantizationLevel = fileType.String()
+	}
+	info.Details.Family = arch
+	info.Details.Families = []string{arch}
+
+	// Keys are prefixed with the architecture like the Ollama API's model_info, and numbers are float64 as if
+	// they'd been decoded from JSON
+	info.ModelInfo = map[string]interface{}{
+		"general.architecture":    arch,
+		"general.parameter_count": float64(paramCount),
+	}
+	values := map[string]uint64{
+		"context_length":          file.ContextLength(),
+		"block_count":             file.BlockCount(),
+		"embedding_length":        file.EmbeddingLength(),
+		"attention.head_count":    file.HeadCount(),
+		"attention.head_count_kv": file.HeadCountKV(),
+		"feed_forward_length":     file.FeedForwardLength(),
+		"vocab_size":              file.VocabSize(),
+	}
+	for key, value := range values {
+		if value > 0 {
+			info.ModelInfo[arch+"."+key] = float64(value)
+		}
+	}
+	return &info, nil
+}
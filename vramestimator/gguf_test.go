@@ -0,0 +1,76 @@
+package vramestimator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mipalgu/gollama/internal/ggufwrite"
+)
+
+func writeTestGGUF(t *testing.T, metadata map[string]any) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "model-Q4_K_M.gguf")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := ggufwrite.Write(f, metadata, nil); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGGUFModelInfo(t *testing.T) {
+	path := writeTestGGUF(t, map[string]any{
+		"general.architecture":          "llama",
+		"general.parameter_count":       uint64(8_030_261_248),
+		"general.file_type":             uint32(15),
+		"llama.context_length":          uint32(131072),
+		"llama.block_count":             uint32(32),
+		"llama.embedding_length":        uint32(4096),
+		"llama.feed_forward_length":     uint32(14336),
+		"llama.attention.head_count":    uint32(32),
+		"llama.attention.head_count_kv": uint32(8),
+		"llama.vocab_size":              uint32(128256),
+	})
+
+	base, quant, err := ParseModelIdentifier(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base != path || quant != "Q4_K_M" {
+		t.Errorf("ParseModelIdentifier = %q, %q, want the path and Q4_K_M", base, quant)
+	}
+
+	info, err := GGUFModelInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Details.QuantizationLevel != "Q4_K_M" || info.Details.ParameterSize != "8.0B" {
+		t.Errorf("Details = %+v", info.Details)
+	}
+	if v, _ := extractModelInfo(info.ModelInfo, "attention.head_count_kv"); v != 8 {
+		t.Errorf("head_count_kv = %v, want 8", v)
+	}
+	if v, _ := extractModelInfo(info.ModelInfo, "attention.head_count"); v != 32 {
+		t.Errorf("head_count = %v, want 32", v)
+	}
+
+	// The estimate works offline from the metadata alone
+	vram, err := CalculateVRAM(path, GGUFMapping["Q4_K_M"], 8192, KVCacheFP16, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vram < 4 || vram > 8 {
+		t.Errorf("CalculateVRAM = %.2f GB, expected an 8B Q4_K_M model at 8K context to need 4-8 GB", vram)
+	}
+}
+
+func TestGGUFModelInfoRejectsProjectors(t *testing.T) {
+	path := writeTestGGUF(t, map[string]any{"general.architecture": "clip"})
+	if _, err := GGUFModelInfo(path); err == nil {
+		t.Error("Expected an error for a vision projector")
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"github.com/olekukonko/tablewriter"
+	"github.com/mipalgu/gollama/gguf"
 	"github.com/mipalgu/gollama/logging"
 	"github.com/mipalgu/gollama/styles"
 	"github.com/mipalgu/gollama/utils"
@@ -213,11 +214,21 @@ func extractModelInfo(info map[string]interface{}, key string) (float64, bool) {
 	return 0, false
 }
 
-func FetchOllamaModelInfo(apiURL, modelName string) (*OllamaModelInfo, error) {
+// FetchOllamaModelInfo asks the Ollama API for a model's details with httpClient, http.DefaultClient if nil, so
+// APIs behind an authenticating proxy can be used
+func FetchOllamaModelInfo(httpClient *http.Client, apiURL, modelName string) (*OllamaModelInfo, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
 	url := fmt.Sprintf("%s/api/show", apiURL)
 	payload := []byte(fmt.Sprintf(`{"name": "%s"}`, modelName))
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(payload))
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request to Ollama API: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("error making request to Ollama API: %v", err)
 	}
@@ -242,13 +253,18 @@ func FetchOllamaModelInfo(apiURL, modelName string) (*OllamaModelInfo, error) {
 	return &modelInfo, nil
 }
 
-func EstimateVRAM(modelIdentifier, apiURL string, fitsVRAM float64) error {
+func EstimateVRAM(httpClient *http.Client, modelIdentifier, apiURL string, fitsVRAM float64) error {
 	var ollamaModelInfo *OllamaModelInfo
 	var err error
 
-	// Check if the modelIdentifier is an Ollama model name
-	if strings.Contains(modelIdentifier, ":") {
-		ollamaModelInfo, err = FetchOllamaModelInfo(apiURL, modelIdentifier)
+	// Check if the modelIdentifier is a local GGUF file or an Ollama model name
+	if IsGGUFFile(modelIdentifier) {
+		ollamaModelInfo, err = GGUFModelInfo(modelIdentifier)
+		if err != nil {
+			return fmt.Errorf("error reading GGUF model info: %v", err)
+		}
+	} else if strings.Contains(modelIdentifier, ":") {
+		ollamaModelInfo, err = FetchOllamaModelInfo(httpClient, apiURL, modelIdentifier)
 		if err != nil {
 			return fmt.Errorf("error fetching Ollama model info: %v", err)
 		}
@@ -872,7 +888,7 @@ func PrintFormattedTable(table QuantResultTable) string {
 }
 
 // ParseModelIdentifier parses a model identifier into its base name and quantisation level.
-// Handles both HuggingFace (contains "/") and Ollama (contains ":" or neither) formats.
+// Handles local .gguf files, HuggingFace (contains "/") and Ollama (contains ":" or neither) formats.
 func ParseModelIdentifier(modelID string) (string, string, error) {
 	modelID = strings.TrimSpace(modelID)
 
@@ -881,6 +897,20 @@ func ParseModelIdentifier(modelID string) (string, string, error) {
 		return "", "", fmt.Errorf("empty model identifier provided")
 	}
 
+	// If it's a GGUF file, read the quantisation level from its metadata
+	if IsGGUFFile(modelID) {
+		file, err := gguf.Open(modelID)
+		if err != nil {
+			return "", "", err
+		}
+		fileType, ok := file.FileType()
+		if _, known := GGUFMapping[fileType.String()]; !ok || !known {
+			logging.DebugLogger.Printf("No estimate for the quantisation of %s (%v), showing all levels", modelID, fileType)
+			return modelID, "", nil
+		}
+		return modelID, fileType.String(), nil
+	}
+
 	// If contains "/", treat as HuggingFace model
 	if strings.Contains(modelID, "/") {
 		return modelID, "", nil